@@ -76,9 +76,10 @@ module "secondary_region" {
   node_groups        = var.node_groups
 
   # RDS Configuration - Read replica
-  create_rds      = true
-  rds_config      = var.rds_config
-  rds_primary_arn = module.primary_region.rds_instance_arn
+  create_rds         = true
+  rds_config         = var.rds_config
+  rds_primary_arn    = module.primary_region.rds_instance_arn
+  rds_primary_region = var.primary_region
 
   tags = merge(
     var.tags,
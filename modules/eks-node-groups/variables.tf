@@ -43,6 +43,11 @@ variable "node_groups" {
     capacity_type  = string
     disk_size      = number
   }))
+
+  validation {
+    condition     = alltrue([for ng in values(var.node_groups) : ng.min_size <= ng.desired_size && ng.desired_size <= ng.max_size])
+    error_message = "Each node group must satisfy min_size <= desired_size <= max_size."
+  }
 }
 
 variable "tags" {
@@ -9,7 +9,8 @@ terraform {
 
 data "aws_caller_identity" "current" {}
 
-# IAM Role for Service Accounts (IRSA) - RDS Access
+# IAM Role for Service Accounts (IRSA) - RDS Access, assumable by the <ou>-rds-access service account in the namespace
+# named after the OU
 resource "aws_iam_role" "rds_access" {
   for_each = { for ou in var.organizational_units : ou.ou_id => ou if var.rds_instance_arn != null }
 
@@ -25,7 +26,7 @@ resource "aws_iam_role" "rds_access" {
       }
       Condition = {
         StringEquals = {
-          "${replace(var.oidc_provider_url, "https://", "")}:sub" = "system:serviceaccount:*:*"
+          "${replace(var.oidc_provider_url, "https://", "")}:sub" = "system:serviceaccount:${each.value.name}:${each.value.name}-rds-access"
           "${replace(var.oidc_provider_url, "https://", "")}:aud" = "sts.amazonaws.com"
         }
       }
@@ -112,6 +112,27 @@ resource "aws_kms_alias" "rds" {
   target_key_id = aws_kms_key.rds[0].key_id
 }
 
+# KMS Key for a cross-region Read Replica, which cannot use the source's key from another region
+resource "aws_kms_key" "replica" {
+  count                   = var.storage_encrypted && local.cross_region_replica ? 1 : 0
+  description             = "KMS key for RDS read replica ${var.identifier}"
+  deletion_window_in_days = 7
+  enable_key_rotation     = true
+
+  tags = merge(
+    var.tags,
+    {
+      Name = "${var.identifier}-rds-replica-key"
+    }
+  )
+}
+
+resource "aws_kms_alias" "replica" {
+  count         = var.storage_encrypted && local.cross_region_replica ? 1 : 0
+  name          = "alias/${var.identifier}-rds-replica"
+  target_key_id = aws_kms_key.replica[0].key_id
+}
+
 # DB Parameter Group
 resource "aws_db_parameter_group" "main" {
   name   = "${var.identifier}-params"
@@ -134,6 +155,11 @@ resource "aws_db_parameter_group" "main" {
 
   lifecycle {
     create_before_destroy = true
+
+    precondition {
+      condition     = local.parameter_group_family != null
+      error_message = "A MySQL engine_version must give at least the major and minor version, e.g. 8.0 or 8.0.35, as its parameter group family is named after both."
+    }
   }
 }
 
@@ -203,6 +229,7 @@ resource "aws_db_instance" "replica" {
   instance_class      = var.instance_class
 
   storage_encrypted = var.storage_encrypted
+  kms_key_id        = var.storage_encrypted && local.cross_region_replica ? aws_kms_key.replica[0].arn : null
 
   vpc_security_group_ids = [aws_security_group.rds.id]
   parameter_group_name   = aws_db_parameter_group.main.name
@@ -253,13 +280,22 @@ resource "aws_iam_role_policy_attachment" "rds_monitoring" {
   policy_arn = "arn:aws:iam::aws:policy/service-role/AmazonRDSEnhancedMonitoringRole"
 }
 
-# Locals for engine-specific configurations
+data "aws_region" "current" {}
+
+# A replica whose source is in another region needs a KMS key in its own region to be encrypted
+locals {
+  cross_region_replica = var.replicate_source_db != null && var.replicate_source_region != null && var.replicate_source_region != data.aws_region.current.name
+}
+
+# Locals for engine-specific configurations; the engine validation leaves only postgres and mysql
 locals {
-  port = var.engine == "postgres" ? 5432 : var.engine == "mysql" ? 3306 : 5432
+  port = var.engine == "mysql" ? 3306 : 5432
 
-  parameter_group_family = var.engine == "postgres" ? "postgres${split(".", var.engine_version)[0]}" : var.engine == "mysql" ? "mysql${split(".", var.engine_version)[0]}" : "postgres15"
+  # PostgreSQL families are named after the major version (postgres15), MySQL ones after major.minor (mysql8.0); a
+  # MySQL version without a minor version has no family, which the parameter group's precondition reports
+  parameter_group_family = var.engine == "mysql" ? try("mysql${join(".", slice(split(".", var.engine_version), 0, 2))}", null) : "postgres${split(".", var.engine_version)[0]}"
 
-  cloudwatch_logs_exports = var.engine == "postgres" ? ["postgresql", "upgrade"] : var.engine == "mysql" ? ["error", "general", "slowquery"] : ["postgresql"]
+  cloudwatch_logs_exports = var.engine == "mysql" ? ["error", "general", "slowquery"] : ["postgresql", "upgrade"]
 
   db_parameters = var.engine == "postgres" ? [
     {
@@ -24,9 +24,14 @@ variable "availability_zones" {
 }
 
 variable "engine" {
-  description = "Database engine (postgres, mysql, etc.)"
+  description = "Database engine (postgres or mysql)"
   type        = string
   default     = "postgres"
+
+  validation {
+    condition     = contains(["postgres", "mysql"], var.engine)
+    error_message = "The engine must be postgres or mysql, the engines the module derives a port, parameter group family and log exports for."
+  }
 }
 
 variable "engine_version" {
@@ -78,6 +83,12 @@ variable "replicate_source_db" {
   default     = null
 }
 
+variable "replicate_source_region" {
+  description = "Region of the replicate_source_db instance when it is not the provider region. An encrypted cross-region replica gets a KMS key of its own in the replica region"
+  type        = string
+  default     = null
+}
+
 variable "allowed_security_group_ids" {
   description = "List of security group IDs allowed to access RDS"
   type        = list(string)
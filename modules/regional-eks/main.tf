@@ -91,7 +91,8 @@ module "rds" {
   storage_encrypted       = var.rds_config.storage_encrypted
 
   # For read replicas in secondary region
-  replicate_source_db = var.rds_primary_arn
+  replicate_source_db     = var.rds_primary_arn
+  replicate_source_region = var.rds_primary_region
 
   # Allow access from EKS cluster
   allowed_security_group_ids = [
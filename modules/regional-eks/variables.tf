@@ -96,6 +96,12 @@ variable "rds_primary_arn" {
   default     = null
 }
 
+variable "rds_primary_region" {
+  description = "Region of the primary RDS instance, when it is not this region (for cross-region read replicas)"
+  type        = string
+  default     = null
+}
+
 variable "tags" {
   description = "Tags to apply to resources"
   type        = map(string)
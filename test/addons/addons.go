@@ -0,0 +1,255 @@
+// Package addons checks the EKS addon versions a cluster is given against a local, versioned compatibility matrix of
+// the addon builds EKS supports on each Kubernetes minor version, so a mismatch such as a kube-proxy build for 1.27 on
+// a 1.28 cluster fails in a test instead of in the EKS API. The matrix is matrix.json; update it from
+// `aws eks describe-addon-versions` and bump its version in the same change.
+package addons
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+//go:embed matrix.json
+var embeddedMatrix []byte
+
+// Addons are the addons of modules/eks-cluster, named as in its <addon>_version variables and aws_eks_addon resources.
+var Addons = []string{"vpc_cni", "coredns", "kube_proxy", "ebs_csi_driver"}
+
+// AddonNames maps each addon to the name EKS knows it by, the addon_name of its aws_eks_addon.
+var AddonNames = map[string]string{
+	"vpc_cni":        "vpc-cni",
+	"coredns":        "coredns",
+	"kube_proxy":     "kube-proxy",
+	"ebs_csi_driver": "aws-ebs-csi-driver",
+}
+
+var (
+	minorVersionPattern = regexp.MustCompile(`^1\.\d+$`)
+	buildPattern        = regexp.MustCompile(`^v(\d+)\.(\d+)\.\d+-eksbuild\.\d+$`)
+)
+
+// Matrix lists the addon builds EKS supports on each Kubernetes minor version. It is versioned by the date the builds
+// were taken from `aws eks describe-addon-versions`.
+type Matrix struct {
+	Version string `json:"version"`
+
+	// KubernetesVersions maps a minor version, e.g. 1.28, to the supported builds of each addon, oldest first.
+	KubernetesVersions map[string]map[string][]string `json:"kubernetes_versions"`
+}
+
+// Default returns the matrix in matrix.json.
+func Default() (*Matrix, error) {
+	return Parse(embeddedMatrix)
+}
+
+// LoadFile reads a matrix from a JSON file, e.g. to check against newer builds.
+func LoadFile(path string) (*Matrix, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	matrix, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return matrix, nil
+}
+
+// Parse decodes and validates a matrix, rejecting unknown fields.
+func Parse(data []byte) (*Matrix, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var matrix Matrix
+	if err := decoder.Decode(&matrix); err != nil {
+		return nil, err
+	}
+	if err := matrix.Validate(); err != nil {
+		return nil, err
+	}
+	return &matrix, nil
+}
+
+// Validate checks that the matrix is versioned, lists builds of every addon for every Kubernetes version, and that
+// each kube-proxy build is for the Kubernetes version it is listed under, as kube-proxy must match the cluster minor.
+func (m *Matrix) Validate() error {
+	var errs []error
+	if m.Version == "" {
+		errs = append(errs, errors.New("version is required"))
+	}
+	if len(m.KubernetesVersions) == 0 {
+		errs = append(errs, errors.New("kubernetes_versions is required"))
+	}
+	for _, version := range m.Versions() {
+		if !minorVersionPattern.MatchString(version) {
+			errs = append(errs, fmt.Errorf("kubernetes version %q is not a minor version such as 1.28", version))
+		}
+		builds := m.KubernetesVersions[version]
+		for _, addon := range Addons {
+			if len(builds[addon]) == 0 {
+				errs = append(errs, fmt.Errorf("%s: %s has no builds", version, addon))
+			}
+		}
+		for addon, list := range builds {
+			if _, ok := AddonNames[addon]; !ok {
+				errs = append(errs, fmt.Errorf("%s: unknown addon %q", version, addon))
+			}
+			seen := map[string]bool{}
+			for _, build := range list {
+				match := buildPattern.FindStringSubmatch(build)
+				switch {
+				case match == nil:
+					errs = append(errs, fmt.Errorf("%s: %s build %q is not of the form v1.2.3-eksbuild.4", version, addon, build))
+				case addon == "kube_proxy" && match[1]+"."+match[2] != version:
+					errs = append(errs, fmt.Errorf("%s: kube_proxy build %s is for Kubernetes %s.%s", version, build, match[1], match[2]))
+				}
+				if seen[build] {
+					errs = append(errs, fmt.Errorf("%s: %s build %s is listed twice", version, addon, build))
+				}
+				seen[build] = true
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Versions returns the Kubernetes minor versions of the matrix, oldest first.
+func (m *Matrix) Versions() []string {
+	versions := make([]string, 0, len(m.KubernetesVersions))
+	for version := range m.KubernetesVersions {
+		versions = append(versions, version)
+	}
+	sort.Slice(versions, func(i, j int) bool { return minor(versions[i]) < minor(versions[j]) })
+	return versions
+}
+
+// minor returns the minor number of a Kubernetes version such as 1.28, or -1.
+func minor(version string) int {
+	_, number, _ := strings.Cut(version, ".")
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// Latest returns the newest supported build of the addon on the Kubernetes version.
+func (m *Matrix) Latest(kubernetesVersion, addon string) (string, bool) {
+	builds := m.KubernetesVersions[kubernetesVersion][addon]
+	if len(builds) == 0 {
+		return "", false
+	}
+	return builds[len(builds)-1], true
+}
+
+// Supports reports whether EKS supports the addon build on the Kubernetes version.
+func (m *Matrix) Supports(kubernetesVersion, addon, build string) bool {
+	for _, supported := range m.KubernetesVersions[kubernetesVersion][addon] {
+		if supported == build {
+			return true
+		}
+	}
+	return false
+}
+
+// Finding is an addon version that EKS does not support on the cluster's Kubernetes version.
+type Finding struct {
+	// Address is the variable, e.g. kube_proxy_version, or the planned aws_eks_addon the finding is about.
+	Address string
+	Message string
+}
+
+// String renders the finding as "<address>: <message>".
+func (f Finding) String() string {
+	return f.Address + ": " + f.Message
+}
+
+// Check checks the addon versions given to a cluster of the Kubernetes version, keyed by addon. An empty version is
+// left to EKS, which installs its default build.
+func (m *Matrix) Check(kubernetesVersion string, versions map[string]string) []Finding {
+	var findings []Finding
+	for _, addon := range Addons {
+		if build := versions[addon]; build != "" {
+			findings = append(findings, m.check(addon+"_version", kubernetesVersion, addon, build)...)
+		}
+	}
+	return m.coverage("kubernetes_version", kubernetesVersion, findings)
+}
+
+// CheckPlan checks the addon_version of every aws_eks_addon planned by the eks-cluster module at the address prefix,
+// e.g. "module.primary_region.module.eks." or empty when the module is planned directly, against the version of its
+// cluster. Addons without a version are left to EKS.
+func (m *Matrix) CheckPlan(plan *planassert.Plan, module string) []Finding {
+	address := module + "aws_eks_cluster.main"
+	cluster, ok := plan.Resource(address)
+	if !ok || cluster.Destroyed() {
+		return []Finding{{Address: address, Message: "not planned"}}
+	}
+	version, _ := cluster.Attribute("version")
+	kubernetesVersion, ok := version.(string)
+	if !ok {
+		return []Finding{{Address: address, Message: fmt.Sprintf("version is %v, not a known string", version)}}
+	}
+
+	var findings []Finding
+	for _, resource := range plan.Match(module + "aws_eks_addon.*") {
+		name, _ := resource.Attribute("addon_name")
+		build, _ := resource.Attribute("addon_version")
+		addon, known := addonOf(fmt.Sprint(name))
+		if known && build != nil {
+			findings = append(findings, m.check(resource.Address, kubernetesVersion, addon, fmt.Sprint(build))...)
+		}
+	}
+	return m.coverage(address, kubernetesVersion, findings)
+}
+
+// coverage replaces the findings with one about the Kubernetes version when the matrix does not cover it, as then no
+// build can be checked.
+func (m *Matrix) coverage(address, kubernetesVersion string, findings []Finding) []Finding {
+	if _, ok := m.KubernetesVersions[kubernetesVersion]; ok || len(findings) == 0 {
+		return findings
+	}
+	versions := m.Versions()
+	return []Finding{{Address: address, Message: fmt.Sprintf("Kubernetes %s is not in the compatibility matrix %s, which covers %s to %s",
+		kubernetesVersion, m.Version, versions[0], versions[len(versions)-1])}}
+}
+
+// check returns a finding when the build of the addon is not supported on the Kubernetes version.
+func (m *Matrix) check(address, kubernetesVersion, addon, build string) []Finding {
+	if m.Supports(kubernetesVersion, addon, build) {
+		return nil
+	}
+	var elsewhere []string
+	for _, version := range m.Versions() {
+		if m.Supports(version, addon, build) {
+			elsewhere = append(elsewhere, version)
+		}
+	}
+	supported := strings.Join(m.KubernetesVersions[kubernetesVersion][addon], ", ")
+	message := fmt.Sprintf("%s %s is not a known build", AddonNames[addon], build)
+	if len(elsewhere) > 0 {
+		message = fmt.Sprintf("%s %s is supported on Kubernetes %s, not %s", AddonNames[addon], build, strings.Join(elsewhere, ", "), kubernetesVersion)
+	}
+	if supported != "" {
+		message += fmt.Sprintf("; Kubernetes %s supports %s", kubernetesVersion, supported)
+	}
+	return []Finding{{Address: address, Message: message}}
+}
+
+func addonOf(name string) (string, bool) {
+	for addon, addonName := range AddonNames {
+		if addonName == name {
+			return addon, true
+		}
+	}
+	return "", false
+}
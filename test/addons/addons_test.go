@@ -0,0 +1,246 @@
+package addons
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// recordingT collects failures without failing the enclosing test.
+type recordingT struct {
+	failed bool
+}
+
+func (r *recordingT) Fail()                                  { r.failed = true }
+func (r *recordingT) FailNow()                               { r.failed = true }
+func (r *recordingT) Fatal(args ...interface{})              { r.failed = true }
+func (r *recordingT) Fatalf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Error(args ...interface{})              { r.failed = true }
+func (r *recordingT) Errorf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Name() string                           { return "recordingT" }
+
+func defaultMatrix(t *testing.T) *Matrix {
+	t.Helper()
+
+	matrix, err := Default()
+	require.NoError(t, err)
+	return matrix
+}
+
+func TestDefaultMatrix(t *testing.T) {
+	t.Parallel()
+
+	matrix := defaultMatrix(t)
+	assert.NotEmpty(t, matrix.Version)
+	assert.Equal(t, []string{"1.27", "1.28", "1.29", "1.30", "1.31"}, matrix.Versions())
+}
+
+// TestSupportedVersions checks every Kubernetes version the modules are used with: the newest build of each addon
+// passes, and the kube-proxy build of the previous minor version is rejected.
+func TestSupportedVersions(t *testing.T) {
+	t.Parallel()
+
+	matrix := defaultMatrix(t)
+	for minor := 27; minor <= 31; minor++ {
+		version := fmt.Sprintf("1.%d", minor)
+		t.Run(version, func(t *testing.T) {
+			latest := map[string]string{}
+			for _, addon := range Addons {
+				build, ok := matrix.Latest(version, addon)
+				require.True(t, ok, addon)
+				latest[addon] = build
+			}
+			assert.Empty(t, matrix.Check(version, latest))
+
+			previous := fmt.Sprintf("1.%d", minor-1)
+			for _, build := range matrix.KubernetesVersions[previous]["kube_proxy"] {
+				findings := matrix.Check(version, map[string]string{"kube_proxy": build})
+				require.Len(t, findings, 1, build)
+				assert.Equal(t, "kube_proxy_version", findings[0].Address)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	matrix := defaultMatrix(t)
+	for _, tc := range []struct {
+		name     string
+		version  string
+		versions map[string]string
+		expected []string
+	}{
+		{
+			name:    "supported builds",
+			version: "1.28",
+			versions: map[string]string{
+				"vpc_cni":        "v1.15.0-eksbuild.1",
+				"coredns":        "v1.10.1-eksbuild.2",
+				"kube_proxy":     "v1.28.1-eksbuild.1",
+				"ebs_csi_driver": "v1.25.0-eksbuild.1",
+			},
+		},
+		{
+			name:     "EKS defaults",
+			version:  "1.31",
+			versions: map[string]string{},
+		},
+		{
+			name:     "kube-proxy of the previous minor version",
+			version:  "1.28",
+			versions: map[string]string{"kube_proxy": "v1.27.16-eksbuild.3"},
+			expected: []string{"kube_proxy_version: kube-proxy v1.27.16-eksbuild.3 is supported on Kubernetes 1.27, not 1.28; " +
+				"Kubernetes 1.28 supports v1.28.1-eksbuild.1, v1.28.2-eksbuild.2, v1.28.12-eksbuild.5"},
+		},
+		{
+			name:     "coredns too new",
+			version:  "1.28",
+			versions: map[string]string{"coredns": "v1.11.3-eksbuild.1", "vpc_cni": "v1.19.0-eksbuild.1"},
+			expected: []string{"coredns_version: coredns v1.11.3-eksbuild.1 is supported on Kubernetes 1.29, 1.30, 1.31, not 1.28; " +
+				"Kubernetes 1.28 supports v1.10.1-eksbuild.2, v1.10.1-eksbuild.13"},
+		},
+		{
+			name:     "unknown build",
+			version:  "1.31",
+			versions: map[string]string{"ebs_csi_driver": "v1.99.0-eksbuild.1"},
+			expected: []string{"ebs_csi_driver_version: aws-ebs-csi-driver v1.99.0-eksbuild.1 is not a known build; " +
+				"Kubernetes 1.31 supports v1.35.0-eksbuild.1"},
+		},
+		{
+			name:     "Kubernetes version outside the matrix",
+			version:  "1.26",
+			versions: map[string]string{"kube_proxy": "v1.26.2-eksbuild.1", "coredns": "v1.9.3-eksbuild.3"},
+			expected: []string{"kubernetes_version: Kubernetes 1.26 is not in the compatibility matrix " + matrix.Version +
+				", which covers 1.27 to 1.31"},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var rendered []string
+			for _, finding := range matrix.Check(tc.version, tc.versions) {
+				rendered = append(rendered, finding.String())
+			}
+			assert.Equal(t, tc.expected, rendered)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+
+	matrix := &Matrix{
+		Version: "2024-11-15",
+		KubernetesVersions: map[string]map[string][]string{
+			"1.28": {
+				"vpc_cni":        {"v1.15.0-eksbuild.1", "v1.15.0-eksbuild.1"},
+				"coredns":        {"1.10.1"},
+				"kube_proxy":     {"v1.27.16-eksbuild.3"},
+				"istio":          {"v1.20.0-eksbuild.1"},
+				"ebs_csi_driver": {},
+			},
+			"1.29.1": {},
+		},
+	}
+	err := matrix.Validate()
+	require.Error(t, err)
+	for _, message := range []string{
+		`kubernetes version "1.29.1" is not a minor version such as 1.28`,
+		"1.29.1: vpc_cni has no builds",
+		"1.28: ebs_csi_driver has no builds",
+		"1.28: vpc_cni build v1.15.0-eksbuild.1 is listed twice",
+		`1.28: coredns build "1.10.1" is not of the form v1.2.3-eksbuild.4`,
+		"1.28: kube_proxy build v1.27.16-eksbuild.3 is for Kubernetes 1.27",
+		`1.28: unknown addon "istio"`,
+	} {
+		assert.Contains(t, err.Error(), message)
+	}
+
+	assert.EqualError(t, (&Matrix{}).Validate(), "version is required\nkubernetes_versions is required")
+}
+
+func TestLoadFile(t *testing.T) {
+	t.Parallel()
+
+	matrix := defaultMatrix(t)
+	matrix.Version = "2025-01-10"
+	matrix.KubernetesVersions["1.32"] = map[string][]string{
+		"vpc_cni":        {"v1.19.2-eksbuild.1"},
+		"coredns":        {"v1.11.4-eksbuild.2"},
+		"kube_proxy":     {"v1.32.0-eksbuild.2"},
+		"ebs_csi_driver": {"v1.38.1-eksbuild.1"},
+	}
+	data, err := json.Marshal(matrix)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "matrix.json")
+	require.NoError(t, os.WriteFile(path, data, 0o644))
+
+	loaded, err := LoadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-01-10", loaded.Version)
+	assert.Equal(t, "1.32", loaded.Versions()[len(loaded.Versions())-1])
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": "2025-01-10", "kubernetes_version": {}}`), 0o644))
+	_, err = LoadFile(path)
+	assert.ErrorContains(t, err, `unknown field "kubernetes_version"`)
+}
+
+// samplePlan renders an eks-cluster plan of a cluster of the Kubernetes version with the addon builds, keyed by
+// addon; a nil build is left to EKS.
+func samplePlan(t *testing.T, version string, builds map[string]interface{}) *planassert.Plan {
+	t.Helper()
+
+	resources := []map[string]interface{}{
+		{"address": "aws_eks_cluster.main", "mode": "managed", "type": "aws_eks_cluster", "name": "main", "values": map[string]interface{}{"version": version}},
+	}
+	for addon, build := range builds {
+		resources = append(resources, map[string]interface{}{
+			"address": "aws_eks_addon." + addon, "mode": "managed", "type": "aws_eks_addon", "name": addon,
+			"values": map[string]interface{}{"addon_name": AddonNames[addon], "addon_version": build},
+		})
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"format_version":   "1.2",
+		"planned_values":   map[string]interface{}{"root_module": map[string]interface{}{"resources": resources}},
+		"resource_changes": []interface{}{},
+	})
+	require.NoError(t, err)
+	plan, err := planassert.ParseJSON(data)
+	require.NoError(t, err)
+	return plan
+}
+
+func TestCheckPlan(t *testing.T) {
+	t.Parallel()
+
+	matrix := defaultMatrix(t)
+	plan := samplePlan(t, "1.28", map[string]interface{}{
+		"vpc_cni":        "v1.15.0-eksbuild.1",
+		"coredns":        nil,
+		"kube_proxy":     "v1.27.16-eksbuild.3",
+		"ebs_csi_driver": "v1.25.0-eksbuild.1",
+	})
+	var rendered []string
+	for _, finding := range matrix.CheckPlan(plan, "") {
+		rendered = append(rendered, finding.String())
+	}
+	assert.Equal(t, []string{"aws_eks_addon.kube_proxy: kube-proxy v1.27.16-eksbuild.3 is supported on Kubernetes 1.27, not 1.28; " +
+		"Kubernetes 1.28 supports v1.28.1-eksbuild.1, v1.28.2-eksbuild.2, v1.28.12-eksbuild.5"}, rendered)
+
+	assert.Equal(t, []Finding{{Address: "module.eks.aws_eks_cluster.main", Message: "not planned"}}, matrix.CheckPlan(plan, "module.eks."))
+
+	recorder := &recordingT{}
+	assert.False(t, AssertCompatible(recorder, matrix, plan, ""))
+	assert.True(t, recorder.failed)
+	assert.True(t, AssertCompatible(t, matrix, samplePlan(t, "1.31", map[string]interface{}{"kube_proxy": "v1.31.2-eksbuild.3"}), ""))
+}
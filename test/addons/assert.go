@@ -0,0 +1,14 @@
+package addons
+
+import (
+	"github.com/gruntwork-io/terratest/modules/testing"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// AssertCompatible checks that every addon version the eks-cluster module at the address prefix plans is a build EKS
+// supports on the cluster's Kubernetes version, according to the matrix.
+func AssertCompatible(t testing.TestingT, matrix *Matrix, plan *planassert.Plan, module string) bool {
+	return planassert.AssertNoFindings(t, matrix.CheckPlan(plan, module),
+		"addon versions are not supported by their cluster (matrix %s)", matrix.Version)
+}
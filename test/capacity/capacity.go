@@ -0,0 +1,341 @@
+// Package capacity simulates how EKS managed node groups spread their instances across the availability zones of
+// their subnets, to check the capacity each zone provides, what survives the loss of a zone, and whether a rolling
+// update can take out every node a group has in one zone.
+//
+// A managed node group is an Auto Scaling group over all of its subnets, and the Auto Scaling group keeps the number
+// of instances in each zone within one of the others. Simulate models that: desired_size instances are dealt out over
+// the zones in order, so a zone never has more than one instance more than another.
+package capacity
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
+)
+
+// ModuleMaxUnavailablePercentage is the update_config.max_unavailable_percentage the eks-node-groups module sets on
+// every node group.
+const ModuleMaxUnavailablePercentage = 33
+
+// Zones is the number of availability zones the modules spread node groups across.
+const Zones = 3
+
+// Spec is the size of an instance type.
+type Spec struct {
+	VCPU      int
+	MemoryGiB float64
+}
+
+// InstanceSpecs are the sizes of the instance types the modules and tests use.
+var InstanceSpecs = map[string]Spec{
+	"t2.large":   {VCPU: 2, MemoryGiB: 8},
+	"t3.small":   {VCPU: 2, MemoryGiB: 2},
+	"t3.medium":  {VCPU: 2, MemoryGiB: 4},
+	"t3.large":   {VCPU: 2, MemoryGiB: 8},
+	"t3.xlarge":  {VCPU: 4, MemoryGiB: 16},
+	"t3a.large":  {VCPU: 2, MemoryGiB: 8},
+	"m5.large":   {VCPU: 2, MemoryGiB: 8},
+	"m5.xlarge":  {VCPU: 4, MemoryGiB: 16},
+	"m5.2xlarge": {VCPU: 8, MemoryGiB: 32},
+	"m5a.xlarge": {VCPU: 4, MemoryGiB: 16},
+	"m5n.xlarge": {VCPU: 4, MemoryGiB: 16},
+	"c5.2xlarge": {VCPU: 8, MemoryGiB: 16},
+}
+
+// Subnet is a subnet a node group launches instances into.
+type Subnet struct {
+	ID               string
+	AvailabilityZone string
+}
+
+// Config is the input of Simulate.
+type Config struct {
+	NodeGroups tfvars.NodeGroups
+	Subnets    []Subnet
+
+	// MaxUnavailablePercentage is the update_config of every node group. Zero means ModuleMaxUnavailablePercentage.
+	MaxUnavailablePercentage int
+}
+
+// Capacity is an amount of compute. OnDemand counts only the on-demand part, which unlike spot capacity is not
+// reclaimed by AWS.
+type Capacity struct {
+	Nodes             int     `json:"nodes"`
+	VCPU              int     `json:"vcpu"`
+	MemoryGiB         float64 `json:"memory_gib"`
+	OnDemandVCPU      int     `json:"on_demand_vcpu"`
+	OnDemandMemoryGiB float64 `json:"on_demand_memory_gib"`
+}
+
+func (c *Capacity) add(nodes int, spec Spec, onDemand bool) {
+	c.Nodes += nodes
+	c.VCPU += nodes * spec.VCPU
+	c.MemoryGiB += float64(nodes) * spec.MemoryGiB
+	if onDemand {
+		c.OnDemandVCPU += nodes * spec.VCPU
+		c.OnDemandMemoryGiB += float64(nodes) * spec.MemoryGiB
+	}
+}
+
+// Placement is where the instances of one node group land.
+type Placement struct {
+	Group string `json:"group"`
+
+	// InstanceType is the type the simulation assumes for every instance: the first type of an on-demand group, which
+	// EKS launches by priority, and the smallest type of a spot group, which can come up as any of them.
+	InstanceType string `json:"instance_type"`
+
+	OnDemand bool `json:"on_demand"`
+
+	// Nodes is the number of instances in each zone at desired_size.
+	Nodes map[string]int `json:"nodes"`
+
+	// MaxUnavailable is the number of nodes a rolling update takes out of service at once.
+	MaxUnavailable int `json:"max_unavailable"`
+}
+
+// Finding is a problem with the spread of the node groups.
+type Finding struct {
+	Check   string `json:"check"`
+	Group   string `json:"group,omitempty"`
+	Zone    string `json:"zone,omitempty"`
+	Message string `json:"message"`
+}
+
+// Checks that produce findings.
+const (
+	CheckZones             = "zones"
+	CheckMinSizeSpread     = "min-size-spread"
+	CheckRolloutDrainsZone = "rollout-drains-zone"
+	CheckZoneLoss          = "zone-loss"
+)
+
+// String renders the finding on one line, e.g. "min-size-spread: general: min_size 4 ...".
+func (f Finding) String() string {
+	parts := []string{f.Check}
+	if f.Group != "" {
+		parts = append(parts, f.Group)
+	}
+	if f.Zone != "" {
+		parts = append(parts, f.Zone)
+	}
+	return strings.Join(parts, ": ") + ": " + f.Message
+}
+
+// Report is the outcome of a simulation.
+type Report struct {
+	// Zones are the availability zones of the subnets, sorted.
+	Zones []string `json:"zones"`
+
+	Placements []Placement         `json:"placements"`
+	PerZone    map[string]Capacity `json:"per_zone"`
+	Total      Capacity            `json:"total"`
+
+	// Findings are the problems with the configuration itself; CheckRequirement adds the zone-loss findings.
+	Findings []Finding `json:"findings"`
+}
+
+// Simulate places desired_size instances of every node group across the zones of the subnets and checks the spread.
+// It fails when a node group has an instance type without a Spec or there are no subnets.
+func Simulate(config Config) (*Report, error) {
+	zones := zonesOf(config.Subnets)
+	if len(zones) == 0 {
+		return nil, errors.New("no subnets to place node groups in")
+	}
+	percentage := config.MaxUnavailablePercentage
+	if percentage == 0 {
+		percentage = ModuleMaxUnavailablePercentage
+	}
+
+	report := &Report{Zones: zones, PerZone: map[string]Capacity{}}
+	for _, zone := range zones {
+		report.PerZone[zone] = Capacity{}
+	}
+	if len(zones) != Zones {
+		report.Findings = append(report.Findings, Finding{
+			Check:   CheckZones,
+			Message: fmt.Sprintf("subnets span %d availability zones (%s), want %d", len(zones), strings.Join(zones, ", "), Zones),
+		})
+	}
+
+	for _, name := range sortedNames(config.NodeGroups) {
+		group := config.NodeGroups[name]
+		instanceType, spec, err := assumedInstanceType(group)
+		if err != nil {
+			return nil, fmt.Errorf("node group %s: %w", name, err)
+		}
+		placement := Placement{
+			Group:          name,
+			InstanceType:   instanceType,
+			OnDemand:       group.CapacityType != "SPOT",
+			Nodes:          spread(group.DesiredSize, zones),
+			MaxUnavailable: maxUnavailable(group.DesiredSize, percentage),
+		}
+		report.Placements = append(report.Placements, placement)
+		for zone, nodes := range placement.Nodes {
+			capacity := report.PerZone[zone]
+			capacity.add(nodes, spec, placement.OnDemand)
+			report.PerZone[zone] = capacity
+		}
+		report.Total.add(group.DesiredSize, spec, placement.OnDemand)
+
+		if group.MinSize%len(zones) != 0 {
+			report.Findings = append(report.Findings, Finding{
+				Check: CheckMinSizeSpread,
+				Group: name,
+				Message: fmt.Sprintf("min_size %d does not divide evenly across %d availability zones, so scaling in leaves zones unequal",
+					group.MinSize, len(zones)),
+			})
+		}
+		if zone, nodes, drains := drainsZone(placement); drains {
+			report.Findings = append(report.Findings, Finding{
+				Check: CheckRolloutDrainsZone,
+				Group: name,
+				Zone:  zone,
+				Message: fmt.Sprintf("max_unavailable_percentage %d takes %d of %d nodes out at once, enough for every node in %s (%d)",
+					percentage, placement.MaxUnavailable, group.DesiredSize, zone, nodes),
+			})
+		}
+	}
+	return report, nil
+}
+
+// Requirement is the capacity a workload needs to keep running.
+type Requirement struct {
+	VCPU      int
+	MemoryGiB float64
+
+	// CountSpot counts spot instances towards the requirement. By default only on-demand capacity counts, since spot
+	// capacity can be reclaimed at the moment a zone fails.
+	CountSpot bool
+}
+
+// LoseZone returns the capacity left in the other zones the moment a zone fails, before the Auto Scaling groups
+// launch replacements there.
+func (r *Report) LoseZone(zone string) Capacity {
+	var surviving Capacity
+	for _, other := range r.Zones {
+		if other == zone {
+			continue
+		}
+		capacity := r.PerZone[other]
+		surviving.Nodes += capacity.Nodes
+		surviving.VCPU += capacity.VCPU
+		surviving.MemoryGiB += capacity.MemoryGiB
+		surviving.OnDemandVCPU += capacity.OnDemandVCPU
+		surviving.OnDemandMemoryGiB += capacity.OnDemandMemoryGiB
+	}
+	return surviving
+}
+
+// CheckRequirement simulates the loss of each zone in turn and returns a finding for every zone whose loss leaves
+// less capacity than the requirement.
+func (r *Report) CheckRequirement(requirement Requirement) []Finding {
+	var findings []Finding
+	for _, zone := range r.Zones {
+		surviving := r.LoseZone(zone)
+		vcpu, memory, kind := surviving.OnDemandVCPU, surviving.OnDemandMemoryGiB, "on-demand "
+		if requirement.CountSpot {
+			vcpu, memory, kind = surviving.VCPU, surviving.MemoryGiB, ""
+		}
+		if vcpu < requirement.VCPU || memory < requirement.MemoryGiB {
+			findings = append(findings, Finding{
+				Check: CheckZoneLoss,
+				Zone:  zone,
+				Message: fmt.Sprintf("losing %s leaves %d vCPU and %g GiB of %scapacity, want %d vCPU and %g GiB",
+					zone, vcpu, memory, kind, requirement.VCPU, requirement.MemoryGiB),
+			})
+		}
+	}
+	return findings
+}
+
+// assumedInstanceType returns the type Placement.InstanceType documents.
+func assumedInstanceType(group tfvars.NodeGroup) (string, Spec, error) {
+	if len(group.InstanceTypes) == 0 {
+		return "", Spec{}, errors.New("no instance types")
+	}
+	var chosen string
+	var chosenSpec Spec
+	for i, instanceType := range group.InstanceTypes {
+		spec, ok := InstanceSpecs[instanceType]
+		if !ok {
+			return "", Spec{}, fmt.Errorf("no spec for instance type %s", instanceType)
+		}
+		first := i == 0
+		smaller := spec.MemoryGiB < chosenSpec.MemoryGiB || (spec.MemoryGiB == chosenSpec.MemoryGiB && spec.VCPU < chosenSpec.VCPU)
+		if first || (group.CapacityType == "SPOT" && smaller) {
+			chosen, chosenSpec = instanceType, spec
+		}
+	}
+	return chosen, chosenSpec, nil
+}
+
+// spread deals n instances over the zones in order.
+func spread(n int, zones []string) map[string]int {
+	nodes := make(map[string]int, len(zones))
+	for i, zone := range zones {
+		nodes[zone] = n / len(zones)
+		if i < n%len(zones) {
+			nodes[zone]++
+		}
+	}
+	return nodes
+}
+
+// maxUnavailable mirrors how EKS turns max_unavailable_percentage into a number of nodes: the percentage of the
+// desired size rounded down, but at least one node.
+func maxUnavailable(desired, percentage int) int {
+	n := desired * percentage / 100
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// drainsZone reports the zone with the fewest nodes if a rolling update can take all of them out at once. A rolling
+// update picks nodes regardless of their zone, so that is possible whenever MaxUnavailable is at least that many.
+func drainsZone(placement Placement) (string, int, bool) {
+	zone, fewest := "", 0
+	for _, candidate := range sortedZones(placement.Nodes) {
+		nodes := placement.Nodes[candidate]
+		if nodes > 0 && (zone == "" || nodes < fewest) {
+			zone, fewest = candidate, nodes
+		}
+	}
+	return zone, fewest, zone != "" && placement.MaxUnavailable >= fewest
+}
+
+func zonesOf(subnets []Subnet) []string {
+	set := map[string]bool{}
+	for _, subnet := range subnets {
+		set[subnet.AvailabilityZone] = true
+	}
+	zones := make([]string, 0, len(set))
+	for zone := range set {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	return zones
+}
+
+func sortedZones(nodes map[string]int) []string {
+	zones := make([]string, 0, len(nodes))
+	for zone := range nodes {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	return zones
+}
+
+func sortedNames(groups tfvars.NodeGroups) []string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
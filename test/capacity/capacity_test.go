@@ -0,0 +1,180 @@
+package capacity
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
+)
+
+var testSubnets = []Subnet{
+	{ID: "subnet-private-a", AvailabilityZone: "us-east-1a"},
+	{ID: "subnet-private-b", AvailabilityZone: "us-east-1b"},
+	{ID: "subnet-private-c", AvailabilityZone: "us-east-1c"},
+}
+
+func findingKeys(findings []Finding) []string {
+	keys := make([]string, len(findings))
+	for i, finding := range findings {
+		keys[i] = finding.Check + " " + finding.Group + " " + finding.Zone
+	}
+	return keys
+}
+
+func TestSimulate(t *testing.T) {
+	t.Parallel()
+
+	report, err := Simulate(Config{
+		NodeGroups: tfvars.NodeGroups{
+			"general": tfvars.BaselineNodeGroup().WithSizes(4, 7, 15).WithInstanceTypes("m5.xlarge", "t3.large"),
+			"spot":    tfvars.BaselineNodeGroup().WithCapacityType("SPOT").WithSizes(0, 3, 12).WithInstanceTypes("t3.xlarge", "t3.large"),
+		},
+		Subnets: testSubnets,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"us-east-1a", "us-east-1b", "us-east-1c"}, report.Zones)
+	assert.Equal(t, []Placement{
+		{
+			Group: "general", InstanceType: "m5.xlarge", OnDemand: true, MaxUnavailable: 2,
+			Nodes: map[string]int{"us-east-1a": 3, "us-east-1b": 2, "us-east-1c": 2},
+		},
+		{
+			Group: "spot", InstanceType: "t3.large", OnDemand: false, MaxUnavailable: 1,
+			Nodes: map[string]int{"us-east-1a": 1, "us-east-1b": 1, "us-east-1c": 1},
+		},
+	}, report.Placements)
+
+	assert.Equal(t, Capacity{Nodes: 4, VCPU: 14, MemoryGiB: 56, OnDemandVCPU: 12, OnDemandMemoryGiB: 48}, report.PerZone["us-east-1a"])
+	assert.Equal(t, Capacity{Nodes: 3, VCPU: 10, MemoryGiB: 40, OnDemandVCPU: 8, OnDemandMemoryGiB: 32}, report.PerZone["us-east-1c"])
+	assert.Equal(t, Capacity{Nodes: 10, VCPU: 34, MemoryGiB: 136, OnDemandVCPU: 28, OnDemandMemoryGiB: 112}, report.Total)
+
+	assert.Equal(t, []string{
+		"min-size-spread general ",
+		"rollout-drains-zone general us-east-1b",
+		"rollout-drains-zone spot us-east-1a",
+	}, findingKeys(report.Findings))
+	assert.Equal(t,
+		"rollout-drains-zone: general: us-east-1b: max_unavailable_percentage 33 takes 2 of 7 nodes out at once, enough for every node in us-east-1b (2)",
+		report.Findings[1].String())
+}
+
+func TestSimulateEvenSpread(t *testing.T) {
+	t.Parallel()
+
+	// desired_size 6 is 2 per zone, and 33% of 6 rounds down to a single node
+	report, err := Simulate(Config{NodeGroups: tfvars.NodeGroups{"general": tfvars.BaselineNodeGroup()}, Subnets: testSubnets})
+	require.NoError(t, err)
+	assert.Empty(t, report.Findings)
+	assert.Equal(t, map[string]int{"us-east-1a": 2, "us-east-1b": 2, "us-east-1c": 2}, report.Placements[0].Nodes)
+
+	// A higher percentage drains a zone of the same group
+	report, err = Simulate(Config{
+		NodeGroups:               tfvars.NodeGroups{"general": tfvars.BaselineNodeGroup()},
+		Subnets:                  testSubnets,
+		MaxUnavailablePercentage: 50,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rollout-drains-zone general us-east-1a"}, findingKeys(report.Findings))
+}
+
+func TestLoseZone(t *testing.T) {
+	t.Parallel()
+
+	report, err := Simulate(Config{
+		NodeGroups: tfvars.NodeGroups{
+			"general": tfvars.BaselineNodeGroup().WithInstanceTypes("m5.xlarge"),
+			"spot":    tfvars.BaselineNodeGroup().WithCapacityType("SPOT").WithSizes(0, 3, 12).WithInstanceTypes("m5.xlarge"),
+		},
+		Subnets: testSubnets,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, Capacity{Nodes: 6, VCPU: 24, MemoryGiB: 96, OnDemandVCPU: 16, OnDemandMemoryGiB: 64}, report.LoseZone("us-east-1b"))
+
+	assert.Empty(t, report.CheckRequirement(Requirement{VCPU: 16, MemoryGiB: 64}))
+	assert.Empty(t, report.CheckRequirement(Requirement{VCPU: 24, MemoryGiB: 96, CountSpot: true}))
+
+	findings := report.CheckRequirement(Requirement{VCPU: 20, MemoryGiB: 64})
+	assert.Equal(t, []string{"zone-loss  us-east-1a", "zone-loss  us-east-1b", "zone-loss  us-east-1c"}, findingKeys(findings))
+	assert.Equal(t, "zone-loss: us-east-1a: losing us-east-1a leaves 16 vCPU and 64 GiB of on-demand capacity, want 20 vCPU and 64 GiB",
+		findings[0].String())
+}
+
+func TestSimulateErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := Simulate(Config{NodeGroups: tfvars.NodeGroups{"general": tfvars.BaselineNodeGroup()}})
+	assert.EqualError(t, err, "no subnets to place node groups in")
+
+	_, err = Simulate(Config{
+		NodeGroups: tfvars.NodeGroups{"gpu": tfvars.BaselineNodeGroup().WithInstanceTypes("p4d.24xlarge")},
+		Subnets:    testSubnets,
+	})
+	assert.EqualError(t, err, "node group gpu: no spec for instance type p4d.24xlarge")
+
+	report, err := Simulate(Config{NodeGroups: tfvars.NodeGroups{"general": tfvars.BaselineNodeGroup()}, Subnets: testSubnets[:2]})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"zones  ", "min-size-spread general "}, findingKeys(report.Findings))
+}
+
+// TestDefaultNodeGroups checks the "# N per AZ" comments on the node_groups default in variables.tf against the
+// simulated spread, and pins what the simulator finds in the default node groups.
+func TestDefaultNodeGroups(t *testing.T) {
+	t.Parallel()
+
+	const variables = "../../variables.tf"
+	groups, err := ParseDefaultNodeGroups(variables)
+	require.NoError(t, err)
+	require.NoError(t, groups.Validate())
+	assert.Equal(t, tfvars.NodeGroup{
+		DesiredSize: 3, MinSize: 0, MaxSize: 12,
+		InstanceTypes: []string{"t3.large", "t3a.large", "t3.xlarge"}, CapacityType: "SPOT", DiskSize: 50,
+	}, groups["spot"])
+
+	report, err := Simulate(Config{NodeGroups: groups, Subnets: testSubnets})
+	require.NoError(t, err)
+	placements := map[string]Placement{}
+	for _, placement := range report.Placements {
+		placements[placement.Group] = placement
+	}
+
+	file, err := os.Open(variables)
+	require.NoError(t, err)
+	defer file.Close()
+
+	groupLine := regexp.MustCompile(`^\s*(\w+) = \{`)
+	claimLine := regexp.MustCompile(`^\s*(desired_size|min_size|max_size)\s*=\s*(\d+)\s*#\s*(\d+) per AZ`)
+	var group string
+	claims := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if match := groupLine.FindStringSubmatch(scanner.Text()); match != nil {
+			group = match[1]
+		}
+		match := claimLine.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		claims++
+		size, _ := strconv.Atoi(match[2])
+		perZone, _ := strconv.Atoi(match[3])
+		assert.Equal(t, perZone*Zones, size, "%s.%s = %d is not %d per AZ", group, match[1], size, perZone)
+		if match[1] == "desired_size" {
+			for zone, nodes := range placements[group].Nodes {
+				assert.Equal(t, perZone, nodes, "%s in %s", group, zone)
+			}
+		}
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, 5, claims, "per-AZ comments checked")
+
+	// One spot node per zone: the single node a rolling update replaces at a time is a whole zone
+	assert.Equal(t, []string{"rollout-drains-zone spot us-east-1a"}, findingKeys(report.Findings))
+}
@@ -0,0 +1,95 @@
+package capacity
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
+)
+
+// ParseDefaultNodeGroups reads the default of the node_groups variable in a variables.tf, so the simulator can check
+// the node groups a deployment gets when it does not set the variable.
+func ParseDefaultNodeGroups(path string) (tfvars.NodeGroups, error) {
+	file, diags := hclparse.NewParser().ParseHCLFile(path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("%s: unexpected body type %T", path, file.Body)
+	}
+
+	for _, block := range body.Blocks {
+		if block.Type != "variable" || len(block.Labels) != 1 || block.Labels[0] != "node_groups" {
+			continue
+		}
+		attribute, ok := block.Body.Attributes["default"]
+		if !ok {
+			return nil, fmt.Errorf("%s: variable node_groups has no default", path)
+		}
+		value, diags := attribute.Expr.Value(nil)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("%s: node_groups default is not a literal: %s", path, diags.Error())
+		}
+		groups, err := nodeGroups(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: node_groups default: %w", path, err)
+		}
+		return groups, nil
+	}
+	return nil, fmt.Errorf("%s: no node_groups variable", path)
+}
+
+func nodeGroups(value cty.Value) (tfvars.NodeGroups, error) {
+	if !value.Type().IsObjectType() && !value.Type().IsMapType() {
+		return nil, fmt.Errorf("not a map of node groups")
+	}
+	groups := tfvars.NodeGroups{}
+	for name, group := range value.AsValueMap() {
+		if !group.Type().IsObjectType() {
+			return nil, fmt.Errorf("%s is not an object", name)
+		}
+		attributes := group.AsValueMap()
+		var parsed tfvars.NodeGroup
+		var err error
+		for field, target := range map[string]*int{
+			"desired_size": &parsed.DesiredSize,
+			"min_size":     &parsed.MinSize,
+			"max_size":     &parsed.MaxSize,
+			"disk_size":    &parsed.DiskSize,
+		} {
+			if *target, err = intAttribute(attributes, field); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		if capacityType, ok := attributes["capacity_type"]; ok && capacityType.Type() == cty.String {
+			parsed.CapacityType = capacityType.AsString()
+		}
+		if instanceTypes, ok := attributes["instance_types"]; ok && instanceTypes.CanIterateElements() {
+			for _, instanceType := range instanceTypes.AsValueSlice() {
+				if instanceType.Type() != cty.String {
+					return nil, fmt.Errorf("%s: instance_types must be strings", name)
+				}
+				parsed.InstanceTypes = append(parsed.InstanceTypes, instanceType.AsString())
+			}
+		}
+		groups[name] = parsed
+	}
+	return groups, nil
+}
+
+func intAttribute(attributes map[string]cty.Value, name string) (int, error) {
+	value, ok := attributes[name]
+	if !ok || value.Type() != cty.Number {
+		return 0, fmt.Errorf("%s is not a number", name)
+	}
+	n, accuracy := value.AsBigFloat().Int64()
+	if accuracy != big.Exact {
+		return 0, fmt.Errorf("%s is not an integer", name)
+	}
+	return int(n), nil
+}
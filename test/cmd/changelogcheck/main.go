@@ -0,0 +1,78 @@
+// Command changelogcheck checks that each module's CHANGELOG.md and RELEASE.md keep up with its variables and outputs.
+// It diffs the interface of every module from a base git ref to the working tree, or to another ref with -head,
+// classifies each change as breaking or additive, and fails when the CHANGELOG.md does not record a release with a big
+// enough major, minor or patch bump, or when a RELEASE.md is not for the newest release or does not list exactly the
+// module's variables and outputs. It prints a report per module and exits with status 1 when a check fails.
+//
+// Usage:
+//
+//	go run ./cmd/changelogcheck -base origin/main [-head HEAD] [-allow-unreleased] [eks-cluster ...]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/your-org/multi-az-eks-cluster/test/release"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("changelogcheck", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	repo := flags.String("repo", "..", "repository root")
+	base := flags.String("base", "", "git ref to diff from, e.g. origin/main or the module's last release tag")
+	head := flags.String("head", "", "git ref to diff to; defaults to the working tree")
+	allowUnreleased := flags.Bool("allow-unreleased", false, "accept changes recorded under ## [Unreleased], if it names each changed variable and output, instead of a new release")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: changelogcheck [-repo dir] -base ref [-head ref] [-allow-unreleased] [module ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *base == "" {
+		flags.Usage()
+		return 2
+	}
+
+	modules := flags.Args()
+	if len(modules) == 0 {
+		changelogs, err := filepath.Glob(filepath.Join(*repo, "modules", "*", "CHANGELOG.md"))
+		if err != nil {
+			fmt.Fprintf(stderr, "changelogcheck: %v\n", err)
+			return 2
+		}
+		for _, changelog := range changelogs {
+			modules = append(modules, filepath.Base(filepath.Dir(changelog)))
+		}
+	}
+	var headTree release.Tree = release.Worktree(*repo)
+	if *head != "" {
+		headTree = release.GitRef{Repo: *repo, Ref: *head}
+	}
+	baseTree := release.GitRef{Repo: *repo, Ref: *base}
+
+	status := 0
+	for _, module := range modules {
+		report, err := release.CheckModule(baseTree, headTree, module, release.Options{AllowUnreleased: *allowUnreleased})
+		if err != nil {
+			fmt.Fprintf(stderr, "changelogcheck: %v\n", err)
+			return 2
+		}
+		if err := report.Write(stdout); err != nil {
+			fmt.Fprintf(stderr, "changelogcheck: %v\n", err)
+			return 2
+		}
+		if !report.OK() {
+			status = 1
+		}
+	}
+	return status
+}
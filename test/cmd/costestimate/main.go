@@ -0,0 +1,117 @@
+// Command costestimate estimates the monthly cost of a Terraform plan from the price tables in cost/prices. It reads
+// the JSON output of `terraform show -json <planfile>` and prints the cost of each priced resource with a per-module
+// and per-region breakdown. With -budget it exits with status 1 when the total exceeds the budget or a resource
+// cannot be priced.
+//
+// Usage:
+//
+//	terraform plan -out tfplan && terraform show -json tfplan > tfplan.json
+//	go run ./cmd/costestimate [-region us-east-1] [-prices dir] [-budget 7000] [-json] tfplan.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/your-org/multi-az-eks-cluster/test/cost"
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("costestimate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	region := flags.String("region", "", "region of the plan's resources; defaults to the primary_region and secondary_region of a root module plan")
+	pricesDir := flags.String("prices", "", "directory of <region>.json price files to use instead of the built-in ones")
+	budget := flags.Float64("budget", 0, "fail when the monthly total exceeds this amount")
+	logGB := flags.Float64("log-gb", cost.DefaultLogIngestionGB, "monthly GB ingested per CloudWatch log group")
+	asJSON := flags.Bool("json", false, "print the estimate as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: costestimate [-region region] [-prices dir] [-budget amount] [-log-gb gb] [-json] <plan.json | ->")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	data, err := readInput(flags.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "costestimate: %v\n", err)
+		return 2
+	}
+	plan, err := planassert.ParseJSON(data)
+	if err != nil {
+		fmt.Fprintf(stderr, "costestimate: parsing plan: %v\n", err)
+		return 2
+	}
+
+	prices, err := cost.Default()
+	if *pricesDir != "" {
+		prices, err = cost.LoadDir(*pricesDir)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "costestimate: loading prices: %v\n", err)
+		return 2
+	}
+
+	options := cost.Options{Region: *region}
+	if *region == "" {
+		if options, err = cost.RootOptions(plan); err != nil {
+			fmt.Fprintf(stderr, "costestimate: %v; pass -region for a module plan\n", err)
+			return 2
+		}
+	}
+	options.LogIngestionGB = *logGB
+
+	estimate, err := prices.Estimate(plan, options)
+	if err != nil {
+		fmt.Fprintf(stderr, "costestimate: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(struct {
+			*cost.Estimate
+			Total    float64            `json:"total"`
+			ByRegion map[string]float64 `json:"by_region"`
+			ByModule []cost.ModuleCost  `json:"by_module"`
+		}{estimate, estimate.Total(), estimate.ByRegion(), estimate.ByModule()}); err != nil {
+			fmt.Fprintf(stderr, "costestimate: %v\n", err)
+			return 2
+		}
+	} else if err := estimate.Write(stdout); err != nil {
+		fmt.Fprintf(stderr, "costestimate: %v\n", err)
+		return 2
+	}
+
+	if *budget > 0 {
+		if len(estimate.Unpriced) > 0 {
+			fmt.Fprintf(stderr, "costestimate: %d resources could not be priced\n", len(estimate.Unpriced))
+			return 1
+		}
+		if estimate.Total() > *budget {
+			fmt.Fprintf(stderr, "costestimate: monthly total %.2f %s exceeds the budget of %.2f\n", estimate.Total(), estimate.Currency, *budget)
+			return 1
+		}
+	}
+	return 0
+}
+
+func readInput(path string, stdin io.Reader) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
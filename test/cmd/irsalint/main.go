@@ -0,0 +1,85 @@
+// Command irsalint lints the trust policies of the IRSA roles in a Terraform plan. It reads the JSON output of
+// `terraform show -json <planfile>` and exits with status 1 if any role can never be assumed or can be assumed by
+// more service accounts than intended.
+//
+// Usage:
+//
+//	terraform plan -out tfplan && terraform show -json tfplan > tfplan.json
+//	go run ./cmd/irsalint [-json] tfplan.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/your-org/multi-az-eks-cluster/test/iampolicy"
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("irsalint", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print findings as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: irsalint [-json] <plan.json | ->")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	data, err := readInput(flags.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "irsalint: %v\n", err)
+		return 2
+	}
+	plan, err := planassert.ParseJSON(data)
+	if err != nil {
+		fmt.Fprintf(stderr, "irsalint: parsing plan: %v\n", err)
+		return 2
+	}
+	findings, err := iampolicy.LintPlan(plan)
+	if err != nil {
+		fmt.Fprintf(stderr, "irsalint: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if findings == nil {
+			findings = []iampolicy.Finding{}
+		}
+		if err := encoder.Encode(findings); err != nil {
+			fmt.Fprintf(stderr, "irsalint: %v\n", err)
+			return 2
+		}
+	} else {
+		for _, finding := range findings {
+			fmt.Fprintln(stdout, finding)
+		}
+	}
+
+	if iampolicy.HasErrors(findings) {
+		return 1
+	}
+	return 0
+}
+
+func readInput(path string, stdin io.Reader) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
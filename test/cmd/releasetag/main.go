@@ -0,0 +1,58 @@
+// Command releasetag checks a module release tag, <module>-v<version> or <module>/v<version>, against the repository:
+// the tag is well formed, modules/<module> exists and its CHANGELOG.md has a ## [<version>] entry. It prints the
+// release metadata as JSON and exits with status 1 when the check fails.
+//
+// Usage:
+//
+//	go run ./cmd/releasetag [-root ..] eks-cluster-v1.0.0
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/your-org/multi-az-eks-cluster/test/release"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("releasetag", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	root := flags.String("root", "..", "repository root, whose modules/ directory the tag's module is in")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: releasetag [-root dir] <tag>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	tag, err := release.ParseTag(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintf(stderr, "releasetag: %v\n", err)
+		return 1
+	}
+	metadata, err := release.Check(*root, tag)
+	if err != nil {
+		fmt.Fprintf(stderr, "releasetag: %v\n", err)
+		return 1
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(metadata); err != nil {
+		fmt.Fprintf(stderr, "releasetag: %v\n", err)
+		return 2
+	}
+	return 0
+}
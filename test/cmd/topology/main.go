@@ -0,0 +1,98 @@
+// Command topology draws the multi-region deployment a root module plan or state describes: the regions and their
+// peered VPCs, the EKS clusters and node groups, the RDS primary and its cross-region replica, and the IRSA roles with
+// the service accounts that assume them. It reads the JSON output of `terraform show -json <planfile>` or of
+// `terraform show -json` for the current state, and prints the graph as DOT, Mermaid or JSON. It exits with status 1
+// when the graph is not connected.
+//
+// Usage:
+//
+//	terraform plan -out tfplan && terraform show -json tfplan > tfplan.json
+//	go run ./cmd/topology [-format dot|mermaid|json] [-source ..] tfplan.json | dot -Tsvg > topology.svg
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+	"github.com/your-org/multi-az-eks-cluster/test/topology"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("topology", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "dot", "output format, one of "+strings.Join(topology.Formats, ", "))
+	source := flags.String("source", "", "root module directory to read the service accounts of trust policies only known after apply from")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: topology [-format dot|mermaid|json] [-source dir] <plan.json | state.json | ->")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	data, err := readInput(flags.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "topology: %v\n", err)
+		return 2
+	}
+	plan, err := parse(data)
+	if err != nil {
+		fmt.Fprintf(stderr, "topology: %v\n", err)
+		return 2
+	}
+	graph, err := topology.Build(plan, topology.Options{SourceDir: *source})
+	if err != nil {
+		fmt.Fprintf(stderr, "topology: %v\n", err)
+		return 2
+	}
+	if err := graph.Write(stdout, *format); err != nil {
+		fmt.Fprintf(stderr, "topology: %v\n", err)
+		return 2
+	}
+
+	if components := graph.Components(); len(components) > 1 {
+		fmt.Fprintf(stderr, "topology: the graph has %d components, expected 1\n", len(components))
+		return 1
+	}
+	return 0
+}
+
+// parse reads a plan, or a state, which has values rather than planned_values.
+func parse(data []byte) (*planassert.Plan, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("parsing input: %w", err)
+	}
+	if _, ok := keys["values"]; ok {
+		plan, err := planassert.ParseStateJSON(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing state: %w", err)
+		}
+		return plan, nil
+	}
+	plan, err := planassert.ParseJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing plan: %w", err)
+	}
+	return plan, nil
+}
+
+func readInput(path string, stdin io.Reader) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,124 @@
+// Command upgradeimpact tells which node groups, launch templates and DB instances a change to the inputs replaces and
+// which it updates in place, and whether nodes are rolled out. It reads two plans of the same configuration, the JSON
+// output of `terraform show -json <planfile>` with the current and with the changed inputs, and diffs the second
+// against the first as if the first were applied. The baseline may also be the JSON of the current state. When the
+// second plan was made against the deployed state, the actions Terraform planned are taken as they are. It prints a
+// risk summary and the change of every resource. With -max-risk it exits with status 1 when the risk is higher.
+//
+// Usage:
+//
+//	terraform plan -var-file current.tfvars -out tfplan.base && terraform show -json tfplan.base > tfplan.base.json
+//	terraform plan -var-file changed.tfvars -out tfplan && terraform show -json tfplan > tfplan.json
+//	go run ./cmd/upgradeimpact [-region us-east-1] [-max-risk low] [-json] tfplan.base.json tfplan.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+	"github.com/your-org/multi-az-eks-cluster/test/upgrade"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("upgradeimpact", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	region := flags.String("region", "", "region of the plans' resources; defaults to the primary_region and secondary_region of a root module plan")
+	maxRisk := flags.String("max-risk", "", "fail when the risk is higher than this, one of none, low, medium, high")
+	asJSON := flags.Bool("json", false, "print the impact as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: upgradeimpact [-region region] [-max-risk risk] [-json] <baseline plan.json | state.json | -> <plan.json | ->")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 || flags.Arg(0) == "-" && flags.Arg(1) == "-" {
+		flags.Usage()
+		return 2
+	}
+	var limit upgrade.Risk
+	if *maxRisk != "" {
+		var err error
+		if limit, err = upgrade.ParseRisk(*maxRisk); err != nil {
+			fmt.Fprintf(stderr, "upgradeimpact: %v\n", err)
+			return 2
+		}
+	}
+
+	var plans [2]*planassert.Plan
+	for i := range plans {
+		data, err := readInput(flags.Arg(i), stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "upgradeimpact: %v\n", err)
+			return 2
+		}
+		if plans[i], err = parse(data); err != nil {
+			fmt.Fprintf(stderr, "upgradeimpact: %s: %v\n", flags.Arg(i), err)
+			return 2
+		}
+	}
+	baseline, modified := plans[0], plans[1]
+
+	options := upgrade.Options{Region: *region}
+	if *region == "" {
+		var err error
+		if options, err = upgrade.RootOptions(modified); err != nil {
+			fmt.Fprintf(stderr, "upgradeimpact: %v; pass -region for a module plan\n", err)
+			return 2
+		}
+	}
+	impact := upgrade.Compare(baseline, modified, options)
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(struct {
+			*upgrade.Impact
+			Summary string       `json:"summary"`
+			Risk    upgrade.Risk `json:"risk"`
+		}{impact, impact.Summary(), impact.Risk()}); err != nil {
+			fmt.Fprintf(stderr, "upgradeimpact: %v\n", err)
+			return 2
+		}
+	} else if err := impact.Write(stdout); err != nil {
+		fmt.Fprintf(stderr, "upgradeimpact: %v\n", err)
+		return 2
+	}
+
+	if limit != "" && impact.Risk().Exceeds(limit) {
+		fmt.Fprintf(stderr, "upgradeimpact: risk %s exceeds %s\n", impact.Risk(), limit)
+		return 1
+	}
+	return 0
+}
+
+// parse reads a plan, or a state, which has values rather than planned_values.
+func parse(data []byte) (*planassert.Plan, error) {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("parsing input: %w", err)
+	}
+	if _, ok := keys["values"]; ok {
+		return planassert.ParseStateJSON(data)
+	}
+	plan, err := planassert.ParseJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing plan: %w", err)
+	}
+	return plan, nil
+}
+
+func readInput(path string, stdin io.Reader) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,18 @@
+package cost
+
+import (
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// AssertWithinBudget checks that every priced resource of the estimate could be priced and that the monthly total
+// does not exceed the budget, printing the breakdown when it does.
+func AssertWithinBudget(t testing.TestingT, estimate *Estimate, budget float64) bool {
+	if !planassert.AssertNoFindings(t, estimate.Unpriced, "resources could not be priced") {
+		return false
+	}
+	return assert.LessOrEqualf(t, estimate.Total(), budget, "monthly cost exceeds the budget of %.2f %s:\n%s",
+		budget, estimate.Currency, estimate)
+}
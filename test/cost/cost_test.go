@@ -0,0 +1,226 @@
+package cost
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// recordingT collects failures without failing the enclosing test.
+type recordingT struct {
+	failed bool
+}
+
+func (r *recordingT) Fail()                                  { r.failed = true }
+func (r *recordingT) FailNow()                               { r.failed = true }
+func (r *recordingT) Fatal(args ...interface{})              { r.failed = true }
+func (r *recordingT) Fatalf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Error(args ...interface{})              { r.failed = true }
+func (r *recordingT) Errorf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Name() string                           { return "recordingT" }
+
+const samplePlan = `{
+  "format_version": "1.2",
+  "variables": {
+    "primary_region": {"value": "us-east-1"},
+    "secondary_region": {"value": "eu-west-1"}
+  },
+  "planned_values": {
+    "root_module": {
+      "resources": [
+        {"address": "aws_vpc_peering_connection.primary_to_secondary", "mode": "managed", "type": "aws_vpc_peering_connection", "name": "primary_to_secondary", "values": {}}
+      ],
+      "child_modules": [
+        {
+          "address": "module.primary_region.module.eks",
+          "resources": [
+            {"address": "module.primary_region.module.eks.aws_eks_cluster.main", "mode": "managed", "type": "aws_eks_cluster", "name": "main", "values": {}},
+            {"address": "module.primary_region.module.eks.aws_kms_key.eks", "mode": "managed", "type": "aws_kms_key", "name": "eks", "values": {}},
+            {"address": "module.primary_region.module.eks.aws_cloudwatch_log_group.cluster", "mode": "managed", "type": "aws_cloudwatch_log_group", "name": "cluster", "values": {}}
+          ]
+        },
+        {
+          "address": "module.primary_region.module.node_groups",
+          "resources": [
+            {"address": "module.primary_region.module.node_groups.aws_eks_node_group.main[\"general\"]", "mode": "managed", "type": "aws_eks_node_group", "name": "main", "index": "general",
+             "values": {"instance_types": ["m5.xlarge", "m5a.xlarge"], "capacity_type": "ON_DEMAND", "scaling_config": [{"desired_size": 9}]}},
+            {"address": "module.primary_region.module.node_groups.aws_eks_node_group.main[\"spot\"]", "mode": "managed", "type": "aws_eks_node_group", "name": "main", "index": "spot",
+             "values": {"instance_types": ["m5.xlarge"], "capacity_type": "SPOT", "scaling_config": [{"desired_size": 6}]}},
+            {"address": "module.primary_region.module.node_groups.aws_eks_node_group.main[\"gpu\"]", "mode": "managed", "type": "aws_eks_node_group", "name": "main", "index": "gpu",
+             "values": {"instance_types": ["p4d.24xlarge"], "capacity_type": "ON_DEMAND", "scaling_config": [{"desired_size": 1}]}}
+          ]
+        },
+        {
+          "address": "module.primary_region.module.rds[0]",
+          "resources": [
+            {"address": "module.primary_region.module.rds[0].aws_db_instance.main[0]", "mode": "managed", "type": "aws_db_instance", "name": "main", "index": 0,
+             "values": {"instance_class": "db.r6g.2xlarge", "allocated_storage": 1000, "storage_type": "gp3", "multi_az": true}}
+          ]
+        },
+        {
+          "address": "module.secondary_region.module.rds[0]",
+          "resources": [
+            {"address": "module.secondary_region.module.rds[0].aws_db_instance.replica[0]", "mode": "managed", "type": "aws_db_instance", "name": "replica", "index": 0,
+             "values": {"instance_class": "db.r6g.2xlarge", "multi_az": false, "replicate_source_db": "arn:aws:rds:us-east-1:123456789012:db:primary"}}
+          ]
+        },
+        {
+          "address": "module.secondary_region.module.vpc",
+          "resources": [
+            {"address": "module.secondary_region.module.vpc.aws_nat_gateway.main[0]", "mode": "managed", "type": "aws_nat_gateway", "name": "main", "index": 0, "values": {}},
+            {"address": "module.secondary_region.module.vpc.aws_nat_gateway.main[1]", "mode": "managed", "type": "aws_nat_gateway", "name": "main", "index": 1, "values": {}},
+            {"address": "module.secondary_region.module.vpc.aws_nat_gateway.main[2]", "mode": "managed", "type": "aws_nat_gateway", "name": "main", "index": 2, "values": {}}
+          ]
+        }
+      ]
+    }
+  }
+}`
+
+func estimateSample(t *testing.T) *Estimate {
+	t.Helper()
+
+	plan, err := planassert.ParseJSON([]byte(samplePlan))
+	require.NoError(t, err)
+	prices, err := Default()
+	require.NoError(t, err)
+	options, err := RootOptions(plan)
+	require.NoError(t, err)
+	estimate, err := prices.Estimate(plan, options)
+	require.NoError(t, err)
+	return estimate
+}
+
+func TestDefaultPrices(t *testing.T) {
+	t.Parallel()
+
+	prices, err := Default()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"eu-west-1", "us-east-1", "us-west-2"}, prices.Regions())
+	for region, table := range prices {
+		assert.Equal(t, "USD", table.Currency, region)
+		assert.NotEmpty(t, table.Version, region)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	t.Parallel()
+
+	estimate := estimateSample(t)
+	monthly := map[string]float64{}
+	details := map[string]string{}
+	for _, item := range estimate.Items {
+		monthly[item.Address] = item.Monthly
+		details[item.Address] = item.Detail
+	}
+
+	const primary, secondary = "module.primary_region.module.", "module.secondary_region.module."
+	assert.InDelta(t, 0.10*730, monthly[primary+"eks.aws_eks_cluster.main"], 1e-9)
+	assert.InDelta(t, 1.00, monthly[primary+"eks.aws_kms_key.eks"], 1e-9)
+	assert.InDelta(t, 5*0.50, monthly[primary+"eks.aws_cloudwatch_log_group.cluster"], 1e-9)
+	assert.InDelta(t, 9*0.192*730, monthly[primary+`node_groups.aws_eks_node_group.main["general"]`], 1e-9)
+	assert.InDelta(t, 6*0.192*0.30*730, monthly[primary+`node_groups.aws_eks_node_group.main["spot"]`], 1e-9)
+	assert.Equal(t, "6 x m5.xlarge spot", details[primary+`node_groups.aws_eks_node_group.main["spot"]`])
+	assert.InDelta(t, 2*(0.899*730+1000*0.115), monthly[primary+"rds[0].aws_db_instance.main[0]"], 1e-9)
+	assert.Equal(t, "db.r6g.2xlarge, 1000 GiB gp3, multi-AZ", details[primary+"rds[0].aws_db_instance.main[0]"])
+
+	// The replica is in eu-west-1 and inherits the storage of its source
+	assert.InDelta(t, 0.988*730+1000*0.127, monthly[secondary+"rds[0].aws_db_instance.replica[0]"], 1e-9)
+	assert.InDelta(t, 0.048*730, monthly[secondary+"vpc.aws_nat_gateway.main[2]"], 1e-9)
+	assert.NotContains(t, monthly, "aws_vpc_peering_connection.primary_to_secondary")
+
+	assert.Equal(t, []Unpriced{{
+		Address: primary + `node_groups.aws_eks_node_group.main["gpu"]`,
+		Reason:  "no price for instance type p4d.24xlarge in us-east-1",
+	}}, estimate.Unpriced)
+	assert.Equal(t, map[string]string{"us-east-1": "2024-06-01", "eu-west-1": "2024-06-01"}, estimate.PriceVersions)
+
+	byRegion := estimate.ByRegion()
+	assert.InDelta(t, 0.988*730+1000*0.127+3*0.048*730, byRegion["eu-west-1"], 1e-9)
+	assert.InDelta(t, byRegion["us-east-1"]+byRegion["eu-west-1"], estimate.Total(), 1e-9)
+
+	var modules []string
+	for _, module := range estimate.ByModule() {
+		modules = append(modules, module.Region+" "+module.Module)
+	}
+	assert.Equal(t, []string{
+		"eu-west-1 module.secondary_region.module.rds[0]",
+		"eu-west-1 module.secondary_region.module.vpc",
+		"us-east-1 module.primary_region.module.eks",
+		"us-east-1 module.primary_region.module.node_groups",
+		"us-east-1 module.primary_region.module.rds[0]",
+	}, modules)
+
+	assert.Contains(t, estimate.String(), "unpriced: "+primary+`node_groups.aws_eks_node_group.main["gpu"]: no price for instance type p4d.24xlarge in us-east-1`)
+}
+
+func TestEstimateRequiresRegionPrices(t *testing.T) {
+	t.Parallel()
+
+	plan, err := planassert.ParseJSON([]byte(samplePlan))
+	require.NoError(t, err)
+	prices, err := Default()
+	require.NoError(t, err)
+
+	_, err = prices.Estimate(plan, Options{Region: "ap-south-2"})
+	assert.EqualError(t, err, `module.primary_region.module.eks.aws_cloudwatch_log_group.cluster: no price table for region "ap-south-2"`)
+
+	plan, err = planassert.ParseJSON([]byte(`{"format_version": "1.2", "variables": {"primary_region": {"value": "us-east-1"}}}`))
+	require.NoError(t, err)
+	_, err = RootOptions(plan)
+	assert.EqualError(t, err, "plan has no secondary_region variable")
+}
+
+func TestAssertWithinBudget(t *testing.T) {
+	t.Parallel()
+
+	estimate := estimateSample(t)
+	mock := &recordingT{}
+	assert.False(t, AssertWithinBudget(mock, estimate, 1e9), "unpriced resources fail the budget")
+	assert.True(t, mock.failed)
+
+	estimate.Unpriced = nil
+	assert.True(t, AssertWithinBudget(t, estimate, estimate.Total()))
+	mock = &recordingT{}
+	assert.False(t, AssertWithinBudget(mock, estimate, estimate.Total()-1))
+	assert.True(t, mock.failed)
+}
+
+func TestLoadDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	write("us-east-1.json", `{"version": "2025-01-01", "region": "us-east-1", "currency": "USD", "eks_cluster_hourly": 0.1}`)
+	prices, err := LoadDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, "2025-01-01", prices["us-east-1"].Version)
+
+	write("us-west-2.json", `{"version": "2025-01-01", "region": "us-east-1", "currency": "USD"}`)
+	_, err = LoadDir(dir)
+	assert.EqualError(t, err, `us-west-2.json: region "us-east-1" does not match the file name`)
+
+	_, err = ParseTable([]byte(`{"version": "2025-01-01", "region": "us-east-1", "currency": "USD", "eks_cluster_hourli": 0.1}`))
+	assert.ErrorContains(t, err, `unknown field "eks_cluster_hourli"`)
+
+	_, err = ParseTable([]byte(`{"region": "us-east-1", "currency": "USD", "spot_discount": 1, "ec2_instance_hourly": {"t3.large": -1}}`))
+	require.Error(t, err)
+	for _, message := range []string{
+		"version is required",
+		"spot_discount 1 must be at least 0 and less than 1",
+		"ec2_instance_hourly[t3.large] must not be negative",
+	} {
+		assert.ErrorContains(t, err, message)
+	}
+
+	_, err = LoadDir(t.TempDir())
+	assert.EqualError(t, err, "no <region>.json price files found")
+}
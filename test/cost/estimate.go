@@ -0,0 +1,244 @@
+// Package cost estimates the monthly cost of the resources in a Terraform plan from local, versioned price tables, so a
+// change to node_groups or rds_config can be priced before it is applied. It prices EKS clusters, managed node groups,
+// NAT gateways, RDS instances, KMS keys and CloudWatch log groups; other resources are free or priced by usage and are
+// left out.
+package cost
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// DefaultLogIngestionGB is the number of GB a log group is assumed to ingest per month when Options does not say.
+const DefaultLogIngestionGB = 5
+
+// RootModule names the root module in the per-module breakdown.
+const RootModule = "(root)"
+
+// Options says which region each resource of the plan is in and how much usage to assume.
+type Options struct {
+	// Region is the region of resources outside ModuleRegions, usually the region of the default provider.
+	Region string
+
+	// ModuleRegions maps a module address to the region of every resource in it, for plans that configure one
+	// provider per module like the root module.
+	ModuleRegions map[string]string
+
+	// LogIngestionGB is the monthly ingestion assumed for each log group. Zero means DefaultLogIngestionGB.
+	LogIngestionGB float64
+}
+
+// RootOptions returns the options for a root module plan: module.primary_region and module.secondary_region in the
+// primary_region and secondary_region variables, and root-module resources in the primary region.
+func RootOptions(plan *planassert.Plan) (Options, error) {
+	regions := map[string]string{}
+	for _, name := range []string{"primary_region", "secondary_region"} {
+		variable, ok := plan.Raw().RawPlan.Variables[name]
+		if !ok || variable == nil {
+			return Options{}, fmt.Errorf("plan has no %s variable", name)
+		}
+		region, ok := variable.Value.(string)
+		if !ok {
+			return Options{}, fmt.Errorf("%s is a %T, not a string", name, variable.Value)
+		}
+		regions[name] = region
+	}
+	return Options{
+		Region: regions["primary_region"],
+		ModuleRegions: map[string]string{
+			"module.primary_region":   regions["primary_region"],
+			"module.secondary_region": regions["secondary_region"],
+		},
+	}, nil
+}
+
+// Item is the monthly cost of one planned resource.
+type Item struct {
+	Address string  `json:"address"`
+	Type    string  `json:"type"`
+	Module  string  `json:"module"`
+	Region  string  `json:"region"`
+	Detail  string  `json:"detail"`
+	Monthly float64 `json:"monthly"`
+}
+
+// Unpriced is a resource of a priced type that could not be priced, e.g. because its instance type is missing from
+// the price table.
+type Unpriced struct {
+	Address string `json:"address"`
+	Reason  string `json:"reason"`
+}
+
+// String renders the unpriced resource as "<address>: <reason>".
+func (u Unpriced) String() string {
+	return u.Address + ": " + u.Reason
+}
+
+// Estimate is the monthly cost of a plan.
+type Estimate struct {
+	Currency string `json:"currency"`
+
+	// PriceVersions are the versions of the price tables used, by region.
+	PriceVersions map[string]string `json:"price_versions"`
+
+	Items    []Item     `json:"items"`
+	Unpriced []Unpriced `json:"unpriced,omitempty"`
+}
+
+// ModuleCost is the monthly cost of the resources directly in one module of one region.
+type ModuleCost struct {
+	Region  string  `json:"region"`
+	Module  string  `json:"module"`
+	Monthly float64 `json:"monthly"`
+}
+
+// Estimate prices every resource of the plan that is planned to exist after apply. It fails only when a resource is
+// in a region without a price table; resources that cannot be priced are listed in Estimate.Unpriced.
+func (p Prices) Estimate(plan *planassert.Plan, options Options) (*Estimate, error) {
+	if options.LogIngestionGB == 0 {
+		options.LogIngestionGB = DefaultLogIngestionGB
+	}
+	estimate := &Estimate{PriceVersions: map[string]string{}}
+
+	storage := primaryStorage(plan)
+	for _, resource := range plan.Managed() {
+		price, ok := pricers[resource.Type]
+		if !ok {
+			continue
+		}
+		region := options.regionOf(resource.Address)
+		table, ok := p[region]
+		if !ok {
+			return nil, fmt.Errorf("%s: no price table for region %q", resource.Address, region)
+		}
+		if estimate.Currency == "" {
+			estimate.Currency = table.Currency
+		} else if estimate.Currency != table.Currency {
+			return nil, fmt.Errorf("price table of %s is in %s, not %s", region, table.Currency, estimate.Currency)
+		}
+		estimate.PriceVersions[region] = table.Version
+
+		monthly, detail, err := price(resource, table, pricingContext{options: options, primaryStorage: storage})
+		if err != nil {
+			estimate.Unpriced = append(estimate.Unpriced, Unpriced{Address: resource.Address, Reason: err.Error()})
+			continue
+		}
+		module := resource.ModuleAddress
+		if module == "" {
+			module = RootModule
+		}
+		estimate.Items = append(estimate.Items, Item{
+			Address: resource.Address,
+			Type:    resource.Type,
+			Module:  module,
+			Region:  region,
+			Detail:  detail,
+			Monthly: monthly,
+		})
+	}
+	return estimate, nil
+}
+
+// regionOf returns the region of the module with the longest address that contains the resource.
+func (o Options) regionOf(address string) string {
+	region, longest := o.Region, -1
+	for module, moduleRegion := range o.ModuleRegions {
+		if strings.HasPrefix(address, module+".") && len(module) > longest {
+			region, longest = moduleRegion, len(module)
+		}
+	}
+	return region
+}
+
+// Total returns the monthly cost of the whole plan.
+func (e *Estimate) Total() float64 {
+	var total float64
+	for _, item := range e.Items {
+		total += item.Monthly
+	}
+	return total
+}
+
+// ByRegion returns the monthly cost of each region.
+func (e *Estimate) ByRegion() map[string]float64 {
+	out := map[string]float64{}
+	for _, item := range e.Items {
+		out[item.Region] += item.Monthly
+	}
+	return out
+}
+
+// ByModule returns the monthly cost of each module in each region, sorted by region and module.
+func (e *Estimate) ByModule() []ModuleCost {
+	index := map[[2]string]float64{}
+	for _, item := range e.Items {
+		index[[2]string{item.Region, item.Module}] += item.Monthly
+	}
+	out := make([]ModuleCost, 0, len(index))
+	for key, monthly := range index {
+		out = append(out, ModuleCost{Region: key[0], Module: key[1], Monthly: monthly})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Region != out[j].Region {
+			return out[i].Region < out[j].Region
+		}
+		return out[i].Module < out[j].Module
+	})
+	return out
+}
+
+// Write renders the estimate as a table of resources followed by the per-module and per-region breakdown.
+func (e *Estimate) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "REGION\tRESOURCE\tDETAIL\t%13s\n", "MONTHLY ("+e.Currency+")")
+	for _, item := range e.sortedItems() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%13.2f\n", item.Region, item.Address, item.Detail, item.Monthly)
+	}
+	fmt.Fprintln(tw)
+	for _, module := range e.ByModule() {
+		fmt.Fprintf(tw, "%s\t%s\t\t%13.2f\n", module.Region, module.Module, module.Monthly)
+	}
+	fmt.Fprintln(tw)
+	byRegion := e.ByRegion()
+	regions := make([]string, 0, len(byRegion))
+	for region := range byRegion {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	for _, region := range regions {
+		fmt.Fprintf(tw, "%s\t(prices %s)\t\t%13.2f\n", region, e.PriceVersions[region], byRegion[region])
+	}
+	fmt.Fprintf(tw, "TOTAL\t\t\t%13.2f\n", e.Total())
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, unpriced := range e.Unpriced {
+		if _, err := fmt.Fprintf(w, "unpriced: %s\n", unpriced); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// String renders the estimate as Write does.
+func (e *Estimate) String() string {
+	var b strings.Builder
+	_ = e.Write(&b)
+	return b.String()
+}
+
+func (e *Estimate) sortedItems() []Item {
+	items := append([]Item(nil), e.Items...)
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].Region != items[j].Region {
+			return items[i].Region < items[j].Region
+		}
+		return items[i].Address < items[j].Address
+	})
+	return items
+}
@@ -0,0 +1,144 @@
+package cost
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// pricingContext is what a pricer needs besides the resource and its region's prices.
+type pricingContext struct {
+	options Options
+
+	// primaryStorage is the allocated storage of the only source DB instance in the plan, or 0 when there is not
+	// exactly one. A replica's allocated storage is unknown until apply and inherited from its source.
+	primaryStorage float64
+}
+
+// pricer returns the monthly cost of a resource and a short description of what was priced.
+type pricer func(resource *planassert.Resource, table *PriceTable, context pricingContext) (float64, string, error)
+
+var pricers = map[string]pricer{
+	"aws_eks_cluster":          priceEKSCluster,
+	"aws_eks_node_group":       priceNodeGroup,
+	"aws_nat_gateway":          priceNATGateway,
+	"aws_db_instance":          priceDBInstance,
+	"aws_kms_key":              priceKMSKey,
+	"aws_cloudwatch_log_group": priceLogGroup,
+}
+
+func priceEKSCluster(_ *planassert.Resource, table *PriceTable, _ pricingContext) (float64, string, error) {
+	return table.EKSClusterHourly * HoursPerMonth, "control plane", nil
+}
+
+// priceNodeGroup prices desired_size nodes of the first instance type, the type EKS launches on-demand capacity with
+// first, less the spot discount for SPOT groups.
+func priceNodeGroup(resource *planassert.Resource, table *PriceTable, _ pricingContext) (float64, string, error) {
+	desired, ok := number(resource, "scaling_config.0.desired_size")
+	if !ok {
+		return 0, "", errors.New("scaling_config.0.desired_size is not known at plan time")
+	}
+	value, _ := resource.Attribute("instance_types.0")
+	instanceType, ok := value.(string)
+	if !ok {
+		return 0, "", errors.New("instance_types is not known at plan time")
+	}
+	hourly, ok := table.EC2InstanceHourly[instanceType]
+	if !ok {
+		return 0, "", fmt.Errorf("no price for instance type %s in %s", instanceType, table.Region)
+	}
+
+	capacityType, _ := resource.Attribute("capacity_type")
+	detail := fmt.Sprintf("%d x %s", int(desired), instanceType)
+	if capacityType == "SPOT" {
+		hourly *= 1 - table.SpotDiscount
+		detail += " spot"
+	}
+	return desired * hourly * HoursPerMonth, detail, nil
+}
+
+func priceNATGateway(_ *planassert.Resource, table *PriceTable, _ pricingContext) (float64, string, error) {
+	return table.NATGatewayHourly * HoursPerMonth, "gateway hours", nil
+}
+
+// priceDBInstance prices the instance class and its storage, both doubled for a multi-AZ standby. Storage of an
+// unknown type is priced as gp3, the type the rds module sets.
+func priceDBInstance(resource *planassert.Resource, table *PriceTable, context pricingContext) (float64, string, error) {
+	value, _ := resource.Attribute("instance_class")
+	class, ok := value.(string)
+	if !ok {
+		return 0, "", errors.New("instance_class is not known at plan time")
+	}
+	hourly, ok := table.RDSInstanceHourly[class]
+	if !ok {
+		return 0, "", fmt.Errorf("no price for instance class %s in %s", class, table.Region)
+	}
+
+	storage, ok := number(resource, "allocated_storage")
+	if !ok {
+		if _, replica := resource.Attribute("replicate_source_db"); !replica && !resource.IsUnknown("replicate_source_db") {
+			return 0, "", errors.New("allocated_storage is not known at plan time")
+		}
+		if context.primaryStorage == 0 {
+			return 0, "", errors.New("allocated_storage of the replica's source is not known")
+		}
+		storage = context.primaryStorage
+	}
+	storageType := "gp3"
+	if value, ok := resource.Attribute("storage_type"); ok {
+		storageType = fmt.Sprint(value)
+	}
+	gbMonth, ok := table.RDSStorageGBMonth[storageType]
+	if !ok {
+		return 0, "", fmt.Errorf("no price for %s storage in %s", storageType, table.Region)
+	}
+
+	copies := 1.0
+	detail := fmt.Sprintf("%s, %d GiB %s", class, int(storage), storageType)
+	if multiAZ, _ := resource.Attribute("multi_az"); multiAZ == true {
+		copies = 2
+		detail += ", multi-AZ"
+	}
+	return copies * (hourly*HoursPerMonth + storage*gbMonth), detail, nil
+}
+
+func priceKMSKey(_ *planassert.Resource, table *PriceTable, _ pricingContext) (float64, string, error) {
+	return table.KMSKeyMonth, "customer managed key", nil
+}
+
+func priceLogGroup(_ *planassert.Resource, table *PriceTable, context pricingContext) (float64, string, error) {
+	gb := context.options.LogIngestionGB
+	return gb * table.CloudWatchLogsIngestedGB, fmt.Sprintf("%g GB ingested (assumed)", gb), nil
+}
+
+// primaryStorage returns the allocated storage of the only DB instance in the plan that is not a replica.
+func primaryStorage(plan *planassert.Plan) float64 {
+	var sources []float64
+	for _, resource := range plan.OfType("aws_db_instance") {
+		if _, replica := resource.Attribute("replicate_source_db"); replica || resource.IsUnknown("replicate_source_db") {
+			continue
+		}
+		if storage, ok := number(resource, "allocated_storage"); ok {
+			sources = append(sources, storage)
+		}
+	}
+	if len(sources) != 1 {
+		return 0
+	}
+	return sources[0]
+}
+
+func number(resource *planassert.Resource, path string) (float64, bool) {
+	value, ok := resource.Attribute(path)
+	if !ok {
+		return 0, false
+	}
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,154 @@
+package cost
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed prices/*.json
+var embeddedPrices embed.FS
+
+// HoursPerMonth is the number of hours AWS bills an always-on resource for in an average month.
+const HoursPerMonth = 730
+
+// PriceTable holds the on-demand prices of one region, in Currency. Each price file is versioned by the date its prices
+// were taken from the AWS price list, so a change in an estimate can be traced to a change in prices or in the plan.
+type PriceTable struct {
+	Version  string `json:"version"`
+	Region   string `json:"region"`
+	Currency string `json:"currency"`
+
+	EKSClusterHourly float64 `json:"eks_cluster_hourly"`
+	NATGatewayHourly float64 `json:"nat_gateway_hourly"`
+
+	// EC2InstanceHourly is the Linux on-demand price of each instance type.
+	EC2InstanceHourly map[string]float64 `json:"ec2_instance_hourly"`
+
+	// SpotDiscount is the fraction of the on-demand price saved by running on spot capacity.
+	SpotDiscount float64 `json:"spot_discount"`
+
+	// RDSInstanceHourly is the single-AZ price of each DB instance class; a multi-AZ instance costs twice as much.
+	RDSInstanceHourly map[string]float64 `json:"rds_instance_hourly"`
+
+	// RDSStorageGBMonth is the price of a GiB of storage per month by storage type, doubled for multi-AZ.
+	RDSStorageGBMonth map[string]float64 `json:"rds_storage_gb_month"`
+
+	KMSKeyMonth float64 `json:"kms_key_month"`
+
+	// CloudWatchLogsIngestedGB is the price of ingesting a GB of logs.
+	CloudWatchLogsIngestedGB float64 `json:"cloudwatch_logs_ingested_gb"`
+}
+
+// Validate checks that the table is versioned and its prices are usable.
+func (p *PriceTable) Validate() error {
+	var errs []error
+	if p.Version == "" {
+		errs = append(errs, errors.New("version is required"))
+	}
+	if p.Region == "" {
+		errs = append(errs, errors.New("region is required"))
+	}
+	if p.Currency == "" {
+		errs = append(errs, errors.New("currency is required"))
+	}
+	if p.SpotDiscount < 0 || p.SpotDiscount >= 1 {
+		errs = append(errs, fmt.Errorf("spot_discount %v must be at least 0 and less than 1", p.SpotDiscount))
+	}
+	for name, price := range map[string]float64{
+		"eks_cluster_hourly":          p.EKSClusterHourly,
+		"nat_gateway_hourly":          p.NATGatewayHourly,
+		"kms_key_month":               p.KMSKeyMonth,
+		"cloudwatch_logs_ingested_gb": p.CloudWatchLogsIngestedGB,
+	} {
+		if price < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", name))
+		}
+	}
+	for name, prices := range map[string]map[string]float64{
+		"ec2_instance_hourly":  p.EC2InstanceHourly,
+		"rds_instance_hourly":  p.RDSInstanceHourly,
+		"rds_storage_gb_month": p.RDSStorageGBMonth,
+	} {
+		for key, price := range prices {
+			if price < 0 {
+				errs = append(errs, fmt.Errorf("%s[%s] must not be negative", name, key))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Prices are the price tables of every region that can be estimated, keyed by region.
+type Prices map[string]*PriceTable
+
+// Default returns the price tables in prices/, one <region>.json per region.
+func Default() (Prices, error) {
+	sub, err := fs.Sub(embeddedPrices, "prices")
+	if err != nil {
+		return nil, err
+	}
+	return load(sub)
+}
+
+// LoadDir reads the price tables of a directory of <region>.json files, e.g. to estimate with newer prices.
+func LoadDir(dir string) (Prices, error) {
+	return load(os.DirFS(dir))
+}
+
+func load(fsys fs.FS) (Prices, error) {
+	names, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no <region>.json price files found")
+	}
+	prices := Prices{}
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		table, err := ParseTable(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if region := strings.TrimSuffix(path.Base(name), ".json"); table.Region != region {
+			return nil, fmt.Errorf("%s: region %q does not match the file name", name, table.Region)
+		}
+		prices[table.Region] = table
+	}
+	return prices, nil
+}
+
+// ParseTable reads and validates a price table. Unknown fields are rejected so a misspelt price is not silently zero.
+func ParseTable(data []byte) (*PriceTable, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var table PriceTable
+	if err := decoder.Decode(&table); err != nil {
+		return nil, err
+	}
+	if err := table.Validate(); err != nil {
+		return nil, err
+	}
+	return &table, nil
+}
+
+// Regions returns the regions that have a price table, sorted.
+func (p Prices) Regions() []string {
+	regions := make([]string, 0, len(p))
+	for region := range p {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions
+}
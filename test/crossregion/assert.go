@@ -0,0 +1,18 @@
+package crossregion
+
+import (
+	"github.com/gruntwork-io/terratest/modules/testing"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// AssertSymmetric checks that the root module plan has no differences between its regions, reporting any it finds as
+// a diff grouped by check.
+func AssertSymmetric(t testing.TestingT, plan *planassert.Plan) bool {
+	differences, err := Check(plan)
+	if err != nil {
+		t.Errorf("checking cross-region invariants: %v", err)
+		return false
+	}
+	return planassert.AssertNoFindingsFormatted(t, differences, Format, "regions are not symmetric")
+}
@@ -0,0 +1,387 @@
+// Package crossregion checks the invariants between the two regions of the root module. main.tf builds
+// module.primary_region and module.secondary_region from the same inputs, so both must plan the same node groups,
+// Kubernetes version, addons and OU access; the only intended differences are that the primary owns the database and
+// the secondary a read replica of it, joined by a VPC peering connection between the two VPCs.
+package crossregion
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// Module addresses of the two regions and the root-module peering resources that join them.
+const (
+	PrimaryModule     = "module.primary_region"
+	SecondaryModule   = "module.secondary_region"
+	PeeringConnection = "aws_vpc_peering_connection.primary_to_secondary"
+	PeeringAccepter   = "aws_vpc_peering_connection_accepter.secondary"
+)
+
+// Checks are the invariants Check verifies, in the order their differences are reported.
+var Checks = []string{"kubernetes_version", "node_groups", "addons", "ou_access", "rds", "peering"}
+
+// UnknownValue stands in for an attribute that is only known after apply.
+const UnknownValue = "(known after apply)"
+
+// Difference is one asymmetry between the regions. Primary and Secondary hold what each region plans at Path, nil
+// when the region plans nothing there. For the peering check they hold the VPC each side should reference.
+type Difference struct {
+	Check     string      `json:"check"`
+	Path      string      `json:"path"`
+	Primary   interface{} `json:"primary"`
+	Secondary interface{} `json:"secondary"`
+	Message   string      `json:"message,omitempty"`
+}
+
+// String renders the difference on one line, e.g.
+// node_groups: general.instance_types: primary ["t3.large"], secondary ["m5.large"]
+func (d Difference) String() string {
+	s := fmt.Sprintf("%s: %s: primary %s, secondary %s", d.Check, d.Path, render(d.Primary), render(d.Secondary))
+	if d.Message != "" {
+		s += " (" + d.Message + ")"
+	}
+	return s
+}
+
+// NodeGroup is the part of a planned node group that must match across regions.
+type NodeGroup struct {
+	InstanceTypes []string `json:"instance_types"`
+	CapacityType  string   `json:"capacity_type"`
+	Version       string   `json:"version"`
+}
+
+// Access is the planned access of one OU, keyed by OU ID in Region.OUAccess.
+type Access struct {
+	PrincipalARN string `json:"principal_arn"`
+	PolicyARN    string `json:"policy_arn"`
+}
+
+// Region is what one region module plans, reduced to the values the checks compare.
+type Region struct {
+	Module            string               `json:"module"`
+	Name              string               `json:"name"`
+	VPCID             string               `json:"vpc_id"`
+	KubernetesVersion string               `json:"kubernetes_version"`
+	NodeGroups        map[string]NodeGroup `json:"node_groups"`
+	Addons            map[string]string    `json:"addons"`
+	OUAccess          map[string]Access    `json:"ou_access"`
+
+	// DBInstances are the names of the planned aws_db_instance resources, e.g. main or replica.
+	DBInstances []string `json:"db_instances"`
+}
+
+// Extract reads the region planned by the module at the given address. The region name comes from the Region tag
+// main.tf adds to the cluster, and the VPC from the security groups the region creates in it.
+func Extract(plan *planassert.Plan, module string) (Region, error) {
+	region := Region{
+		Module:     module,
+		NodeGroups: map[string]NodeGroup{},
+		Addons:     map[string]string{},
+		OUAccess:   map[string]Access{},
+	}
+
+	clusterAddress := module + ".module.eks.aws_eks_cluster.main"
+	cluster, ok := plan.Resource(clusterAddress)
+	if !ok || cluster.Destroyed() {
+		return region, fmt.Errorf("%s is not planned", clusterAddress)
+	}
+	region.KubernetesVersion = stringAttribute(cluster, "version")
+	region.Name = stringAttribute(cluster, "tags.Region")
+
+	vpcs := map[string]bool{}
+	for _, group := range ofType(plan, module, "aws_security_group") {
+		vpcs[stringAttribute(group, "vpc_id")] = true
+	}
+	switch ids := sortedKeys(vpcs); len(ids) {
+	case 0:
+		return region, fmt.Errorf("%s plans no security groups to read its VPC from", module)
+	case 1:
+		region.VPCID = ids[0]
+	default:
+		return region, fmt.Errorf("%s plans security groups in more than one VPC: %s", module, strings.Join(ids, ", "))
+	}
+
+	for _, group := range plan.Match(module + ".module.node_groups.aws_eks_node_group.main[*]") {
+		region.NodeGroups[fmt.Sprint(group.Index)] = NodeGroup{
+			InstanceTypes: stringsAttribute(group, "instance_types"),
+			CapacityType:  stringAttribute(group, "capacity_type"),
+			Version:       stringAttribute(group, "version"),
+		}
+	}
+
+	for _, addon := range ofType(plan, module, "aws_eks_addon") {
+		region.Addons[stringAttribute(addon, "addon_name")] = stringAttribute(addon, "addon_version")
+	}
+
+	for _, entry := range plan.Match(module + ".module.eks.aws_eks_access_entry.ou_access[*]") {
+		region.OUAccess[fmt.Sprint(entry.Index)] = Access{PrincipalARN: stringAttribute(entry, "principal_arn")}
+	}
+	for _, association := range plan.Match(module + ".module.eks.aws_eks_access_policy_association.ou_policies[*]") {
+		key := fmt.Sprint(association.Index)
+		access := region.OUAccess[key]
+		access.PolicyARN = stringAttribute(association, "policy_arn")
+		region.OUAccess[key] = access
+	}
+
+	names := map[string]bool{}
+	for _, resource := range ofType(plan, module, "aws_db_instance") {
+		names[resource.Name] = true
+	}
+	region.DBInstances = sortedKeys(names)
+
+	return region, nil
+}
+
+// Check extracts both regions from a root module plan and returns every difference between them, grouped by check
+// in the order of Checks. An error means the plan is not a root module plan.
+func Check(plan *planassert.Plan) ([]Difference, error) {
+	primary, err := Extract(plan, PrimaryModule)
+	if err != nil {
+		return nil, err
+	}
+	secondary, err := Extract(plan, SecondaryModule)
+	if err != nil {
+		return nil, err
+	}
+	differences := Compare(primary, secondary)
+	differences = append(differences, checkPeering(plan, primary, secondary)...)
+	return differences, nil
+}
+
+// Compare returns the differences between two extracted regions, without the peering check that needs the plan.
+func Compare(primary, secondary Region) []Difference {
+	var differences []Difference
+	add := func(check, path string, p, s interface{}, message string) {
+		differences = append(differences, Difference{Check: check, Path: path, Primary: p, Secondary: s, Message: message})
+	}
+
+	if primary.KubernetesVersion != secondary.KubernetesVersion {
+		add("kubernetes_version", "aws_eks_cluster.main.version", primary.KubernetesVersion, secondary.KubernetesVersion, "")
+	}
+
+	for _, name := range unionKeys(primary.NodeGroups, secondary.NodeGroups) {
+		p, inPrimary := primary.NodeGroups[name]
+		s, inSecondary := secondary.NodeGroups[name]
+		switch {
+		case !inPrimary:
+			add("node_groups", name, nil, s, "node group only planned in the secondary")
+		case !inSecondary:
+			add("node_groups", name, p, nil, "node group only planned in the primary")
+		default:
+			if !reflect.DeepEqual(p.InstanceTypes, s.InstanceTypes) {
+				add("node_groups", name+".instance_types", p.InstanceTypes, s.InstanceTypes, "")
+			}
+			if p.CapacityType != s.CapacityType {
+				add("node_groups", name+".capacity_type", p.CapacityType, s.CapacityType, "")
+			}
+			if p.Version != s.Version {
+				add("node_groups", name+".version", p.Version, s.Version, "")
+			}
+		}
+	}
+
+	for _, name := range unionKeys(primary.Addons, secondary.Addons) {
+		p, inPrimary := primary.Addons[name]
+		s, inSecondary := secondary.Addons[name]
+		switch {
+		case !inPrimary:
+			add("addons", name, nil, s, "addon only planned in the secondary")
+		case !inSecondary:
+			add("addons", name, p, nil, "addon only planned in the primary")
+		case p != s:
+			add("addons", name+".addon_version", p, s, "")
+		}
+	}
+
+	for _, ou := range unionKeys(primary.OUAccess, secondary.OUAccess) {
+		p, inPrimary := primary.OUAccess[ou]
+		s, inSecondary := secondary.OUAccess[ou]
+		switch {
+		case !inPrimary:
+			add("ou_access", ou, nil, s, "access entry only planned in the secondary")
+		case !inSecondary:
+			add("ou_access", ou, p, nil, "access entry only planned in the primary")
+		default:
+			if p.PrincipalARN != s.PrincipalARN {
+				add("ou_access", ou+".principal_arn", p.PrincipalARN, s.PrincipalARN, "")
+			}
+			if p.PolicyARN != s.PolicyARN {
+				add("ou_access", ou+".policy_arn", p.PolicyARN, s.PolicyARN, "")
+			}
+		}
+	}
+
+	if !reflect.DeepEqual(primary.DBInstances, []string{"main"}) || !reflect.DeepEqual(secondary.DBInstances, []string{"replica"}) {
+		add("rds", "aws_db_instance", primary.DBInstances, secondary.DBInstances,
+			"want aws_db_instance.main only in the primary and aws_db_instance.replica only in the secondary")
+	}
+
+	return differences
+}
+
+// checkPeering verifies that the peering connection is requested from the primary VPC to the secondary VPC and
+// region, and that the accepter in the secondary region accepts that connection.
+func checkPeering(plan *planassert.Plan, primary, secondary Region) []Difference {
+	var differences []Difference
+	add := func(path, message string) {
+		differences = append(differences, Difference{
+			Check: "peering", Path: path, Primary: primary.VPCID, Secondary: secondary.VPCID, Message: message,
+		})
+	}
+
+	connection, ok := plan.Resource(PeeringConnection)
+	if !ok || connection.Destroyed() {
+		add(PeeringConnection, "peering connection is not planned")
+	} else {
+		if vpc := stringAttribute(connection, "vpc_id"); vpc != primary.VPCID {
+			add(PeeringConnection+".vpc_id", fmt.Sprintf("requester VPC is %s, want the primary VPC", vpc))
+		}
+		if vpc := stringAttribute(connection, "peer_vpc_id"); vpc != secondary.VPCID {
+			add(PeeringConnection+".peer_vpc_id", fmt.Sprintf("peer VPC is %s, want the secondary VPC", vpc))
+		}
+		if region := stringAttribute(connection, "peer_region"); region != secondary.Name {
+			add(PeeringConnection+".peer_region", fmt.Sprintf("peer region is %s, want the secondary region %s", region, secondary.Name))
+		}
+	}
+
+	accepter, ok := plan.Resource(PeeringAccepter)
+	if !ok || accepter.Destroyed() {
+		add(PeeringAccepter, "peering accepter is not planned")
+		return differences
+	}
+	config := rootConfig(plan, PeeringAccepter)
+	if config == nil {
+		add(PeeringAccepter, "plan has no configuration for the peering accepter")
+		return differences
+	}
+	if !strings.HasSuffix(config.ProviderConfigKey, "secondary") {
+		add(PeeringAccepter+".provider", fmt.Sprintf("accepter uses provider %s, want the secondary region's provider", config.ProviderConfigKey))
+	}
+	if !references(config, "vpc_peering_connection_id", PeeringConnection) {
+		add(PeeringAccepter+".vpc_peering_connection_id", "accepter does not reference "+PeeringConnection)
+	}
+	return differences
+}
+
+// rootConfig returns the configuration of a root module resource, nil when the plan carries no configuration.
+func rootConfig(plan *planassert.Plan, address string) *tfjson.ConfigResource {
+	raw := plan.Raw()
+	if raw == nil || raw.RawPlan.Config == nil || raw.RawPlan.Config.RootModule == nil {
+		return nil
+	}
+	for _, resource := range raw.RawPlan.Config.RootModule.Resources {
+		if resource.Address == address {
+			return resource
+		}
+	}
+	return nil
+}
+
+func references(resource *tfjson.ConfigResource, attribute, address string) bool {
+	expression, ok := resource.Expressions[attribute]
+	if !ok || expression == nil || expression.ExpressionData == nil {
+		return false
+	}
+	for _, reference := range expression.References {
+		if reference == address || strings.HasPrefix(reference, address+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Format renders the differences as a diff grouped by check, primary values prefixed with - and secondary values
+// with +. It returns the empty string when there are no differences.
+func Format(differences []Difference) string {
+	var b strings.Builder
+	for _, check := range Checks {
+		first := true
+		for _, d := range differences {
+			if d.Check != check {
+				continue
+			}
+			if first {
+				fmt.Fprintf(&b, "%s:\n", check)
+				first = false
+			}
+			fmt.Fprintf(&b, "  %s:\n", d.Path)
+			if d.Message != "" {
+				fmt.Fprintf(&b, "    # %s\n", d.Message)
+			}
+			fmt.Fprintf(&b, "    - primary:   %s\n", render(d.Primary))
+			fmt.Fprintf(&b, "    + secondary: %s\n", render(d.Secondary))
+		}
+	}
+	return b.String()
+}
+
+func render(value interface{}) string {
+	if value == nil {
+		return "<absent>"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// ofType returns the planned resources of the type anywhere below the module.
+func ofType(plan *planassert.Plan, module, resourceType string) []*planassert.Resource {
+	var out []*planassert.Resource
+	for _, resource := range plan.OfType(resourceType) {
+		if strings.HasPrefix(resource.Address, module+".") {
+			out = append(out, resource)
+		}
+	}
+	return out
+}
+
+func stringAttribute(resource *planassert.Resource, path string) string {
+	if resource.IsUnknown(path) {
+		return UnknownValue
+	}
+	value, _ := resource.Attribute(path)
+	s, _ := value.(string)
+	return s
+}
+
+func stringsAttribute(resource *planassert.Resource, path string) []string {
+	if resource.IsUnknown(path) {
+		return []string{UnknownValue}
+	}
+	value, _ := resource.Attribute(path)
+	list, _ := value.([]interface{})
+	out := make([]string, 0, len(list))
+	for _, element := range list {
+		out = append(out, fmt.Sprint(element))
+	}
+	return out
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func unionKeys[V any](a, b map[string]V) []string {
+	set := map[string]bool{}
+	for key := range a {
+		set[key] = true
+	}
+	for key := range b {
+		set[key] = true
+	}
+	return sortedKeys(set)
+}
@@ -0,0 +1,197 @@
+package crossregion
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// recordingT collects failures without failing the enclosing test.
+type recordingT struct {
+	failed bool
+}
+
+func (r *recordingT) Fail()                                  { r.failed = true }
+func (r *recordingT) FailNow()                               { r.failed = true }
+func (r *recordingT) Fatal(args ...interface{})              { r.failed = true }
+func (r *recordingT) Fatalf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Error(args ...interface{})              { r.failed = true }
+func (r *recordingT) Errorf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Name() string                           { return "recordingT" }
+
+type resource = map[string]interface{}
+
+func managed(address, resourceType, name string, index interface{}, values resource) resource {
+	r := resource{"address": address, "mode": "managed", "type": resourceType, "name": name, "values": values}
+	if index != nil {
+		r["index"] = index
+	}
+	return r
+}
+
+// regionResources returns the resources a region module plans with one general node group, two addons and one OU.
+func regionResources(module, region, vpc, db string) []resource {
+	eks := module + ".module.eks."
+	return []resource{
+		managed(eks+"aws_eks_cluster.main", "aws_eks_cluster", "main", nil,
+			resource{"version": "1.28", "tags": resource{"Region": region}}),
+		managed(eks+"aws_security_group.cluster", "aws_security_group", "cluster", nil, resource{"vpc_id": vpc}),
+		managed(eks+"aws_eks_addon.vpc_cni", "aws_eks_addon", "vpc_cni", nil, resource{"addon_name": "vpc-cni"}),
+		managed(eks+"aws_eks_addon.coredns", "aws_eks_addon", "coredns", nil, resource{"addon_name": "coredns"}),
+		managed(eks+`aws_eks_access_entry.ou_access["ou-test-001"]`, "aws_eks_access_entry", "ou_access", "ou-test-001",
+			resource{"principal_arn": "arn:aws:iam::123456789012:role/test-ou-eks-access-role"}),
+		managed(eks+`aws_eks_access_policy_association.ou_policies["ou-test-001"]`, "aws_eks_access_policy_association", "ou_policies", "ou-test-001",
+			resource{"policy_arn": "arn:aws:eks::aws:cluster-access-policy/AmazonEKSClusterAdminPolicy"}),
+		managed(module+`.module.node_groups.aws_eks_node_group.main["general"]`, "aws_eks_node_group", "main", "general",
+			resource{"instance_types": []string{"t3.large"}, "capacity_type": "ON_DEMAND", "version": "1.28"}),
+		managed(module+".module.node_groups.aws_security_group.node_group", "aws_security_group", "node_group", nil, resource{"vpc_id": vpc}),
+		managed(module+".module.rds[0].aws_db_instance."+db+"[0]", "aws_db_instance", db, 0, resource{}),
+	}
+}
+
+// samplePlan renders a symmetric root module plan, letting the test edit the resources of each region first.
+func samplePlan(t *testing.T, edit func(primary, secondary []resource, peering resource, config resource)) *planassert.Plan {
+	t.Helper()
+
+	primary := regionResources(PrimaryModule, "us-east-1", "vpc-primary", "main")
+	secondary := regionResources(SecondaryModule, "us-west-2", "vpc-secondary", "replica")
+	peering := managed(PeeringConnection, "aws_vpc_peering_connection", "primary_to_secondary", nil,
+		resource{"vpc_id": "vpc-primary", "peer_vpc_id": "vpc-secondary", "peer_region": "us-west-2"})
+	accepterConfig := resource{
+		"address": PeeringAccepter, "mode": "managed", "type": "aws_vpc_peering_connection_accepter", "name": "secondary",
+		"provider_config_key": "aws.secondary",
+		"expressions": resource{"vpc_peering_connection_id": resource{
+			"references": []string{PeeringConnection + ".id", PeeringConnection},
+		}},
+	}
+	if edit != nil {
+		edit(primary, secondary, peering, accepterConfig)
+	}
+
+	data, err := json.Marshal(resource{
+		"format_version": "1.2",
+		"planned_values": resource{"root_module": resource{
+			"resources": []resource{
+				peering,
+				managed(PeeringAccepter, "aws_vpc_peering_connection_accepter", "secondary", nil, resource{}),
+			},
+			"child_modules": []resource{
+				{"address": PrimaryModule, "resources": primary},
+				{"address": SecondaryModule, "resources": secondary},
+			},
+		}},
+		"configuration": resource{"root_module": resource{"resources": []resource{accepterConfig}}},
+	})
+	require.NoError(t, err)
+	plan, err := planassert.ParseJSON(data)
+	require.NoError(t, err)
+	return plan
+}
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+
+	region, err := Extract(samplePlan(t, nil), SecondaryModule)
+	require.NoError(t, err)
+	assert.Equal(t, Region{
+		Module:            SecondaryModule,
+		Name:              "us-west-2",
+		VPCID:             "vpc-secondary",
+		KubernetesVersion: "1.28",
+		NodeGroups:        map[string]NodeGroup{"general": {InstanceTypes: []string{"t3.large"}, CapacityType: "ON_DEMAND", Version: "1.28"}},
+		Addons:            map[string]string{"vpc-cni": "", "coredns": ""},
+		OUAccess: map[string]Access{"ou-test-001": {
+			PrincipalARN: "arn:aws:iam::123456789012:role/test-ou-eks-access-role",
+			PolicyARN:    "arn:aws:eks::aws:cluster-access-policy/AmazonEKSClusterAdminPolicy",
+		}},
+		DBInstances: []string{"replica"},
+	}, region)
+
+	_, err = Extract(samplePlan(t, nil), "module.tertiary_region")
+	assert.EqualError(t, err, "module.tertiary_region.module.eks.aws_eks_cluster.main is not planned")
+}
+
+func TestCheckSymmetricPlan(t *testing.T) {
+	t.Parallel()
+
+	plan := samplePlan(t, nil)
+	differences, err := Check(plan)
+	require.NoError(t, err)
+	assert.Empty(t, differences)
+	assert.True(t, AssertSymmetric(t, plan))
+}
+
+func TestCheckReportsAsymmetry(t *testing.T) {
+	t.Parallel()
+
+	plan := samplePlan(t, func(primary, secondary []resource, peering, config resource) {
+		secondary[0]["values"].(resource)["version"] = "1.29"
+		secondary[3]["values"].(resource)["addon_name"] = "kube-proxy"
+		secondary[5]["values"].(resource)["policy_arn"] = "arn:aws:eks::aws:cluster-access-policy/AmazonEKSViewPolicy"
+		secondary[6]["values"].(resource)["instance_types"] = []string{"m5.large"}
+		primary[8] = managed(PrimaryModule+".module.rds[0].aws_db_instance.replica[0]", "aws_db_instance", "replica", 0, resource{})
+		peering["values"].(resource)["peer_vpc_id"] = "vpc-primary"
+		config["provider_config_key"] = "aws.primary"
+		config["expressions"] = resource{}
+	})
+
+	differences, err := Check(plan)
+	require.NoError(t, err)
+
+	keys := make([]string, len(differences))
+	for i, d := range differences {
+		keys[i] = d.Check + " " + d.Path
+	}
+	assert.Equal(t, []string{
+		"kubernetes_version aws_eks_cluster.main.version",
+		"node_groups general.instance_types",
+		"addons coredns",
+		"addons kube-proxy",
+		"ou_access ou-test-001.policy_arn",
+		"rds aws_db_instance",
+		"peering " + PeeringConnection + ".peer_vpc_id",
+		"peering " + PeeringAccepter + ".provider",
+		"peering " + PeeringAccepter + ".vpc_peering_connection_id",
+	}, keys)
+
+	assert.Equal(t, Difference{
+		Check: "node_groups", Path: "general.instance_types", Primary: []string{"t3.large"}, Secondary: []string{"m5.large"},
+	}, differences[1])
+	assert.Equal(t, `addons: coredns: primary "", secondary <absent> (addon only planned in the primary)`, differences[2].String())
+	assert.Equal(t, []string{"replica"}, differences[5].Primary)
+
+	diff := Format(differences[:2])
+	assert.Equal(t, `kubernetes_version:
+  aws_eks_cluster.main.version:
+    - primary:   "1.28"
+    + secondary: "1.29"
+node_groups:
+  general.instance_types:
+    - primary:   ["t3.large"]
+    + secondary: ["m5.large"]
+`, diff)
+
+	mock := &recordingT{}
+	assert.False(t, AssertSymmetric(mock, plan))
+	assert.True(t, mock.failed)
+}
+
+func TestCheckRequiresBothRegions(t *testing.T) {
+	t.Parallel()
+
+	plan := samplePlan(t, func(primary, secondary []resource, peering, config resource) {
+		for _, r := range secondary {
+			r["address"] = "module.other" + r["address"].(string)[len(SecondaryModule):]
+		}
+	})
+	_, err := Check(plan)
+	assert.EqualError(t, err, "module.secondary_region.module.eks.aws_eks_cluster.main is not planned")
+
+	mock := &recordingT{}
+	assert.False(t, AssertSymmetric(mock, plan))
+	assert.True(t, mock.failed)
+}
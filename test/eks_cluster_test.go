@@ -5,178 +5,272 @@ import (
 
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/addons"
+	"github.com/your-org/multi-az-eks-cluster/test/harness"
+	"github.com/your-org/multi-az-eks-cluster/test/ouaccess"
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
 )
 
 func TestEKSClusterModule(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/eks-cluster",
-		Vars: map[string]interface{}{
-			"cluster_name":              "test-eks-cluster",
-			"kubernetes_version":        "1.28",
-			"vpc_id":                    "vpc-12345678",
-			"subnet_ids":                []string{"subnet-1", "subnet-2", "subnet-3"},
-			"control_plane_subnet_ids":  []string{"subnet-1", "subnet-2", "subnet-3"},
-			"environment":               "test",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"admin"},
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
+	runStages(t, fixture, func() *terraform.Options {
+		return terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+			TerraformDir: "../modules/eks-cluster",
+			Vars: map[string]interface{}{
+				"cluster_name":             "test-eks-cluster",
+				"kubernetes_version":       "1.28",
+				"vpc_id":                   fixture.VPC().ID,
+				"subnet_ids":               fixture.VPC().PrivateSubnetIDs,
+				"control_plane_subnet_ids": fixture.VPC().PrivateSubnetIDs,
+				"environment":              "test",
+				"organizational_units":     ouVars(t, tfvars.BaselineOU()),
+				"tags": map[string]string{
+					"Environment": "test",
 				},
 			},
-			"tags": map[string]string{
-				"Environment": "test",
-			},
-		},
-		PlanOnly: true,
-	})
+		})
+	}, func(plan *planassert.Plan) {
+		// Expected resources:
+		// - IAM Role for cluster
+		// - 2 IAM Role Policy Attachments
+		// - KMS Key + Alias
+		// - Security Group
+		// - Security Group Rule
+		// - CloudWatch Log Group
+		// - EKS Cluster
+		// - OIDC Provider
+		// - 4 EKS Addons
+		// - EKS Access Entry per OU
+		// - IAM Role per OU
+		// - EKS Access Policy Association per OU
+		planassert.AssertCounts(t, plan, 17, 0, 0)
+
+		planassert.AssertResourceCount(t, plan, "aws_eks_cluster.main", 1)
+		planassert.AssertResourceCount(t, plan, "aws_iam_role_policy_attachment.cluster_*", 2)
+		planassert.AssertResourceCount(t, plan, "aws_iam_openid_connect_provider.cluster", 1)
+		planassert.AssertTypeCount(t, plan, "aws_eks_addon", 4)
+		planassert.AssertResourceCount(t, plan, "aws_eks_access_entry.ou_access[*]", 1)
+		planassert.AssertResourceCount(t, plan, "aws_iam_role.ou_access[*]", 1)
+		planassert.AssertResourceCount(t, plan, "aws_eks_access_policy_association.ou_policies[*]", 1)
 
-	defer terraform.Destroy(t, terraformOptions)
-
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
-
-	// Expected resources:
-	// - IAM Role for cluster
-	// - 2 IAM Role Policy Attachments
-	// - KMS Key + Alias
-	// - Security Group
-	// - Security Group Rule
-	// - CloudWatch Log Group
-	// - EKS Cluster
-	// - OIDC Provider
-	// - 4 EKS Addons
-	// - EKS Access Entry per OU
-	// - IAM Role per OU
-	// - EKS Access Policy Association per OU
-
-	assert.Greater(t, resourceCounts.Add, 15, "Should create more than 15 resources")
+		planassert.AssertAttributeEquals(t, plan, "aws_eks_cluster.main", "name", "test-eks-cluster")
+		planassert.AssertAttributeEquals(t, plan, "aws_eks_cluster.main", "version", "1.28")
+		planassert.AssertAttributeEquals(t, plan, "aws_eks_cluster.main", "vpc_config.0.subnet_ids", fixture.VPC().PrivateSubnetIDs)
+		planassert.AssertAttributeEquals(t, plan, "aws_iam_role.ou_access[\"ou-test-001\"]", "name", "test-ou-eks-access-role")
+
+		// The API endpoint and the workstation HTTPS rule are both open to the world
+		assertPolicy(t, plan,
+			"eks-public-endpoint-open aws_eks_cluster.main",
+			"sg-rule-ingress-open aws_security_group_rule.cluster_ingress_workstation_https",
+		)
+		assertGolden(t, plan)
+	})
 }
 
 func TestEKSClusterEncryption(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/eks-cluster",
 		Vars: map[string]interface{}{
 			"cluster_name":             "test-eks-encrypted",
 			"kubernetes_version":       "1.28",
-			"vpc_id":                   "vpc-12345678",
-			"subnet_ids":               []string{"subnet-1", "subnet-2", "subnet-3"},
-			"control_plane_subnet_ids": []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":                   fixture.VPC().ID,
+			"subnet_ids":               fixture.VPC().PrivateSubnetIDs,
+			"control_plane_subnet_ids": fixture.VPC().PrivateSubnetIDs,
 			"environment":              "test",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"view"},
-				},
-			},
+			"organizational_units":     ouVars(t, tfvars.OU{Name: "test-ou", OUID: "ou-test-001", Permissions: []string{"view"}}),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should include KMS encryption")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	// Cluster secrets are encrypted with a dedicated, rotated KMS key
+	planassert.AssertResourceCount(t, plan, "aws_kms_key.eks", 1)
+	planassert.AssertResourceCount(t, plan, "aws_kms_alias.eks", 1)
+	planassert.AssertAttributeEquals(t, plan, "aws_kms_key.eks", "enable_key_rotation", true)
+	planassert.AssertAttributeEquals(t, plan, "aws_kms_key.eks", "deletion_window_in_days", 7)
+	planassert.AssertAttributeEquals(t, plan, "aws_kms_alias.eks", "name", "alias/test-eks-encrypted-eks")
+	planassert.AssertAttributeEquals(t, plan, "aws_eks_cluster.main", "encryption_config.0.resources", []string{"secrets"})
+	planassert.AssertAttributeUnknown(t, plan, "aws_eks_cluster.main", "encryption_config.0.provider.0.key_arn")
 }
 
 func TestEKSClusterAddons(t *testing.T) {
 	t.Parallel()
 
 	// Test with custom addon versions
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/eks-cluster",
 		Vars: map[string]interface{}{
 			"cluster_name":             "test-eks-addons",
 			"kubernetes_version":       "1.28",
-			"vpc_id":                   "vpc-12345678",
-			"subnet_ids":               []string{"subnet-1", "subnet-2", "subnet-3"},
-			"control_plane_subnet_ids": []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":                   fixture.VPC().ID,
+			"subnet_ids":               fixture.VPC().PrivateSubnetIDs,
+			"control_plane_subnet_ids": fixture.VPC().PrivateSubnetIDs,
 			"environment":              "test",
 			"vpc_cni_version":          "v1.15.0-eksbuild.1",
 			"coredns_version":          "v1.10.1-eksbuild.2",
 			"kube_proxy_version":       "v1.28.1-eksbuild.1",
 			"ebs_csi_driver_version":   "v1.25.0-eksbuild.1",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"deploy", "view"},
-				},
-			},
+			"organizational_units":     ouVars(t, tfvars.OU{Name: "test-ou", OUID: "ou-test-001", Permissions: []string{"deploy", "view"}}),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should succeed with custom addon versions")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	expectedVersions := map[string]string{
+		"aws_eks_addon.vpc_cni":        "v1.15.0-eksbuild.1",
+		"aws_eks_addon.coredns":        "v1.10.1-eksbuild.2",
+		"aws_eks_addon.kube_proxy":     "v1.28.1-eksbuild.1",
+		"aws_eks_addon.ebs_csi_driver": "v1.25.0-eksbuild.1",
+	}
+	planassert.AssertTypeCount(t, plan, "aws_eks_addon", len(expectedVersions))
+	for address, version := range expectedVersions {
+		planassert.AssertAttributeEquals(t, plan, address, "addon_version", version)
+		planassert.AssertAttributeEquals(t, plan, address, "resolve_conflicts_on_update", "OVERWRITE")
+	}
+	planassert.AssertAttributeEquals(t, plan, "aws_eks_addon.ebs_csi_driver", "addon_name", "aws-ebs-csi-driver")
+
+	matrix, err := addons.Default()
+	require.NoError(t, err)
+	addons.AssertCompatible(t, matrix, plan, "")
+}
+
+// addonOptions returns the options of an eks-cluster of the Kubernetes version with the addon builds, keyed by addon.
+func addonOptions(t *testing.T, fixture *harness.Fixture, kubernetesVersion string, builds map[string]string) *terraform.Options {
+	vars := map[string]interface{}{
+		"cluster_name":             "test-eks-addon-matrix",
+		"kubernetes_version":       kubernetesVersion,
+		"vpc_id":                   fixture.VPC().ID,
+		"subnet_ids":               fixture.VPC().PrivateSubnetIDs,
+		"control_plane_subnet_ids": fixture.VPC().PrivateSubnetIDs,
+		"environment":              "test",
+		"organizational_units":     ouVars(t, tfvars.OU{Name: "test-ou", OUID: "ou-test-001", Permissions: []string{"view"}}),
+	}
+	for addon, build := range builds {
+		vars[addon+"_version"] = build
+	}
+	return terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+		TerraformDir: "../modules/eks-cluster",
+		Vars:         vars,
+	})
+}
+
+func TestEKSClusterAddonMatrix(t *testing.T) {
+	t.Parallel()
+
+	// Plan a cluster of every Kubernetes version the modules are used with, given the newest build of each addon
+	matrix, err := addons.Default()
+	require.NoError(t, err)
+	require.Equal(t, []string{"1.27", "1.28", "1.29", "1.30", "1.31"}, matrix.Versions())
+
+	for _, version := range matrix.Versions() {
+		version := version
+		t.Run(version, func(t *testing.T) {
+			t.Parallel()
+
+			builds := map[string]string{}
+			for _, addon := range addons.Addons {
+				builds[addon], _ = matrix.Latest(version, addon)
+			}
+			fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+			plan := initAndPlan(t, fixture, addonOptions(t, fixture, version, builds))
+
+			planassert.AssertAttributeEquals(t, plan, "aws_eks_cluster.main", "version", version)
+			for addon, build := range builds {
+				planassert.AssertAttributeEquals(t, plan, "aws_eks_addon."+addon, "addon_version", build)
+			}
+			addons.AssertCompatible(t, matrix, plan, "")
+		})
+	}
+}
+
+func TestEKSClusterAddonMismatch(t *testing.T) {
+	t.Parallel()
+
+	// A kube-proxy build for 1.27 on a 1.28 cluster plans, and fails only in the EKS API
+	matrix, err := addons.Default()
+	require.NoError(t, err)
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+	plan := initAndPlan(t, fixture, addonOptions(t, fixture, "1.28", map[string]string{"kube_proxy": "v1.27.16-eksbuild.3"}))
+
+	findings := matrix.CheckPlan(plan, "")
+	require.Len(t, findings, 1)
+	assert.Equal(t, "aws_eks_addon.kube_proxy", findings[0].Address)
+	assert.Contains(t, findings[0].Message, "is supported on Kubernetes 1.27, not 1.28")
 }
 
 func TestEKSClusterMultipleOUs(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
+	ous := tfvars.OUs{
+		{Name: "ou-admin", OUID: "ou-admin-001", Permissions: []string{"admin"}},
+		{Name: "ou-dev", OUID: "ou-dev-001", Permissions: []string{"deploy", "view"}},
+		{Name: "ou-readonly", OUID: "ou-ro-001", Permissions: []string{"view"}},
+	}
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/eks-cluster",
 		Vars: map[string]interface{}{
 			"cluster_name":             "test-eks-multi-ou",
 			"kubernetes_version":       "1.28",
-			"vpc_id":                   "vpc-12345678",
-			"subnet_ids":               []string{"subnet-1", "subnet-2", "subnet-3"},
-			"control_plane_subnet_ids": []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":                   fixture.VPC().ID,
+			"subnet_ids":               fixture.VPC().PrivateSubnetIDs,
+			"control_plane_subnet_ids": fixture.VPC().PrivateSubnetIDs,
 			"environment":              "test",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "ou-admin",
-					"ou_id":       "ou-admin-001",
-					"permissions": []string{"admin"},
-				},
-				{
-					"name":        "ou-dev",
-					"ou_id":       "ou-dev-001",
-					"permissions": []string{"deploy", "view"},
-				},
-				{
-					"name":        "ou-readonly",
-					"ou_id":       "ou-ro-001",
-					"permissions": []string{"view"},
-				},
-			},
+			"organizational_units":     ouVars(t, ous...),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
+	plan := initAndPlan(t, fixture, terraformOptions)
 
-	// Should create resources for 3 OUs
-	assert.Greater(t, resourceCounts.Add, 20, "Should create resources for multiple OUs")
+	// 14 cluster resources plus an access entry, IAM role and policy association for each of the 3 OUs
+	planassert.AssertCounts(t, plan, 23, 0, 0)
+	planassert.AssertResourceCount(t, plan, "aws_eks_access_entry.ou_access[*]", 3)
+	planassert.AssertResourceCount(t, plan, "aws_iam_role.ou_access[*]", 3)
+	planassert.AssertResourceCount(t, plan, "aws_eks_access_policy_association.ou_policies[*]", 3)
+
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_role.ou_access[\"ou-dev-001\"]", "name", "ou-dev-eks-access-role")
+	planassert.AssertAttributeEquals(t, plan, "aws_eks_access_entry.ou_access[\"ou-ro-001\"]", "type", "STANDARD")
+
+	// ClusterAdmin, Edit and View in order of precedence
+	ouaccess.AssertPolicies(t, plan, "", ous)
 }
 
 func TestEKSClusterLogging(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/eks-cluster",
 		Vars: map[string]interface{}{
 			"cluster_name":             "test-eks-logging",
 			"kubernetes_version":       "1.28",
-			"vpc_id":                   "vpc-12345678",
-			"subnet_ids":               []string{"subnet-1", "subnet-2", "subnet-3"},
-			"control_plane_subnet_ids": []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":                   fixture.VPC().ID,
+			"subnet_ids":               fixture.VPC().PrivateSubnetIDs,
+			"control_plane_subnet_ids": fixture.VPC().PrivateSubnetIDs,
 			"environment":              "test",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"admin"},
-				},
-			},
+			"organizational_units":     ouVars(t, tfvars.BaselineOU()),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should include CloudWatch logging")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	planassert.AssertAttributeEquals(t, plan, "aws_cloudwatch_log_group.cluster", "name", "/aws/eks/test-eks-logging/cluster")
+	planassert.AssertAttributeEquals(t, plan, "aws_cloudwatch_log_group.cluster", "retention_in_days", 7)
+	planassert.AssertAttributeEquals(t, plan, "aws_eks_cluster.main", "enabled_cluster_log_types",
+		[]string{"api", "audit", "authenticator", "controllerManager", "scheduler"})
 }
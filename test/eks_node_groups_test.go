@@ -5,221 +5,269 @@ import (
 
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/capacity"
+	"github.com/your-org/multi-az-eks-cluster/test/harness"
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
 )
 
 func TestEKSNodeGroupsModule(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/eks-node-groups",
-		Vars: map[string]interface{}{
-			"cluster_name":                       "test-cluster",
-			"cluster_version":                    "1.28",
-			"vpc_id":                             "vpc-12345678",
-			"subnet_ids":                         []string{"subnet-1", "subnet-2", "subnet-3"},
-			"cluster_security_group_id":          "sg-cluster",
-			"cluster_primary_security_group_id":  "sg-primary",
-			"node_groups": map[string]interface{}{
-				"general": map[string]interface{}{
-					"desired_size":   6,
-					"min_size":       3,
-					"max_size":       15,
-					"instance_types": []string{"t3.large"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      50,
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
+	nodeGroups := tfvars.NodeGroups{"general": tfvars.BaselineNodeGroup()}
+	tfvars.Require(t, nodeGroups)
+
+	runStages(t, fixture, func() *terraform.Options {
+		return terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+			TerraformDir: "../modules/eks-node-groups",
+			Vars: map[string]interface{}{
+				"cluster_name":                      "test-cluster",
+				"cluster_version":                   "1.28",
+				"vpc_id":                            fixture.VPC().ID,
+				"subnet_ids":                        fixture.VPC().PrivateSubnetIDs,
+				"cluster_security_group_id":         "sg-cluster",
+				"cluster_primary_security_group_id": "sg-primary",
+				"node_groups":                       nodeGroups.Vars(),
+				"tags": map[string]string{
+					"Environment": "test",
 				},
 			},
-			"tags": map[string]string{
-				"Environment": "test",
-			},
-		},
-		PlanOnly: true,
-	})
+		})
+	}, func(plan *planassert.Plan) {
+		// Expected resources:
+		// - IAM Role
+		// - 5 IAM Role Policy Attachments
+		// - Security Group
+		// - 3 Security Group Rules
+		// - 1 Launch Template per node group
+		// - 1 EKS Node Group per node group
+		planassert.AssertCounts(t, plan, 12, 0, 0)
 
-	defer terraform.Destroy(t, terraformOptions)
+		planassert.AssertResourceCount(t, plan, "aws_iam_role.node_group", 1)
+		planassert.AssertTypeCount(t, plan, "aws_iam_role_policy_attachment", 5)
+		planassert.AssertResourceCount(t, plan, "aws_security_group.node_group", 1)
+		planassert.AssertTypeCount(t, plan, "aws_security_group_rule", 3)
+		planassert.AssertResourceCount(t, plan, "aws_launch_template.node_group[*]", 1)
+		planassert.AssertResourceCount(t, plan, "aws_eks_node_group.main[*]", 1)
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
+		const nodeGroup = "aws_eks_node_group.main[\"general\"]"
+		planassert.AssertAttributeEquals(t, plan, nodeGroup, "node_group_name", "test-cluster-general")
+		planassert.AssertAttributeEquals(t, plan, nodeGroup, "version", "1.28")
+		planassert.AssertAttributeEquals(t, plan, nodeGroup, "scaling_config", []map[string]interface{}{
+			{"desired_size": 6, "min_size": 3, "max_size": 15},
+		})
+		planassert.AssertAttributeEquals(t, plan, nodeGroup, "update_config.0.max_unavailable_percentage", 33)
+		planassert.AssertAttributeEquals(t, plan, nodeGroup, "labels", map[string]string{
+			"nodegroup": "general",
+			"capacity":  "ON_DEMAND",
+		})
 
-	// Expected resources:
-	// - IAM Role
-	// - 5 IAM Role Policy Attachments
-	// - Security Group
-	// - 3 Security Group Rules
-	// - 1 Launch Template per node group
-	// - 1 EKS Node Group per node group
-
-	assert.Greater(t, resourceCounts.Add, 10, "Should create more than 10 resources")
+		assertPolicy(t, plan)
+		assertGolden(t, plan)
+	})
 }
 
 func TestEKSNodeGroupsMultipleGroups(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
+	compute := tfvars.BaselineNodeGroup().WithSizes(3, 6, 18).WithInstanceTypes("c5.2xlarge")
+	compute.DiskSize = 100
+	nodeGroups := tfvars.NodeGroups{
+		"general": tfvars.BaselineNodeGroup().WithInstanceTypes("t3.large", "t3a.large"),
+		"spot":    tfvars.BaselineNodeGroup().WithCapacityType("SPOT").WithSizes(0, 3, 12).WithInstanceTypes("t3.large", "t3.xlarge"),
+		"compute": compute,
+	}
+	tfvars.Require(t, nodeGroups)
+
+	// Two general and two compute nodes per zone; the single spot node per zone is a whole zone to a rolling update
+	report, err := capacity.Simulate(capacity.Config{NodeGroups: nodeGroups, Subnets: privateSubnets(fixture.VPC())})
+	require.NoError(t, err)
+	assert.Equal(t, capacity.Capacity{Nodes: 5, VCPU: 22, MemoryGiB: 56, OnDemandVCPU: 20, OnDemandMemoryGiB: 48},
+		report.PerZone["us-east-1a"])
+	require.Len(t, report.Findings, 1)
+	assert.Equal(t, capacity.CheckRolloutDrainsZone+" spot", report.Findings[0].Check+" "+report.Findings[0].Group)
+	assert.Empty(t, report.CheckRequirement(capacity.Requirement{VCPU: 32, MemoryGiB: 96}), "on-demand capacity after losing a zone")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/eks-node-groups",
 		Vars: map[string]interface{}{
 			"cluster_name":                      "test-cluster-multi",
 			"cluster_version":                   "1.28",
-			"vpc_id":                            "vpc-12345678",
-			"subnet_ids":                        []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":                            fixture.VPC().ID,
+			"subnet_ids":                        fixture.VPC().PrivateSubnetIDs,
 			"cluster_security_group_id":         "sg-cluster",
 			"cluster_primary_security_group_id": "sg-primary",
-			"node_groups": map[string]interface{}{
-				"general": map[string]interface{}{
-					"desired_size":   6,
-					"min_size":       3,
-					"max_size":       15,
-					"instance_types": []string{"t3.large", "t3a.large"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      50,
-				},
-				"spot": map[string]interface{}{
-					"desired_size":   3,
-					"min_size":       0,
-					"max_size":       12,
-					"instance_types": []string{"t3.large", "t3.xlarge"},
-					"capacity_type":  "SPOT",
-					"disk_size":      50,
-				},
-				"compute": map[string]interface{}{
-					"desired_size":   6,
-					"min_size":       3,
-					"max_size":       18,
-					"instance_types": []string{"c5.2xlarge"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      100,
-				},
-			},
+			"node_groups":                       nodeGroups.Vars(),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	// 10 shared resources plus a launch template and node group for each of the 3 node groups
+	planassert.AssertCounts(t, plan, 16, 0, 0)
+	planassert.AssertResourceCount(t, plan, "aws_launch_template.node_group[*]", 3)
+	planassert.AssertResourceCount(t, plan, "aws_eks_node_group.main[*]", 3)
 
-	// Should create resources for 3 node groups
-	assert.Greater(t, resourceCounts.Add, 15, "Should create resources for multiple node groups")
+	planassert.AssertAttributeEquals(t, plan, "aws_eks_node_group.main[\"spot\"]", "capacity_type", "SPOT")
+	planassert.AssertAttributeEquals(t, plan, "aws_eks_node_group.main[\"spot\"]", "scaling_config.0.min_size", 0)
+	planassert.AssertAttributeEquals(t, plan, "aws_eks_node_group.main[\"compute\"]", "instance_types", []string{"c5.2xlarge"})
+	planassert.AssertAttributeEquals(t, plan, "aws_launch_template.node_group[\"compute\"]", "block_device_mappings.0.ebs.0.volume_size", 100)
+	planassert.AssertAllAttributesEqual(t, plan, "aws_eks_node_group.main[*]", "update_config.0.max_unavailable_percentage",
+		capacity.ModuleMaxUnavailablePercentage)
 }
 
 func TestEKSNodeGroupsSpotInstances(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
+	nodeGroups := tfvars.NodeGroups{
+		"spot-workers": tfvars.BaselineNodeGroup().WithCapacityType("SPOT").WithSizes(3, 9, 30).WithInstanceTypes("t3.large", "t3a.large", "t2.large"),
+	}
+	tfvars.Require(t, nodeGroups)
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/eks-node-groups",
 		Vars: map[string]interface{}{
 			"cluster_name":                      "test-cluster-spot",
 			"cluster_version":                   "1.28",
-			"vpc_id":                            "vpc-12345678",
-			"subnet_ids":                        []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":                            fixture.VPC().ID,
+			"subnet_ids":                        fixture.VPC().PrivateSubnetIDs,
 			"cluster_security_group_id":         "sg-cluster",
 			"cluster_primary_security_group_id": "sg-primary",
-			"node_groups": map[string]interface{}{
-				"spot-workers": map[string]interface{}{
-					"desired_size":   9,
-					"min_size":       3,
-					"max_size":       30,
-					"instance_types": []string{"t3.large", "t3a.large", "t2.large"},
-					"capacity_type":  "SPOT",
-					"disk_size":      50,
-				},
-			},
+			"node_groups":                       nodeGroups.Vars(),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should succeed for SPOT instances")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	const nodeGroup = "aws_eks_node_group.main[\"spot-workers\"]"
+	planassert.AssertResourceCount(t, plan, "aws_eks_node_group.main[*]", 1)
+	planassert.AssertAttributeEquals(t, plan, nodeGroup, "capacity_type", "SPOT")
+	planassert.AssertAttributeEquals(t, plan, nodeGroup, "instance_types", []string{"t3.large", "t3a.large", "t2.large"})
+	planassert.AssertAttributeEquals(t, plan, nodeGroup, "labels.capacity", "SPOT")
 }
 
 func TestEKSNodeGroupsLaunchTemplate(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
+	custom := tfvars.BaselineNodeGroup().WithInstanceTypes("m5.xlarge")
+	custom.DiskSize = 100
+	nodeGroups := tfvars.NodeGroups{"custom": custom}
+	tfvars.Require(t, nodeGroups)
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/eks-node-groups",
 		Vars: map[string]interface{}{
 			"cluster_name":                      "test-cluster-lt",
 			"cluster_version":                   "1.28",
-			"vpc_id":                            "vpc-12345678",
-			"subnet_ids":                        []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":                            fixture.VPC().ID,
+			"subnet_ids":                        fixture.VPC().PrivateSubnetIDs,
 			"cluster_security_group_id":         "sg-cluster",
 			"cluster_primary_security_group_id": "sg-primary",
-			"node_groups": map[string]interface{}{
-				"custom": map[string]interface{}{
-					"desired_size":   6,
-					"min_size":       3,
-					"max_size":       15,
-					"instance_types": []string{"m5.xlarge"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      100,
-				},
-			},
+			"node_groups":                       nodeGroups.Vars(),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should include launch template with custom disk size")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	const launchTemplate = "aws_launch_template.node_group[\"custom\"]"
+	planassert.AssertResourceCount(t, plan, "aws_launch_template.node_group[*]", 1)
+	planassert.AssertAttributeEquals(t, plan, launchTemplate, "name_prefix", "test-cluster-lt-custom-")
+	planassert.AssertAttributeEquals(t, plan, launchTemplate, "block_device_mappings.0.device_name", "/dev/xvda")
+	planassert.AssertAttributeEquals(t, plan, launchTemplate, "block_device_mappings.0.ebs.0.volume_size", 100)
+	planassert.AssertAttributeEquals(t, plan, launchTemplate, "block_device_mappings.0.ebs.0.volume_type", "gp3")
+	planassert.AssertAttributeEquals(t, plan, launchTemplate, "block_device_mappings.0.ebs.0.encrypted", "true")
+	planassert.AssertAttributeEquals(t, plan, launchTemplate, "metadata_options.0.http_tokens", "required")
+	planassert.AssertAttributeEquals(t, plan, launchTemplate, "network_interfaces.0.associate_public_ip_address", "false")
+
+	assertGolden(t, plan)
 }
 
 func TestEKSNodeGroupsSecurityGroups(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
+	nodeGroups := tfvars.NodeGroups{"workers": tfvars.BaselineNodeGroup().WithInstanceTypes("t3.medium")}
+	tfvars.Require(t, nodeGroups)
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/eks-node-groups",
 		Vars: map[string]interface{}{
 			"cluster_name":                      "test-cluster-sg",
 			"cluster_version":                   "1.28",
-			"vpc_id":                            "vpc-12345678",
-			"subnet_ids":                        []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":                            fixture.VPC().ID,
+			"subnet_ids":                        fixture.VPC().PrivateSubnetIDs,
 			"cluster_security_group_id":         "sg-cluster-123",
 			"cluster_primary_security_group_id": "sg-primary-456",
-			"node_groups": map[string]interface{}{
-				"workers": map[string]interface{}{
-					"desired_size":   6,
-					"min_size":       3,
-					"max_size":       15,
-					"instance_types": []string{"t3.medium"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      50,
-				},
-			},
+			"node_groups":                       nodeGroups.Vars(),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should configure security groups correctly")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	planassert.AssertTypeCount(t, plan, "aws_security_group", 1)
+	planassert.AssertTypeCount(t, plan, "aws_security_group_rule", 3)
+
+	planassert.AssertAttributeEquals(t, plan, "aws_security_group_rule.node_ingress_cluster", "source_security_group_id", "sg-cluster-123")
+	planassert.AssertAttributeEquals(t, plan, "aws_security_group_rule.node_ingress_cluster", "from_port", 1025)
+	planassert.AssertAttributeEquals(t, plan, "aws_security_group_rule.cluster_ingress_node_https", "security_group_id", "sg-cluster-123")
+	planassert.AssertAttributeEquals(t, plan, "aws_security_group_rule.cluster_ingress_node_https", "from_port", 443)
+	planassert.AssertAttributeEquals(t, plan, "aws_security_group_rule.node_ingress_self", "protocol", "-1")
+	planassert.AssertAttributeEquals(t, plan, "aws_security_group.node_group", "tags", map[string]string{
+		"Name":                                  "test-cluster-sg-node-group-sg",
+		"kubernetes.io/cluster/test-cluster-sg": "owned",
+	})
 }
 
 func TestEKSNodeGroupsIAMRoles(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
+	workers := tfvars.BaselineNodeGroup().WithSizes(1, 3, 9).WithInstanceTypes("t3.small")
+	workers.DiskSize = 30
+	nodeGroups := tfvars.NodeGroups{"workers": workers}
+	tfvars.Require(t, nodeGroups)
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/eks-node-groups",
 		Vars: map[string]interface{}{
 			"cluster_name":                      "test-cluster-iam",
 			"cluster_version":                   "1.28",
-			"vpc_id":                            "vpc-12345678",
-			"subnet_ids":                        []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":                            fixture.VPC().ID,
+			"subnet_ids":                        fixture.VPC().PrivateSubnetIDs,
 			"cluster_security_group_id":         "sg-cluster",
 			"cluster_primary_security_group_id": "sg-primary",
-			"node_groups": map[string]interface{}{
-				"workers": map[string]interface{}{
-					"desired_size":   3,
-					"min_size":       1,
-					"max_size":       9,
-					"instance_types": []string{"t3.small"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      30,
-				},
-			},
+			"node_groups":                       nodeGroups.Vars(),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
+	plan := initAndPlan(t, fixture, terraformOptions)
 
 	// Should include IAM role and policy attachments
-	assert.Greater(t, resourceCounts.Add, 8, "Should create IAM resources for node groups")
+	planassert.AssertResourceCount(t, plan, "aws_iam_role.node_group", 1)
+	planassert.AssertTypeCount(t, plan, "aws_iam_role_policy_attachment", 5)
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_role.node_group", "name", "test-cluster-iam-node-group-role")
+
+	expectedPolicies := map[string]string{
+		"aws_iam_role_policy_attachment.node_group_policy":         "arn:aws:iam::aws:policy/AmazonEKSWorkerNodePolicy",
+		"aws_iam_role_policy_attachment.node_group_cni_policy":     "arn:aws:iam::aws:policy/AmazonEKS_CNI_Policy",
+		"aws_iam_role_policy_attachment.node_group_ecr_policy":     "arn:aws:iam::aws:policy/AmazonEC2ContainerRegistryReadOnly",
+		"aws_iam_role_policy_attachment.node_group_ssm_policy":     "arn:aws:iam::aws:policy/AmazonSSMManagedInstanceCore",
+		"aws_iam_role_policy_attachment.node_group_ebs_csi_policy": "arn:aws:iam::aws:policy/service-role/AmazonEBSCSIDriverPolicy",
+	}
+	for address, policyARN := range expectedPolicies {
+		planassert.AssertAttributeEquals(t, plan, address, "policy_arn", policyARN)
+		planassert.AssertAttributeEquals(t, plan, address, "role", "test-cluster-iam-node-group-role")
+	}
 }
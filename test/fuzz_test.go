@@ -0,0 +1,267 @@
+package test
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/harness"
+	"github.com/your-org/multi-az-eks-cluster/test/ouaccess"
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
+	"github.com/your-org/multi-az-eks-cluster/test/vpclayout"
+)
+
+// The fuzz targets decode the fuzzer's bytes into random but type-valid module inputs and check properties of the
+// plan that must hold for every input. go test runs the seed corpus in testdata/fuzz; go test -fuzz=FuzzX explores
+// further, and minimizes a failing input before writing it to testdata/fuzz/FuzzX.
+
+// fuzzInstanceTypes are the instance types node groups are drawn from.
+var fuzzInstanceTypes = []string{"t3.medium", "t3.large", "m5.xlarge"}
+
+// fuzzVPCBases are the private ranges VPC CIDRs are drawn from.
+var fuzzVPCBases = []netip.Prefix{
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+}
+
+// fuzzInput decodes module inputs from fuzzer bytes. Reading past the end yields zero, and zero decodes to the smallest
+// choice: no OUs, the lowest sizes, the first option. Minimizing a failing input by dropping and lowering bytes
+// therefore also shrinks the decoded configuration to a minimal reproduction.
+type fuzzInput struct {
+	data []byte
+}
+
+func (in *fuzzInput) next() byte {
+	if len(in.data) == 0 {
+		return 0
+	}
+	b := in.data[0]
+	in.data = in.data[1:]
+	return b
+}
+
+// intn returns a number in [0, n).
+func (in *fuzzInput) intn(n int) int {
+	return int(in.next()) % n
+}
+
+// subset returns a non-empty subset of options, which must have fewer than 8 elements.
+func (in *fuzzInput) subset(options []string) []string {
+	mask := 1 + in.intn(1<<len(options)-1)
+	var out []string
+	for i, option := range options {
+		if mask&(1<<i) != 0 {
+			out = append(out, option)
+		}
+	}
+	return out
+}
+
+// vpcCIDR returns a network in one of fuzzVPCBases with a prefix length between /16 and maxBits.
+func (in *fuzzInput) vpcCIDR(maxBits int) netip.Prefix {
+	base := fuzzVPCBases[in.intn(len(fuzzVPCBases))]
+	bits := 16 + in.intn(maxBits-15)
+
+	host := uint32(in.next())<<16 | uint32(in.next())<<8 | uint32(in.next())
+	network := binary.BigEndian.Uint32(base.Addr().AsSlice())
+	network |= host &^ (^uint32(0) << (32 - base.Bits()))
+
+	var addr [4]byte
+	binary.BigEndian.PutUint32(addr[:], network)
+	return netip.PrefixFrom(netip.AddrFrom4(addr), bits).Masked()
+}
+
+// ous returns up to 10 OUs, each with a random non-empty subset of the permissions.
+func (in *fuzzInput) ous() tfvars.OUs {
+	ous := make(tfvars.OUs, in.intn(11))
+	for i := range ous {
+		ous[i] = tfvars.OU{
+			Name:        fmt.Sprintf("ou-%d", i),
+			OUID:        fmt.Sprintf("ou-fuzz-%03d", i),
+			Permissions: in.subset(tfvars.Permissions),
+		}
+	}
+	return ous
+}
+
+// nodeGroups returns one to three node groups. The sizes are drawn independently, so min_size <= desired_size <=
+// max_size is violated often enough to exercise the module's validation.
+func (in *fuzzInput) nodeGroups() tfvars.NodeGroups {
+	groups := tfvars.NodeGroups{}
+	for i, n := 0, 1+in.intn(3); i < n; i++ {
+		groups[fmt.Sprintf("ng%d", i)] = tfvars.NodeGroup{
+			MinSize:       in.intn(16),
+			DesiredSize:   in.intn(16),
+			MaxSize:       1 + in.intn(15),
+			CapacityType:  tfvars.CapacityTypes[in.intn(len(tfvars.CapacityTypes))],
+			InstanceTypes: in.subset(fuzzInstanceTypes),
+			DiskSize:      20 + in.intn(100),
+		}
+	}
+	return groups
+}
+
+// logReproduction logs the decoded input when the test fails, so a minimized failure reads as a configuration rather
+// than as bytes.
+func logReproduction(t *testing.T, input interface{}) {
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("reproduction: %+v", input)
+		}
+	})
+}
+
+// planE plans terraformOptions against the fixture and returns the plan, or the error of a plan that failed.
+func planE(t *testing.T, fixture *harness.Fixture, terraformOptions *terraform.Options) (*planassert.Plan, error) {
+	t.Helper()
+
+	isolate(t, terraformOptions, t.TempDir())
+	withFixture(t, fixture, terraformOptions)
+	terraformOptions.PlanFilePath = filepath.Join(t.TempDir(), "plan.out")
+
+	plan, err := terraform.InitAndPlanAndShowWithStructE(t, terraformOptions)
+	if err != nil {
+		return nil, err
+	}
+	return planassert.New(plan), nil
+}
+
+// FuzzVPCModule checks that for every VPC CIDR the subnet layout fits, the planned subnets are the ones the cidrsubnet
+// locals compute, and they are disjoint, inside the VPC and sized for their tier.
+func FuzzVPCModule(f *testing.F) {
+	allocations, err := vpclayout.ParseAllocations("../modules/vpc/main.tf")
+	require.NoError(f, err)
+	maxBits := vpclayout.MaxSubnetPrefix
+	for _, allocation := range allocations {
+		maxBits = min(maxBits, vpclayout.MaxSubnetPrefix-allocation.NewBits)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		in := &fuzzInput{data: data}
+		vpc := in.vpcCIDR(maxBits)
+		logReproduction(t, vpc)
+
+		expected, errs := vpclayout.Compute(vpc, allocations)
+		require.Empty(t, errs)
+		report := vpclayout.Verify(vpc, expected, vpclayout.ExpectedBits(vpc.Bits(), allocations))
+		require.NoError(t, report.Err())
+
+		fixture := harness.NewFixture("us-east-1")
+		plan, err := planE(t, fixture, &terraform.Options{
+			TerraformDir: "../modules/vpc",
+			Vars: map[string]interface{}{
+				"region":             fixture.Region,
+				"vpc_cidr":           vpc.String(),
+				"availability_zones": []string{"us-east-1a", "us-east-1b", "us-east-1c"},
+				"cluster_name":       "fuzz-cluster",
+				"environment":        "test",
+			},
+		})
+		require.NoError(t, err)
+
+		planassert.AssertCounts(t, plan, 36, 0, 0)
+		subnets, err := vpclayout.FromPlan(plan, "")
+		require.NoError(t, err)
+		assert.ElementsMatch(t, expected, subnets)
+	})
+}
+
+// FuzzEKSClusterOUs checks that every OU gets exactly one access entry, IAM role and access policy association, so the
+// number of planned resources grows linearly with the number of OUs, and that each association grants the policy its
+// permissions call for.
+func FuzzEKSClusterOUs(f *testing.F) {
+	// Resources the cluster plans regardless of its OUs, and the number each OU adds
+	const clusterResources, perOU = 14, 3
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		ous := (&fuzzInput{data: data}).ous()
+		logReproduction(t, ous)
+		require.NoError(t, ous.Validate())
+
+		fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+		plan, err := planE(t, fixture, &terraform.Options{
+			TerraformDir: "../modules/eks-cluster",
+			Vars: map[string]interface{}{
+				"cluster_name":             "fuzz-cluster",
+				"kubernetes_version":       "1.28",
+				"vpc_id":                   fixture.VPC().ID,
+				"subnet_ids":               fixture.VPC().PrivateSubnetIDs,
+				"control_plane_subnet_ids": fixture.VPC().PrivateSubnetIDs,
+				"environment":              "test",
+				"organizational_units":     ous.Vars(),
+			},
+		})
+		require.NoError(t, err)
+
+		planassert.AssertCounts(t, plan, clusterResources+perOU*len(ous), 0, 0)
+		planassert.AssertResourceCount(t, plan, "aws_eks_access_entry.ou_access[*]", len(ous))
+		planassert.AssertResourceCount(t, plan, "aws_iam_role.ou_access[*]", len(ous))
+		planassert.AssertResourceCount(t, plan, "aws_eks_access_policy_association.ou_policies[*]", len(ous))
+		for _, ou := range ous {
+			planassert.AssertResourceCount(t, plan, fmt.Sprintf("aws_eks_access_entry.ou_access[%q]", ou.OUID), 1)
+		}
+		ouaccess.AssertPolicies(t, plan, "", ous)
+	})
+}
+
+// FuzzEKSNodeGroups checks that min_size <= desired_size <= max_size is enforced, by both the tfvars builders and the
+// module's validation, and that every valid node group is planned with the requested scaling config.
+func FuzzEKSNodeGroups(f *testing.F) {
+	// Resources the module plans regardless of its node groups, and the number each node group adds
+	const sharedResources, perGroup = 10, 2
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		groups := (&fuzzInput{data: data}).nodeGroups()
+		logReproduction(t, groups)
+
+		ordered := true
+		for name, group := range groups {
+			inOrder := group.MinSize <= group.DesiredSize && group.DesiredSize <= group.MaxSize
+			err := group.Validate()
+			if inOrder {
+				assert.NoError(t, err, name)
+			} else if assert.Error(t, err, name) {
+				assert.Contains(t, err.Error(), "desired_size", name)
+			}
+			ordered = ordered && inOrder
+		}
+
+		fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+		plan, err := planE(t, fixture, &terraform.Options{
+			TerraformDir: "../modules/eks-node-groups",
+			Vars: map[string]interface{}{
+				"cluster_name":                      "fuzz-cluster",
+				"cluster_version":                   "1.28",
+				"vpc_id":                            fixture.VPC().ID,
+				"subnet_ids":                        fixture.VPC().PrivateSubnetIDs,
+				"cluster_security_group_id":         "sg-cluster",
+				"cluster_primary_security_group_id": "sg-primary",
+				"node_groups":                       groups.Vars(),
+			},
+		})
+		if !ordered {
+			require.Error(t, err, "plan must reject node groups outside min_size <= desired_size <= max_size")
+			assert.Contains(t, err.Error(), "min_size <= desired_size <= max_size")
+			return
+		}
+		require.NoError(t, err)
+
+		planassert.AssertCounts(t, plan, sharedResources+perGroup*len(groups), 0, 0)
+		for name, group := range groups {
+			nodeGroup := fmt.Sprintf("aws_eks_node_group.main[%q]", name)
+			planassert.AssertAttributeEquals(t, plan, nodeGroup, "scaling_config", []map[string]interface{}{
+				{"desired_size": group.DesiredSize, "min_size": group.MinSize, "max_size": group.MaxSize},
+			})
+			planassert.AssertAttributeEquals(t, plan, nodeGroup, "capacity_type", group.CapacityType)
+			planassert.AssertAttributeEquals(t, plan, nodeGroup, "instance_types", group.InstanceTypes)
+		}
+	})
+}
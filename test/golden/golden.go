@@ -0,0 +1,236 @@
+// Package golden snapshots a normalized view of a Terraform plan to a JSON file and compares later plans against it,
+// so a change to any planned attribute, such as a launch template or a parameter group setting, shows up as a readable
+// diff rather than going unnoticed by resource counts.
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// Placeholders replace values that differ between runs or must not be written to disk.
+const (
+	Unknown   = "(known after apply)"
+	Sensitive = "(sensitive)"
+	Timestamp = "(timestamp)"
+)
+
+// Dir is where golden files are kept, relative to the test package directory.
+var Dir = filepath.Join("testdata", "golden")
+
+// timestampPattern matches RFC 3339 timestamps, with or without fractional seconds.
+var timestampPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})$`)
+
+// View is the normalized view of a plan that is written to a golden file.
+type View struct {
+	Resources []ResourceView         `json:"resources"`
+	Outputs   map[string]interface{} `json:"outputs,omitempty"`
+}
+
+// ResourceView is a planned managed resource with its actions and attribute values. Attributes only known after apply
+// are shown as Unknown, sensitive ones as Sensitive and timestamps as Timestamp.
+type ResourceView struct {
+	Address string                 `json:"address"`
+	Type    string                 `json:"type"`
+	Actions []string               `json:"actions,omitempty"`
+	Values  map[string]interface{} `json:"values"`
+}
+
+// Snapshot builds the normalized view of the plan: every planned managed resource in address order, and the root
+// module outputs.
+func Snapshot(plan *planassert.Plan) *View {
+	view := &View{Resources: []ResourceView{}}
+	changes := plan.Raw().ResourceChangesMap
+
+	for _, resource := range plan.Managed() {
+		var sensitive interface{}
+		if change, ok := changes[resource.Address]; ok && change.Change != nil {
+			sensitive = change.Change.AfterSensitive
+		}
+		values, _ := mask(resource.Values, resource.Unknown, sensitive).(map[string]interface{})
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+
+		actions := make([]string, len(resource.Actions))
+		for i, action := range resource.Actions {
+			actions[i] = string(action)
+		}
+		view.Resources = append(view.Resources, ResourceView{
+			Address: resource.Address,
+			Type:    resource.Type,
+			Actions: actions,
+			Values:  values,
+		})
+	}
+
+	for name, change := range plan.Raw().RawPlan.OutputChanges {
+		if change == nil {
+			continue
+		}
+		if view.Outputs == nil {
+			view.Outputs = map[string]interface{}{}
+		}
+		view.Outputs[name] = mask(change.After, change.AfterUnknown, change.AfterSensitive)
+	}
+	return view
+}
+
+// mask merges the after_unknown and after_sensitive structures of a change into its planned values.
+func mask(value, unknown, sensitive interface{}) interface{} {
+	if isSensitive, ok := sensitive.(bool); ok && isSensitive {
+		return Sensitive
+	}
+	if isUnknown, ok := unknown.(bool); ok && isUnknown {
+		return Unknown
+	}
+
+	switch node := value.(type) {
+	case map[string]interface{}:
+		unknownMap, _ := unknown.(map[string]interface{})
+		sensitiveMap, _ := sensitive.(map[string]interface{})
+		out := make(map[string]interface{}, len(node))
+		for key, child := range node {
+			out[key] = mask(child, unknownMap[key], sensitiveMap[key])
+		}
+		// Unknown attributes are absent from the planned values altogether
+		for key, child := range unknownMap {
+			if _, ok := out[key]; !ok {
+				if masked := mask(nil, child, sensitiveMap[key]); masked != nil {
+					out[key] = masked
+				}
+			}
+		}
+		return out
+	case []interface{}:
+		unknownList, _ := unknown.([]interface{})
+		sensitiveList, _ := sensitive.([]interface{})
+		out := make([]interface{}, len(node))
+		for i, child := range node {
+			out[i] = mask(child, at(unknownList, i), at(sensitiveList, i))
+		}
+		return out
+	case string:
+		if timestampPattern.MatchString(node) {
+			return Timestamp
+		}
+		return node
+	case nil:
+		// Nested unknowns under an absent block, e.g. {"tags_all": {"Name": true}}, only matter if a leaf is unknown
+		if _, ok := unknown.(map[string]interface{}); ok {
+			if masked := mask(map[string]interface{}{}, unknown, sensitive).(map[string]interface{}); len(masked) > 0 {
+				return masked
+			}
+		}
+		return nil
+	default:
+		return node
+	}
+}
+
+func at(list []interface{}, i int) interface{} {
+	if i < len(list) {
+		return list[i]
+	}
+	return nil
+}
+
+// Marshal renders the view as indented JSON with sorted keys and a trailing newline.
+func (v *View) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Path returns the golden file for the named test; subtest separators become underscores.
+func Path(testName string) string {
+	return filepath.Join(Dir, strings.ReplaceAll(testName, "/", "_")+".json")
+}
+
+// Assert compares the snapshot of the plan with the golden file of the test, failing with a unified diff if they
+// differ. With update, which the test package's -update flag sets, the golden file is written instead. A missing
+// golden file is a failure otherwise, so a plan is never passed without being compared.
+func Assert(t testing.TestingT, plan *planassert.Plan, update bool) bool {
+	actual, err := Snapshot(plan).Marshal()
+	if err != nil {
+		t.Errorf("rendering plan snapshot: %v", err)
+		return false
+	}
+
+	path := Path(t.Name())
+	expected, err := os.ReadFile(path)
+	missing := errors.Is(err, fs.ErrNotExist)
+	if err != nil && !missing {
+		t.Errorf("reading golden file: %v", err)
+		return false
+	}
+
+	if update {
+		if err := write(path, actual); err != nil {
+			t.Errorf("writing golden file: %v", err)
+			return false
+		}
+		if missing {
+			logf(t, "wrote new golden file %s", path)
+		}
+		return true
+	}
+	if missing {
+		t.Errorf("golden file %s does not exist; run `go test -run '^%s$' -update` and commit it", path, t.Name())
+		return false
+	}
+
+	if bytes.Equal(expected, actual) {
+		return true
+	}
+	t.Errorf("plan differs from golden file %s (run with -update to accept):\n%s", path, Diff(expected, actual, path))
+	return false
+}
+
+// Diff renders a unified diff between the golden file contents and the new snapshot.
+func Diff(expected, actual []byte, path string) string {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(expected)),
+		B:        difflib.SplitLines(string(actual)),
+		FromFile: path,
+		ToFile:   "planned",
+		Context:  3,
+	})
+	if err != nil {
+		return fmt.Sprintf("computing diff: %v", err)
+	}
+	return diff
+}
+
+func write(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// logf logs through the test when it supports logging.
+func logf(t testing.TestingT, format string, args ...interface{}) {
+	if logger, ok := t.(interface {
+		Logf(format string, args ...interface{})
+	}); ok {
+		logger.Logf(format, args...)
+	}
+}
@@ -0,0 +1,124 @@
+package golden
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+const samplePlan = `{
+  "format_version": "1.2",
+  "planned_values": {
+    "root_module": {
+      "resources": [
+        {"address": "aws_launch_template.main[\"general\"]", "mode": "managed", "type": "aws_launch_template", "name": "main", "index": "general",
+         "values": {"name_prefix": "cluster-general-", "block_device_mappings": [{"device_name": "/dev/xvda", "ebs": [{"volume_size": %d}]}]}},
+        {"address": "random_password.master[0]", "mode": "managed", "type": "random_password", "name": "master", "index": 0,
+         "values": {"length": 32, "special": true}},
+        {"address": "time_static.created", "mode": "managed", "type": "time_static", "name": "created", "values": {"rfc3339": "2024-01-02T03:04:05Z"}}
+      ]
+    }
+  },
+  "resource_changes": [
+    {"address": "aws_launch_template.main[\"general\"]", "mode": "managed", "type": "aws_launch_template", "name": "main", "index": "general",
+     "change": {"actions": ["create"], "after_unknown": {"id": true, "arn": true, "tags_all": {}, "block_device_mappings": [{"ebs": [{"kms_key_id": true}]}]}}},
+    {"address": "random_password.master[0]", "mode": "managed", "type": "random_password", "name": "master", "index": 0,
+     "change": {"actions": ["create"], "after_unknown": {"result": true}, "after_sensitive": {"result": true}}},
+    {"address": "time_static.created", "mode": "managed", "type": "time_static", "name": "created", "change": {"actions": ["create"]}}
+  ],
+  "output_changes": {
+    "template_name": {"actions": ["create"], "after": "cluster-general-", "after_unknown": false},
+    "password": {"actions": ["create"], "after_unknown": true, "after_sensitive": true}
+  }
+}`
+
+func parseSample(t *testing.T, volumeSize int) *planassert.Plan {
+	t.Helper()
+
+	plan, err := planassert.ParseJSON([]byte(fmt.Sprintf(samplePlan, volumeSize)))
+	require.NoError(t, err)
+	return plan
+}
+
+// recordingT collects failures and logs without failing the enclosing test.
+type recordingT struct {
+	name   string
+	errors []string
+	logs   []string
+}
+
+func (r *recordingT) Fail()                     { r.errors = append(r.errors, "fail") }
+func (r *recordingT) FailNow()                  { r.errors = append(r.errors, "fail") }
+func (r *recordingT) Fatal(args ...interface{}) { r.errors = append(r.errors, fmt.Sprint(args...)) }
+func (r *recordingT) Fatalf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+func (r *recordingT) Error(args ...interface{}) { r.errors = append(r.errors, fmt.Sprint(args...)) }
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+func (r *recordingT) Logf(format string, args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+func (r *recordingT) Name() string { return r.name }
+
+func TestSnapshotMasksUnknownSensitiveAndTimestamps(t *testing.T) {
+	t.Parallel()
+
+	view := Snapshot(parseSample(t, 50))
+	require.Len(t, view.Resources, 3)
+
+	template := view.Resources[0]
+	assert.Equal(t, `aws_launch_template.main["general"]`, template.Address)
+	assert.Equal(t, []string{"create"}, template.Actions)
+	assert.Equal(t, Unknown, template.Values["id"])
+	assert.NotContains(t, template.Values, "tags_all", "blocks without unknown leaves are left out")
+	assert.Equal(t, map[string]interface{}{"kms_key_id": Unknown, "volume_size": float64(50)},
+		template.Values["block_device_mappings"].([]interface{})[0].(map[string]interface{})["ebs"].([]interface{})[0])
+
+	assert.Equal(t, Sensitive, view.Resources[1].Values["result"])
+	assert.Equal(t, Timestamp, view.Resources[2].Values["rfc3339"])
+
+	assert.Equal(t, map[string]interface{}{"template_name": "cluster-general-", "password": Sensitive}, view.Outputs)
+}
+
+func TestAssert(t *testing.T) {
+	dir := t.TempDir()
+	previousDir := Dir
+	Dir = dir
+	defer func() { Dir = previousDir }()
+
+	// Without update a missing golden file is an error rather than silently recorded
+	missing := &recordingT{name: "TestLaunchTemplate/general"}
+	assert.False(t, Assert(missing, parseSample(t, 50), false))
+	require.Len(t, missing.errors, 1)
+	assert.Contains(t, missing.errors[0], "-update")
+	path := filepath.Join(dir, "TestLaunchTemplate_general.json")
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	// Updating records the snapshot
+	first := &recordingT{name: "TestLaunchTemplate/general"}
+	assert.True(t, Assert(first, parseSample(t, 50), true))
+	assert.Empty(t, first.errors)
+	require.Len(t, first.logs, 1)
+	assert.FileExists(t, path)
+
+	// An identical plan matches
+	same := &recordingT{name: "TestLaunchTemplate/general"}
+	assert.True(t, Assert(same, parseSample(t, 50), false))
+	assert.Empty(t, same.errors)
+
+	// A changed attribute fails with a diff naming it
+	changed := &recordingT{name: "TestLaunchTemplate/general"}
+	assert.False(t, Assert(changed, parseSample(t, 100), false))
+	require.Len(t, changed.errors, 1)
+	assert.Contains(t, changed.errors[0], "-                \"volume_size\": 50\n")
+	assert.Contains(t, changed.errors[0], "+                \"volume_size\": 100\n")
+}
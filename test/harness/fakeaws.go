@@ -0,0 +1,548 @@
+package harness
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	ec2Namespace = "http://ec2.amazonaws.com/doc/2016-11-15/"
+	stsNamespace = "https://sts.amazonaws.com/doc/2011-06-15/"
+	requestID    = "00000000-0000-0000-0000-000000000000"
+)
+
+// FakeAWS is a local stand-in for the parts of the EC2 and STS query APIs that the modules read at plan time:
+// sts:GetCallerIdentity for the provider and data.aws_caller_identity, and the EC2 Describe calls behind
+// data.aws_vpc and data.aws_subnets. Every other action is answered with an error and recorded, so a plan that
+// starts depending on a new API fails loudly instead of reaching out to AWS.
+type FakeAWS struct {
+	fixture *Fixture
+	server  *httptest.Server
+
+	mu          sync.Mutex
+	calls       []string
+	unsupported []string
+}
+
+// StartFakeAWS starts a fake AWS API serving the fixture. Call Close when done.
+func StartFakeAWS(fixture *Fixture) *FakeAWS {
+	fake := &FakeAWS{fixture: fixture}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.handle))
+	return fake
+}
+
+// URL returns the endpoint of the fake.
+func (f *FakeAWS) URL() string {
+	return f.server.URL
+}
+
+// Close shuts the fake down.
+func (f *FakeAWS) Close() {
+	f.server.Close()
+}
+
+// Env returns the environment variables that point the AWS provider at the fake with static dummy credentials.
+func (f *FakeAWS) Env() map[string]string {
+	return map[string]string{
+		"AWS_ENDPOINT_URL":          f.server.URL,
+		"AWS_ENDPOINT_URL_EC2":      f.server.URL,
+		"AWS_ENDPOINT_URL_IAM":      f.server.URL,
+		"AWS_ENDPOINT_URL_STS":      f.server.URL,
+		"AWS_ACCESS_KEY_ID":         "AKIAOFFLINETESTING00",
+		"AWS_SECRET_ACCESS_KEY":     "offline-testing-secret",
+		"AWS_SESSION_TOKEN":         "",
+		"AWS_PROFILE":               "",
+		"AWS_REGION":                f.fixture.Region,
+		"AWS_DEFAULT_REGION":        f.fixture.Region,
+		"AWS_EC2_METADATA_DISABLED": "true",
+	}
+}
+
+// Calls returns the actions served so far, in order.
+func (f *FakeAWS) Calls() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// Unsupported returns the service and action of every request the fake could not answer.
+func (f *FakeAWS) Unsupported() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.unsupported...)
+}
+
+func (f *FakeAWS) handle(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	action := r.Form.Get("Action")
+	service := signingService(r)
+
+	f.mu.Lock()
+	f.calls = append(f.calls, action)
+	f.mu.Unlock()
+
+	var (
+		body interface{}
+		err  error
+	)
+	switch action {
+	case "GetCallerIdentity":
+		body = f.getCallerIdentity()
+	case "DescribeVpcs":
+		body, err = f.describeVpcs(r.Form)
+	case "DescribeVpcAttribute":
+		body, err = f.describeVpcAttribute(r.Form)
+	case "DescribeRouteTables":
+		body, err = f.describeRouteTables(r.Form)
+	case "DescribeSubnets":
+		body, err = f.describeSubnets(r.Form)
+	default:
+		f.mu.Lock()
+		f.unsupported = append(f.unsupported, service+":"+action)
+		f.mu.Unlock()
+		err = &apiError{Code: "UnsupportedOperation", Message: fmt.Sprintf("the offline fake does not implement %s:%s", service, action)}
+	}
+
+	if err != nil {
+		writeError(w, service, err)
+		return
+	}
+	writeXML(w, http.StatusOK, body)
+}
+
+// signingService extracts the service name from the SigV4 credential scope, e.g. "sts" from
+// "Credential=AKID/20240101/us-east-1/sts/aws4_request".
+func signingService(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	i := strings.Index(auth, "Credential=")
+	if i < 0 {
+		return "ec2"
+	}
+	scope := strings.SplitN(auth[i+len("Credential="):], ",", 2)[0]
+	parts := strings.Split(scope, "/")
+	if len(parts) < 4 {
+		return "ec2"
+	}
+	return parts[3]
+}
+
+type apiError struct {
+	Code    string
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func writeError(w http.ResponseWriter, service string, err error) {
+	apiErr, ok := err.(*apiError)
+	if !ok {
+		apiErr = &apiError{Code: "InternalError", Message: err.Error()}
+	}
+	if service == "sts" || service == "iam" {
+		writeXML(w, http.StatusBadRequest, queryErrorResponse{
+			Error:     queryError{Type: "Sender", Code: apiErr.Code, Message: apiErr.Message},
+			RequestID: requestID,
+		})
+		return
+	}
+	writeXML(w, http.StatusBadRequest, ec2ErrorResponse{
+		Errors:    []ec2Error{{Code: apiErr.Code, Message: apiErr.Message}},
+		RequestID: requestID,
+	})
+}
+
+func writeXML(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(body)
+}
+
+func (f *FakeAWS) getCallerIdentity() interface{} {
+	return getCallerIdentityResponse{
+		Xmlns: stsNamespace,
+		Result: callerIdentity{
+			Arn:     f.fixture.CallerARN(),
+			UserID:  "AIDAOFFLINETESTING00",
+			Account: f.fixture.AccountID,
+		},
+		RequestID: requestID,
+	}
+}
+
+func (f *FakeAWS) describeVpcs(form url.Values) (interface{}, error) {
+	ids := indexedValues(form, "VpcId")
+	filters, err := parseFilters(form, "vpc-id", "cidr", "state")
+	if err != nil {
+		return nil, err
+	}
+
+	response := describeVpcsResponse{Xmlns: ec2Namespace, RequestID: requestID}
+	for _, vpc := range f.fixture.VPCs {
+		if len(ids) > 0 && !contains(ids, vpc.ID) {
+			continue
+		}
+		if !filters.match(map[string]string{"vpc-id": vpc.ID, "cidr": vpc.CIDR, "state": "available"}) {
+			continue
+		}
+		response.Vpcs = append(response.Vpcs, vpcItem{
+			VpcID:           vpc.ID,
+			OwnerID:         f.fixture.AccountID,
+			State:           "available",
+			CidrBlock:       vpc.CIDR,
+			DhcpOptionsID:   "dopt-offline",
+			InstanceTenancy: "default",
+			IsDefault:       false,
+			CidrBlockAssociations: []cidrBlockAssociation{{
+				AssociationID: "vpc-cidr-assoc-" + strings.TrimPrefix(vpc.ID, "vpc-"),
+				CidrBlock:     vpc.CIDR,
+				State:         cidrBlockState{State: "associated"},
+			}},
+			Tags: []tag{{Key: "Name", Value: vpc.ID}},
+		})
+	}
+	if len(ids) > 0 && len(response.Vpcs) == 0 {
+		return nil, &apiError{Code: "InvalidVpcID.NotFound", Message: fmt.Sprintf("The vpc ID '%s' does not exist", strings.Join(ids, ", "))}
+	}
+	return response, nil
+}
+
+func (f *FakeAWS) describeVpcAttribute(form url.Values) (interface{}, error) {
+	id := form.Get("VpcId")
+	if _, ok := f.fixture.FindVPC(id); !ok {
+		return nil, &apiError{Code: "InvalidVpcID.NotFound", Message: fmt.Sprintf("The vpc ID '%s' does not exist", id)}
+	}
+
+	response := describeVpcAttributeResponse{Xmlns: ec2Namespace, RequestID: requestID, VpcID: id}
+	switch attribute := form.Get("Attribute"); attribute {
+	case "enableDnsSupport":
+		response.EnableDNSSupport = &attributeBoolean{Value: true}
+	case "enableDnsHostnames":
+		response.EnableDNSHostnames = &attributeBoolean{Value: true}
+	case "enableNetworkAddressUsageMetrics":
+		response.EnableNetworkAddressUsageMetrics = &attributeBoolean{Value: false}
+	default:
+		return nil, &apiError{Code: "InvalidParameterValue", Message: fmt.Sprintf("unsupported VPC attribute %q", attribute)}
+	}
+	return response, nil
+}
+
+func (f *FakeAWS) describeRouteTables(form url.Values) (interface{}, error) {
+	filters, err := parseFilters(form, "vpc-id", "association.main")
+	if err != nil {
+		return nil, err
+	}
+
+	response := describeRouteTablesResponse{Xmlns: ec2Namespace, RequestID: requestID}
+	for _, vpc := range f.fixture.VPCs {
+		if !filters.match(map[string]string{"vpc-id": vpc.ID, "association.main": "true"}) {
+			continue
+		}
+		id := "rtb-" + strings.TrimPrefix(vpc.ID, "vpc-") + "-main"
+		response.RouteTables = append(response.RouteTables, routeTableItem{
+			RouteTableID: id,
+			VpcID:        vpc.ID,
+			OwnerID:      f.fixture.AccountID,
+			Associations: []routeTableAssociation{{
+				ID:           "rtbassoc-" + strings.TrimPrefix(vpc.ID, "vpc-"),
+				RouteTableID: id,
+				Main:         true,
+				State:        associationState{State: "associated"},
+			}},
+			Routes: []route{{
+				DestinationCidrBlock: vpc.CIDR,
+				GatewayID:            "local",
+				Origin:               "CreateRouteTable",
+				State:                "active",
+			}},
+		})
+	}
+	return response, nil
+}
+
+func (f *FakeAWS) describeSubnets(form url.Values) (interface{}, error) {
+	ids := indexedValues(form, "SubnetId")
+	filters, err := parseFilters(form, "vpc-id", "tag:Type", "availability-zone", "subnet-id")
+	if err != nil {
+		return nil, err
+	}
+
+	response := describeSubnetsResponse{Xmlns: ec2Namespace, RequestID: requestID}
+	for _, vpc := range f.fixture.VPCs {
+		for _, subnet := range f.subnets(vpc) {
+			if len(ids) > 0 && !contains(ids, subnet.SubnetID) {
+				continue
+			}
+			attributes := map[string]string{
+				"vpc-id":            subnet.VpcID,
+				"availability-zone": subnet.AvailabilityZone,
+				"subnet-id":         subnet.SubnetID,
+			}
+			for _, t := range subnet.Tags {
+				attributes["tag:"+t.Key] = t.Value
+			}
+			if filters.match(attributes) {
+				response.Subnets = append(response.Subnets, subnet)
+			}
+		}
+	}
+	return response, nil
+}
+
+// subnets returns the private and database subnets of the VPC, laid out the way the vpc module would lay them out.
+func (f *FakeAWS) subnets(vpc VPC) []subnetItem {
+	var out []subnetItem
+	add := func(id, kind, zone, cidr string) {
+		out = append(out, subnetItem{
+			SubnetID:                id,
+			SubnetArn:               fmt.Sprintf("arn:aws:ec2:%s:%s:subnet/%s", f.fixture.Region, f.fixture.AccountID, id),
+			VpcID:                   vpc.ID,
+			OwnerID:                 f.fixture.AccountID,
+			State:                   "available",
+			CidrBlock:               cidr,
+			AvailabilityZone:        zone,
+			AvailableIPAddressCount: 250,
+			Tags:                    []tag{{Key: "Name", Value: id}, {Key: "Type", Value: kind}},
+		})
+	}
+	for i, id := range vpc.PrivateSubnetIDs {
+		add(id, "private", zoneAt(vpc, i), subnetCIDR(vpc.CIDR, 32*i, 19))
+	}
+	for i, id := range vpc.DatabaseSubnetIDs {
+		add(id, "database", zoneAt(vpc, i), subnetCIDR(vpc.CIDR, 144+8*i, 21))
+	}
+	return out
+}
+
+func zoneAt(vpc VPC, i int) string {
+	if len(vpc.AvailabilityZones) == 0 {
+		return ""
+	}
+	return vpc.AvailabilityZones[i%len(vpc.AvailabilityZones)]
+}
+
+// subnetCIDR returns the subnet of the /16 VPC CIDR starting at the given third octet.
+func subnetCIDR(vpcCIDR string, thirdOctet int, bits int) string {
+	ip, _, err := net.ParseCIDR(vpcCIDR)
+	if err != nil || ip.To4() == nil {
+		return ""
+	}
+	v4 := ip.To4()
+	return fmt.Sprintf("%d.%d.%d.0/%d", v4[0], v4[1], thirdOctet%256, bits)
+}
+
+// filterSet is the parsed Filter.N.Name / Filter.N.Value.M parameters of an EC2 Describe call.
+type filterSet map[string][]string
+
+// parseFilters parses the filters of the request, rejecting any filter name the fake does not understand rather than
+// silently ignoring it.
+func parseFilters(form url.Values, supported ...string) (filterSet, error) {
+	filters := filterSet{}
+	for n := 1; ; n++ {
+		name := form.Get(fmt.Sprintf("Filter.%d.Name", n))
+		if name == "" {
+			break
+		}
+		if !contains(supported, name) {
+			return nil, &apiError{Code: "InvalidParameterValue", Message: fmt.Sprintf("the offline fake does not support filter %q", name)}
+		}
+		filters[name] = append(filters[name], indexedValues(form, fmt.Sprintf("Filter.%d.Value", n))...)
+	}
+	return filters, nil
+}
+
+// match reports whether the attributes satisfy every filter: each filter must match one of its values.
+func (f filterSet) match(attributes map[string]string) bool {
+	for name, values := range f {
+		value, ok := attributes[name]
+		if !ok || !contains(values, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// indexedValues returns the values of a query list parameter such as VpcId.1, VpcId.2.
+func indexedValues(form url.Values, prefix string) []string {
+	type indexed struct {
+		n     int
+		value string
+	}
+	var items []indexed
+	for key, values := range form {
+		if !strings.HasPrefix(key, prefix+".") || len(values) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(key, prefix+"."))
+		if err != nil {
+			continue
+		}
+		items = append(items, indexed{n: n, value: values[0]})
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].n < items[j].n })
+	out := make([]string, len(items))
+	for i, item := range items {
+		out[i] = item.value
+	}
+	return out
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// XML shapes of the query protocol responses. Only the fields the provider reads are modelled.
+
+type tag struct {
+	Key   string `xml:"key"`
+	Value string `xml:"value"`
+}
+
+type getCallerIdentityResponse struct {
+	XMLName   xml.Name       `xml:"GetCallerIdentityResponse"`
+	Xmlns     string         `xml:"xmlns,attr"`
+	Result    callerIdentity `xml:"GetCallerIdentityResult"`
+	RequestID string         `xml:"ResponseMetadata>RequestId"`
+}
+
+type callerIdentity struct {
+	Arn     string `xml:"Arn"`
+	UserID  string `xml:"UserId"`
+	Account string `xml:"Account"`
+}
+
+type describeVpcsResponse struct {
+	XMLName   xml.Name  `xml:"DescribeVpcsResponse"`
+	Xmlns     string    `xml:"xmlns,attr"`
+	RequestID string    `xml:"requestId"`
+	Vpcs      []vpcItem `xml:"vpcSet>item"`
+}
+
+type vpcItem struct {
+	VpcID                 string                 `xml:"vpcId"`
+	OwnerID               string                 `xml:"ownerId"`
+	State                 string                 `xml:"state"`
+	CidrBlock             string                 `xml:"cidrBlock"`
+	CidrBlockAssociations []cidrBlockAssociation `xml:"cidrBlockAssociationSet>item"`
+	DhcpOptionsID         string                 `xml:"dhcpOptionsId"`
+	InstanceTenancy       string                 `xml:"instanceTenancy"`
+	IsDefault             bool                   `xml:"isDefault"`
+	Tags                  []tag                  `xml:"tagSet>item"`
+}
+
+type cidrBlockAssociation struct {
+	AssociationID string         `xml:"associationId"`
+	CidrBlock     string         `xml:"cidrBlock"`
+	State         cidrBlockState `xml:"cidrBlockState"`
+}
+
+type cidrBlockState struct {
+	State string `xml:"state"`
+}
+
+type describeVpcAttributeResponse struct {
+	XMLName                          xml.Name          `xml:"DescribeVpcAttributeResponse"`
+	Xmlns                            string            `xml:"xmlns,attr"`
+	RequestID                        string            `xml:"requestId"`
+	VpcID                            string            `xml:"vpcId"`
+	EnableDNSSupport                 *attributeBoolean `xml:"enableDnsSupport,omitempty"`
+	EnableDNSHostnames               *attributeBoolean `xml:"enableDnsHostnames,omitempty"`
+	EnableNetworkAddressUsageMetrics *attributeBoolean `xml:"enableNetworkAddressUsageMetrics,omitempty"`
+}
+
+type attributeBoolean struct {
+	Value bool `xml:"value"`
+}
+
+type describeRouteTablesResponse struct {
+	XMLName     xml.Name         `xml:"DescribeRouteTablesResponse"`
+	Xmlns       string           `xml:"xmlns,attr"`
+	RequestID   string           `xml:"requestId"`
+	RouteTables []routeTableItem `xml:"routeTableSet>item"`
+}
+
+type routeTableItem struct {
+	RouteTableID string                  `xml:"routeTableId"`
+	VpcID        string                  `xml:"vpcId"`
+	OwnerID      string                  `xml:"ownerId"`
+	Routes       []route                 `xml:"routeSet>item"`
+	Associations []routeTableAssociation `xml:"associationSet>item"`
+}
+
+type route struct {
+	DestinationCidrBlock string `xml:"destinationCidrBlock"`
+	GatewayID            string `xml:"gatewayId"`
+	State                string `xml:"state"`
+	Origin               string `xml:"origin"`
+}
+
+type routeTableAssociation struct {
+	ID           string           `xml:"routeTableAssociationId"`
+	RouteTableID string           `xml:"routeTableId"`
+	Main         bool             `xml:"main"`
+	State        associationState `xml:"associationState"`
+}
+
+type associationState struct {
+	State string `xml:"state"`
+}
+
+type describeSubnetsResponse struct {
+	XMLName   xml.Name     `xml:"DescribeSubnetsResponse"`
+	Xmlns     string       `xml:"xmlns,attr"`
+	RequestID string       `xml:"requestId"`
+	Subnets   []subnetItem `xml:"subnetSet>item"`
+}
+
+type subnetItem struct {
+	SubnetID                string `xml:"subnetId"`
+	SubnetArn               string `xml:"subnetArn"`
+	VpcID                   string `xml:"vpcId"`
+	OwnerID                 string `xml:"ownerId"`
+	State                   string `xml:"state"`
+	CidrBlock               string `xml:"cidrBlock"`
+	AvailabilityZone        string `xml:"availabilityZone"`
+	AvailableIPAddressCount int    `xml:"availableIpAddressCount"`
+	Tags                    []tag  `xml:"tagSet>item"`
+}
+
+type ec2ErrorResponse struct {
+	XMLName   xml.Name   `xml:"Response"`
+	Errors    []ec2Error `xml:"Errors>Error"`
+	RequestID string     `xml:"RequestID"`
+}
+
+type ec2Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+type queryErrorResponse struct {
+	XMLName   xml.Name   `xml:"ErrorResponse"`
+	Error     queryError `xml:"Error"`
+	RequestID string     `xml:"RequestId"`
+}
+
+type queryError struct {
+	Type    string `xml:"Type"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
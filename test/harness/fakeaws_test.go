@@ -0,0 +1,133 @@
+package harness
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSession(t *testing.T, fake *FakeAWS, region string) *session.Session {
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String(region),
+		Endpoint:    aws.String(fake.URL()),
+		Credentials: credentials.NewStaticCredentials("AKIAOFFLINETESTING00", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	})
+	require.NoError(t, err)
+	return sess
+}
+
+func TestFakeAWSCallerIdentity(t *testing.T) {
+	t.Parallel()
+
+	fixture := NewFixture("eu-west-1")
+	fixture.AccountID = "210987654321"
+	fake := StartFakeAWS(fixture)
+	defer fake.Close()
+
+	identity, err := sts.New(newSession(t, fake, "eu-west-1")).GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	require.NoError(t, err)
+	assert.Equal(t, "210987654321", aws.StringValue(identity.Account))
+	assert.Equal(t, "arn:aws:iam::210987654321:user/terratest", aws.StringValue(identity.Arn))
+}
+
+func TestFakeAWSVPC(t *testing.T) {
+	t.Parallel()
+
+	fixture := NewFixture("us-east-1", "vpc-12345678", "vpc-87654321")
+	fake := StartFakeAWS(fixture)
+	defer fake.Close()
+	client := ec2.New(newSession(t, fake, "us-east-1"))
+
+	vpcs, err := client.DescribeVpcs(&ec2.DescribeVpcsInput{VpcIds: aws.StringSlice([]string{"vpc-87654321"})})
+	require.NoError(t, err)
+	require.Len(t, vpcs.Vpcs, 1)
+	assert.Equal(t, "vpc-87654321", aws.StringValue(vpcs.Vpcs[0].VpcId))
+	assert.Equal(t, "10.1.0.0/16", aws.StringValue(vpcs.Vpcs[0].CidrBlock))
+	assert.Equal(t, fixture.AccountID, aws.StringValue(vpcs.Vpcs[0].OwnerId))
+
+	attribute, err := client.DescribeVpcAttribute(&ec2.DescribeVpcAttributeInput{
+		VpcId:     aws.String("vpc-12345678"),
+		Attribute: aws.String(ec2.VpcAttributeNameEnableDnsHostnames),
+	})
+	require.NoError(t, err)
+	assert.True(t, aws.BoolValue(attribute.EnableDnsHostnames.Value))
+
+	tables, err := client.DescribeRouteTables(&ec2.DescribeRouteTablesInput{Filters: []*ec2.Filter{
+		{Name: aws.String("association.main"), Values: aws.StringSlice([]string{"true"})},
+		{Name: aws.String("vpc-id"), Values: aws.StringSlice([]string{"vpc-12345678"})},
+	}})
+	require.NoError(t, err)
+	require.Len(t, tables.RouteTables, 1)
+	assert.Equal(t, "rtb-12345678-main", aws.StringValue(tables.RouteTables[0].RouteTableId))
+	assert.True(t, aws.BoolValue(tables.RouteTables[0].Associations[0].Main))
+
+	_, err = client.DescribeVpcs(&ec2.DescribeVpcsInput{VpcIds: aws.StringSlice([]string{"vpc-missing"})})
+	require.Error(t, err)
+	assert.Equal(t, "InvalidVpcID.NotFound", err.(awserr.Error).Code())
+}
+
+func TestFakeAWSSubnets(t *testing.T) {
+	t.Parallel()
+
+	fixture := NewFixture("us-west-2", "vpc-12345678", "vpc-87654321")
+	fake := StartFakeAWS(fixture)
+	defer fake.Close()
+	client := ec2.New(newSession(t, fake, "us-west-2"))
+
+	subnets, err := client.DescribeSubnets(&ec2.DescribeSubnetsInput{Filters: []*ec2.Filter{
+		{Name: aws.String("vpc-id"), Values: aws.StringSlice([]string{"vpc-87654321"})},
+		{Name: aws.String("tag:Type"), Values: aws.StringSlice([]string{"database"})},
+	}})
+	require.NoError(t, err)
+
+	var ids, zones, cidrs []string
+	for _, subnet := range subnets.Subnets {
+		ids = append(ids, aws.StringValue(subnet.SubnetId))
+		zones = append(zones, aws.StringValue(subnet.AvailabilityZone))
+		cidrs = append(cidrs, aws.StringValue(subnet.CidrBlock))
+	}
+	assert.Equal(t, fixture.VPCs[1].DatabaseSubnetIDs, ids)
+	assert.Equal(t, []string{"us-west-2a", "us-west-2b", "us-west-2c"}, zones)
+	assert.Equal(t, []string{"10.1.144.0/21", "10.1.152.0/21", "10.1.160.0/21"}, cidrs)
+
+	_, err = client.DescribeSubnets(&ec2.DescribeSubnetsInput{Filters: []*ec2.Filter{
+		{Name: aws.String("map-public-ip-on-launch"), Values: aws.StringSlice([]string{"true"})},
+	}})
+	require.Error(t, err, "unknown filters must not be ignored")
+	assert.Equal(t, "InvalidParameterValue", err.(awserr.Error).Code())
+}
+
+func TestFakeAWSUnsupported(t *testing.T) {
+	t.Parallel()
+
+	fake := StartFakeAWS(NewFixture("us-east-1"))
+	defer fake.Close()
+
+	_, err := iam.New(newSession(t, fake, "us-east-1")).GetUser(&iam.GetUserInput{})
+	require.Error(t, err)
+	assert.Equal(t, "UnsupportedOperation", err.(awserr.Error).Code())
+	assert.Equal(t, []string{"iam:GetUser"}, fake.Unsupported())
+	assert.Equal(t, []string{"GetUser"}, fake.Calls())
+}
+
+func TestFixture(t *testing.T) {
+	t.Parallel()
+
+	fixture := NewFixture("us-east-1", "vpc-12345678")
+
+	assert.Equal(t, "arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/EXAMPLED539D4633E53DE1B716D3041E", fixture.OIDCProviderARN())
+	assert.Equal(t, "arn:aws:rds:us-east-1:123456789012:db:test-db", fixture.ARN("rds", "db:test-db"))
+	assert.Equal(t, []string{"subnet-12345678-private-a", "subnet-12345678-private-b", "subnet-12345678-private-c"}, fixture.VPC().PrivateSubnetIDs)
+
+	_, ok := fixture.FindVPC("vpc-other")
+	assert.False(t, ok)
+}
@@ -0,0 +1,99 @@
+// Package harness provides the stand-ins that let the module tests plan without AWS credentials or network access:
+// a per-test Fixture describing the account and network the plan should see, and a fake AWS API that serves it. Its
+// run modes decide whether a test goes on to deploy what it planned, and whether it destroys it again.
+package harness
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultAccountID is the AWS account ID used by fixtures unless a test overrides it.
+const DefaultAccountID = "123456789012"
+
+// Fixture holds the stub values a test plans against. The fake AWS API answers data source reads from it, and tests
+// use the same values for the variables they pass in, so expectations never drift from what the fake returns.
+type Fixture struct {
+	// AccountID is returned by sts:GetCallerIdentity and used in generated ARNs.
+	AccountID string
+
+	// Region is the default region of the provider.
+	Region string
+
+	// OIDCIssuer is the issuer URL of the EKS cluster OIDC provider, e.g.
+	// https://oidc.eks.us-east-1.amazonaws.com/id/EXAMPLED539D4633E53DE1B716D3041E.
+	OIDCIssuer string
+
+	// VPCs are the existing VPCs that data.aws_vpc and data.aws_subnets can read.
+	VPCs []VPC
+}
+
+// VPC is an existing VPC together with the subnets the regional-eks module looks up by their Type tag.
+type VPC struct {
+	ID                string
+	CIDR              string
+	AvailabilityZones []string
+	PrivateSubnetIDs  []string
+	DatabaseSubnetIDs []string
+}
+
+// NewFixture returns a fixture for the region with one VPC per ID. Each VPC gets a distinct /16, three availability
+// zones and one private and one database subnet per zone, with subnet IDs derived from the VPC ID.
+func NewFixture(region string, vpcIDs ...string) *Fixture {
+	fixture := &Fixture{
+		AccountID:  DefaultAccountID,
+		Region:     region,
+		OIDCIssuer: fmt.Sprintf("https://oidc.eks.%s.amazonaws.com/id/EXAMPLED539D4633E53DE1B716D3041E", region),
+	}
+	for i, id := range vpcIDs {
+		fixture.VPCs = append(fixture.VPCs, newVPC(region, id, i))
+	}
+	return fixture
+}
+
+func newVPC(region, id string, n int) VPC {
+	suffix := strings.TrimPrefix(id, "vpc-")
+	vpc := VPC{
+		ID:   id,
+		CIDR: fmt.Sprintf("10.%d.0.0/16", n),
+	}
+	for _, zone := range []string{"a", "b", "c"} {
+		vpc.AvailabilityZones = append(vpc.AvailabilityZones, region+zone)
+		vpc.PrivateSubnetIDs = append(vpc.PrivateSubnetIDs, fmt.Sprintf("subnet-%s-private-%s", suffix, zone))
+		vpc.DatabaseSubnetIDs = append(vpc.DatabaseSubnetIDs, fmt.Sprintf("subnet-%s-database-%s", suffix, zone))
+	}
+	return vpc
+}
+
+// VPC returns the first VPC of the fixture, or the zero VPC if it has none.
+func (f *Fixture) VPC() VPC {
+	if len(f.VPCs) == 0 {
+		return VPC{}
+	}
+	return f.VPCs[0]
+}
+
+// FindVPC returns the VPC with the given ID.
+func (f *Fixture) FindVPC(id string) (VPC, bool) {
+	for _, vpc := range f.VPCs {
+		if vpc.ID == id {
+			return vpc, true
+		}
+	}
+	return VPC{}, false
+}
+
+// OIDCProviderARN returns the ARN of the IAM OIDC provider for OIDCIssuer.
+func (f *Fixture) OIDCProviderARN() string {
+	return fmt.Sprintf("arn:aws:iam::%s:oidc-provider/%s", f.AccountID, strings.TrimPrefix(f.OIDCIssuer, "https://"))
+}
+
+// ARN returns a regional ARN in the fixture account, e.g. ARN("rds", "db:test-db").
+func (f *Fixture) ARN(service, resource string) string {
+	return fmt.Sprintf("arn:aws:%s:%s:%s:%s", service, f.Region, f.AccountID, resource)
+}
+
+// CallerARN returns the ARN of the identity the fake sts:GetCallerIdentity reports.
+func (f *Fixture) CallerARN() string {
+	return fmt.Sprintf("arn:aws:iam::%s:user/terratest", f.AccountID)
+}
@@ -0,0 +1,219 @@
+package harness
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// LockedProvider is a provider version a .terraform.lock.hcl file selects, with the checksums init accepts for it.
+type LockedProvider struct {
+	// Address is the full source address, e.g. registry.terraform.io/hashicorp/aws.
+	Address string
+
+	Version string
+
+	// Hashes are the h1: checksums of unpacked packages and the zh: checksums of zip archives.
+	Hashes []string
+
+	// LockFile is the path of the lock file.
+	LockFile string
+}
+
+func (p LockedProvider) String() string {
+	return p.Address + " " + p.Version
+}
+
+// ParseLockFile reads the providers a .terraform.lock.hcl file selects, in file order.
+func ParseLockFile(path string) ([]LockedProvider, error) {
+	file, diags := hclparse.NewParser().ParseHCLFile(path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("%s: unexpected body type %T", path, file.Body)
+	}
+
+	var providers []LockedProvider
+	for _, block := range body.Blocks {
+		if block.Type != "provider" || len(block.Labels) != 1 {
+			continue
+		}
+		provider := LockedProvider{Address: block.Labels[0], LockFile: path}
+
+		version, ok := block.Body.Attributes["version"]
+		if !ok {
+			return nil, fmt.Errorf("%s: provider %s has no version", path, provider.Address)
+		}
+		value, diags := version.Expr.Value(nil)
+		if diags.HasErrors() || value.Type() != cty.String || value.IsNull() {
+			return nil, fmt.Errorf("%s: provider %s: version is not a literal string", path, provider.Address)
+		}
+		provider.Version = value.AsString()
+
+		if hashes, ok := block.Body.Attributes["hashes"]; ok {
+			value, diags := hashes.Expr.Value(nil)
+			if diags.HasErrors() || !value.Type().IsTupleType() {
+				return nil, fmt.Errorf("%s: provider %s: hashes is not a list of literal strings", path, provider.Address)
+			}
+			for _, hash := range value.AsValueSlice() {
+				if hash.Type() != cty.String || hash.IsNull() {
+					return nil, fmt.Errorf("%s: provider %s: hashes is not a list of literal strings", path, provider.Address)
+				}
+				provider.Hashes = append(provider.Hashes, hash.AsString())
+			}
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// ProviderMirror is a filesystem mirror of provider packages, laid out as `terraform providers mirror` writes it, with
+// zip archives, or with unpacked packages. Inits that use its CLI configuration install every provider from it and
+// never reach the registry.
+type ProviderMirror struct {
+	// Dir is the mirror folder.
+	Dir string
+
+	// CLIConfigFile is where WriteCLIConfig writes the CLI configuration, TF_CLI_CONFIG_FILE.
+	CLIConfigFile string
+
+	// Platform is the <os>_<arch> of the packages inits need, by default the one of the running tests.
+	Platform string
+}
+
+// NewProviderMirror returns the mirror in dir, whose CLI configuration is written to cliConfigFile.
+func NewProviderMirror(dir, cliConfigFile string) *ProviderMirror {
+	return &ProviderMirror{Dir: dir, CLIConfigFile: cliConfigFile, Platform: runtime.GOOS + "_" + runtime.GOARCH}
+}
+
+// CLIConfig returns a CLI configuration that installs providers from the mirror only.
+func (m *ProviderMirror) CLIConfig() string {
+	return fmt.Sprintf(`provider_installation {
+  filesystem_mirror {
+    path = %q
+  }
+}
+`, m.Dir)
+}
+
+// WriteCLIConfig writes the CLI configuration to CLIConfigFile.
+func (m *ProviderMirror) WriteCLIConfig() error {
+	if err := os.MkdirAll(filepath.Dir(m.CLIConfigFile), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(m.CLIConfigFile, []byte(m.CLIConfig()), 0o644)
+}
+
+// Verify checks that the mirror holds a package for the platform of every provider the lock files select, whose
+// checksum is one the lock file accepts, and names every provider that is missing or does not match. Terraform only
+// reports the first one, after init has already started.
+func (m *ProviderMirror) Verify(lockFiles ...string) error {
+	var problems []string
+	seen := map[string]bool{}
+	for _, lockFile := range lockFiles {
+		providers, err := ParseLockFile(lockFile)
+		if err != nil {
+			return err
+		}
+		for _, provider := range providers {
+			if seen[provider.String()] {
+				continue
+			}
+			seen[provider.String()] = true
+			if problem := m.verify(provider); problem != "" {
+				problems = append(problems, problem)
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("provider mirror %s is incomplete, populate it with `terraform providers mirror -platform=%s %s` in the root module:\n  %s",
+			m.Dir, m.Platform, m.Dir, strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// verify returns what is wrong with the mirror's package of the provider, or the empty string.
+func (m *ProviderMirror) verify(provider LockedProvider) string {
+	packed, unpacked, err := m.packagePaths(provider)
+	if err != nil {
+		return fmt.Sprintf("%s: %v", provider, err)
+	}
+
+	var hash string
+	switch {
+	case fileExists(packed):
+		// A zip archive may match the zh: checksum of the archive or the h1: checksum of its contents
+		zh, err := zipHash(packed)
+		if err != nil {
+			return fmt.Sprintf("%s: %v", provider, err)
+		}
+		if accepts(provider, zh) {
+			return ""
+		}
+		if hash, err = dirhash.HashZip(packed, dirhash.Hash1); err != nil {
+			return fmt.Sprintf("%s: %v", provider, err)
+		}
+	case fileExists(unpacked):
+		if hash, err = dirhash.HashDir(unpacked, "", dirhash.Hash1); err != nil {
+			return fmt.Sprintf("%s: %v", provider, err)
+		}
+	default:
+		return fmt.Sprintf("%s for %s is missing, locked in %s", provider, m.Platform, provider.LockFile)
+	}
+	if !accepts(provider, hash) {
+		return fmt.Sprintf("%s for %s has checksum %s, which %s does not accept", provider, m.Platform, hash, provider.LockFile)
+	}
+	return ""
+}
+
+// packagePaths returns where the mirror keeps the zip archive and the unpacked package of the provider.
+func (m *ProviderMirror) packagePaths(provider LockedProvider) (packed, unpacked string, err error) {
+	parts := strings.Split(provider.Address, "/")
+	if len(parts) != 3 {
+		return "", "", fmt.Errorf("provider address %q is not <hostname>/<namespace>/<type>", provider.Address)
+	}
+	dir := filepath.Join(m.Dir, parts[0], parts[1], parts[2])
+	archive := fmt.Sprintf("terraform-provider-%s_%s_%s.zip", parts[2], provider.Version, m.Platform)
+	return filepath.Join(dir, archive), filepath.Join(dir, provider.Version, m.Platform), nil
+}
+
+// zipHash returns the zh: checksum of a zip archive, its SHA-256.
+func zipHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return "zh:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func accepts(provider LockedProvider, hash string) bool {
+	for _, accepted := range provider.Hashes {
+		if accepted == hash {
+			return true
+		}
+	}
+	return false
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
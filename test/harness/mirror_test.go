@@ -0,0 +1,151 @@
+package harness
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+func TestParseLockFile(t *testing.T) {
+	t.Parallel()
+
+	providers, err := ParseLockFile("../../.terraform.lock.hcl")
+	require.NoError(t, err)
+
+	var locked []string
+	for _, provider := range providers {
+		locked = append(locked, provider.String())
+		assert.NotEmpty(t, provider.Hashes, provider.String())
+	}
+	assert.Equal(t, []string{
+		"registry.terraform.io/hashicorp/aws 5.100.0",
+		"registry.terraform.io/hashicorp/kubernetes 2.38.0",
+		"registry.terraform.io/hashicorp/random 3.7.2",
+		"registry.terraform.io/hashicorp/tls 4.1.0",
+	}, locked)
+	assert.Contains(t, providers[0].Hashes, "h1:Ijt7pOlB7Tr7maGQIqtsLFbl7pSMIj06TVdkoSBcYOw=")
+}
+
+func TestProviderMirrorVerify(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	mirror := NewProviderMirror(filepath.Join(dir, "mirror"), filepath.Join(dir, ".terraformrc"))
+	mirror.Platform = "linux_amd64"
+
+	// aws is mirrored as a zip archive, as `terraform providers mirror` writes it, and tls unpacked
+	archive := filepath.Join(mirror.Dir, "registry.terraform.io/hashicorp/aws/terraform-provider-aws_5.100.0_linux_amd64.zip")
+	writeZip(t, archive, "terraform-provider-aws_v5.100.0_x5", "aws")
+	zh, err := zipHash(archive)
+	require.NoError(t, err)
+
+	unpacked := filepath.Join(mirror.Dir, "registry.terraform.io/hashicorp/tls/4.1.0/linux_amd64")
+	require.NoError(t, os.MkdirAll(unpacked, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(unpacked, "terraform-provider-tls_v4.1.0_x5"), []byte("tls"), 0o755))
+	h1, err := dirhash.HashDir(unpacked, "", dirhash.Hash1)
+	require.NoError(t, err)
+
+	lockFile := filepath.Join(dir, ".terraform.lock.hcl")
+	writeLockFile(t, lockFile, map[string][]string{
+		"registry.terraform.io/hashicorp/aws 5.100.0": {zh},
+		"registry.terraform.io/hashicorp/tls 4.1.0":   {h1},
+	})
+	assert.NoError(t, mirror.Verify(lockFile))
+
+	// A checksum of the archive's contents is accepted too
+	archiveH1, err := dirhash.HashZip(archive, dirhash.Hash1)
+	require.NoError(t, err)
+	writeLockFile(t, lockFile, map[string][]string{"registry.terraform.io/hashicorp/aws 5.100.0": {archiveH1}})
+	assert.NoError(t, mirror.Verify(lockFile))
+
+	writeLockFile(t, lockFile, map[string][]string{
+		"registry.terraform.io/hashicorp/aws 5.100.0":  {"zh:0000"},
+		"registry.terraform.io/hashicorp/tls 4.1.0":    {h1},
+		"registry.terraform.io/hashicorp/random 3.7.2": {"zh:1111"},
+	})
+	err = mirror.Verify(lockFile)
+	require.Error(t, err)
+	message := err.Error()
+	assert.True(t, strings.HasPrefix(message, "provider mirror "+mirror.Dir+" is incomplete, populate it with `terraform providers mirror -platform=linux_amd64 "+mirror.Dir+"`"), message)
+	assert.Contains(t, message, "registry.terraform.io/hashicorp/aws 5.100.0 for linux_amd64 has checksum "+archiveH1+", which "+lockFile+" does not accept")
+	assert.Contains(t, message, "registry.terraform.io/hashicorp/random 3.7.2 for linux_amd64 is missing, locked in "+lockFile)
+	assert.NotContains(t, message, "tls")
+}
+
+func TestProviderMirrorVerifyReportsEachProviderOnce(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	mirror := NewProviderMirror(filepath.Join(dir, "mirror"), filepath.Join(dir, ".terraformrc"))
+
+	// The root module and a child module lock the same version
+	root, module := filepath.Join(dir, "root.lock.hcl"), filepath.Join(dir, "module.lock.hcl")
+	writeLockFile(t, root, map[string][]string{"registry.terraform.io/hashicorp/aws 5.100.0": {"zh:0000"}})
+	writeLockFile(t, module, map[string][]string{"registry.terraform.io/hashicorp/aws 5.100.0": {"zh:0000"}})
+
+	err := mirror.Verify(root, module)
+	require.Error(t, err)
+	assert.Equal(t, 1, strings.Count(err.Error(), "hashicorp/aws"), err.Error())
+}
+
+func TestProviderMirrorCLIConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	mirror := NewProviderMirror("/var/cache/terraform-providers", filepath.Join(dir, "cli", ".terraformrc"))
+	require.NoError(t, mirror.WriteCLIConfig())
+
+	data, err := os.ReadFile(mirror.CLIConfigFile)
+	require.NoError(t, err)
+	assert.Equal(t, `provider_installation {
+  filesystem_mirror {
+    path = "/var/cache/terraform-providers"
+  }
+}
+`, string(data))
+
+	cache := NewPluginCache("/tmp/plugin-cache")
+	assert.NotContains(t, cache.Env(), "TF_CLI_CONFIG_FILE")
+	cache.Mirror = mirror
+	assert.Equal(t, mirror.CLIConfigFile, cache.Env()["TF_CLI_CONFIG_FILE"])
+}
+
+// writeZip writes a zip archive holding one file.
+func writeZip(t *testing.T, path, name, content string) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	file, err := os.Create(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	archive := zip.NewWriter(file)
+	entry, err := archive.Create(name)
+	require.NoError(t, err)
+	_, err = entry.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, archive.Close())
+}
+
+// writeLockFile writes a lock file selecting each "<address> <version>" with the given hashes.
+func writeLockFile(t *testing.T, path string, providers map[string][]string) {
+	t.Helper()
+
+	var lock strings.Builder
+	for provider, hashes := range providers {
+		address, version, _ := strings.Cut(provider, " ")
+		fmt.Fprintf(&lock, "provider %q {\n  version = %q\n  hashes = [\n", address, version)
+		for _, hash := range hashes {
+			fmt.Fprintf(&lock, "    %q,\n", hash)
+		}
+		lock.WriteString("  ]\n}\n\n")
+	}
+	require.NoError(t, os.WriteFile(path, []byte(lock.String()), 0o644))
+}
@@ -0,0 +1,175 @@
+package harness
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// RunMode says how far a test takes its terraform options: whether it only plans, or deploys the plan and then
+// destroys or keeps what it deployed.
+type RunMode string
+
+// Run modes.
+const (
+	PlanOnly        RunMode = "plan-only"
+	ApplyAndDestroy RunMode = "apply-and-destroy"
+	ApplyAndKeep    RunMode = "apply-and-keep"
+)
+
+// RunModes are the run modes in order of how much they do.
+var RunModes = []RunMode{PlanOnly, ApplyAndDestroy, ApplyAndKeep}
+
+// RunModeEnvVar selects the run mode of the tests. Unset, the tests only plan.
+const RunModeEnvVar = "TERRATEST_RUN_MODE"
+
+// ParseRunMode parses the name of a run mode, the empty string being PlanOnly.
+func ParseRunMode(name string) (RunMode, error) {
+	if name == "" {
+		return PlanOnly, nil
+	}
+	for _, mode := range RunModes {
+		if RunMode(name) == mode {
+			return mode, nil
+		}
+	}
+	names := make([]string, len(RunModes))
+	for i, mode := range RunModes {
+		names[i] = string(mode)
+	}
+	return "", fmt.Errorf("unknown run mode %q, must be one of %s", name, strings.Join(names, ", "))
+}
+
+// RunModeFromEnv returns the run mode RunModeEnvVar selects.
+func RunModeFromEnv() (RunMode, error) {
+	mode, err := ParseRunMode(os.Getenv(RunModeEnvVar))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", RunModeEnvVar, err)
+	}
+	return mode, nil
+}
+
+// Applies reports whether the mode deploys what the test plans.
+func (m RunMode) Applies() bool {
+	return m == ApplyAndDestroy || m == ApplyAndKeep
+}
+
+// Destroys reports whether the mode destroys what the test deployed.
+func (m RunMode) Destroys() bool {
+	return m == ApplyAndDestroy
+}
+
+// workspaceUnsafe matches the characters a workspace name is built without.
+var workspaceUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Workspace returns the terraform workspace a test deploys into, derived from its name so that later runs, e.g. the
+// one that destroys what an apply-and-keep run deployed, find the same state.
+func Workspace(testName string) string {
+	return "terratest-" + strings.Trim(workspaceUnsafe.ReplaceAllString(strings.ToLower(testName), "-"), "-")
+}
+
+// Lifecycle runs a test's terraform options through the commands its run mode calls for. A test that deploys does so
+// in a workspace of its own, and Destroy refuses to run in any other, so it can never touch the default workspace's
+// state or another test's.
+type Lifecycle struct {
+	Mode      RunMode
+	Workspace string
+}
+
+// NewLifecycle returns the lifecycle of the named test in the given mode.
+func NewLifecycle(mode RunMode, testName string) *Lifecycle {
+	return &Lifecycle{Mode: mode, Workspace: Workspace(testName)}
+}
+
+// Init runs terraform init and, when the mode deploys, selects the test's workspace, creating it if needed.
+func (l *Lifecycle) Init(t testing.TestingT, options *terraform.Options) {
+	terraform.Init(t, options)
+	if l.Mode.Applies() {
+		terraform.WorkspaceSelectOrNew(t, options, l.Workspace)
+	}
+}
+
+// Apply applies the options, or their plan file, in the test's workspace. It does nothing unless the mode deploys,
+// and reports whether it applied.
+func (l *Lifecycle) Apply(t testing.TestingT, options *terraform.Options) bool {
+	if !l.Mode.Applies() {
+		return false
+	}
+	if err := l.selectWorkspace(t, options); err != nil {
+		t.Fatal(err)
+	}
+	terraform.Apply(t, options)
+	return true
+}
+
+// Deployment selects the test's workspace and returns the options without their plan file, so terraform show and
+// terraform output read what Apply deployed there. It reports false unless the mode deploys.
+func (l *Lifecycle) Deployment(t testing.TestingT, options *terraform.Options) (*terraform.Options, bool) {
+	if !l.Mode.Applies() {
+		return nil, false
+	}
+	if err := l.selectWorkspace(t, options); err != nil {
+		t.Fatal(err)
+	}
+	deployment := *options
+	deployment.PlanFilePath = ""
+	return &deployment, true
+}
+
+// Destroy destroys what Apply deployed and deletes the test's workspace. It does nothing unless the mode destroys, and
+// reports whether it destroyed.
+func (l *Lifecycle) Destroy(t testing.TestingT, options *terraform.Options) bool {
+	destroyed, err := l.DestroyE(t, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return destroyed
+}
+
+// DestroyE is Destroy, returning the error instead of failing the test. It refuses to destroy in a mode that does not
+// destroy, and in any workspace but the test's.
+func (l *Lifecycle) DestroyE(t testing.TestingT, options *terraform.Options) (bool, error) {
+	if !l.Mode.Destroys() {
+		return false, nil
+	}
+	if err := l.selectWorkspace(t, options); err != nil {
+		return false, err
+	}
+
+	// Destroying takes no plan file
+	destroyOptions := *options
+	destroyOptions.PlanFilePath = ""
+	if _, err := terraform.DestroyE(t, &destroyOptions); err != nil {
+		return false, err
+	}
+	if _, err := terraform.WorkspaceDeleteE(t, &destroyOptions, l.Workspace); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// selectWorkspace initializes the working directory and switches to the test's workspace, which must exist, checking
+// that terraform reports it as current.
+func (l *Lifecycle) selectWorkspace(t testing.TestingT, options *terraform.Options) error {
+	if l.Workspace == "" || l.Workspace == "default" {
+		return fmt.Errorf("refusing to deploy or destroy in workspace %q, each test needs its own", l.Workspace)
+	}
+	if _, err := terraform.InitE(t, options); err != nil {
+		return err
+	}
+	if _, err := terraform.RunTerraformCommandE(t, options, "workspace", "select", l.Workspace); err != nil {
+		return fmt.Errorf("selecting workspace %s: %w", l.Workspace, err)
+	}
+	current, err := terraform.RunTerraformCommandAndGetStdoutE(t, options, "workspace", "show")
+	if err != nil {
+		return err
+	}
+	if current = strings.TrimSpace(current); current != l.Workspace {
+		return fmt.Errorf("terraform is in workspace %q, expected %q", current, l.Workspace)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package harness
+
+import (
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRunMode(t *testing.T) {
+	t.Parallel()
+
+	for name, expected := range map[string]RunMode{
+		"":                  PlanOnly,
+		"plan-only":         PlanOnly,
+		"apply-and-destroy": ApplyAndDestroy,
+		"apply-and-keep":    ApplyAndKeep,
+	} {
+		mode, err := ParseRunMode(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, expected, mode, name)
+	}
+
+	_, err := ParseRunMode("apply")
+	assert.EqualError(t, err, `unknown run mode "apply", must be one of plan-only, apply-and-destroy, apply-and-keep`)
+}
+
+func TestRunModeFromEnv(t *testing.T) {
+	t.Setenv(RunModeEnvVar, "")
+	mode, err := RunModeFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, PlanOnly, mode)
+
+	t.Setenv(RunModeEnvVar, "destroy")
+	_, err = RunModeFromEnv()
+	assert.EqualError(t, err, `TERRATEST_RUN_MODE: unknown run mode "destroy", must be one of plan-only, apply-and-destroy, apply-and-keep`)
+}
+
+func TestRunModeLifecycleHooks(t *testing.T) {
+	t.Parallel()
+
+	assert.False(t, PlanOnly.Applies())
+	assert.False(t, PlanOnly.Destroys())
+	assert.True(t, ApplyAndDestroy.Applies())
+	assert.True(t, ApplyAndDestroy.Destroys())
+	assert.True(t, ApplyAndKeep.Applies())
+	assert.False(t, ApplyAndKeep.Destroys())
+}
+
+func TestWorkspace(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "terratest-testvpcmodule", Workspace("TestVPCModule"))
+	assert.Equal(t, "terratest-testvalidationerrors-vpc-vpc-cidr", Workspace("TestValidationErrors/vpc/vpc_cidr"))
+	assert.NotEqual(t, Workspace("TestRDSModule"), Workspace("TestRDSModuleMySQL"))
+}
+
+func TestLifecycleNeverDestroysOutsideItsMode(t *testing.T) {
+	t.Parallel()
+
+	// Neither mode runs terraform, so the options point nowhere
+	options := &terraform.Options{TerraformDir: "does-not-exist"}
+	for _, mode := range []RunMode{PlanOnly, ApplyAndKeep} {
+		lifecycle := NewLifecycle(mode, "TestVPCModule")
+		destroyed, err := lifecycle.DestroyE(t, options)
+		require.NoError(t, err, mode)
+		assert.False(t, destroyed, mode)
+		assert.False(t, lifecycle.Destroy(t, options), mode)
+	}
+	assert.False(t, NewLifecycle(PlanOnly, "TestVPCModule").Apply(t, options))
+	_, deployed := NewLifecycle(PlanOnly, "TestVPCModule").Deployment(t, options)
+	assert.False(t, deployed)
+
+	lifecycle := &Lifecycle{Mode: ApplyAndDestroy, Workspace: "default"}
+	destroyed, err := lifecycle.DestroyE(t, options)
+	assert.EqualError(t, err, `refusing to deploy or destroy in workspace "default", each test needs its own`)
+	assert.False(t, destroyed)
+}
@@ -0,0 +1,134 @@
+package harness
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gruntwork-io/terratest/modules/files"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// WorkingCopy copies the Terraform configuration under rootDir into destDir and returns the path of moduleDir, which
+// must be rootDir or a folder inside it, within the copy. Copying the whole root rather than the module alone keeps
+// relative module sources such as "../eks-cluster" resolving, and gives every test its own .terraform directory, lock
+// file and state, so tests running in parallel never init the same folder.
+//
+// Like test_structure.CopyTerraformFolderToTemp it leaves out state, tfvars and hidden files other than lock files, but
+// it always copies, whether or not a SKIP_<stage> variable is set, and it leaves out Go modules, i.e. the tests.
+func WorkingCopy(rootDir, moduleDir, destDir string) (string, error) {
+	rel, err := filepath.Rel(rootDir, moduleDir)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is not inside %s", moduleDir, rootDir)
+	}
+
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return "", err
+	}
+	if err := files.CopyFolderContentsWithFilter(rootDir, destDir, copiesToWorkingCopy); err != nil {
+		return "", fmt.Errorf("copying %s to %s: %w", rootDir, destDir, err)
+	}
+	return filepath.Join(destDir, rel), nil
+}
+
+// copiesToWorkingCopy is the filter of the files WorkingCopy copies.
+func copiesToWorkingCopy(path string) bool {
+	if files.PathIsTerraformLockFile(path) || files.PathIsTerraformVersionFile(path) {
+		return true
+	}
+	if files.PathContainsHiddenFileOrFolder(path) || files.PathContainsTerraformStateOrVars(path) {
+		return false
+	}
+	return !files.FileExists(filepath.Join(path, "go.mod"))
+}
+
+// PluginCache is a provider plugin cache the tests of a run share. Terraform does not make concurrent writes to a
+// cache safe, so Warm fills it once, one module at a time, before any test inits against it; from then on inits only
+// link the providers it holds and never write to it.
+type PluginCache struct {
+	// Dir is the cache folder, TF_PLUGIN_CACHE_DIR.
+	Dir string
+
+	// Mirror, when set, is where the cache is filled from instead of the registry, so inits need no network.
+	Mirror *ProviderMirror
+
+	once sync.Once
+	err  error
+}
+
+// NewPluginCache returns the plugin cache in dir, which is created when warmed.
+func NewPluginCache(dir string) *PluginCache {
+	return &PluginCache{Dir: dir}
+}
+
+// Env returns the environment that points terraform at the cache. Working copies of modules without a lock file of
+// their own have no checksums to verify cached providers against, so the cache is trusted for them: the lock files
+// those inits write are thrown away with the copy, and committed lock files are still checked.
+func (c *PluginCache) Env() map[string]string {
+	env := map[string]string{
+		"TF_PLUGIN_CACHE_DIR":                            c.Dir,
+		"TF_PLUGIN_CACHE_MAY_BREAK_DEPENDENCY_LOCK_FILE": "true",
+	}
+	if c.Mirror != nil {
+		env["TF_CLI_CONFIG_FILE"] = c.Mirror.CLIConfigFile
+	}
+	return env
+}
+
+// Warm inits the root module in rootDir and every module in its modules folder once, in a working copy under
+// scratchDir and one after the other, so the cache holds each provider any of them needs before the tests init in
+// parallel. With a mirror, it first checks that the mirror holds every provider the lock files select and writes the
+// CLI configuration that points inits at it. Only the first call does anything; every call returns its error.
+func (c *PluginCache) Warm(t testing.TestingT, rootDir, scratchDir string) error {
+	c.once.Do(func() {
+		c.err = c.warm(t, rootDir, scratchDir)
+	})
+	return c.err
+}
+
+func (c *PluginCache) warm(t testing.TestingT, rootDir, scratchDir string) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return err
+	}
+	if c.Mirror != nil {
+		lockFiles, err := filepath.Glob(filepath.Join(rootDir, "modules", "*", ".terraform.lock.hcl"))
+		if err != nil {
+			return err
+		}
+		if err := c.Mirror.Verify(append([]string{filepath.Join(rootDir, ".terraform.lock.hcl")}, lockFiles...)...); err != nil {
+			return err
+		}
+		if err := c.Mirror.WriteCLIConfig(); err != nil {
+			return err
+		}
+	}
+
+	modules, err := filepath.Glob(filepath.Join(rootDir, "modules", "*", "*.tf"))
+	if err != nil {
+		return err
+	}
+	moduleDirs := []string{rootDir}
+	for _, file := range modules {
+		if dir := filepath.Dir(file); dir != moduleDirs[len(moduleDirs)-1] {
+			moduleDirs = append(moduleDirs, dir)
+		}
+	}
+
+	for i, moduleDir := range moduleDirs {
+		dir, err := WorkingCopy(rootDir, moduleDir, filepath.Join(scratchDir, fmt.Sprintf("warm-%d", i)))
+		if err != nil {
+			return err
+		}
+		options := &terraform.Options{TerraformDir: dir, EnvVars: c.Env(), NoColor: true}
+		if _, err := terraform.RunTerraformCommandE(t, options, "init", "-input=false", "-backend=false"); err != nil {
+			return fmt.Errorf("warming the plugin cache with %s: %w", moduleDir, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package harness
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkingCopy(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	for _, file := range []string{
+		"main.tf",
+		".terraform.lock.hcl",
+		".terraform/providers/registry.terraform.io/hashicorp/aws/provider",
+		"terraform.tfstate",
+		"terraform.tfvars",
+		"modules/regional-eks/main.tf",
+		"modules/eks-cluster/main.tf",
+		"modules/eks-cluster/.terraform.lock.hcl",
+		"modules/eks-node-groups/user_data.sh",
+		"test/go.mod",
+		"test/vpc_test.go",
+	} {
+		path := filepath.Join(root, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(file), 0o644))
+	}
+
+	dest := filepath.Join(t.TempDir(), "copy")
+	dir, err := WorkingCopy(root, filepath.Join(root, "modules", "regional-eks"), dest)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dest, "modules", "regional-eks"), dir)
+
+	// The sibling modules a relative source points at come along
+	for _, file := range []string{
+		"main.tf",
+		".terraform.lock.hcl",
+		"modules/regional-eks/main.tf",
+		"modules/eks-cluster/main.tf",
+		"modules/eks-cluster/.terraform.lock.hcl",
+		"modules/eks-node-groups/user_data.sh",
+	} {
+		assert.FileExists(t, filepath.Join(dest, file))
+	}
+	for _, file := range []string{".terraform", "terraform.tfstate", "terraform.tfvars", "test"} {
+		assert.NoFileExists(t, filepath.Join(dest, file))
+		assert.NoDirExists(t, filepath.Join(dest, file))
+	}
+
+	dir, err = WorkingCopy(root, root, filepath.Join(t.TempDir(), "root"))
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, "main.tf"))
+
+	_, err = WorkingCopy(filepath.Join(root, "modules"), root, t.TempDir())
+	assert.ErrorContains(t, err, "is not inside")
+}
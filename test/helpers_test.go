@@ -0,0 +1,315 @@
+package test
+
+import (
+	"flag"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/capacity"
+	"github.com/your-org/multi-az-eks-cluster/test/cost"
+	"github.com/your-org/multi-az-eks-cluster/test/golden"
+	"github.com/your-org/multi-az-eks-cluster/test/harness"
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+	"github.com/your-org/multi-az-eks-cluster/test/policy"
+	"github.com/your-org/multi-az-eks-cluster/test/tfdiag"
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
+	"github.com/your-org/multi-az-eks-cluster/test/topology"
+	"github.com/your-org/multi-az-eks-cluster/test/upgrade"
+)
+
+// liveAWSEnvVar opts out of the offline fake: when set to true, plans run against the AWS account of the current
+// credentials instead.
+const liveAWSEnvVar = "TERRATEST_LIVE_AWS"
+
+// updateGolden rewrites the golden plan snapshots in testdata/golden instead of comparing plans against them.
+var updateGolden = flag.Bool("update", false, "rewrite golden plan snapshots instead of comparing against them")
+
+// repoRoot is the root module, which holds every module the tests plan; working copies are made of all of it.
+const repoRoot = ".."
+
+// providerMirrorEnvVar points the tests at a filesystem provider mirror other than test/.provider-mirror.
+const providerMirrorEnvVar = "TERRATEST_PROVIDER_MIRROR"
+
+// pluginCache is the provider plugin cache the tests of a run share, under test/.plugin-cache.
+var pluginCache = newPluginCache()
+
+// newPluginCache returns the plugin cache, filled from the provider mirror when there is one: the folder
+// TERRATEST_PROVIDER_MIRROR names, which must then hold every locked provider, or else test/.provider-mirror if it
+// exists. Without a mirror, providers are downloaded from the registry.
+func newPluginCache() *harness.PluginCache {
+	cache := harness.NewPluginCache(mustAbs(".plugin-cache"))
+
+	mirror, ok := os.LookupEnv(providerMirrorEnvVar)
+	if !ok {
+		mirror = ".provider-mirror"
+		if _, err := os.Stat(mirror); err != nil {
+			return cache
+		}
+	}
+	cache.Mirror = harness.NewProviderMirror(mustAbs(mirror), mustAbs(".terraformrc"))
+	return cache
+}
+
+// initAndPlan runs terraform init, plan and show with the given options against the fixture and returns the plan
+// indexed by resource address. The plan file is written to a per-test temporary directory.
+func initAndPlan(t *testing.T, fixture *harness.Fixture, terraformOptions *terraform.Options) *planassert.Plan {
+	t.Helper()
+
+	isolate(t, terraformOptions, t.TempDir())
+	withFixture(t, fixture, terraformOptions)
+
+	if terraformOptions.PlanFilePath == "" {
+		terraformOptions.PlanFilePath = filepath.Join(t.TempDir(), "plan.out")
+	}
+
+	return planassert.New(terraform.InitAndPlanAndShowWithStruct(t, terraformOptions))
+}
+
+// planDiagnostics runs terraform init, then plan with -json against the fixture, and returns the diagnostics of the
+// plan, which must fail.
+func planDiagnostics(t *testing.T, fixture *harness.Fixture, terraformOptions *terraform.Options) []tfdiag.Diagnostic {
+	t.Helper()
+
+	isolate(t, terraformOptions, t.TempDir())
+	withFixture(t, fixture, terraformOptions)
+	terraform.Init(t, terraformOptions)
+
+	output, err := terraform.RunTerraformCommandE(t, terraformOptions,
+		terraform.FormatArgs(terraformOptions, "plan", "-input=false", "-json")...)
+	require.Error(t, err, "plan must fail")
+	return tfdiag.Parse(output)
+}
+
+// assertValidationError checks that planning terraformOptions fails on the validation block of the variable in the
+// module at terraformOptions.TerraformDir, with the block's exact error_message, and on no other validation.
+func assertValidationError(t *testing.T, fixture *harness.Fixture, terraformOptions *terraform.Options, variable string) {
+	t.Helper()
+
+	validations, err := tfdiag.ParseValidations(terraformOptions.TerraformDir)
+	require.NoError(t, err)
+	var validation *tfdiag.Validation
+	for i := range validations {
+		if validations[i].Variable == variable {
+			require.Nil(t, validation, "var.%s has more than one validation block", variable)
+			validation = &validations[i]
+		}
+	}
+	require.NotNil(t, validation, "%s has no validation block for var.%s", terraformOptions.TerraformDir, variable)
+
+	tfdiag.AssertValidationError(t, planDiagnostics(t, fixture, terraformOptions), *validation)
+}
+
+// isolate points terraformOptions at a working copy of their module in dir, made for the test alone, and at the
+// plugin cache the tests share, so tests running in parallel never init the same folder or download the same provider.
+func isolate(t *testing.T, terraformOptions *terraform.Options, dir string) {
+	t.Helper()
+
+	workingDir, err := harness.WorkingCopy(repoRoot, terraformOptions.TerraformDir, dir)
+	if err != nil {
+		t.Fatalf("copying %s: %v", terraformOptions.TerraformDir, err)
+	}
+	terraformOptions.TerraformDir = workingDir
+
+	if terraformOptions.EnvVars == nil {
+		terraformOptions.EnvVars = map[string]string{}
+	}
+	for name, value := range pluginCache.Env() {
+		terraformOptions.EnvVars[name] = value
+	}
+}
+
+// withFixture points terraformOptions at a fake AWS API serving the fixture for the rest of the test, using dummy
+// credentials, so plans need neither network access nor an AWS account. It does nothing in live mode. The test is
+// skipped when no terraform binary is available, and fails if the plan called an AWS API the fake does not serve.
+func withFixture(t *testing.T, fixture *harness.Fixture, terraformOptions *terraform.Options) {
+	t.Helper()
+
+	requireTerraform(t)
+	if err := pluginCache.Warm(t, repoRoot, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	if liveAWS() {
+		return
+	}
+
+	fake := harness.StartFakeAWS(fixture)
+	t.Cleanup(func() {
+		if unsupported := fake.Unsupported(); len(unsupported) > 0 {
+			t.Errorf("plan called AWS APIs the offline fake does not serve: %s", strings.Join(unsupported, ", "))
+		}
+		fake.Close()
+	})
+
+	if terraformOptions.EnvVars == nil {
+		terraformOptions.EnvVars = map[string]string{}
+	}
+	for name, value := range fake.Env() {
+		terraformOptions.EnvVars[name] = value
+	}
+}
+
+// mustAbs returns the absolute path of a folder under test/; terraform runs in working copies elsewhere.
+func mustAbs(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		panic(err)
+	}
+	return abs
+}
+
+// liveAWS reports whether the tests should run against real AWS rather than the offline fake.
+func liveAWS() bool {
+	live, _ := strconv.ParseBool(os.Getenv(liveAWSEnvVar))
+	return live
+}
+
+// requireTerraform skips the test when no terraform binary is available.
+func requireTerraform(t *testing.T) {
+	t.Helper()
+
+	if _, err := exec.LookPath("terraform"); err != nil {
+		t.Skip("terraform binary not found in PATH")
+	}
+}
+
+// assertPolicy evaluates the default policy rules against the plan and checks that the violations are exactly the
+// expected ones, each given as "<rule ID> <address>".
+func assertPolicy(t *testing.T, plan *planassert.Plan, expected ...string) {
+	t.Helper()
+
+	rules, err := policy.Default()
+	if err != nil {
+		t.Fatalf("loading policy rules: %v", err)
+	}
+	policy.AssertViolations(t, rules.Evaluate(plan), expected...)
+}
+
+// assertGolden compares a normalized snapshot of the plan with testdata/golden/<TestName>.json; run the tests with
+// -update to rewrite the snapshots after an intended change. Live plans see a different account and are not compared.
+func assertGolden(t *testing.T, plan *planassert.Plan) {
+	t.Helper()
+
+	if liveAWS() {
+		t.Log("skipping golden plan comparison in live mode")
+		return
+	}
+	golden.Assert(t, plan, *updateGolden)
+}
+
+// assertRootBudget estimates the monthly cost of a root module plan from the price tables in cost/prices and checks
+// that it is within budget.
+func assertRootBudget(t *testing.T, plan *planassert.Plan, budget float64) {
+	t.Helper()
+
+	prices, err := cost.Default()
+	if err != nil {
+		t.Fatalf("loading prices: %v", err)
+	}
+	options, err := cost.RootOptions(plan)
+	if err != nil {
+		t.Fatalf("estimating cost: %v", err)
+	}
+	estimate, err := prices.Estimate(plan, options)
+	if err != nil {
+		t.Fatalf("estimating cost: %v", err)
+	}
+	t.Logf("estimated monthly cost:\n%s", estimate)
+	cost.AssertWithinBudget(t, estimate, budget)
+}
+
+// assertTopology builds the topology graph of a root module plan and checks that it is connected and that the RDS
+// replica in the secondary region replicates the primary. The service accounts of the IRSA roles, whose trust policies
+// are only known after apply, are read from the module source.
+func assertTopology(t *testing.T, plan *planassert.Plan) {
+	t.Helper()
+
+	graph, err := topology.Build(plan, topology.Options{SourceDir: ".."})
+	if err != nil {
+		t.Fatalf("building topology: %v", err)
+	}
+	topology.AssertConnected(t, graph)
+	topology.AssertReplicaEdges(t, graph)
+}
+
+// planUpgrade plans the baseline and the modified options against the same fixture and classifies what applying the
+// modified plan over the baseline replaces and updates. Root module plans are split by region; module plans are in
+// the fixture region.
+func planUpgrade(t *testing.T, fixture *harness.Fixture, baseline, modified *terraform.Options) *upgrade.Impact {
+	t.Helper()
+
+	root := filepath.Clean(modified.TerraformDir) == filepath.Clean(repoRoot)
+	before := initAndPlan(t, fixture, baseline)
+	after := initAndPlan(t, fixture, modified)
+	options := upgrade.Options{Region: fixture.Region}
+	if root {
+		var err error
+		options, err = upgrade.RootOptions(after)
+		require.NoError(t, err)
+	}
+	impact := upgrade.Compare(before, after, options)
+	t.Logf("upgrade impact:\n%s", impact)
+	return impact
+}
+
+// privateSubnets returns the private subnets of a fixture VPC with their availability zones.
+func privateSubnets(vpc harness.VPC) []capacity.Subnet {
+	subnets := make([]capacity.Subnet, len(vpc.PrivateSubnetIDs))
+	for i, id := range vpc.PrivateSubnetIDs {
+		subnets[i] = capacity.Subnet{ID: id, AvailabilityZone: vpc.AvailabilityZones[i]}
+	}
+	return subnets
+}
+
+// rootOptions returns the options for planning the root module with vars, failing the test if they are invalid.
+func rootOptions(t *testing.T, vars tfvars.RootVars) *terraform.Options {
+	t.Helper()
+
+	tfvars.Require(t, vars)
+	return terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+		TerraformDir: "..",
+		Vars:         vars.Vars(),
+	})
+}
+
+// regionalOptions returns the options for planning modules/regional-eks with vars, failing the test if they are
+// invalid.
+func regionalOptions(t *testing.T, vars tfvars.RegionalVars) *terraform.Options {
+	t.Helper()
+
+	tfvars.Require(t, vars)
+	return terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+		TerraformDir: "../modules/regional-eks",
+		Vars:         vars.Vars(),
+	})
+}
+
+// smallNodeGroup is an on-demand group of t3.medium nodes, one per availability zone.
+func smallNodeGroup() tfvars.NodeGroup {
+	return tfvars.BaselineNodeGroup().WithSizes(1, 3, 9).WithInstanceTypes("t3.medium")
+}
+
+// smallRDSConfig is a single-AZ db.t3.small PostgreSQL instance.
+func smallRDSConfig() *tfvars.RDSConfig {
+	config := tfvars.BaselineRDSConfig()
+	config.InstanceClass = "db.t3.small"
+	config.AllocatedStorage = 50
+	config.MasterUsername = "admin"
+	config.MultiAZ = false
+	return &config
+}
+
+// ouVars renders the organizational_units variable, failing the test if the OUs are invalid.
+func ouVars(t *testing.T, ous ...tfvars.OU) []map[string]interface{} {
+	t.Helper()
+
+	tfvars.Require(t, tfvars.OUs(ous))
+	return tfvars.OUs(ous).Vars()
+}
@@ -5,234 +5,299 @@ import (
 
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/your-org/multi-az-eks-cluster/test/harness"
+	"github.com/your-org/multi-az-eks-cluster/test/iampolicy"
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
 )
 
 func TestIAMRolesModule(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/iam-roles",
-		Vars: map[string]interface{}{
-			"cluster_name":       "test-cluster",
-			"oidc_provider_arn":  "arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/EXAMPLED539D4633E53DE1B716D3041E",
-			"oidc_provider_url":  "https://oidc.eks.us-east-1.amazonaws.com/id/EXAMPLED539D4633E53DE1B716D3041E",
-			"rds_instance_arn":   "arn:aws:rds:us-east-1:123456789012:db:test-db",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"admin"},
+	fixture := harness.NewFixture("us-east-1")
+
+	runStages(t, fixture, func() *terraform.Options {
+		return terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+			TerraformDir: "../modules/iam-roles",
+			Vars: map[string]interface{}{
+				"cluster_name":         "test-cluster",
+				"oidc_provider_arn":    fixture.OIDCProviderARN(),
+				"oidc_provider_url":    fixture.OIDCIssuer,
+				"rds_instance_arn":     fixture.ARN("rds", "db:test-db"),
+				"organizational_units": ouVars(t, tfvars.BaselineOU()),
+				"tags": map[string]string{
+					"Environment": "test",
 				},
 			},
-			"tags": map[string]string{
-				"Environment": "test",
-			},
-		},
-		PlanOnly: true,
+		})
+	}, func(plan *planassert.Plan) {
+		// Expected resources:
+		// - RDS access role per OU + policy + attachment
+		// - ALB controller role + policy + attachment
+		// - EBS CSI driver role + attachment
+		// - Cluster autoscaler role + policy + attachment
+		// - External DNS role + policy + attachment
+		planassert.AssertCounts(t, plan, 14, 0, 0)
+
+		planassert.AssertTypeCount(t, plan, "aws_iam_role", 5)
+		planassert.AssertTypeCount(t, plan, "aws_iam_policy", 4)
+		planassert.AssertTypeCount(t, plan, "aws_iam_role_policy_attachment", 5)
+		planassert.AssertResourceCount(t, plan, "aws_iam_role.rds_access[*]", 1)
+
+		planassert.AssertAttributeEquals(t, plan, "aws_iam_role.rds_access[\"ou-test-001\"]", "name", "test-cluster-test-ou-rds-access")
+		planassert.AssertAttributeEquals(t, plan, "aws_iam_role.rds_access[\"ou-test-001\"]", "tags", map[string]string{
+			"Environment": "test",
+			"Name":        "test-cluster-test-ou-rds-access",
+			"OU":          "ou-test-001",
+		})
+
+		assertPolicy(t, plan)
+		assertGolden(t, plan)
 	})
-
-	defer terraform.Destroy(t, terraformOptions)
-
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
-
-	// Expected resources:
-	// - RDS access role per OU + policy + attachment
-	// - ALB controller role + policy + attachment
-	// - EBS CSI driver role + attachment
-	// - Cluster autoscaler role + policy + attachment
-	// - External DNS role + policy + attachment
-
-	assert.Greater(t, resourceCounts.Add, 12, "Should create more than 12 IAM resources")
 }
 
 func TestIAMRolesMultipleOUs(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/iam-roles",
 		Vars: map[string]interface{}{
 			"cluster_name":      "test-cluster-multi-ou",
-			"oidc_provider_arn": "arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/TEST",
-			"oidc_provider_url": "https://oidc.eks.us-east-1.amazonaws.com/id/TEST",
-			"rds_instance_arn":  "arn:aws:rds:us-east-1:123456789012:db:test-db",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "ou-admin",
-					"ou_id":       "ou-admin-001",
-					"permissions": []string{"admin"},
-				},
-				{
-					"name":        "ou-dev",
-					"ou_id":       "ou-dev-001",
-					"permissions": []string{"deploy", "view"},
-				},
-				{
-					"name":        "ou-readonly",
-					"ou_id":       "ou-ro-001",
-					"permissions": []string{"view"},
-				},
-			},
+			"oidc_provider_arn": fixture.OIDCProviderARN(),
+			"oidc_provider_url": fixture.OIDCIssuer,
+			"rds_instance_arn":  fixture.ARN("rds", "db:test-db"),
+			"organizational_units": ouVars(t,
+				tfvars.OU{Name: "ou-admin", OUID: "ou-admin-001", Permissions: []string{"admin"}},
+				tfvars.OU{Name: "ou-dev", OUID: "ou-dev-001", Permissions: []string{"deploy", "view"}},
+				tfvars.OU{Name: "ou-readonly", OUID: "ou-ro-001", Permissions: []string{"view"}},
+			),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
+	plan := initAndPlan(t, fixture, terraformOptions)
 
 	// Should create RDS access resources for each OU (3 OUs * 3 resources each = 9)
-	// Plus common service roles
-	assert.Greater(t, resourceCounts.Add, 18, "Should create resources for multiple OUs")
+	// Plus 11 common service role resources
+	planassert.AssertCounts(t, plan, 20, 0, 0)
+	planassert.AssertResourceCount(t, plan, "aws_iam_role.rds_access[*]", 3)
+	planassert.AssertResourceCount(t, plan, "aws_iam_policy.rds_access[*]", 3)
+	planassert.AssertResourceCount(t, plan, "aws_iam_role_policy_attachment.rds_access[*]", 3)
+
+	for ouID, name := range map[string]string{"ou-admin-001": "ou-admin", "ou-dev-001": "ou-dev", "ou-ro-001": "ou-readonly"} {
+		planassert.AssertAttributeEquals(t, plan, "aws_iam_policy.rds_access[\""+ouID+"\"]", "name", "test-cluster-multi-ou-"+name+"-rds-policy")
+	}
 }
 
 func TestIAMRolesWithoutRDS(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/iam-roles",
 		Vars: map[string]interface{}{
-			"cluster_name":      "test-cluster-no-rds",
-			"oidc_provider_arn": "arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/TEST",
-			"oidc_provider_url": "https://oidc.eks.us-east-1.amazonaws.com/id/TEST",
-			"rds_instance_arn":  nil, // No RDS
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"admin"},
-				},
-			},
+			"cluster_name":         "test-cluster-no-rds",
+			"oidc_provider_arn":    fixture.OIDCProviderARN(),
+			"oidc_provider_url":    fixture.OIDCIssuer,
+			"rds_instance_arn":     nil, // No RDS
+			"organizational_units": ouVars(t, tfvars.BaselineOU()),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
+	plan := initAndPlan(t, fixture, terraformOptions)
 
 	// Should not create RDS-specific roles
-	assert.Greater(t, resourceCounts.Add, 8, "Should create service roles without RDS roles")
+	planassert.AssertCounts(t, plan, 11, 0, 0)
+	planassert.AssertNoResourcesMatching(t, plan, "aws_iam_role.rds_access[*]")
+	planassert.AssertNoResourcesMatching(t, plan, "aws_iam_policy.rds_access[*]")
+	planassert.AssertNoResourcesMatching(t, plan, "aws_iam_role_policy_attachment.rds_access[*]")
+	planassert.AssertTypeCount(t, plan, "aws_iam_role", 4)
 }
 
 func TestIAMRolesALBController(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/iam-roles",
 		Vars: map[string]interface{}{
-			"cluster_name":      "test-cluster-alb",
-			"oidc_provider_arn": "arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/TEST",
-			"oidc_provider_url": "https://oidc.eks.us-east-1.amazonaws.com/id/TEST",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"admin"},
-				},
-			},
+			"cluster_name":         "test-cluster-alb",
+			"oidc_provider_arn":    fixture.OIDCProviderARN(),
+			"oidc_provider_url":    fixture.OIDCIssuer,
+			"organizational_units": ouVars(t, tfvars.BaselineOU()),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should include ALB controller role and policy")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_role.alb_controller", "name", "test-cluster-alb-alb-controller")
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_policy.alb_controller", "name", "test-cluster-alb-alb-controller-policy")
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_role_policy_attachment.alb_controller", "role", "test-cluster-alb-alb-controller")
+	planassert.AssertAttributeUnknown(t, plan, "aws_iam_role_policy_attachment.alb_controller", "policy_arn")
+
+	trust := iampolicy.RequireFromPlan(t, plan, "aws_iam_role.alb_controller", "assume_role_policy")
+	iampolicy.AssertAllowedActions(t, trust, "sts:AssumeRoleWithWebIdentity")
+	iampolicy.AssertPrincipals(t, trust, "Federated", fixture.OIDCProviderARN())
+	iampolicy.AssertCondition(t, trust, "StringEquals", ":sub", "system:serviceaccount:kube-system:aws-load-balancer-controller")
+	iampolicy.AssertCondition(t, trust, "StringEquals", ":aud", "sts.amazonaws.com")
+
+	policy := iampolicy.RequireFromPlan(t, plan, "aws_iam_policy.alb_controller", "policy")
+	iampolicy.AssertGrants(t, policy, "elasticloadbalancing:CreateLoadBalancer", "*")
+	iampolicy.AssertNotGranted(t, policy, "iam:PassRole", "*")
+	// Mutating load balancers and security groups is only allowed on resources tagged by the controller
+	assert.False(t, policy.GrantsUnconditionally("elasticloadbalancing:DeleteLoadBalancer", "*"))
+	assert.False(t, policy.GrantsUnconditionally("ec2:DeleteSecurityGroup", "*"))
 }
 
 func TestIAMRolesClusterAutoscaler(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/iam-roles",
 		Vars: map[string]interface{}{
-			"cluster_name":      "test-cluster-ca",
-			"oidc_provider_arn": "arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/TEST",
-			"oidc_provider_url": "https://oidc.eks.us-east-1.amazonaws.com/id/TEST",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"admin"},
-				},
-			},
+			"cluster_name":         "test-cluster-ca",
+			"oidc_provider_arn":    fixture.OIDCProviderARN(),
+			"oidc_provider_url":    fixture.OIDCIssuer,
+			"organizational_units": ouVars(t, tfvars.BaselineOU()),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should include Cluster Autoscaler role and policy")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_role.cluster_autoscaler", "name", "test-cluster-ca-cluster-autoscaler")
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_policy.cluster_autoscaler", "name", "test-cluster-ca-cluster-autoscaler-policy")
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_role_policy_attachment.cluster_autoscaler", "role", "test-cluster-ca-cluster-autoscaler")
+	planassert.AssertAttributeUnknown(t, plan, "aws_iam_role_policy_attachment.cluster_autoscaler", "policy_arn")
+
+	trust := iampolicy.RequireFromPlan(t, plan, "aws_iam_role.cluster_autoscaler", "assume_role_policy")
+	iampolicy.AssertPrincipals(t, trust, "Federated", fixture.OIDCProviderARN())
+	iampolicy.AssertCondition(t, trust, "StringEquals", ":sub", "system:serviceaccount:kube-system:cluster-autoscaler")
+	iampolicy.AssertCondition(t, trust, "StringEquals", ":aud", "sts.amazonaws.com")
+
+	policy := iampolicy.RequireFromPlan(t, plan, "aws_iam_policy.cluster_autoscaler", "policy")
+	iampolicy.AssertAllowedActions(t, policy,
+		"autoscaling:DescribeAutoScalingGroups",
+		"autoscaling:DescribeAutoScalingInstances",
+		"autoscaling:DescribeLaunchConfigurations",
+		"autoscaling:DescribeScalingActivities",
+		"autoscaling:DescribeTags",
+		"autoscaling:SetDesiredCapacity",
+		"autoscaling:TerminateInstanceInAutoScalingGroup",
+		"ec2:DescribeImages",
+		"ec2:DescribeInstanceTypes",
+		"ec2:DescribeLaunchTemplateVersions",
+		"ec2:GetInstanceTypesFromInstanceRequirements",
+		"eks:DescribeNodegroup",
+	)
+	iampolicy.AssertNotGranted(t, policy, "autoscaling:DeleteAutoScalingGroup", "*")
+	iampolicy.AssertNotGranted(t, policy, "ec2:TerminateInstances", "*")
 }
 
 func TestIAMRolesEBSCSIDriver(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/iam-roles",
 		Vars: map[string]interface{}{
-			"cluster_name":      "test-cluster-ebs",
-			"oidc_provider_arn": "arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/TEST",
-			"oidc_provider_url": "https://oidc.eks.us-east-1.amazonaws.com/id/TEST",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"admin"},
-				},
-			},
+			"cluster_name":         "test-cluster-ebs",
+			"oidc_provider_arn":    fixture.OIDCProviderARN(),
+			"oidc_provider_url":    fixture.OIDCIssuer,
+			"organizational_units": ouVars(t, tfvars.BaselineOU()),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should include EBS CSI driver role")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_role.ebs_csi_driver", "name", "test-cluster-ebs-ebs-csi-driver")
+	planassert.AssertNoResourcesMatching(t, plan, "aws_iam_policy.ebs_csi_driver")
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_role_policy_attachment.ebs_csi_driver", "policy_arn",
+		"arn:aws:iam::aws:policy/service-role/AmazonEBSCSIDriverPolicy")
+
+	trust := iampolicy.RequireFromPlan(t, plan, "aws_iam_role.ebs_csi_driver", "assume_role_policy")
+	iampolicy.AssertAllowedActions(t, trust, "sts:AssumeRoleWithWebIdentity")
+	iampolicy.AssertPrincipals(t, trust, "Federated", fixture.OIDCProviderARN())
+	iampolicy.AssertCondition(t, trust, "StringEquals", ":sub", "system:serviceaccount:kube-system:ebs-csi-controller-sa")
+	iampolicy.AssertCondition(t, trust, "StringEquals", ":aud", "sts.amazonaws.com")
 }
 
 func TestIAMRolesExternalDNS(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/iam-roles",
 		Vars: map[string]interface{}{
-			"cluster_name":      "test-cluster-dns",
-			"oidc_provider_arn": "arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/TEST",
-			"oidc_provider_url": "https://oidc.eks.us-east-1.amazonaws.com/id/TEST",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"admin"},
-				},
-			},
+			"cluster_name":         "test-cluster-dns",
+			"oidc_provider_arn":    fixture.OIDCProviderARN(),
+			"oidc_provider_url":    fixture.OIDCIssuer,
+			"organizational_units": ouVars(t, tfvars.BaselineOU()),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should include External DNS role and policy")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_role.external_dns", "name", "test-cluster-dns-external-dns")
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_policy.external_dns", "name", "test-cluster-dns-external-dns-policy")
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_role_policy_attachment.external_dns", "role", "test-cluster-dns-external-dns")
+	planassert.AssertAttributeUnknown(t, plan, "aws_iam_role_policy_attachment.external_dns", "policy_arn")
+
+	trust := iampolicy.RequireFromPlan(t, plan, "aws_iam_role.external_dns", "assume_role_policy")
+	iampolicy.AssertPrincipals(t, trust, "Federated", fixture.OIDCProviderARN())
+	iampolicy.AssertCondition(t, trust, "StringEquals", ":sub", "system:serviceaccount:kube-system:external-dns")
+	iampolicy.AssertCondition(t, trust, "StringEquals", ":aud", "sts.amazonaws.com")
+	assert.Empty(t, trust.Condition("StringLike", ":sub"))
+
+	policy := iampolicy.RequireFromPlan(t, plan, "aws_iam_policy.external_dns", "policy")
+	iampolicy.AssertAllowedActions(t, policy,
+		"route53:ChangeResourceRecordSets",
+		"route53:ListHostedZones",
+		"route53:ListResourceRecordSets",
+	)
+	iampolicy.AssertResourcesFor(t, policy, "route53:ChangeResourceRecordSets", "arn:aws:route53:::hostedzone/*")
+	iampolicy.AssertNotGranted(t, policy, "route53:ChangeResourceRecordSets", "*")
 }
 
 func TestIAMRolesRDSAccess(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/iam-roles",
 		Vars: map[string]interface{}{
-			"cluster_name":      "test-cluster-rds-access",
-			"oidc_provider_arn": "arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/TEST",
-			"oidc_provider_url": "https://oidc.eks.us-east-1.amazonaws.com/id/TEST",
-			"rds_instance_arn":  "arn:aws:rds:us-east-1:123456789012:db:production-db",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "app-team",
-					"ou_id":       "ou-app-001",
-					"permissions": []string{"deploy", "view"},
-				},
-			},
+			"cluster_name":         "test-cluster-rds-access",
+			"oidc_provider_arn":    fixture.OIDCProviderARN(),
+			"oidc_provider_url":    fixture.OIDCIssuer,
+			"rds_instance_arn":     fixture.ARN("rds", "db:production-db"),
+			"organizational_units": ouVars(t, tfvars.OU{Name: "app-team", OUID: "ou-app-001", Permissions: []string{"deploy", "view"}}),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
+	plan := initAndPlan(t, fixture, terraformOptions)
 
 	// Should include RDS-specific IAM resources
-	assert.Greater(t, resourceCounts.Add, 10, "Should create RDS access IAM resources")
+	planassert.AssertCounts(t, plan, 14, 0, 0)
+	planassert.AssertResourceCount(t, plan, "aws_iam_role.rds_access[*]", 1)
+	planassert.AssertResourceCount(t, plan, "aws_iam_policy.rds_access[*]", 1)
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_role_policy_attachment.rds_access[\"ou-app-001\"]", "role", "test-cluster-rds-access-app-team-rds-access")
+
+	// The OU role is assumed by the OU's own service account, in the namespace named after the OU
+	trust := iampolicy.RequireFromPlan(t, plan, "aws_iam_role.rds_access[\"ou-app-001\"]", "assume_role_policy")
+	iampolicy.AssertAllowedActions(t, trust, "sts:AssumeRoleWithWebIdentity")
+	iampolicy.AssertPrincipals(t, trust, "Federated", fixture.OIDCProviderARN())
+	iampolicy.AssertCondition(t, trust, "StringEquals", ":sub", "system:serviceaccount:app-team:app-team-rds-access")
+	iampolicy.AssertCondition(t, trust, "StringEquals", ":aud", "sts.amazonaws.com")
+
+	findings, err := iampolicy.LintPlan(plan)
+	assert.NoError(t, err)
+	assert.Empty(t, findings)
 }
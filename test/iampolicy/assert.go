@@ -0,0 +1,76 @@
+package iampolicy
+
+import (
+	"fmt"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// FromPlan parses the policy document held in a JSON string attribute of a planned resource, e.g. the
+// assume_role_policy of an aws_iam_role or the policy of an aws_iam_policy. It fails if the document is only known
+// after apply.
+func FromPlan(plan *planassert.Plan, address, attribute string) (*Document, error) {
+	resource, ok := plan.Resource(address)
+	if !ok {
+		return nil, fmt.Errorf("%s is not planned", address)
+	}
+	value, ok := resource.Attribute(attribute)
+	if !ok {
+		if resource.IsUnknown(attribute) {
+			return nil, fmt.Errorf("%s.%s is only known after apply", address, attribute)
+		}
+		return nil, fmt.Errorf("%s has no %s", address, attribute)
+	}
+	policy, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%s.%s is a %T, not a JSON string", address, attribute, value)
+	}
+	document, err := Parse(policy)
+	if err != nil {
+		return nil, fmt.Errorf("%s.%s: %w", address, attribute, err)
+	}
+	return document, nil
+}
+
+// RequireFromPlan parses the policy document of a planned resource, halting the test if it cannot.
+func RequireFromPlan(t testing.TestingT, plan *planassert.Plan, address, attribute string) *Document {
+	document, err := FromPlan(plan, address, attribute)
+	require.NoError(t, err)
+	return document
+}
+
+// AssertAllowedActions checks that the Allow statements of the document grant exactly the expected action patterns.
+func AssertAllowedActions(t testing.TestingT, document *Document, expected ...string) bool {
+	return assert.ElementsMatch(t, expected, document.AllowedActions(), "unexpected set of allowed actions")
+}
+
+// AssertGrants checks that the document allows the action on the resource.
+func AssertGrants(t testing.TestingT, document *Document, action, resource string) bool {
+	return assert.Truef(t, document.Grants(action, resource), "expected %s to be allowed on %s", action, resource)
+}
+
+// AssertNotGranted checks that the document does not allow the action on the resource. Use resource "*" to check
+// that the action is never granted on every resource.
+func AssertNotGranted(t testing.TestingT, document *Document, action, resource string) bool {
+	return assert.Falsef(t, document.Grants(action, resource), "expected %s not to be allowed on %s, allowed on %v",
+		action, resource, document.ResourcesFor(action))
+}
+
+// AssertResourcesFor checks that the Allow statements applying to the action name exactly the expected resources.
+func AssertResourcesFor(t testing.TestingT, document *Document, action string, expected ...string) bool {
+	return assert.ElementsMatchf(t, expected, document.ResourcesFor(action), "unexpected resources for %s", action)
+}
+
+// AssertCondition checks the values of a condition across the document. See Document.Condition for how keys match.
+func AssertCondition(t testing.TestingT, document *Document, operator, key string, expected ...string) bool {
+	return assert.ElementsMatchf(t, expected, document.Condition(operator, key), "unexpected values for %s %s", operator, key)
+}
+
+// AssertPrincipals checks the values of a principal type across the document.
+func AssertPrincipals(t testing.TestingT, document *Document, principalType string, expected ...string) bool {
+	return assert.ElementsMatchf(t, expected, document.Principals(principalType), "unexpected %s principals", principalType)
+}
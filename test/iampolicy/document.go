@@ -0,0 +1,272 @@
+// Package iampolicy parses IAM policy documents, such as the planned assume_role_policy of an aws_iam_role or policy of
+// an aws_iam_policy, and answers questions about them: which actions are allowed, on which resources, and under which
+// conditions.
+package iampolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// StringList is a policy element that may be written either as a single string or as a list of strings.
+type StringList []string
+
+// UnmarshalJSON accepts a string or a list of strings.
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = StringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("expected a string or a list of strings: %w", err)
+	}
+	*l = list
+	return nil
+}
+
+// Principal maps a principal type, e.g. Federated or AWS, to its values. The anonymous principal "*" is represented
+// as {"*": ["*"]}.
+type Principal map[string]StringList
+
+// UnmarshalJSON accepts "*" or an object of principal types.
+func (p *Principal) UnmarshalJSON(data []byte) error {
+	var wildcard string
+	if err := json.Unmarshal(data, &wildcard); err == nil {
+		*p = Principal{wildcard: {wildcard}}
+		return nil
+	}
+	var principals map[string]StringList
+	if err := json.Unmarshal(data, &principals); err != nil {
+		return fmt.Errorf("expected \"*\" or an object of principals: %w", err)
+	}
+	*p = principals
+	return nil
+}
+
+// Conditions maps a condition operator, e.g. StringEquals, to its condition keys and their values.
+type Conditions map[string]map[string]StringList
+
+// Statement is a single policy statement.
+type Statement struct {
+	Sid          string     `json:"Sid"`
+	Effect       string     `json:"Effect"`
+	Principal    Principal  `json:"Principal"`
+	NotPrincipal Principal  `json:"NotPrincipal"`
+	Action       StringList `json:"Action"`
+	NotAction    StringList `json:"NotAction"`
+	Resource     StringList `json:"Resource"`
+	NotResource  StringList `json:"NotResource"`
+	Condition    Conditions `json:"Condition"`
+}
+
+// Document is a parsed IAM policy document.
+type Document struct {
+	Version    string
+	Statements []Statement
+}
+
+// Parse parses a policy document. The Statement element may be a single statement or a list of them.
+func Parse(policy string) (*Document, error) {
+	var raw struct {
+		Version   string          `json:"Version"`
+		Statement json.RawMessage `json:"Statement"`
+	}
+	if err := json.Unmarshal([]byte(policy), &raw); err != nil {
+		return nil, fmt.Errorf("parsing policy document: %w", err)
+	}
+	if len(raw.Statement) == 0 {
+		return nil, fmt.Errorf("policy document has no Statement")
+	}
+
+	document := &Document{Version: raw.Version}
+	var single Statement
+	if err := json.Unmarshal(raw.Statement, &document.Statements); err != nil {
+		if err := json.Unmarshal(raw.Statement, &single); err != nil {
+			return nil, fmt.Errorf("parsing policy statements: %w", err)
+		}
+		document.Statements = []Statement{single}
+	}
+	for i, statement := range document.Statements {
+		if statement.Effect != "Allow" && statement.Effect != "Deny" {
+			return nil, fmt.Errorf("statement %d: invalid Effect %q", i, statement.Effect)
+		}
+	}
+	return document, nil
+}
+
+// Allows reports whether the statement has the Allow effect.
+func (s Statement) Allows() bool {
+	return s.Effect == "Allow"
+}
+
+// MatchesAction reports whether the statement applies to the action, honouring wildcards and NotAction. Action names
+// are case insensitive.
+func (s Statement) MatchesAction(action string) bool {
+	if len(s.NotAction) > 0 {
+		return !matchAny(s.NotAction, action, true)
+	}
+	return matchAny(s.Action, action, true)
+}
+
+// MatchesResource reports whether the statement applies to the resource ARN, honouring wildcards and NotResource.
+// Passing "*" asks whether the statement applies to every resource.
+func (s Statement) MatchesResource(resource string) bool {
+	if len(s.NotResource) > 0 {
+		return !matchAny(s.NotResource, resource, false)
+	}
+	return matchAny(s.Resource, resource, false)
+}
+
+// Unconditional reports whether the statement has no Condition element.
+func (s Statement) Unconditional() bool {
+	return len(s.Condition) == 0
+}
+
+// AllowedActions returns the action patterns of every Allow statement, sorted and de-duplicated.
+func (d *Document) AllowedActions() []string {
+	seen := map[string]bool{}
+	for _, statement := range d.Statements {
+		if !statement.Allows() {
+			continue
+		}
+		for _, action := range statement.Action {
+			seen[action] = true
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// StatementsFor returns the Allow statements that apply to the action.
+func (d *Document) StatementsFor(action string) []Statement {
+	var out []Statement
+	for _, statement := range d.Statements {
+		if statement.Allows() && statement.MatchesAction(action) {
+			out = append(out, statement)
+		}
+	}
+	return out
+}
+
+// ResourcesFor returns the resource patterns of the Allow statements that apply to the action, sorted and
+// de-duplicated.
+func (d *Document) ResourcesFor(action string) []string {
+	seen := map[string]bool{}
+	for _, statement := range d.StatementsFor(action) {
+		for _, resource := range statement.Resource {
+			seen[resource] = true
+		}
+	}
+	return sortedKeys(seen)
+}
+
+// Grants reports whether the document allows the action on the resource, ignoring conditions: some Allow statement
+// must match and no unconditional Deny statement may match.
+func (d *Document) Grants(action, resource string) bool {
+	allowed := false
+	for _, statement := range d.Statements {
+		if !statement.MatchesAction(action) || !statement.MatchesResource(resource) {
+			continue
+		}
+		if !statement.Allows() && statement.Unconditional() {
+			return false
+		}
+		if statement.Allows() {
+			allowed = true
+		}
+	}
+	return allowed
+}
+
+// GrantsUnconditionally reports whether the document allows the action on the resource through a statement without a
+// Condition element, and no unconditional Deny statement matches.
+func (d *Document) GrantsUnconditionally(action, resource string) bool {
+	allowed := false
+	for _, statement := range d.Statements {
+		if !statement.Unconditional() || !statement.MatchesAction(action) || !statement.MatchesResource(resource) {
+			continue
+		}
+		if !statement.Allows() {
+			return false
+		}
+		allowed = true
+	}
+	return allowed
+}
+
+// Condition returns the values of every condition with the operator and key across all statements. A key starting
+// with ":" matches by suffix, so ":sub" finds "oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE:sub" without knowing the
+// issuer.
+func (d *Document) Condition(operator, key string) []string {
+	var values []string
+	for _, statement := range d.Statements {
+		for conditionKey, conditionValues := range statement.Condition[operator] {
+			if conditionKey == key || (strings.HasPrefix(key, ":") && strings.HasSuffix(conditionKey, key)) {
+				values = append(values, conditionValues...)
+			}
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// Principals returns the values of the principal type across all statements, sorted.
+func (d *Document) Principals(principalType string) []string {
+	var values []string
+	for _, statement := range d.Statements {
+		values = append(values, statement.Principal[principalType]...)
+	}
+	sort.Strings(values)
+	return values
+}
+
+func matchAny(patterns []string, value string, caseInsensitive bool) bool {
+	for _, pattern := range patterns {
+		if Match(pattern, value, caseInsensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether value matches an IAM pattern in which "*" matches any run of characters and "?" matches any
+// single character.
+func Match(pattern, value string, caseInsensitive bool) bool {
+	if caseInsensitive {
+		pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+	}
+	// Iterative wildcard matching with backtracking to the last "*".
+	p, v, star, mark := 0, 0, -1, 0
+	for v < len(value) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == value[v]):
+			p++
+			v++
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, v
+			p++
+		case star >= 0:
+			p = star + 1
+			mark++
+			v = mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
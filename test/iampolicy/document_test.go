@@ -0,0 +1,111 @@
+package iampolicy
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const externalDNSTrust = `{
+  "Version": "2012-10-17",
+  "Statement": [{
+    "Action": "sts:AssumeRoleWithWebIdentity",
+    "Effect": "Allow",
+    "Principal": {"Federated": "arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE"},
+    "Condition": {
+      "StringEquals": {
+        "oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE:sub": "system:serviceaccount:kube-system:external-dns",
+        "oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE:aud": "sts.amazonaws.com"
+      }
+    }
+  }]
+}`
+
+const externalDNSPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Action": ["route53:ChangeResourceRecordSets"], "Resource": "arn:aws:route53:::hostedzone/*"},
+    {"Effect": "Allow", "Action": ["route53:ListHostedZones", "route53:ListResourceRecordSets"], "Resource": "*"}
+  ]
+}`
+
+func TestParseTrustPolicy(t *testing.T) {
+	t.Parallel()
+
+	document, err := Parse(externalDNSTrust)
+	require.NoError(t, err)
+
+	AssertCondition(t, document, "StringEquals", ":sub", "system:serviceaccount:kube-system:external-dns")
+	AssertCondition(t, document, "StringEquals", ":aud", "sts.amazonaws.com")
+	AssertPrincipals(t, document, "Federated", "arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE")
+	AssertAllowedActions(t, document, "sts:AssumeRoleWithWebIdentity")
+	assert.Empty(t, document.Condition("StringLike", ":sub"))
+}
+
+func TestParsePermissionsPolicy(t *testing.T) {
+	t.Parallel()
+
+	document, err := Parse(externalDNSPolicy)
+	require.NoError(t, err)
+
+	AssertAllowedActions(t, document, "route53:ChangeResourceRecordSets", "route53:ListHostedZones", "route53:ListResourceRecordSets")
+	AssertResourcesFor(t, document, "route53:ChangeResourceRecordSets", "arn:aws:route53:::hostedzone/*")
+	AssertGrants(t, document, "route53:ChangeResourceRecordSets", "arn:aws:route53:::hostedzone/Z123")
+	AssertNotGranted(t, document, "route53:ChangeResourceRecordSets", "*")
+	AssertNotGranted(t, document, "route53:DeleteHostedZone", "arn:aws:route53:::hostedzone/Z123")
+	AssertGrants(t, document, "ROUTE53:listhostedzones", "*")
+}
+
+func TestSingleStatementAndDeny(t *testing.T) {
+	t.Parallel()
+
+	document, err := Parse(`{
+	  "Version": "2012-10-17",
+	  "Statement": [
+	    {"Effect": "Allow", "Action": "ec2:*", "Resource": "*"},
+	    {"Effect": "Deny", "Action": "ec2:Terminate*", "Resource": "*"},
+	    {"Effect": "Deny", "NotAction": "ec2:Describe*", "Resource": "*", "Condition": {"Bool": {"aws:SecureTransport": "false"}}}
+	  ]
+	}`)
+	require.NoError(t, err)
+
+	assert.True(t, document.Grants("ec2:DescribeVpcs", "*"))
+	assert.False(t, document.Grants("ec2:TerminateInstances", "*"), "unconditional deny wins")
+	assert.True(t, document.Grants("ec2:RunInstances", "*"), "conditional deny is ignored")
+
+	single, err := Parse(`{"Version": "2012-10-17", "Statement": {"Effect": "Allow", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::bucket/*"}}`)
+	require.NoError(t, err)
+	assert.True(t, single.Grants("s3:GetObject", "arn:aws:s3:::bucket/key"))
+
+	_, err = Parse(`{"Version": "2012-10-17", "Statement": [{"Effect": "Maybe", "Action": "*", "Resource": "*"}]}`)
+	assert.Error(t, err)
+}
+
+func TestALBControllerPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy, err := os.ReadFile("../../modules/iam-roles/policies/alb-controller-policy.json")
+	require.NoError(t, err)
+	document, err := Parse(string(policy))
+	require.NoError(t, err)
+
+	// Deleting security groups is only allowed for groups tagged as managed by the controller
+	AssertGrants(t, document, "ec2:DeleteSecurityGroup", "*")
+	assert.False(t, document.GrantsUnconditionally("ec2:DeleteSecurityGroup", "*"))
+	AssertCondition(t, document, "Null", "aws:ResourceTag/elbv2.k8s.aws/cluster", "false", "false", "false", "false")
+	AssertNotGranted(t, document, "iam:PassRole", "*")
+	AssertCondition(t, document, "StringEquals", "iam:AWSServiceName", "elasticloadbalancing.amazonaws.com")
+}
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, Match("ec2:Describe*", "ec2:DescribeVpcs", false))
+	assert.True(t, Match("arn:aws:ec2:*:*:security-group/*", "arn:aws:ec2:us-east-1:1:security-group/sg-1", false))
+	assert.True(t, Match("s3:Get?bject", "s3:GetObject", false))
+	assert.False(t, Match("ec2:Describe*", "ec2:RunInstances", false))
+	assert.False(t, Match("arn:aws:route53:::hostedzone/*", "*", false))
+	assert.True(t, Match("*", "*", false))
+}
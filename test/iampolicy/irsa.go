@@ -0,0 +1,232 @@
+package iampolicy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// webIdentityAction is the action an IRSA trust policy allows the cluster OIDC provider to perform.
+const webIdentityAction = "sts:AssumeRoleWithWebIdentity"
+
+// serviceAccountPrefix prefixes the sub claim of every Kubernetes service account token.
+const serviceAccountPrefix = "system:serviceaccount:"
+
+// Severity is how serious a lint finding is. Error findings fail the linter.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is a problem with the trust policy of an IRSA role.
+type Finding struct {
+	// Address is the planned address of the role, empty when linting a document directly.
+	Address string `json:"address,omitempty"`
+
+	// Role is the planned name of the role.
+	Role string `json:"role,omitempty"`
+
+	// OU is the organizational unit the role was created for, from its OU tag. Empty for shared roles.
+	OU string `json:"ou,omitempty"`
+
+	Severity Severity `json:"severity"`
+
+	// Operator, Key and Value identify the offending condition, when there is one.
+	Operator string `json:"operator,omitempty"`
+	Key      string `json:"key,omitempty"`
+	Value    string `json:"value,omitempty"`
+
+	Message string `json:"message"`
+}
+
+// String renders the finding on one line, e.g.
+// error: aws_iam_role.rds_access["ou-app-001"] (OU ou-app-001): wildcard ... under StringEquals ...
+func (f Finding) String() string {
+	var b strings.Builder
+	b.WriteString(string(f.Severity))
+	b.WriteString(": ")
+	if f.Address != "" {
+		b.WriteString(f.Address)
+		if f.OU != "" {
+			fmt.Fprintf(&b, " (OU %s)", f.OU)
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(f.Message)
+	return b.String()
+}
+
+// HasErrors reports whether any of the findings is an error.
+func HasErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// exactOperators compare condition values literally, so "*" and "?" in their values are not wildcards.
+var exactOperators = []string{
+	"StringEquals",
+	"StringEqualsIgnoreCase",
+	"ForAnyValue:StringEquals",
+	"ForAllValues:StringEquals",
+}
+
+// patternOperators treat "*" and "?" in their values as wildcards.
+var patternOperators = []string{
+	"StringLike",
+	"ForAnyValue:StringLike",
+	"ForAllValues:StringLike",
+}
+
+// LintTrust checks the web identity statements of an IRSA trust policy:
+//
+//   - a wildcard in a StringEquals value is compared literally, so the role can never be assumed, and switching the
+//     operator to StringLike would let every matching service account assume it;
+//   - a StringLike sub pattern must pin the namespace, and should pin the service account;
+//   - the sub claim must be restricted, and the aud claim should be.
+//
+// Documents without a sts:AssumeRoleWithWebIdentity statement yield no findings.
+func LintTrust(document *Document) []Finding {
+	var findings []Finding
+	for _, statement := range document.Statements {
+		if !statement.Allows() || !statement.MatchesAction(webIdentityAction) {
+			continue
+		}
+
+		restrictsSub, restrictsAud := false, false
+		for _, operator := range sortedOperators(statement.Condition) {
+			exact := contains(exactOperators, operator)
+			pattern := contains(patternOperators, operator)
+			for _, key := range sortedConditionKeys(statement.Condition[operator]) {
+				isSub, isAud := strings.HasSuffix(key, ":sub"), strings.HasSuffix(key, ":aud")
+				restrictsSub = restrictsSub || (isSub && (exact || pattern))
+				restrictsAud = restrictsAud || (isAud && (exact || pattern))
+
+				for _, value := range statement.Condition[operator][key] {
+					switch {
+					case exact && strings.ContainsAny(value, "*?"):
+						findings = append(findings, Finding{
+							Severity: SeverityError,
+							Operator: operator,
+							Key:      key,
+							Value:    value,
+							Message: fmt.Sprintf("wildcard %q under %s is compared literally and never matches; "+
+								"name the service account, or use StringLike with a pinned namespace", value, operator),
+						})
+					case pattern && isSub:
+						if finding, ok := lintSubPattern(value); ok {
+							finding.Operator, finding.Key, finding.Value = operator, key, value
+							findings = append(findings, finding)
+						}
+					}
+				}
+			}
+		}
+
+		if !restrictsSub {
+			findings = append(findings, Finding{
+				Severity: SeverityError,
+				Message:  "web identity trust does not restrict the :sub claim, so every service account in the cluster can assume the role",
+			})
+		}
+		if !restrictsAud {
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Message:  "web identity trust does not restrict the :aud claim to sts.amazonaws.com",
+			})
+		}
+	}
+	return findings
+}
+
+// lintSubPattern checks a StringLike pattern for the sub claim.
+func lintSubPattern(value string) (Finding, bool) {
+	if !strings.ContainsAny(value, "*?") {
+		return Finding{}, false
+	}
+	if !strings.HasPrefix(value, serviceAccountPrefix) {
+		return Finding{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("StringLike subject %q matches more than Kubernetes service accounts", value),
+		}, true
+	}
+	parts := strings.SplitN(strings.TrimPrefix(value, serviceAccountPrefix), ":", 2)
+	if strings.ContainsAny(parts[0], "*?") || len(parts) < 2 {
+		return Finding{
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("StringLike subject %q matches service accounts in any namespace", value),
+		}, true
+	}
+	return Finding{
+		Severity: SeverityWarning,
+		Message:  fmt.Sprintf("StringLike subject %q matches every service account in namespace %s", value, parts[0]),
+	}, true
+}
+
+// LintPlan lints the trust policy of every planned aws_iam_role that can be assumed with a web identity, and reports
+// which role and OU each finding affects. Roles whose trust policy is only known after apply are reported as warnings,
+// since they cannot be checked.
+func LintPlan(plan *planassert.Plan) ([]Finding, error) {
+	var findings []Finding
+	for _, role := range plan.OfType("aws_iam_role") {
+		name, _ := role.Attribute("name")
+		ou, _ := role.Attribute("tags.OU")
+		roleName, _ := name.(string)
+		ouID, _ := ou.(string)
+
+		if role.IsUnknown("assume_role_policy") {
+			findings = append(findings, Finding{
+				Address:  role.Address,
+				Role:     roleName,
+				OU:       ouID,
+				Severity: SeverityWarning,
+				Message:  "trust policy is only known after apply and cannot be linted",
+			})
+			continue
+		}
+
+		document, err := FromPlan(plan, role.Address, "assume_role_policy")
+		if err != nil {
+			return nil, err
+		}
+		for _, finding := range LintTrust(document) {
+			finding.Address, finding.Role, finding.OU = role.Address, roleName, ouID
+			findings = append(findings, finding)
+		}
+	}
+	return findings, nil
+}
+
+func sortedOperators(conditions Conditions) []string {
+	operators := make([]string, 0, len(conditions))
+	for operator := range conditions {
+		operators = append(operators, operator)
+	}
+	sort.Strings(operators)
+	return operators
+}
+
+func sortedConditionKeys(keys map[string]StringList) []string {
+	out := make([]string, 0, len(keys))
+	for key := range keys {
+		out = append(out, key)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package iampolicy
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+const issuer = "oidc.eks.us-east-1.amazonaws.com/id/EXAMPLE"
+
+func trustPolicy(operator, sub string) string {
+	conditions := map[string]map[string]string{
+		"StringEquals": {issuer + ":aud": "sts.amazonaws.com"},
+	}
+	if conditions[operator] == nil {
+		conditions[operator] = map[string]string{}
+	}
+	conditions[operator][issuer+":sub"] = sub
+
+	policy, err := json.Marshal(map[string]interface{}{
+		"Version": "2012-10-17",
+		"Statement": []map[string]interface{}{{
+			"Action":    "sts:AssumeRoleWithWebIdentity",
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Federated": "arn:aws:iam::123456789012:oidc-provider/" + issuer},
+			"Condition": conditions,
+		}},
+	})
+	if err != nil {
+		panic(err)
+	}
+	return string(policy)
+}
+
+func TestLintTrust(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		operator string
+		sub      string
+		severity Severity
+		message  string
+	}{
+		{
+			name:     "wildcard under StringEquals",
+			operator: "StringEquals",
+			sub:      "system:serviceaccount:*:*",
+			severity: SeverityError,
+			message:  `wildcard "system:serviceaccount:*:*" under StringEquals is compared literally and never matches; name the service account, or use StringLike with a pinned namespace`,
+		},
+		{
+			name:     "any namespace under StringLike",
+			operator: "StringLike",
+			sub:      "system:serviceaccount:*:*",
+			severity: SeverityError,
+			message:  `StringLike subject "system:serviceaccount:*:*" matches service accounts in any namespace`,
+		},
+		{
+			name:     "everything under StringLike",
+			operator: "StringLike",
+			sub:      "*",
+			severity: SeverityError,
+			message:  `StringLike subject "*" matches more than Kubernetes service accounts`,
+		},
+		{
+			name:     "whole namespace under StringLike",
+			operator: "StringLike",
+			sub:      "system:serviceaccount:payments:*",
+			severity: SeverityWarning,
+			message:  `StringLike subject "system:serviceaccount:payments:*" matches every service account in namespace payments`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+
+			document, err := Parse(trustPolicy(testCase.operator, testCase.sub))
+			require.NoError(t, err)
+
+			findings := LintTrust(document)
+			require.Len(t, findings, 1)
+			assert.Equal(t, testCase.severity, findings[0].Severity)
+			assert.Equal(t, testCase.operator, findings[0].Operator)
+			assert.Equal(t, issuer+":sub", findings[0].Key)
+			assert.Equal(t, testCase.sub, findings[0].Value)
+			assert.Equal(t, testCase.message, findings[0].Message)
+		})
+	}
+}
+
+func TestLintTrustAcceptsPinnedServiceAccount(t *testing.T) {
+	t.Parallel()
+
+	for _, operator := range []string{"StringEquals", "StringLike"} {
+		document, err := Parse(trustPolicy(operator, "system:serviceaccount:kube-system:external-dns"))
+		require.NoError(t, err)
+		assert.Empty(t, LintTrust(document), operator)
+	}
+}
+
+func TestLintTrustMissingConditions(t *testing.T) {
+	t.Parallel()
+
+	document, err := Parse(`{
+	  "Version": "2012-10-17",
+	  "Statement": [
+	    {"Action": "sts:AssumeRoleWithWebIdentity", "Effect": "Allow", "Principal": {"Federated": "arn:aws:iam::123456789012:oidc-provider/x"}},
+	    {"Action": "sts:AssumeRole", "Effect": "Allow", "Principal": {"Service": "eks.amazonaws.com"}}
+	  ]
+	}`)
+	require.NoError(t, err)
+
+	findings := LintTrust(document)
+	require.Len(t, findings, 2)
+	assert.Equal(t, SeverityError, findings[0].Severity)
+	assert.Contains(t, findings[0].Message, "every service account in the cluster")
+	assert.Equal(t, SeverityWarning, findings[1].Severity)
+	assert.True(t, HasErrors(findings))
+}
+
+func TestLintPlanReportsOURole(t *testing.T) {
+	t.Parallel()
+
+	trust, err := json.Marshal(trustPolicy("StringEquals", "system:serviceaccount:*:*"))
+	require.NoError(t, err)
+	shared, err := json.Marshal(trustPolicy("StringEquals", "system:serviceaccount:kube-system:external-dns"))
+	require.NoError(t, err)
+
+	plan, err := planassert.ParseJSON([]byte(fmt.Sprintf(`{
+	  "format_version": "1.2",
+	  "planned_values": {"root_module": {"resources": [
+	    {"address": "aws_iam_role.rds_access[\"ou-app-001\"]", "mode": "managed", "type": "aws_iam_role", "name": "rds_access", "index": "ou-app-001",
+	     "values": {"name": "cluster-app-team-rds-access", "assume_role_policy": %s, "tags": {"OU": "ou-app-001"}}},
+	    {"address": "aws_iam_role.external_dns", "mode": "managed", "type": "aws_iam_role", "name": "external_dns",
+	     "values": {"name": "cluster-external-dns", "assume_role_policy": %s}},
+	    {"address": "aws_iam_role.pending", "mode": "managed", "type": "aws_iam_role", "name": "pending", "values": {"name": "cluster-pending"}}
+	  ]}},
+	  "resource_changes": [
+	    {"address": "aws_iam_role.rds_access[\"ou-app-001\"]", "mode": "managed", "type": "aws_iam_role", "name": "rds_access", "index": "ou-app-001", "change": {"actions": ["create"]}},
+	    {"address": "aws_iam_role.external_dns", "mode": "managed", "type": "aws_iam_role", "name": "external_dns", "change": {"actions": ["create"]}},
+	    {"address": "aws_iam_role.pending", "mode": "managed", "type": "aws_iam_role", "name": "pending",
+	     "change": {"actions": ["create"], "after_unknown": {"assume_role_policy": true}}}
+	  ]
+	}`, trust, shared)))
+	require.NoError(t, err)
+
+	findings, err := LintPlan(plan)
+	require.NoError(t, err)
+	require.Len(t, findings, 2)
+
+	// Findings are ordered by role address
+	assert.Equal(t, "aws_iam_role.pending", findings[0].Address)
+	assert.Equal(t, SeverityWarning, findings[0].Severity)
+
+	assert.Equal(t, `aws_iam_role.rds_access["ou-app-001"]`, findings[1].Address)
+	assert.Equal(t, "cluster-app-team-rds-access", findings[1].Role)
+	assert.Equal(t, "ou-app-001", findings[1].OU)
+	assert.Equal(t, SeverityError, findings[1].Severity)
+	assert.Equal(t, `error: aws_iam_role.rds_access["ou-app-001"] (OU ou-app-001): `+findings[1].Message, findings[1].String())
+}
@@ -4,293 +4,210 @@ import (
 	"testing"
 
 	"github.com/gruntwork-io/terratest/modules/terraform"
-	"github.com/stretchr/testify/assert"
+
+	"github.com/your-org/multi-az-eks-cluster/test/crossregion"
+	"github.com/your-org/multi-az-eks-cluster/test/harness"
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+	"github.com/your-org/multi-az-eks-cluster/test/rdsreplica"
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
 )
 
 // TestMultiRegionEKSIntegration tests the complete multi-region setup
 func TestMultiRegionEKSIntegration(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "..",
-		Vars: map[string]interface{}{
-			"primary_region":   "us-east-1",
-			"secondary_region": "us-west-2",
-			"primary_vpc_id":   "vpc-primary123",
-			"secondary_vpc_id": "vpc-secondary456",
-			"primary_availability_zones":   []string{"us-east-1a", "us-east-1b", "us-east-1c"},
-			"secondary_availability_zones": []string{"us-west-2a", "us-west-2b", "us-west-2c"},
-			"cluster_name_prefix":          "test-multi-region",
-			"environment":                  "test",
-			"kubernetes_version":           "1.28",
-			"node_groups": map[string]interface{}{
-				"general": map[string]interface{}{
-					"desired_size":   6,
-					"min_size":       3,
-					"max_size":       15,
-					"instance_types": []string{"t3.large"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      50,
-				},
-			},
-			"rds_config": map[string]interface{}{
-				"engine":                  "postgres",
-				"engine_version":          "15.4",
-				"instance_class":          "db.t3.medium",
-				"allocated_storage":       100,
-				"database_name":           "testdb",
-				"master_username":         "dbadmin",
-				"backup_retention_period": 7,
-				"multi_az":                true,
-				"storage_encrypted":       true,
-			},
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"admin"},
-				},
-			},
-		},
-		PlanOnly: true,
+	fixture := harness.NewFixture("us-east-1", "vpc-primary123", "vpc-secondary456")
+
+	runStages(t, fixture, func() *terraform.Options {
+		return rootOptions(t, tfvars.BaselineRoot(fixture.VPCs[0].ID, fixture.VPCs[1].ID))
+	}, func(plan *planassert.Plan) {
+		// Should create resources in both regions
+		// Each region: EKS cluster, node groups, RDS, IAM roles
+		// Primary: 49 + 6 per OU + 2 per node group (RDS primary)
+		// Secondary: 46 + 6 per OU + 2 per node group (RDS read replica with its own KMS key and alias)
+		// Plus: VPC peering connection and accepter
+		planassert.AssertCounts(t, plan, 113, 0, 0)
+
+		for _, region := range []string{"module.primary_region", "module.secondary_region"} {
+			planassert.AssertResourceCount(t, plan, region+".module.eks.aws_eks_cluster.main", 1)
+			planassert.AssertResourceCount(t, plan, region+".module.node_groups.aws_eks_node_group.main[*]", 1)
+			planassert.AssertResourceCount(t, plan, region+".module.iam_roles.aws_iam_role.rds_access[*]", 1)
+		}
+		planassert.AssertResourceCount(t, plan, "aws_vpc_peering_connection.primary_to_secondary", 1)
+		planassert.AssertResourceCount(t, plan, "aws_vpc_peering_connection_accepter.secondary", 1)
+
+		planassert.AssertAttributeEquals(t, plan, "module.primary_region.module.eks.aws_eks_cluster.main", "name", "test-multi-region-primary")
+		planassert.AssertAttributeEquals(t, plan, "module.secondary_region.module.eks.aws_eks_cluster.main", "name", "test-multi-region-secondary")
+
+		var violations []string
+		for _, region := range []string{"module.primary_region", "module.secondary_region"} {
+			violations = append(violations,
+				"eks-public-endpoint-open "+region+".module.eks.aws_eks_cluster.main",
+				"sg-rule-ingress-open "+region+".module.eks.aws_security_group_rule.cluster_ingress_workstation_https",
+				"rds-egress-open "+region+".module.rds[0].aws_security_group_rule.rds_egress",
+			)
+		}
+		assertPolicy(t, plan, violations...)
+		crossregion.AssertSymmetric(t, plan)
+		assertTopology(t, plan)
+		assertGolden(t, plan)
 	})
-
-	defer terraform.Destroy(t, terraformOptions)
-
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
-
-	// Should create resources in both regions
-	// Each region: EKS cluster, node groups, RDS, IAM roles
-	// Plus: VPC peering
-	assert.Greater(t, resourceCounts.Add, 60, "Should create more than 60 resources for multi-region setup")
 }
 
 func TestMultiRegionEKSVPCPeering(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "..",
-		Vars: map[string]interface{}{
-			"primary_region":               "us-east-1",
-			"secondary_region":             "eu-west-1",
-			"primary_vpc_id":               "vpc-primary123",
-			"secondary_vpc_id":             "vpc-secondary456",
-			"primary_availability_zones":   []string{"us-east-1a", "us-east-1b", "us-east-1c"},
-			"secondary_availability_zones": []string{"eu-west-1a", "eu-west-1b", "eu-west-1c"},
-			"cluster_name_prefix":          "test-vpc-peering",
-			"environment":                  "test",
-			"kubernetes_version":           "1.28",
-			"node_groups": map[string]interface{}{
-				"workers": map[string]interface{}{
-					"desired_size":   3,
-					"min_size":       1,
-					"max_size":       9,
-					"instance_types": []string{"t3.medium"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      50,
-				},
-			},
-			"rds_config": map[string]interface{}{
-				"engine":                  "postgres",
-				"engine_version":          "15.4",
-				"instance_class":          "db.t3.small",
-				"allocated_storage":       50,
-				"database_name":           "testdb",
-				"master_username":         "admin",
-				"backup_retention_period": 7,
-				"multi_az":                false,
-				"storage_encrypted":       true,
-			},
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"view"},
-				},
-			},
-		},
-		PlanOnly: true,
+	fixture := harness.NewFixture("us-east-1", "vpc-primary123", "vpc-secondary456")
+
+	vars := tfvars.BaselineRoot(fixture.VPCs[0].ID, fixture.VPCs[1].ID).WithSecondaryRegion("eu-west-1")
+	vars.ClusterNamePrefix = "test-vpc-peering"
+	vars.NodeGroups = tfvars.NodeGroups{"workers": smallNodeGroup()}
+	vars.RDSConfig = smallRDSConfig()
+	vars.OrganizationalUnits[0].Permissions = []string{"view"}
+
+	runStages(t, fixture, func() *terraform.Options {
+		return rootOptions(t, vars)
+	}, func(plan *planassert.Plan) {
+		const peering = "aws_vpc_peering_connection.primary_to_secondary"
+		planassert.AssertAttributeEquals(t, plan, peering, "vpc_id", fixture.VPCs[0].ID)
+		planassert.AssertAttributeEquals(t, plan, peering, "peer_vpc_id", fixture.VPCs[1].ID)
+		planassert.AssertAttributeEquals(t, plan, peering, "peer_region", "eu-west-1")
+		planassert.AssertAttributeEquals(t, plan, peering, "auto_accept", false)
+		planassert.AssertAttributeEquals(t, plan, "aws_vpc_peering_connection_accepter.secondary", "auto_accept", true)
+		planassert.AssertAttributeUnknown(t, plan, "aws_vpc_peering_connection_accepter.secondary", "vpc_peering_connection_id")
+		crossregion.AssertSymmetric(t, plan)
 	})
-
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should include VPC peering between regions")
 }
 
 func TestMultiRegionEKSRDSReplication(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "..",
-		Vars: map[string]interface{}{
-			"primary_region":               "us-east-1",
-			"secondary_region":             "us-west-2",
-			"primary_vpc_id":               "vpc-primary123",
-			"secondary_vpc_id":             "vpc-secondary456",
-			"primary_availability_zones":   []string{"us-east-1a", "us-east-1b", "us-east-1c"},
-			"secondary_availability_zones": []string{"us-west-2a", "us-west-2b", "us-west-2c"},
-			"cluster_name_prefix":          "test-rds-replication",
-			"environment":                  "production",
-			"kubernetes_version":           "1.28",
-			"node_groups": map[string]interface{}{
-				"general": map[string]interface{}{
-					"desired_size":   9,
-					"min_size":       6,
-					"max_size":       18,
-					"instance_types": []string{"m5.xlarge"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      100,
-				},
-			},
-			"rds_config": map[string]interface{}{
-				"engine":                  "postgres",
-				"engine_version":          "15.4",
-				"instance_class":          "db.r6g.xlarge",
-				"allocated_storage":       500,
-				"database_name":           "proddb",
-				"master_username":         "dbadmin",
-				"backup_retention_period": 30,
-				"multi_az":                true,
-				"storage_encrypted":       true,
-			},
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "prod-ops",
-					"ou_id":       "ou-ops-001",
-					"permissions": []string{"admin"},
-				},
-			},
-		},
-		PlanOnly: true,
+	fixture := harness.NewFixture("us-east-1", "vpc-primary123", "vpc-secondary456")
+
+	general := tfvars.BaselineNodeGroup().WithSizes(6, 9, 18).WithInstanceTypes("m5.xlarge")
+	general.DiskSize = 100
+
+	vars := tfvars.BaselineRoot(fixture.VPCs[0].ID, fixture.VPCs[1].ID)
+	vars.ClusterNamePrefix = "test-rds-replication"
+	vars.Environment = "production"
+	vars.NodeGroups = tfvars.NodeGroups{"general": general}
+	vars.RDSConfig.InstanceClass = "db.r6g.xlarge"
+	vars.RDSConfig.AllocatedStorage = 500
+	vars.RDSConfig.DatabaseName = "proddb"
+	vars.RDSConfig.BackupRetentionPeriod = 30
+	vars.OrganizationalUnits = tfvars.OUs{{Name: "prod-ops", OUID: "ou-ops-001", Permissions: []string{"admin"}}}
+
+	runStages(t, fixture, func() *terraform.Options {
+		return rootOptions(t, vars)
+	}, func(plan *planassert.Plan) {
+		planassert.AssertResourceCount(t, plan, "module.primary_region.module.rds[0].aws_db_instance.main[*]", 1)
+		planassert.AssertNoResourcesMatching(t, plan, "module.primary_region.module.rds[0].aws_db_instance.replica[*]")
+		planassert.AssertResourceCount(t, plan, "module.secondary_region.module.rds[0].aws_db_instance.replica[*]", 1)
+		planassert.AssertNoResourcesMatching(t, plan, "module.secondary_region.module.rds[0].aws_db_instance.main[*]")
+
+		// The replica source is the ARN of the primary, which is only known once the primary exists
+		planassert.AssertAttributeUnknown(t, plan, "module.secondary_region.module.rds[0].aws_db_instance.replica[0]", "replicate_source_db")
+		planassert.AssertAttributeEquals(t, plan, "module.primary_region.module.rds[0].aws_db_instance.main[0]", "identifier", "test-rds-replication-primary-db")
+		planassert.AssertAttributeEquals(t, plan, "module.secondary_region.module.rds[0].aws_db_instance.replica[0]", "identifier", "test-rds-replication-secondary-db")
+		rdsreplica.AssertReplica(t, plan, rdsreplica.Options{
+			Module:       "module.secondary_region.module.rds[0].",
+			Region:       vars.SecondaryRegion,
+			SourceRegion: vars.PrimaryRegion,
+		})
+		crossregion.AssertSymmetric(t, plan)
+		assertTopology(t, plan)
 	})
-
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should include RDS primary and read replica")
 }
 
 func TestMultiRegionEKSProduction(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "..",
-		Vars: map[string]interface{}{
-			"primary_region":               "us-east-1",
-			"secondary_region":             "us-west-2",
-			"primary_vpc_id":               "vpc-primary123",
-			"secondary_vpc_id":             "vpc-secondary456",
-			"primary_availability_zones":   []string{"us-east-1a", "us-east-1b", "us-east-1c"},
-			"secondary_availability_zones": []string{"us-west-2a", "us-west-2b", "us-west-2c"},
-			"cluster_name_prefix":          "production",
-			"environment":                  "production",
-			"kubernetes_version":           "1.28",
-			"node_groups": map[string]interface{}{
-				"general": map[string]interface{}{
-					"desired_size":   9,
-					"min_size":       6,
-					"max_size":       18,
-					"instance_types": []string{"m5.xlarge", "m5a.xlarge"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      100,
-				},
-				"spot": map[string]interface{}{
-					"desired_size":   6,
-					"min_size":       0,
-					"max_size":       15,
-					"instance_types": []string{"m5.xlarge", "m5a.xlarge", "m5n.xlarge"},
-					"capacity_type":  "SPOT",
-					"disk_size":      100,
-				},
-			},
-			"rds_config": map[string]interface{}{
-				"engine":                  "postgres",
-				"engine_version":          "15.4",
-				"instance_class":          "db.r6g.2xlarge",
-				"allocated_storage":       1000,
-				"database_name":           "proddb",
-				"master_username":         "dbadmin",
-				"backup_retention_period": 30,
-				"multi_az":                true,
-				"storage_encrypted":       true,
-			},
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "production-ops",
-					"ou_id":       "ou-prod-ops-001",
-					"permissions": []string{"admin", "deploy", "view"},
-				},
-				{
-					"name":        "production-dev",
-					"ou_id":       "ou-prod-dev-001",
-					"permissions": []string{"deploy", "view"},
-				},
-				{
-					"name":        "production-readonly",
-					"ou_id":       "ou-prod-ro-001",
-					"permissions": []string{"view"},
-				},
-			},
-			"tags": map[string]string{
-				"Environment": "production",
-				"ManagedBy":   "terraform",
-				"Team":        "platform",
-			},
-		},
-		PlanOnly: true,
+	fixture := harness.NewFixture("us-east-1", "vpc-primary123", "vpc-secondary456")
+
+	runStages(t, fixture, func() *terraform.Options {
+		return rootOptions(t, productionRootVars(fixture))
+	}, func(plan *planassert.Plan) {
+		// Production setup with 3 OUs, 2 node groups per region, multi-AZ RDS
+		planassert.AssertCounts(t, plan, 141, 0, 0)
+		planassert.AssertTypeCount(t, plan, "aws_eks_cluster", 2)
+		planassert.AssertTypeCount(t, plan, "aws_eks_node_group", 4)
+		planassert.AssertTypeCount(t, plan, "aws_eks_access_entry", 6)
+		planassert.AssertTypeCount(t, plan, "aws_db_instance", 2)
+		planassert.AssertTypeCount(t, plan, "aws_nat_gateway", 0)
+
+		planassert.AssertAttributeEquals(t, plan, "module.primary_region.module.rds[0].aws_db_instance.main[0]", "instance_class", "db.r6g.2xlarge")
+		planassert.AssertAttributeEquals(t, plan, "module.primary_region.module.rds[0].aws_db_instance.main[0]", "allocated_storage", 1000)
+		crossregion.AssertSymmetric(t, plan)
+
+		// About 3,130 USD a month per region at the 2024-06-01 prices, most of it the general node group and the
+		// multi-AZ database and replica
+		assertRootBudget(t, plan, productionMonthlyBudget)
 	})
-
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
-
-	// Production setup with 3 OUs, 2 node groups per region, multi-AZ RDS
-	assert.Greater(t, resourceCounts.Add, 80, "Should create more than 80 resources for production multi-region setup")
 }
 
+// productionMonthlyBudget is the monthly cost in USD the production deployment must stay within.
+const productionMonthlyBudget = 7000
+
 func TestMultiRegionEKSOutputs(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "..",
-		Vars: map[string]interface{}{
-			"primary_region":               "us-east-1",
-			"secondary_region":             "us-west-2",
-			"primary_vpc_id":               "vpc-primary123",
-			"secondary_vpc_id":             "vpc-secondary456",
-			"primary_availability_zones":   []string{"us-east-1a", "us-east-1b", "us-east-1c"},
-			"secondary_availability_zones": []string{"us-west-2a", "us-west-2b", "us-west-2c"},
-			"cluster_name_prefix":          "test-outputs",
-			"environment":                  "test",
-			"node_groups": map[string]interface{}{
-				"workers": map[string]interface{}{
-					"desired_size":   3,
-					"min_size":       1,
-					"max_size":       9,
-					"instance_types": []string{"t3.medium"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      50,
-				},
-			},
-			"rds_config": map[string]interface{}{
-				"engine":                  "postgres",
-				"engine_version":          "15.4",
-				"instance_class":          "db.t3.small",
-				"allocated_storage":       50,
-				"database_name":           "testdb",
-				"master_username":         "admin",
-				"backup_retention_period": 7,
-				"multi_az":                false,
-				"storage_encrypted":       true,
-			},
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"admin"},
-				},
-			},
-		},
-		PlanOnly: true,
+	fixture := harness.NewFixture("us-east-1", "vpc-primary123", "vpc-secondary456")
+
+	vars := tfvars.BaselineRoot(fixture.VPCs[0].ID, fixture.VPCs[1].ID)
+	vars.ClusterNamePrefix = "test-outputs"
+	vars.KubernetesVersion = ""
+	vars.NodeGroups = tfvars.NodeGroups{"workers": smallNodeGroup()}
+	vars.RDSConfig = smallRDSConfig()
+
+	runStages(t, fixture, func() *terraform.Options {
+		return rootOptions(t, vars)
+	}, func(plan *planassert.Plan) {
+		planassert.AssertOutputsPlanned(t, plan,
+			"primary_cluster_endpoint",
+			"primary_cluster_name",
+			"primary_cluster_security_group_id",
+			"secondary_cluster_endpoint",
+			"secondary_cluster_name",
+			"secondary_cluster_security_group_id",
+			"primary_rds_endpoint",
+			"secondary_rds_endpoint",
+			"primary_vpc_id",
+			"secondary_vpc_id",
+			"vpc_peering_connection_id",
+			"primary_cluster_oidc_issuer",
+			"secondary_cluster_oidc_issuer",
+		)
+		planassert.AssertOutputEquals(t, plan, "primary_cluster_name", "test-outputs-primary")
+		planassert.AssertOutputEquals(t, plan, "secondary_cluster_name", "test-outputs-secondary")
+		planassert.AssertOutputEquals(t, plan, "primary_vpc_id", fixture.VPCs[0].ID)
+		planassert.AssertOutputEquals(t, plan, "secondary_vpc_id", fixture.VPCs[1].ID)
 	})
+}
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should include all expected outputs")
+// productionRootVars mirrors the production defaults of variables.tf: three OUs and an on-demand and a spot node group
+// of m5 instances in each region, and a large multi-AZ database.
+func productionRootVars(fixture *harness.Fixture) tfvars.RootVars {
+	general := tfvars.BaselineNodeGroup().WithSizes(6, 9, 18).WithInstanceTypes("m5.xlarge", "m5a.xlarge")
+	general.DiskSize = 100
+	spot := general.WithCapacityType("SPOT").WithSizes(0, 6, 15).WithInstanceTypes("m5.xlarge", "m5a.xlarge", "m5n.xlarge")
+
+	vars := tfvars.BaselineRoot(fixture.VPCs[0].ID, fixture.VPCs[1].ID)
+	vars.ClusterNamePrefix = "production"
+	vars.Environment = "production"
+	vars.NodeGroups = tfvars.NodeGroups{"general": general, "spot": spot}
+	vars.RDSConfig.InstanceClass = "db.r6g.2xlarge"
+	vars.RDSConfig.AllocatedStorage = 1000
+	vars.RDSConfig.DatabaseName = "proddb"
+	vars.RDSConfig.BackupRetentionPeriod = 30
+	vars.OrganizationalUnits = tfvars.OUs{
+		{Name: "production-ops", OUID: "ou-prod-ops-001", Permissions: []string{"admin", "deploy", "view"}},
+		{Name: "production-dev", OUID: "ou-prod-dev-001", Permissions: []string{"deploy", "view"}},
+		{Name: "production-readonly", OUID: "ou-prod-ro-001", Permissions: []string{"view"}},
+	}
+	vars.Tags = map[string]string{
+		"Environment": "production",
+		"ManagedBy":   "terraform",
+		"Team":        "platform",
+	}
+	return vars
 }
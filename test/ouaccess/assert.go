@@ -0,0 +1,14 @@
+package ouaccess
+
+import (
+	"github.com/gruntwork-io/terratest/modules/testing"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
+)
+
+// AssertPolicies checks that every OU is associated with exactly the access policy its permissions grant according
+// to Precedence, and that no OU has an unknown permission.
+func AssertPolicies(t testing.TestingT, plan *planassert.Plan, modulePrefix string, ous tfvars.OUs) bool {
+	return planassert.AssertNoFindings(t, Verify(plan, modulePrefix, ous), "OU access policies do not match their permissions")
+}
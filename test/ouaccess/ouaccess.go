@@ -0,0 +1,135 @@
+// Package ouaccess verifies the EKS access policy the eks-cluster module associates with each organizational unit.
+// The module picks the policy with a nested conditional over the OU's permissions, in which any permission it does
+// not know, such as "readonly" or a misspelled "deploy ", silently falls through to view access. The verifier
+// computes the expected policy from an explicit precedence table instead and rejects unknown permissions.
+package ouaccess
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
+)
+
+// The EKS access policies the module associates with OUs.
+const (
+	ClusterAdminPolicyARN = "arn:aws:eks::aws:cluster-access-policy/AmazonEKSClusterAdminPolicy"
+	EditPolicyARN         = "arn:aws:eks::aws:cluster-access-policy/AmazonEKSEditPolicy"
+	ViewPolicyARN         = "arn:aws:eks::aws:cluster-access-policy/AmazonEKSViewPolicy"
+)
+
+// AssociationType is the resource type the module associates OU roles with access policies by.
+const AssociationType = "aws_eks_access_policy_association"
+
+// Rule grants the access policy PolicyARN to an OU that has Permission.
+type Rule struct {
+	Permission string
+	PolicyARN  string
+}
+
+// Precedence lists the rules in the order the module checks them: an OU gets the policy of the first rule whose
+// permission it has, so admin outranks deploy, which outranks view.
+var Precedence = []Rule{
+	{Permission: "admin", PolicyARN: ClusterAdminPolicyARN},
+	{Permission: "deploy", PolicyARN: EditPolicyARN},
+	{Permission: "view", PolicyARN: ViewPolicyARN},
+}
+
+// ExpectedPolicy returns the access policy ARN an OU with the given permissions should be associated with. It fails
+// when the permissions are empty or include one that no rule knows, both of which the module grants view access.
+func ExpectedPolicy(permissions []string) (string, error) {
+	if len(permissions) == 0 {
+		return "", fmt.Errorf("no permissions, which the module grants view access")
+	}
+	known := map[string]bool{}
+	for _, rule := range Precedence {
+		known[rule.Permission] = true
+	}
+	var unknown []string
+	for _, permission := range permissions {
+		if !known[permission] {
+			unknown = append(unknown, fmt.Sprintf("%q", permission))
+		}
+	}
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("unknown permissions %s, must be one of %s", strings.Join(unknown, ", "), permissionList())
+	}
+
+	for _, rule := range Precedence {
+		for _, permission := range permissions {
+			if permission == rule.Permission {
+				return rule.PolicyARN, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("none of the permissions is one of %s", permissionList())
+}
+
+func permissionList() string {
+	permissions := make([]string, len(Precedence))
+	for i, rule := range Precedence {
+		permissions[i] = rule.Permission
+	}
+	return strings.Join(permissions, ", ")
+}
+
+// Finding is an OU whose planned access policy association is missing, differs from the expected one or cannot be
+// checked, or an association for an OU that was not passed in.
+type Finding struct {
+	OUID    string
+	Address string
+	Message string
+}
+
+// String renders the finding as "<address>: <message>".
+func (f Finding) String() string {
+	return f.Address + ": " + f.Message
+}
+
+// Verify compares the planned policy_arn of each OU's access policy association in the eks-cluster module at
+// modulePrefix, e.g. "module.primary_region.module.eks." or empty when the module is planned directly, with the
+// policy the precedence table expects for the OU's permissions.
+func Verify(plan *planassert.Plan, modulePrefix string, ous tfvars.OUs) []Finding {
+	var findings []Finding
+	passed := map[string]bool{}
+	for _, ou := range ous {
+		passed[ou.OUID] = true
+		address := fmt.Sprintf("%s%s.ou_policies[%q]", modulePrefix, AssociationType, ou.OUID)
+		add := func(format string, a ...interface{}) {
+			findings = append(findings, Finding{OUID: ou.OUID, Address: address, Message: fmt.Sprintf(format, a...)})
+		}
+
+		expected, err := ExpectedPolicy(ou.Permissions)
+		if err != nil {
+			add("OU %s: %v", ou.Name, err)
+			continue
+		}
+		association, ok := plan.Resource(address)
+		if !ok || association.Destroyed() {
+			add("not planned, expected %s for OU %s", expected, ou.Name)
+			continue
+		}
+		if association.IsUnknown("policy_arn") {
+			add("policy_arn is not known at plan time, expected %s", expected)
+			continue
+		}
+		planned, _ := association.Attribute("policy_arn")
+		if planned != expected {
+			add("policy_arn is %v, expected %s for permissions %s", planned, expected, strings.Join(ou.Permissions, ", "))
+		}
+	}
+
+	var extra []string
+	for _, association := range plan.Match(modulePrefix + AssociationType + ".ou_policies[*]") {
+		if ouID, ok := association.Index.(string); ok && !passed[ouID] {
+			extra = append(extra, association.Address)
+		}
+	}
+	sort.Strings(extra)
+	for _, address := range extra {
+		findings = append(findings, Finding{Address: address, Message: "planned for an OU that was not passed in"})
+	}
+	return findings
+}
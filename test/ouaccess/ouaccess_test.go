@@ -0,0 +1,180 @@
+package ouaccess
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
+)
+
+// recordingT collects failures without failing the enclosing test.
+type recordingT struct {
+	failed bool
+}
+
+func (r *recordingT) Fail()                                  { r.failed = true }
+func (r *recordingT) FailNow()                               { r.failed = true }
+func (r *recordingT) Fatal(args ...interface{})              { r.failed = true }
+func (r *recordingT) Fatalf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Error(args ...interface{})              { r.failed = true }
+func (r *recordingT) Errorf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Name() string                           { return "recordingT" }
+
+type resource = map[string]interface{}
+
+// samplePlan renders an eks-cluster module plan with an access policy association per OU ID.
+func samplePlan(t *testing.T, policies map[string]string) *planassert.Plan {
+	t.Helper()
+
+	var resources []resource
+	for ouID, policyARN := range policies {
+		resources = append(resources, resource{
+			"address": `aws_eks_access_policy_association.ou_policies["` + ouID + `"]`,
+			"mode":    "managed", "type": AssociationType, "name": "ou_policies", "index": ouID,
+			"values": resource{"policy_arn": policyARN},
+		})
+	}
+	data, err := json.Marshal(resource{
+		"format_version": "1.2",
+		"planned_values": resource{"root_module": resource{"resources": resources}},
+	})
+	require.NoError(t, err)
+	plan, err := planassert.ParseJSON(data)
+	require.NoError(t, err)
+	return plan
+}
+
+func TestExpectedPolicy(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		permissions []string
+		expected    string
+	}{
+		{[]string{"admin"}, ClusterAdminPolicyARN},
+		{[]string{"view", "admin"}, ClusterAdminPolicyARN},
+		{[]string{"admin", "deploy", "view"}, ClusterAdminPolicyARN},
+		{[]string{"deploy"}, EditPolicyARN},
+		{[]string{"view", "deploy"}, EditPolicyARN},
+		{[]string{"view"}, ViewPolicyARN},
+	} {
+		policy, err := ExpectedPolicy(tc.permissions)
+		require.NoError(t, err, "%v", tc.permissions)
+		assert.Equal(t, tc.expected, policy, "%v", tc.permissions)
+	}
+
+	// The module would grant all of these view access
+	_, err := ExpectedPolicy([]string{"deploy "})
+	assert.EqualError(t, err, `unknown permissions "deploy ", must be one of admin, deploy, view`)
+	_, err = ExpectedPolicy([]string{"admin", "readonly", "Admin"})
+	assert.EqualError(t, err, `unknown permissions "readonly", "Admin", must be one of admin, deploy, view`)
+	_, err = ExpectedPolicy(nil)
+	assert.EqualError(t, err, "no permissions, which the module grants view access")
+}
+
+// TestPrecedenceMatchesModule checks the precedence table against the nested conditional of the eks-cluster module
+// and the permissions the tfvars builders accept.
+func TestPrecedenceMatchesModule(t *testing.T) {
+	t.Parallel()
+
+	main, err := os.ReadFile("../../modules/eks-cluster/main.tf")
+	require.NoError(t, err)
+	policyARN := regexp.MustCompile(`(?m)^\s*policy_arn\s*=\s*(contains\(.+)$`).FindSubmatch(main)
+	require.NotNil(t, policyARN, "policy_arn of ou_policies")
+
+	var module []Rule
+	for _, match := range regexp.MustCompile(`contains\(each\.value\.permissions, "(\w+)"\) \? "([^"]+)"`).
+		FindAllStringSubmatch(string(policyARN[1]), -1) {
+		module = append(module, Rule{Permission: match[1], PolicyARN: match[2]})
+	}
+	fallback := regexp.MustCompile(`: "([^"]+)"\s*$`).FindStringSubmatch(string(policyARN[1]))
+	require.NotNil(t, fallback, "fallback policy of ou_policies")
+
+	// The last rule is the module's fallback, which also catches every unknown permission
+	last := Precedence[len(Precedence)-1]
+	assert.Equal(t, Precedence[:len(Precedence)-1], module)
+	assert.Equal(t, last.PolicyARN, fallback[1])
+
+	permissions := make([]string, len(Precedence))
+	for i, rule := range Precedence {
+		permissions[i] = rule.Permission
+	}
+	assert.ElementsMatch(t, tfvars.Permissions, permissions)
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	ous := tfvars.OUs{
+		{Name: "ou-admin", OUID: "ou-admin-001", Permissions: []string{"view", "admin"}},
+		{Name: "ou-dev", OUID: "ou-dev-001", Permissions: []string{"deploy", "view"}},
+		{Name: "ou-readonly", OUID: "ou-ro-001", Permissions: []string{"view"}},
+	}
+	plan := samplePlan(t, map[string]string{
+		"ou-admin-001": ClusterAdminPolicyARN,
+		"ou-dev-001":   EditPolicyARN,
+		"ou-ro-001":    ViewPolicyARN,
+	})
+	assert.Empty(t, Verify(plan, "", ous))
+	assert.True(t, AssertPolicies(t, plan, "", ous))
+
+	// The dev OU is planned with view access, the readonly OU is missing and an OU nobody passed in is planned
+	plan = samplePlan(t, map[string]string{
+		"ou-admin-001": ClusterAdminPolicyARN,
+		"ou-dev-001":   ViewPolicyARN,
+		"ou-other-001": ViewPolicyARN,
+	})
+	findings := Verify(plan, "", ous)
+	require.Len(t, findings, 3)
+	assert.Equal(t, `aws_eks_access_policy_association.ou_policies["ou-dev-001"]: policy_arn is `+ViewPolicyARN+
+		`, expected `+EditPolicyARN+` for permissions deploy, view`, findings[0].String())
+	assert.Equal(t, "ou-ro-001", findings[1].OUID)
+	assert.Equal(t, "not planned, expected "+ViewPolicyARN+" for OU ou-readonly", findings[1].Message)
+	assert.Equal(t, `aws_eks_access_policy_association.ou_policies["ou-other-001"]: planned for an OU that was not passed in`,
+		findings[2].String())
+
+	r := &recordingT{}
+	assert.False(t, AssertPolicies(r, plan, "", ous))
+	assert.True(t, r.failed)
+}
+
+func TestVerifyUnknownPermission(t *testing.T) {
+	t.Parallel()
+
+	// The module plans view access for a misspelled permission, which the verifier rejects
+	ous := tfvars.OUs{{Name: "ou-dev", OUID: "ou-dev-001", Permissions: []string{"deploy "}}}
+	plan := samplePlan(t, map[string]string{"ou-dev-001": ViewPolicyARN})
+
+	findings := Verify(plan, "", ous)
+	require.Len(t, findings, 1)
+	assert.Equal(t, `OU ou-dev: unknown permissions "deploy ", must be one of admin, deploy, view`, findings[0].Message)
+}
+
+func TestVerifyModulePrefix(t *testing.T) {
+	t.Parallel()
+
+	data, err := json.Marshal(resource{
+		"format_version": "1.2",
+		"planned_values": resource{"root_module": resource{"child_modules": []resource{{
+			"address": "module.primary_region.module.eks",
+			"resources": []resource{{
+				"address": `module.primary_region.module.eks.aws_eks_access_policy_association.ou_policies["ou-test-001"]`,
+				"mode":    "managed", "type": AssociationType, "name": "ou_policies", "index": "ou-test-001",
+				"values": resource{"policy_arn": ClusterAdminPolicyARN},
+			}},
+		}}}},
+	})
+	require.NoError(t, err)
+	plan, err := planassert.ParseJSON(data)
+	require.NoError(t, err)
+
+	ous := tfvars.OUs{tfvars.BaselineOU()}
+	assert.Empty(t, Verify(plan, "module.primary_region.module.eks.", ous))
+	assert.Len(t, Verify(plan, "module.secondary_region.module.eks.", ous), 1)
+}
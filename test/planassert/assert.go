@@ -0,0 +1,169 @@
+package planassert
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// AssertResourceCount checks that exactly count planned resources match the address pattern, failing the test if
+// they do not. See Plan.Match for the pattern syntax.
+func AssertResourceCount(t testing.TestingT, plan *Plan, pattern string, count int) bool {
+	matched := plan.Match(pattern)
+	return assert.Lenf(t, matched, count, "expected exactly %d resources matching %s, planned %s", count, pattern, describe(matched))
+}
+
+// RequireResourceCount checks that exactly count planned resources match the address pattern, failing and halting
+// the test if they do not.
+func RequireResourceCount(t testing.TestingT, plan *Plan, pattern string, count int) {
+	matched := plan.Match(pattern)
+	require.Lenf(t, matched, count, "expected exactly %d resources matching %s, planned %s", count, pattern, describe(matched))
+}
+
+// AssertResourceExists checks that a resource is planned at the exact address.
+func AssertResourceExists(t testing.TestingT, plan *Plan, address string) bool {
+	resource, ok := plan.Resource(address)
+	return assert.Truef(t, ok && !resource.Destroyed(), "expected %s to be planned", address)
+}
+
+// RequireResourceExists checks that a resource is planned at the exact address, halting the test if it is not, and
+// returns it.
+func RequireResourceExists(t testing.TestingT, plan *Plan, address string) *Resource {
+	resource, ok := plan.Resource(address)
+	require.Truef(t, ok && !resource.Destroyed(), "expected %s to be planned", address)
+	return resource
+}
+
+// AssertNoResourcesMatching checks that no planned resource matches the address pattern.
+func AssertNoResourcesMatching(t testing.TestingT, plan *Plan, pattern string) bool {
+	matched := plan.Match(pattern)
+	return assert.Emptyf(t, matched, "expected no resources matching %s, planned %s", pattern, describe(matched))
+}
+
+// AssertNoResourcesOfType checks that no managed resource of the given type is planned, in any module.
+func AssertNoResourcesOfType(t testing.TestingT, plan *Plan, resourceType string) bool {
+	matched := plan.OfType(resourceType)
+	return assert.Emptyf(t, matched, "expected no %s resources, planned %s", resourceType, describe(matched))
+}
+
+// AssertTypeCount checks that exactly count managed resources of the given type are planned across all modules.
+func AssertTypeCount(t testing.TestingT, plan *Plan, resourceType string, count int) bool {
+	matched := plan.OfType(resourceType)
+	return assert.Lenf(t, matched, count, "expected exactly %d %s resources, planned %s", count, resourceType, describe(matched))
+}
+
+// AssertAttributeEquals checks that the planned value of the attribute at the dotted path of the resource at address
+// equals expected. Expected is compared by its JSON representation, so Go ints, string slices and maps can be used
+// directly against the float64s and generic slices of the plan.
+func AssertAttributeEquals(t testing.TestingT, plan *Plan, address string, path string, expected interface{}) bool {
+	resource, ok := plan.Resource(address)
+	if !assert.Truef(t, ok, "expected %s to be planned", address) {
+		return false
+	}
+	actual, known := resource.Attribute(path)
+	if !known {
+		if resource.IsUnknown(path) {
+			return assert.Failf(t, "attribute unknown", "%s.%s is only known after apply, expected %v", address, path, expected)
+		}
+		if expected == nil {
+			return true
+		}
+		return assert.Failf(t, "attribute missing", "%s has no planned value for %s, expected %v", address, path, expected)
+	}
+	return assert.Equalf(t, normalize(expected), actual, "unexpected planned value for %s.%s", address, path)
+}
+
+// AssertAttributeUnknown checks that the attribute at the dotted path of the resource at address is only known after
+// apply.
+func AssertAttributeUnknown(t testing.TestingT, plan *Plan, address string, path string) bool {
+	resource, ok := plan.Resource(address)
+	if !assert.Truef(t, ok, "expected %s to be planned", address) {
+		return false
+	}
+	return assert.Truef(t, resource.IsUnknown(path), "expected %s.%s to be unknown until apply", address, path)
+}
+
+// AssertAllAttributesEqual checks that the attribute at the dotted path equals expected on every resource matching the
+// pattern, and that at least one resource matches.
+func AssertAllAttributesEqual(t testing.TestingT, plan *Plan, pattern string, path string, expected interface{}) bool {
+	matched := plan.Match(pattern)
+	if !assert.NotEmptyf(t, matched, "expected resources matching %s", pattern) {
+		return false
+	}
+	ok := true
+	for _, resource := range matched {
+		ok = AssertAttributeEquals(t, plan, resource.Address, path, expected) && ok
+	}
+	return ok
+}
+
+// AssertCounts checks the add, change and destroy totals of the plan.
+func AssertCounts(t testing.TestingT, plan *Plan, add, change, destroy int) bool {
+	counts := plan.Counts()
+	return assert.Equalf(t, [3]int{add, change, destroy}, [3]int{counts.Add, counts.Change, counts.Destroy},
+		"expected %d to add, %d to change, %d to destroy", add, change, destroy)
+}
+
+// normalize round-trips a value through JSON so it has the same shape as a value decoded from a plan.
+func normalize(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return value
+	}
+	return out
+}
+
+// AssertOutputsPlanned checks that the plan includes a change for each of the named root module outputs.
+func AssertOutputsPlanned(t testing.TestingT, plan *Plan, names ...string) bool {
+	ok := true
+	for _, name := range names {
+		_, planned := plan.Output(name)
+		ok = assert.Truef(t, planned, "expected output %s to be planned", name) && ok
+	}
+	return ok
+}
+
+// AssertOutputEquals checks that the named root module output is known at plan time and equals expected.
+func AssertOutputEquals(t testing.TestingT, plan *Plan, name string, expected interface{}) bool {
+	change, ok := plan.Output(name)
+	if !assert.Truef(t, ok, "expected output %s to be planned", name) {
+		return false
+	}
+	if unknown, isBool := change.AfterUnknown.(bool); isBool && unknown {
+		return assert.Failf(t, "output unknown", "output %s is only known after apply, expected %v", name, expected)
+	}
+	return assert.Equalf(t, normalize(expected), change.After, "unexpected planned value for output %s", name)
+}
+
+// AssertNoFindings checks that a check of the plan found nothing, failing with the message, formatted with args,
+// followed by one finding per line. Checks such as crossregion, ouaccess and rdsreplica keep only their finding type
+// and report through it.
+func AssertNoFindings[F fmt.Stringer](t testing.TestingT, findings []F, message string, args ...interface{}) bool {
+	return AssertNoFindingsFormatted(t, findings, func(findings []F) string {
+		lines := make([]string, len(findings))
+		for i, finding := range findings {
+			lines[i] = finding.String()
+		}
+		return strings.Join(lines, "\n")
+	}, message, args...)
+}
+
+// AssertNoFindingsFormatted is AssertNoFindings for findings that render together, such as a diff grouped by check,
+// rather than one per line: format renders all of them below the message.
+func AssertNoFindingsFormatted[F any](t testing.TestingT, findings []F, format func([]F) string, message string, args ...interface{}) bool {
+	if len(findings) == 0 {
+		return true
+	}
+	return assert.Failf(t, "unexpected findings", "%s:\n%s", fmt.Sprintf(message, args...), format(findings))
+}
@@ -0,0 +1,334 @@
+// Package planassert indexes a Terraform plan by resource address and provides exact assertions over it, so tests
+// can state precisely which resources a module plans instead of comparing the number of additions to a threshold.
+package planassert
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// Resource is a single resource instance in a plan, combining its planned values with the actions Terraform will
+// take on it.
+type Resource struct {
+	// Address is the full resource address, e.g. module.eks.aws_eks_addon.vpc_cni.
+	Address string
+
+	// ModuleAddress is the module portion of Address, empty for resources in the root module.
+	ModuleAddress string
+
+	// Mode is either "managed" or "data".
+	Mode tfjson.ResourceMode
+
+	// Type is the resource type, e.g. aws_nat_gateway.
+	Type string
+
+	// Name is the resource name, e.g. main for aws_nat_gateway.main.
+	Name string
+
+	// Index is the count index (int) or for_each key (string), nil for single instances.
+	Index interface{}
+
+	// Actions are the planned actions. Empty when the resource only appears in planned values.
+	Actions tfjson.Actions
+
+	// Values are the known planned attribute values. Attributes that are unknown until apply are absent.
+	Values map[string]interface{}
+
+	// Unknown mirrors the after_unknown structure of the change: leaves are true for attributes computed at apply.
+	Unknown interface{}
+}
+
+// Plan is an index of the resources in a Terraform plan keyed by address.
+type Plan struct {
+	resources map[string]*Resource
+	addresses []string
+	raw       *terraform.PlanStruct
+}
+
+// New indexes the resources of the given plan. Both the planned values and the resource changes maps are used, so
+// resources that are only being destroyed are indexed as well.
+func New(plan *terraform.PlanStruct) *Plan {
+	p := &Plan{resources: map[string]*Resource{}, raw: plan}
+
+	if plan.RawPlan.PlannedValues != nil && plan.RawPlan.PlannedValues.RootModule != nil {
+		p.indexModule(plan.RawPlan.PlannedValues.RootModule)
+	}
+
+	for address, change := range plan.ResourceChangesMap {
+		if change.DeposedKey != "" {
+			// Deposed objects share the address of the current instance; the current instance is what tests
+			// care about.
+			continue
+		}
+		resource, ok := p.resources[address]
+		if !ok {
+			resource = &Resource{
+				Address:       address,
+				ModuleAddress: change.ModuleAddress,
+				Mode:          change.Mode,
+				Type:          change.Type,
+				Name:          change.Name,
+				Index:         normalizeIndex(change.Index),
+			}
+			p.resources[address] = resource
+		}
+		if change.Change == nil {
+			continue
+		}
+		resource.Actions = change.Change.Actions
+		resource.Unknown = change.Change.AfterUnknown
+		if resource.Values == nil {
+			if after, ok := change.Change.After.(map[string]interface{}); ok {
+				resource.Values = after
+			}
+		}
+	}
+
+	for address := range p.resources {
+		p.addresses = append(p.addresses, address)
+	}
+	sort.Strings(p.addresses)
+
+	return p
+}
+
+// indexModule recursively adds the planned values of a module and its children, keeping track of the module address
+// that the flattened ResourcePlannedValuesMap of terratest discards.
+func (p *Plan) indexModule(module *tfjson.StateModule) {
+	for _, planned := range module.Resources {
+		p.resources[planned.Address] = &Resource{
+			Address:       planned.Address,
+			ModuleAddress: module.Address,
+			Mode:          planned.Mode,
+			Type:          planned.Type,
+			Name:          planned.Name,
+			Index:         normalizeIndex(planned.Index),
+			Values:        planned.AttributeValues,
+		}
+	}
+	for _, child := range module.ChildModules {
+		p.indexModule(child)
+	}
+}
+
+// ParseJSON indexes a plan from the output of `terraform show -json <planfile>`.
+func ParseJSON(data []byte) (*Plan, error) {
+	plan, err := terraform.ParsePlanJSON(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return New(plan), nil
+}
+
+// ParseStateJSON indexes the resources of a state from the output of `terraform show -json` without a plan file, as if
+// they were planned, so code that reads plans can read what is deployed too. The result has no actions, variables or
+// configuration.
+func ParseStateJSON(data []byte) (*Plan, error) {
+	var state tfjson.State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parsing state: %w", err)
+	}
+	return New(&terraform.PlanStruct{RawPlan: tfjson.Plan{PlannedValues: state.Values}}), nil
+}
+
+// Raw returns the plan the index was built from.
+func (p *Plan) Raw() *terraform.PlanStruct {
+	return p.raw
+}
+
+// Addresses returns every indexed address in sorted order.
+func (p *Plan) Addresses() []string {
+	return append([]string(nil), p.addresses...)
+}
+
+// Resource returns the resource at the exact address.
+func (p *Plan) Resource(address string) (*Resource, bool) {
+	resource, ok := p.resources[address]
+	return resource, ok
+}
+
+// Match returns the planned resources whose address matches the pattern, sorted by address. Resources that are only
+// being destroyed are excluded.
+//
+// A pattern is a resource address in which "[*]" matches any single instance key and "*" matches any run of
+// characters within one address segment. For example "aws_nat_gateway.main[*]" matches every NAT gateway instance
+// and "module.*.aws_eks_cluster.main" matches the cluster in any directly nested module. A pattern without an index
+// only matches the un-indexed instance.
+func (p *Plan) Match(pattern string) []*Resource {
+	re := compilePattern(pattern)
+	var out []*Resource
+	for _, address := range p.addresses {
+		resource := p.resources[address]
+		if resource.Destroyed() {
+			continue
+		}
+		if re.MatchString(address) {
+			out = append(out, resource)
+		}
+	}
+	return out
+}
+
+// OfType returns the planned managed resources of the given type, sorted by address.
+func (p *Plan) OfType(resourceType string) []*Resource {
+	var out []*Resource
+	for _, address := range p.addresses {
+		resource := p.resources[address]
+		if resource.Mode == tfjson.ManagedResourceMode && resource.Type == resourceType && !resource.Destroyed() {
+			out = append(out, resource)
+		}
+	}
+	return out
+}
+
+// Managed returns every planned managed resource, sorted by address.
+func (p *Plan) Managed() []*Resource {
+	var out []*Resource
+	for _, address := range p.addresses {
+		resource := p.resources[address]
+		if resource.Mode == tfjson.ManagedResourceMode && !resource.Destroyed() {
+			out = append(out, resource)
+		}
+	}
+	return out
+}
+
+// Counts returns the number of resources to add, change and destroy, using the same accounting as the summary line
+// of `terraform plan`: a replacement counts as one add and one destroy.
+func (p *Plan) Counts() terraform.ResourceCount {
+	var counts terraform.ResourceCount
+	for _, address := range p.addresses {
+		resource := p.resources[address]
+		if resource.Mode != tfjson.ManagedResourceMode {
+			continue
+		}
+		for _, action := range resource.Actions {
+			switch action {
+			case tfjson.ActionCreate:
+				counts.Add++
+			case tfjson.ActionUpdate:
+				counts.Change++
+			case tfjson.ActionDelete:
+				counts.Destroy++
+			}
+		}
+	}
+	return counts
+}
+
+// Destroyed reports whether the only planned action for the resource is to delete it.
+func (r *Resource) Destroyed() bool {
+	return r.Actions.Delete()
+}
+
+// Attribute returns the planned value at the dotted attribute path, e.g. "vpc_config.0.public_access_cidrs". List
+// elements are addressed by their numeric index. The second result is false when the attribute is absent or unknown
+// until apply.
+func (r *Resource) Attribute(path string) (interface{}, bool) {
+	return lookup(r.Values, path)
+}
+
+// IsUnknown reports whether the attribute at the dotted path is only known after apply.
+func (r *Resource) IsUnknown(path string) bool {
+	value, ok := lookup(r.Unknown, path)
+	if !ok {
+		return false
+	}
+	known, isBool := value.(bool)
+	return isBool && known
+}
+
+// String returns the resource address.
+func (r *Resource) String() string {
+	return r.Address
+}
+
+func lookup(root interface{}, path string) (interface{}, bool) {
+	current := root
+	if path == "" {
+		return current, current != nil
+	}
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			next, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+	return current, current != nil
+}
+
+// normalizeIndex converts JSON number indexes to int so count indexes compare naturally against Go literals.
+func normalizeIndex(index interface{}) interface{} {
+	switch value := index.(type) {
+	case float64:
+		return int(value)
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return int(i)
+		}
+	}
+	return index
+}
+
+var patternCache sync.Map
+
+func compilePattern(pattern string) *regexp.Regexp {
+	if re, ok := patternCache.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch {
+		case strings.HasPrefix(pattern[i:], "[*]"):
+			b.WriteString(`\[[^\]]+\]`)
+			i += 2
+		case pattern[i] == '*':
+			b.WriteString(`[^.\[\]]*`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+		}
+	}
+	b.WriteString("$")
+	re := regexp.MustCompile(b.String())
+	patternCache.Store(pattern, re)
+	return re
+}
+
+// describe renders the addresses of the resources for failure messages.
+func describe(resources []*Resource) string {
+	if len(resources) == 0 {
+		return "none"
+	}
+	addresses := make([]string, len(resources))
+	for i, resource := range resources {
+		addresses[i] = resource.Address
+	}
+	return fmt.Sprintf("%d: %s", len(addresses), strings.Join(addresses, ", "))
+}
+
+// Output returns the planned change of the named root module output.
+func (p *Plan) Output(name string) (*tfjson.Change, bool) {
+	change, ok := p.raw.RawPlan.OutputChanges[name]
+	return change, ok && change != nil
+}
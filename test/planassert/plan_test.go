@@ -0,0 +1,184 @@
+package planassert
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const samplePlan = `{
+  "format_version": "1.2",
+  "planned_values": {
+    "root_module": {
+      "resources": [
+        {"address": "aws_vpc.main", "mode": "managed", "type": "aws_vpc", "name": "main",
+         "values": {"cidr_block": "10.0.0.0/16", "tags": {"Name": "test-vpc"}}},
+        {"address": "aws_nat_gateway.main[0]", "mode": "managed", "type": "aws_nat_gateway", "name": "main", "index": 0, "values": {}},
+        {"address": "aws_nat_gateway.main[1]", "mode": "managed", "type": "aws_nat_gateway", "name": "main", "index": 1, "values": {}},
+        {"address": "aws_nat_gateway.main[2]", "mode": "managed", "type": "aws_nat_gateway", "name": "main", "index": 2, "values": {}}
+      ],
+      "child_modules": [
+        {
+          "address": "module.eks",
+          "resources": [
+            {"address": "module.eks.aws_eks_cluster.main", "mode": "managed", "type": "aws_eks_cluster", "name": "main",
+             "values": {"version": "1.28", "vpc_config": [{"public_access_cidrs": ["0.0.0.0/0"]}]}},
+            {"address": "module.eks.aws_iam_role.ou_access[\"ou-a.b\"]", "mode": "managed", "type": "aws_iam_role", "name": "ou_access", "index": "ou-a.b", "values": {}}
+          ]
+        }
+      ]
+    }
+  },
+  "resource_changes": [
+    {"address": "aws_vpc.main", "mode": "managed", "type": "aws_vpc", "name": "main",
+     "change": {"actions": ["create"], "after": {"cidr_block": "10.0.0.0/16"}, "after_unknown": {"id": true, "arn": true}}},
+    {"address": "aws_nat_gateway.main[0]", "mode": "managed", "type": "aws_nat_gateway", "name": "main", "index": 0, "change": {"actions": ["create"]}},
+    {"address": "aws_nat_gateway.main[1]", "mode": "managed", "type": "aws_nat_gateway", "name": "main", "index": 1, "change": {"actions": ["create"]}},
+    {"address": "aws_nat_gateway.main[2]", "mode": "managed", "type": "aws_nat_gateway", "name": "main", "index": 2, "change": {"actions": ["delete", "create"]}},
+    {"address": "aws_eip.old", "mode": "managed", "type": "aws_eip", "name": "old", "change": {"actions": ["delete"]}},
+    {"address": "module.eks.aws_eks_cluster.main", "module_address": "module.eks", "mode": "managed", "type": "aws_eks_cluster", "name": "main",
+     "change": {"actions": ["update"]}},
+    {"address": "module.eks.aws_iam_role.ou_access[\"ou-a.b\"]", "module_address": "module.eks", "mode": "managed", "type": "aws_iam_role", "name": "ou_access", "index": "ou-a.b",
+     "change": {"actions": ["create"]}}
+  ]
+}`
+
+// recordingT collects failures without failing the enclosing test.
+type recordingT struct {
+	failed  bool
+	message string
+}
+
+func (r *recordingT) Fail()                                  { r.failed = true }
+func (r *recordingT) FailNow()                               { r.failed = true }
+func (r *recordingT) Fatal(args ...interface{})              { r.failed = true }
+func (r *recordingT) Fatalf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Error(args ...interface{})              { r.failed = true }
+func (r *recordingT) Errorf(format string, a ...interface{}) {
+	r.failed = true
+	r.message += fmt.Sprintf(format, a...)
+}
+func (r *recordingT) Name() string { return "recordingT" }
+
+func parseSample(t *testing.T) *Plan {
+	plan, err := ParseJSON([]byte(samplePlan))
+	require.NoError(t, err)
+	return plan
+}
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	plan := parseSample(t)
+
+	assert.Len(t, plan.Match("aws_nat_gateway.main[*]"), 3)
+	assert.Empty(t, plan.Match("aws_nat_gateway.main"), "pattern without index should not match indexed instances")
+	assert.Len(t, plan.Match("module.*.aws_eks_cluster.main"), 1)
+	assert.Len(t, plan.Match("module.eks.aws_iam_role.ou_access[*]"), 1, "for_each keys containing dots should match [*]")
+	assert.Empty(t, plan.Match("aws_eip.old"), "destroyed resources are not planned")
+	assert.Len(t, plan.OfType("aws_nat_gateway"), 3)
+
+	resource, ok := plan.Resource("module.eks.aws_iam_role.ou_access[\"ou-a.b\"]")
+	require.True(t, ok)
+	assert.Equal(t, "module.eks", resource.ModuleAddress)
+	assert.Equal(t, "ou-a.b", resource.Index)
+
+	nat, ok := plan.Resource("aws_nat_gateway.main[2]")
+	require.True(t, ok)
+	assert.Equal(t, 2, nat.Index)
+}
+
+func TestCounts(t *testing.T) {
+	t.Parallel()
+
+	plan := parseSample(t)
+	counts := plan.Counts()
+
+	assert.Equal(t, 5, counts.Add)
+	assert.Equal(t, 1, counts.Change)
+	assert.Equal(t, 2, counts.Destroy)
+}
+
+func TestAttributes(t *testing.T) {
+	t.Parallel()
+
+	plan := parseSample(t)
+
+	AssertAttributeEquals(t, plan, "aws_vpc.main", "cidr_block", "10.0.0.0/16")
+	AssertAttributeEquals(t, plan, "aws_vpc.main", "tags", map[string]string{"Name": "test-vpc"})
+	AssertAttributeEquals(t, plan, "module.eks.aws_eks_cluster.main", "vpc_config.0.public_access_cidrs", []string{"0.0.0.0/0"})
+	AssertAttributeUnknown(t, plan, "aws_vpc.main", "arn")
+	AssertResourceCount(t, plan, "aws_nat_gateway.main[*]", 3)
+	AssertNoResourcesOfType(t, plan, "aws_db_instance")
+
+	cluster, _ := plan.Resource("module.eks.aws_eks_cluster.main")
+	_, ok := cluster.Attribute("vpc_config.1.public_access_cidrs")
+	assert.False(t, ok, "out of range list index")
+}
+
+func TestAssertionsFail(t *testing.T) {
+	t.Parallel()
+
+	plan := parseSample(t)
+	mock := &recordingT{}
+
+	assert.False(t, AssertResourceCount(mock, plan, "aws_nat_gateway.main[*]", 2))
+	assert.False(t, AssertAttributeEquals(mock, plan, "aws_vpc.main", "cidr_block", "10.1.0.0/16"))
+	assert.False(t, AssertAttributeEquals(mock, plan, "aws_vpc.main", "arn", "arn:aws:ec2:::vpc/x"))
+	assert.False(t, AssertNoResourcesOfType(mock, plan, "aws_nat_gateway"))
+	assert.False(t, AssertCounts(mock, plan, 0, 0, 0))
+	assert.True(t, mock.failed)
+}
+
+// finding is a check's finding for TestAssertNoFindings.
+type finding string
+
+func (f finding) String() string { return string(f) }
+
+func TestAssertNoFindings(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, AssertNoFindings(t, []finding(nil), "no %s", "findings"))
+
+	mock := &recordingT{}
+	assert.False(t, AssertNoFindings(mock, []finding{"aws_vpc.main: first", "aws_vpc.main: second"}, "checking %s", "the vpc"))
+	assert.True(t, mock.failed)
+	for _, line := range []string{"unexpected findings", "checking the vpc:\n", "aws_vpc.main: first\n", "aws_vpc.main: second\n"} {
+		assert.Contains(t, mock.message, line)
+	}
+
+	mock = &recordingT{}
+	format := func(findings []finding) string { return fmt.Sprintf("%d findings", len(findings)) }
+	assert.False(t, AssertNoFindingsFormatted(mock, []finding{"a", "b"}, format, "grouped"))
+	assert.Contains(t, mock.message, "grouped:\n")
+	assert.Contains(t, mock.message, "2 findings\n")
+}
+
+func TestParseStateJSON(t *testing.T) {
+	t.Parallel()
+
+	plan, err := ParseStateJSON([]byte(`{
+  "format_version": "1.0",
+  "terraform_version": "1.6.6",
+  "values": {"root_module": {"child_modules": [{
+    "address": "module.eks",
+    "resources": [{
+      "address": "module.eks.aws_eks_cluster.main", "mode": "managed", "type": "aws_eks_cluster", "name": "main",
+      "values": {"name": "deployed", "arn": "arn:aws:eks:us-east-1:123456789012:cluster/deployed"}
+    }]
+  }]}}
+}`))
+	require.NoError(t, err)
+
+	resource, ok := plan.Resource("module.eks.aws_eks_cluster.main")
+	require.True(t, ok)
+	assert.Equal(t, "module.eks", resource.ModuleAddress)
+	assert.Empty(t, resource.Actions)
+	assert.False(t, resource.IsUnknown("arn"), "a deployed state has no unknown values")
+	AssertAttributeEquals(t, plan, "module.eks.aws_eks_cluster.main", "name", "deployed")
+
+	_, err = ParseStateJSON([]byte(`{"format_version": "1.0", "values": [`))
+	assert.Error(t, err)
+}
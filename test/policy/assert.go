@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// Key identifies the violation as "<rule ID> <address>", the form AssertViolations compares.
+func (v Violation) Key() string {
+	return v.RuleID + " " + v.Address
+}
+
+// AssertViolations checks that the violations are exactly the expected ones, each given as "<rule ID> <address>", in
+// any order. Pinning known violations this way keeps them visible while they are fixed and fails on any new one.
+func AssertViolations(t testing.TestingT, violations []Violation, expected ...string) bool {
+	actual := make([]string, len(violations))
+	lines := make([]string, len(violations))
+	for i, violation := range violations {
+		actual[i] = violation.Key()
+		lines[i] = violation.String()
+	}
+	sort.Strings(actual)
+	want := append([]string{}, expected...)
+	sort.Strings(want)
+	if len(want) == 0 {
+		want = nil
+	}
+	if len(actual) == 0 {
+		actual = nil
+	}
+	return assert.Equalf(t, want, actual, "unexpected policy violations:\n%s", strings.Join(lines, "\n"))
+}
+
+// AssertNoViolations checks that none of the violations is at least as serious as min.
+func AssertNoViolations(t testing.TestingT, violations []Violation, min Severity) bool {
+	serious := Filter(violations, min)
+	lines := make([]string, len(serious))
+	for i, violation := range serious {
+		lines[i] = violation.String()
+	}
+	return assert.Emptyf(t, serious, "expected no %s or more serious policy violations:\n%s", min, strings.Join(lines, "\n"))
+}
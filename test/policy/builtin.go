@@ -0,0 +1,50 @@
+package policy
+
+import (
+	_ "embed"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+//go:embed rules.yaml
+var defaultRules []byte
+
+// Default returns the rules every module is checked against: the declarative rules in rules.yaml followed by the Go
+// rules below.
+func Default() (*RuleSet, error) {
+	declared, err := LoadYAML(defaultRules)
+	if err != nil {
+		return nil, err
+	}
+	return NewRuleSet(append(declared, RDSEgressOpen)...)
+}
+
+// RDSEgressOpen flags security group rules that let a database reach the internet. The security group a rule
+// attaches to is only known after apply, so the rule applies to egress rules open to 0.0.0.0/0 in any module that
+// plans an aws_db_instance; databases never need to initiate connections out of the VPC.
+var RDSEgressOpen = Rule{
+	ID:          "rds-egress-open",
+	Description: "security group rule lets the RDS instance send traffic to the internet",
+	Severity:    SeverityMedium,
+	Check: func(plan *planassert.Plan) []Violation {
+		databaseModules := map[string]bool{}
+		for _, database := range plan.OfType("aws_db_instance") {
+			databaseModules[database.ModuleAddress] = true
+		}
+
+		var violations []Violation
+		for _, rule := range plan.OfType("aws_security_group_rule") {
+			if !databaseModules[rule.ModuleAddress] {
+				continue
+			}
+			if ruleType, _ := rule.Attribute("type"); ruleType != "egress" {
+				continue
+			}
+			if containsAny(Values(rule, "cidr_blocks"), []string{"0.0.0.0/0"}) ||
+				containsAny(Values(rule, "ipv6_cidr_blocks"), []string{"::/0"}) {
+				violations = append(violations, Violation{Address: rule.Address})
+			}
+		}
+		return violations
+	},
+}
@@ -0,0 +1,258 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// File is a file of declarative rules:
+//
+//	rules:
+//	  - id: eks-public-endpoint-open
+//	    description: EKS public API endpoint is open to the internet
+//	    severity: critical
+//	    resource_type: aws_eks_cluster
+//	    when:
+//	      - attribute: vpc_config.0.endpoint_public_access
+//	        equals: true
+//	      - attribute: vpc_config.0.public_access_cidrs
+//	        contains_any: ["0.0.0.0/0"]
+//
+// A resource of the type violates the rule when every condition under when holds.
+type File struct {
+	Rules []Declaration `yaml:"rules"`
+}
+
+// Declaration is a single declarative rule.
+type Declaration struct {
+	ID          string `yaml:"id"`
+	Description string `yaml:"description"`
+	Severity    string `yaml:"severity"`
+
+	// ResourceType is the type of the managed resources the rule applies to.
+	ResourceType string `yaml:"resource_type"`
+
+	// Address optionally narrows the rule to resources whose address matches a planassert pattern.
+	Address string `yaml:"address"`
+
+	When []Condition `yaml:"when"`
+}
+
+// Condition tests one planned attribute. Attribute is a dotted path as accepted by planassert.Resource.Attribute, in
+// which a "*" segment stands for every element of a list, so "ingress.*.cidr_blocks" looks at the CIDR blocks of
+// every inline ingress block. The condition holds when any value the path resolves to satisfies it. Exactly one of
+// Equals and ContainsAny must be set.
+type Condition struct {
+	Attribute string `yaml:"attribute"`
+
+	// Equals holds when the attribute equals the value. Numbers compare by value.
+	Equals yaml.Node `yaml:"equals"`
+
+	// ContainsAny holds when the attribute is a list containing any of the values, or a string equal to one of them.
+	ContainsAny []string `yaml:"contains_any"`
+}
+
+// LoadFile reads declarative rules from a YAML file.
+func LoadFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := LoadYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// LoadYAML compiles declarative rules. Unknown fields are rejected so that a misspelt condition fails loudly rather
+// than matching everything.
+func LoadYAML(data []byte) ([]Rule, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var file File
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parsing rules: %w", err)
+	}
+
+	rules := make([]Rule, 0, len(file.Rules))
+	for i, declaration := range file.Rules {
+		rule, err := declaration.compile()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i, declaration.ID, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// compiledCondition is a validated condition.
+type compiledCondition struct {
+	attribute   string
+	equals      interface{}
+	hasEquals   bool
+	containsAny []string
+}
+
+func (d Declaration) compile() (Rule, error) {
+	if d.ID == "" {
+		return Rule{}, fmt.Errorf("missing id")
+	}
+	if d.ResourceType == "" {
+		return Rule{}, fmt.Errorf("missing resource_type")
+	}
+	severity, err := ParseSeverity(d.Severity)
+	if err != nil {
+		return Rule{}, err
+	}
+	if len(d.When) == 0 {
+		return Rule{}, fmt.Errorf("no conditions under when")
+	}
+
+	conditions := make([]compiledCondition, 0, len(d.When))
+	for i, condition := range d.When {
+		if condition.Attribute == "" {
+			return Rule{}, fmt.Errorf("condition %d: missing attribute", i)
+		}
+		if (condition.Equals.Kind != 0) == (len(condition.ContainsAny) > 0) {
+			return Rule{}, fmt.Errorf("condition %d: set exactly one of equals and contains_any", i)
+		}
+		compiled := compiledCondition{attribute: condition.Attribute, containsAny: condition.ContainsAny}
+		if condition.Equals.Kind != 0 {
+			var value interface{}
+			if err := condition.Equals.Decode(&value); err != nil {
+				return Rule{}, fmt.Errorf("condition %d: %w", i, err)
+			}
+			if compiled.equals, err = normalize(value); err != nil {
+				return Rule{}, fmt.Errorf("condition %d: %w", i, err)
+			}
+			compiled.hasEquals = true
+		}
+		conditions = append(conditions, compiled)
+	}
+
+	return Rule{
+		ID:          d.ID,
+		Description: d.Description,
+		Severity:    severity,
+		Check: func(plan *planassert.Plan) []Violation {
+			var inScope map[string]bool
+			if d.Address != "" {
+				inScope = map[string]bool{}
+				for _, resource := range plan.Match(d.Address) {
+					inScope[resource.Address] = true
+				}
+			}
+
+			var violations []Violation
+			for _, resource := range plan.OfType(d.ResourceType) {
+				if inScope != nil && !inScope[resource.Address] {
+					continue
+				}
+				if holdsAll(resource, conditions) {
+					violations = append(violations, Violation{Address: resource.Address})
+				}
+			}
+			return violations
+		},
+	}, nil
+}
+
+func holdsAll(resource *planassert.Resource, conditions []compiledCondition) bool {
+	for _, condition := range conditions {
+		if !condition.holds(resource) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c compiledCondition) holds(resource *planassert.Resource) bool {
+	for _, value := range Values(resource, c.attribute) {
+		if c.hasEquals {
+			normalized, err := normalize(value)
+			if err == nil && reflect.DeepEqual(normalized, c.equals) {
+				return true
+			}
+			continue
+		}
+		if containsAny(value, c.containsAny) {
+			return true
+		}
+	}
+	return false
+}
+
+// Values returns every planned value the dotted path resolves to. A "*" segment expands to every element of a list,
+// or every value of a map. Attributes that are absent or unknown until apply resolve to nothing.
+func Values(resource *planassert.Resource, path string) []interface{} {
+	current := []interface{}{resource.Values}
+	for _, segment := range strings.Split(path, ".") {
+		var next []interface{}
+		for _, node := range current {
+			switch node := node.(type) {
+			case map[string]interface{}:
+				if segment == "*" {
+					for _, value := range node {
+						next = append(next, value)
+					}
+				} else if value, ok := node[segment]; ok {
+					next = append(next, value)
+				}
+			case []interface{}:
+				if segment == "*" {
+					next = append(next, node...)
+				} else if index, err := strconv.Atoi(segment); err == nil && index >= 0 && index < len(node) {
+					next = append(next, node[index])
+				}
+			}
+		}
+		current = next
+	}
+
+	out := current[:0]
+	for _, value := range current {
+		if value != nil {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+func containsAny(value interface{}, wanted []string) bool {
+	switch value := value.(type) {
+	case string:
+		for _, w := range wanted {
+			if value == w {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, element := range value {
+			if containsAny(element, wanted) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalize round-trips a value through JSON so that YAML integers and plan float64s compare equal.
+func normalize(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
@@ -0,0 +1,145 @@
+// Package policy evaluates policy-as-code rules against a Terraform plan, so the security checks that tfsec and
+// checkov run from the Makefile also run under `go test`, against the exact values a module plans. Rules are either
+// Go functions over the whole plan or declarative YAML rules over the planned attributes of one resource type.
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// Severity is how serious a violation is.
+type Severity string
+
+const (
+	SeverityCritical Severity = "critical"
+	SeverityHigh     Severity = "high"
+	SeverityMedium   Severity = "medium"
+	SeverityLow      Severity = "low"
+)
+
+var severityRanks = map[Severity]int{
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     3,
+	SeverityCritical: 4,
+}
+
+// ParseSeverity parses a severity name, case insensitively.
+func ParseSeverity(name string) (Severity, error) {
+	severity := Severity(strings.ToLower(name))
+	if _, ok := severityRanks[severity]; !ok {
+		return "", fmt.Errorf("unknown severity %q, expected one of critical, high, medium or low", name)
+	}
+	return severity, nil
+}
+
+// AtLeast reports whether the severity is as serious as min or more.
+func (s Severity) AtLeast(min Severity) bool {
+	return severityRanks[s] >= severityRanks[min]
+}
+
+// Violation is a resource that breaks a rule.
+type Violation struct {
+	RuleID   string   `json:"rule_id"`
+	Severity Severity `json:"severity"`
+	Address  string   `json:"address"`
+	Message  string   `json:"message"`
+}
+
+// String renders the violation on one line, e.g.
+// critical: module.eks.aws_eks_cluster.main: [eks-public-endpoint-open] EKS public API endpoint is open to the internet
+func (v Violation) String() string {
+	return fmt.Sprintf("%s: %s: [%s] %s", v.Severity, v.Address, v.RuleID, v.Message)
+}
+
+// Rule is a single policy rule.
+type Rule struct {
+	// ID identifies the rule in violations, e.g. eks-public-endpoint-open.
+	ID string
+
+	// Description says what is wrong with a violating resource. It is the message of violations whose check does not
+	// return one of its own.
+	Description string
+
+	Severity Severity
+
+	// Check returns the resources of the plan that violate the rule. The engine fills in the rule ID and severity,
+	// and the description when the message is empty.
+	Check func(plan *planassert.Plan) []Violation
+}
+
+// RuleSet is an ordered collection of rules with unique IDs.
+type RuleSet struct {
+	rules []Rule
+	ids   map[string]bool
+}
+
+// NewRuleSet returns a rule set holding the given rules.
+func NewRuleSet(rules ...Rule) (*RuleSet, error) {
+	set := &RuleSet{ids: map[string]bool{}}
+	if err := set.Add(rules...); err != nil {
+		return nil, err
+	}
+	return set, nil
+}
+
+// Add appends rules to the set. Every rule needs an ID that is not already in the set, a known severity and a check.
+func (s *RuleSet) Add(rules ...Rule) error {
+	for _, rule := range rules {
+		switch {
+		case rule.ID == "":
+			return fmt.Errorf("rule has no ID")
+		case s.ids[rule.ID]:
+			return fmt.Errorf("duplicate rule ID %q", rule.ID)
+		case rule.Check == nil:
+			return fmt.Errorf("rule %s has no check", rule.ID)
+		}
+		if _, err := ParseSeverity(string(rule.Severity)); err != nil {
+			return fmt.Errorf("rule %s: %w", rule.ID, err)
+		}
+		s.ids[rule.ID] = true
+		s.rules = append(s.rules, rule)
+	}
+	return nil
+}
+
+// Rules returns the rules in the order they were added.
+func (s *RuleSet) Rules() []Rule {
+	return append([]Rule(nil), s.rules...)
+}
+
+// Evaluate runs every rule against the plan and returns the violations sorted by address, then rule ID.
+func (s *RuleSet) Evaluate(plan *planassert.Plan) []Violation {
+	var violations []Violation
+	for _, rule := range s.rules {
+		for _, violation := range rule.Check(plan) {
+			violation.RuleID, violation.Severity = rule.ID, rule.Severity
+			if violation.Message == "" {
+				violation.Message = rule.Description
+			}
+			violations = append(violations, violation)
+		}
+	}
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Address != violations[j].Address {
+			return violations[i].Address < violations[j].Address
+		}
+		return violations[i].RuleID < violations[j].RuleID
+	})
+	return violations
+}
+
+// Filter returns the violations at least as serious as min.
+func Filter(violations []Violation, min Severity) []Violation {
+	var out []Violation
+	for _, violation := range violations {
+		if violation.Severity.AtLeast(min) {
+			out = append(out, violation)
+		}
+	}
+	return out
+}
@@ -0,0 +1,175 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+const samplePlan = `{
+  "format_version": "1.2",
+  "planned_values": {
+    "root_module": {
+      "child_modules": [
+        {
+          "address": "module.eks",
+          "resources": [
+            {"address": "module.eks.aws_eks_cluster.main", "mode": "managed", "type": "aws_eks_cluster", "name": "main",
+             "values": {"vpc_config": [{"endpoint_public_access": true, "public_access_cidrs": ["0.0.0.0/0"]}]}},
+            {"address": "module.eks.aws_security_group_rule.cluster_ingress_workstation_https", "mode": "managed", "type": "aws_security_group_rule", "name": "cluster_ingress_workstation_https",
+             "values": {"type": "ingress", "from_port": 443, "to_port": 443, "cidr_blocks": ["0.0.0.0/0"]}},
+            {"address": "module.eks.aws_security_group.cluster", "mode": "managed", "type": "aws_security_group", "name": "cluster",
+             "values": {"ingress": [], "egress": [{"cidr_blocks": ["0.0.0.0/0"]}]}}
+          ]
+        },
+        {
+          "address": "module.node_groups",
+          "resources": [
+            {"address": "module.node_groups.aws_security_group.node_group", "mode": "managed", "type": "aws_security_group", "name": "node_group",
+             "values": {"ingress": [{"cidr_blocks": ["10.0.0.0/16"]}, {"cidr_blocks": ["10.1.0.0/16", "0.0.0.0/0"]}]}},
+            {"address": "module.node_groups.aws_security_group_rule.egress", "mode": "managed", "type": "aws_security_group_rule", "name": "egress",
+             "values": {"type": "egress", "cidr_blocks": ["0.0.0.0/0"]}}
+          ]
+        },
+        {
+          "address": "module.rds",
+          "resources": [
+            {"address": "module.rds.aws_db_instance.main[0]", "mode": "managed", "type": "aws_db_instance", "name": "main", "index": 0, "values": {}},
+            {"address": "module.rds.aws_security_group_rule.rds_egress", "mode": "managed", "type": "aws_security_group_rule", "name": "rds_egress",
+             "values": {"type": "egress", "cidr_blocks": ["0.0.0.0/0"]}}
+          ]
+        },
+        {
+          "address": "module.private_eks",
+          "resources": [
+            {"address": "module.private_eks.aws_eks_cluster.main", "mode": "managed", "type": "aws_eks_cluster", "name": "main",
+             "values": {"vpc_config": [{"endpoint_public_access": false, "public_access_cidrs": ["0.0.0.0/0"]}]}}
+          ]
+        }
+      ]
+    }
+  }
+}`
+
+func parseSample(t *testing.T) *planassert.Plan {
+	t.Helper()
+
+	plan, err := planassert.ParseJSON([]byte(samplePlan))
+	require.NoError(t, err)
+	return plan
+}
+
+func TestDefaultRules(t *testing.T) {
+	t.Parallel()
+
+	rules, err := Default()
+	require.NoError(t, err)
+
+	violations := rules.Evaluate(parseSample(t))
+	AssertViolations(t, violations,
+		"eks-public-endpoint-open module.eks.aws_eks_cluster.main",
+		"sg-rule-ingress-open module.eks.aws_security_group_rule.cluster_ingress_workstation_https",
+		"sg-inline-ingress-open module.node_groups.aws_security_group.node_group",
+		"rds-egress-open module.rds.aws_security_group_rule.rds_egress",
+	)
+
+	// Sorted by address, with the engine filling in severity and message
+	require.Len(t, violations, 4)
+	assert.Equal(t, "module.eks.aws_eks_cluster.main", violations[0].Address)
+	assert.Equal(t, SeverityCritical, violations[0].Severity)
+	assert.Equal(t, "critical: module.eks.aws_eks_cluster.main: [eks-public-endpoint-open] "+
+		"EKS public API endpoint is open to the internet; restrict vpc_config.public_access_cidrs", violations[0].String())
+	assert.Equal(t, SeverityMedium, violations[3].Severity)
+
+	AssertNoViolations(t, Filter(violations, SeverityLow)[3:], SeverityHigh)
+	assert.Len(t, Filter(violations, SeverityHigh), 3)
+}
+
+func TestGoRule(t *testing.T) {
+	t.Parallel()
+
+	rule := Rule{
+		ID:       "no-node-groups",
+		Severity: SeverityLow,
+		Check: func(plan *planassert.Plan) []Violation {
+			var violations []Violation
+			for _, resource := range plan.Managed() {
+				if resource.ModuleAddress != "module.node_groups" {
+					continue
+				}
+				violations = append(violations, Violation{Address: resource.Address, Message: "node group module resource"})
+			}
+			return violations
+		},
+	}
+	rules, err := NewRuleSet(rule)
+	require.NoError(t, err)
+
+	violations := rules.Evaluate(parseSample(t))
+	require.Len(t, violations, 2)
+	assert.Equal(t, "no-node-groups", violations[0].RuleID)
+	assert.Equal(t, "node group module resource", violations[0].Message)
+
+	assert.Error(t, rules.Add(rule), "duplicate ID")
+	_, err = NewRuleSet(Rule{ID: "x", Severity: "urgent", Check: rule.Check})
+	assert.Error(t, err, "unknown severity")
+}
+
+func TestDeclarativeRules(t *testing.T) {
+	t.Parallel()
+
+	rules, err := LoadYAML([]byte(`
+rules:
+  - id: https-ingress
+    description: HTTPS ingress rule
+    severity: LOW
+    resource_type: aws_security_group_rule
+    address: module.eks.aws_security_group_rule.*
+    when:
+      - attribute: from_port
+        equals: 443
+      - attribute: type
+        equals: ingress
+`))
+	require.NoError(t, err)
+	set, err := NewRuleSet(rules...)
+	require.NoError(t, err)
+
+	AssertViolations(t, set.Evaluate(parseSample(t)), "https-ingress module.eks.aws_security_group_rule.cluster_ingress_workstation_https")
+}
+
+func TestLoadYAMLRejectsInvalidRules(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]string{
+		"unknown field":      "rules: [{id: a, severity: low, resource_type: t, when: [{attribute: x, equal: 1}]}]",
+		"missing id":         "rules: [{severity: low, resource_type: t, when: [{attribute: x, equals: 1}]}]",
+		"missing type":       "rules: [{id: a, severity: low, when: [{attribute: x, equals: 1}]}]",
+		"bad severity":       "rules: [{id: a, severity: urgent, resource_type: t, when: [{attribute: x, equals: 1}]}]",
+		"no conditions":      "rules: [{id: a, severity: low, resource_type: t}]",
+		"no attribute":       "rules: [{id: a, severity: low, resource_type: t, when: [{equals: 1}]}]",
+		"no test":            "rules: [{id: a, severity: low, resource_type: t, when: [{attribute: x}]}]",
+		"both tests":         "rules: [{id: a, severity: low, resource_type: t, when: [{attribute: x, equals: 1, contains_any: [a]}]}]",
+		"not a rule listing": "rules: {id: a}",
+	}
+	for name, rules := range testCases {
+		_, err := LoadYAML([]byte(rules))
+		assert.Error(t, err, name)
+	}
+}
+
+func TestValues(t *testing.T) {
+	t.Parallel()
+
+	plan := parseSample(t)
+	resource, ok := plan.Resource("module.node_groups.aws_security_group.node_group")
+	require.True(t, ok)
+
+	assert.Len(t, Values(resource, "ingress.*.cidr_blocks"), 2)
+	assert.Equal(t, []interface{}{"10.1.0.0/16"}, Values(resource, "ingress.1.cidr_blocks.0"))
+	assert.Empty(t, Values(resource, "ingress.2.cidr_blocks"))
+	assert.Empty(t, Values(resource, "egress.*.cidr_blocks"))
+}
@@ -5,62 +5,91 @@ import (
 
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/harness"
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+	"github.com/your-org/multi-az-eks-cluster/test/rdsreplica"
+	"github.com/your-org/multi-az-eks-cluster/test/tfdiag"
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
 )
 
 func TestRDSModule(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/rds",
-		Vars: map[string]interface{}{
-			"identifier":              "test-rds",
-			"vpc_id":                  "vpc-12345678",
-			"subnet_ids":              []string{"subnet-db-1", "subnet-db-2", "subnet-db-3"},
-			"availability_zones":      []string{"us-east-1a", "us-east-1b", "us-east-1c"},
-			"engine":                  "postgres",
-			"engine_version":          "15.4",
-			"instance_class":          "db.t3.medium",
-			"allocated_storage":       100,
-			"database_name":           "testdb",
-			"master_username":         "dbadmin",
-			"backup_retention_period": 7,
-			"multi_az":                true,
-			"storage_encrypted":       true,
-			"allowed_security_group_ids": []string{"sg-eks-nodes"},
-			"tags": map[string]string{
-				"Environment": "test",
-			},
-		},
-		PlanOnly: true,
-	})
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
 
-	defer terraform.Destroy(t, terraformOptions)
+	runStages(t, fixture, func() *terraform.Options {
+		return terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+			TerraformDir: "../modules/rds",
+			Vars: map[string]interface{}{
+				"identifier":                 "test-rds",
+				"vpc_id":                     fixture.VPC().ID,
+				"subnet_ids":                 fixture.VPC().DatabaseSubnetIDs,
+				"availability_zones":         []string{"us-east-1a", "us-east-1b", "us-east-1c"},
+				"engine":                     "postgres",
+				"engine_version":             "15.4",
+				"instance_class":             "db.t3.medium",
+				"allocated_storage":          100,
+				"database_name":              "testdb",
+				"master_username":            "dbadmin",
+				"backup_retention_period":    7,
+				"multi_az":                   true,
+				"storage_encrypted":          true,
+				"allowed_security_group_ids": []string{"sg-eks-nodes"},
+				"tags": map[string]string{
+					"Environment": "test",
+				},
+			},
+		})
+	}, func(plan *planassert.Plan) {
+		// Expected resources:
+		// - Random password
+		// - Secrets Manager secret + version
+		// - DB Subnet Group
+		// - Security Group + Rules
+		// - KMS Key + Alias
+		// - DB Parameter Group
+		// - DB Instance
+		// - IAM Role for monitoring + policy attachment
+		planassert.AssertCounts(t, plan, 13, 0, 0)
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
+		planassert.AssertResourceCount(t, plan, "random_password.master[*]", 1)
+		planassert.AssertResourceCount(t, plan, "aws_secretsmanager_secret.rds_password[*]", 1)
+		planassert.AssertResourceCount(t, plan, "aws_secretsmanager_secret_version.rds_password[*]", 1)
+		planassert.AssertResourceCount(t, plan, "aws_security_group_rule.rds_ingress_eks[*]", 1)
+		planassert.AssertResourceCount(t, plan, "aws_db_instance.main[*]", 1)
+		planassert.AssertNoResourcesMatching(t, plan, "aws_db_instance.replica[*]")
 
-	// Expected resources:
-	// - Random password
-	// - Secrets Manager secret + version
-	// - DB Subnet Group
-	// - Security Group + Rules
-	// - KMS Key + Alias
-	// - DB Parameter Group
-	// - DB Instance
-	// - IAM Role for monitoring + policy attachment
+		const instance = "aws_db_instance.main[0]"
+		planassert.AssertAttributeEquals(t, plan, instance, "identifier", "test-rds")
+		planassert.AssertAttributeEquals(t, plan, instance, "engine", "postgres")
+		planassert.AssertAttributeEquals(t, plan, instance, "engine_version", "15.4")
+		planassert.AssertAttributeEquals(t, plan, instance, "instance_class", "db.t3.medium")
+		planassert.AssertAttributeEquals(t, plan, instance, "allocated_storage", 100)
+		planassert.AssertAttributeEquals(t, plan, instance, "storage_type", "gp3")
+		planassert.AssertAttributeEquals(t, plan, instance, "db_name", "testdb")
+		planassert.AssertAttributeEquals(t, plan, instance, "username", "dbadmin")
+		planassert.AssertAttributeEquals(t, plan, instance, "deletion_protection", true)
+		planassert.AssertAttributeEquals(t, plan, "aws_security_group_rule.rds_ingress_eks[\"sg-eks-nodes\"]", "source_security_group_id", "sg-eks-nodes")
 
-	assert.Greater(t, resourceCounts.Add, 12, "Should create more than 12 resources")
+		// The database security group allows egress to anywhere
+		assertPolicy(t, plan, "rds-egress-open aws_security_group_rule.rds_egress")
+		assertGolden(t, plan)
+	})
 }
 
 func TestRDSModuleMultiAZ(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/rds",
 		Vars: map[string]interface{}{
 			"identifier":              "test-rds-multi-az",
-			"vpc_id":                  "vpc-12345678",
-			"subnet_ids":              []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":                  fixture.VPC().ID,
+			"subnet_ids":              fixture.VPC().DatabaseSubnetIDs,
 			"availability_zones":      []string{"us-east-1a", "us-east-1b", "us-east-1c"},
 			"engine":                  "postgres",
 			"engine_version":          "15.4",
@@ -72,22 +101,27 @@ func TestRDSModuleMultiAZ(t *testing.T) {
 			"multi_az":                true,
 			"storage_encrypted":       true,
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should succeed with multi-AZ configuration")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "multi_az", true)
+	// With multi_az the availability zone is left for AWS to choose
+	planassert.AssertAttributeUnknown(t, plan, "aws_db_instance.main[0]", "availability_zone")
+	planassert.AssertAttributeEquals(t, plan, "aws_db_subnet_group.main", "subnet_ids", fixture.VPC().DatabaseSubnetIDs)
 }
 
 func TestRDSModuleReadReplica(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-87654321")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/rds",
 		Vars: map[string]interface{}{
 			"identifier":          "test-rds-replica",
-			"vpc_id":              "vpc-87654321",
-			"subnet_ids":          []string{"subnet-rep-1", "subnet-rep-2", "subnet-rep-3"},
+			"vpc_id":              fixture.VPC().ID,
+			"subnet_ids":          fixture.VPC().DatabaseSubnetIDs,
 			"availability_zones":  []string{"us-west-2a", "us-west-2b", "us-west-2c"},
 			"engine":              "postgres",
 			"engine_version":      "15.4",
@@ -97,27 +131,75 @@ func TestRDSModuleReadReplica(t *testing.T) {
 			"master_username":     "admin",
 			"multi_az":            true,
 			"storage_encrypted":   true,
-			"replicate_source_db": "arn:aws:rds:us-east-1:123456789012:db:test-rds-primary",
+			"replicate_source_db": fixture.ARN("rds", "db:test-rds-primary"),
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
+	plan := initAndPlan(t, fixture, terraformOptions)
 
 	// Read replica shouldn't create secrets or primary DB
-	assert.Greater(t, resourceCounts.Add, 5, "Should create replica resources")
+	planassert.AssertCounts(t, plan, 7, 0, 0)
+	planassert.AssertResourceCount(t, plan, "aws_db_instance.replica[*]", 1)
+	planassert.AssertNoResourcesMatching(t, plan, "aws_db_instance.main[*]")
+	planassert.AssertNoResourcesOfType(t, plan, "random_password")
+	planassert.AssertNoResourcesOfType(t, plan, "aws_secretsmanager_secret")
+	planassert.AssertNoResourcesOfType(t, plan, "aws_secretsmanager_secret_version")
+
+	planassert.AssertAttributeEquals(t, plan, "aws_db_instance.replica[0]", "replicate_source_db", fixture.ARN("rds", "db:test-rds-primary"))
+	planassert.AssertAttributeEquals(t, plan, "aws_db_instance.replica[0]", "backup_retention_period", 0)
+
+	// A replica in the source region is encrypted with the source's key
+	rdsreplica.AssertReplica(t, plan, rdsreplica.Options{Region: fixture.Region, SourceARN: fixture.ARN("rds", "db:test-rds-primary")})
+}
+
+func TestRDSModuleCrossRegionReadReplica(t *testing.T) {
+	t.Parallel()
+
+	fixture := harness.NewFixture("us-west-2", "vpc-87654321")
+	sourceARN := "arn:aws:rds:us-east-1:" + fixture.AccountID + ":db:test-rds-primary"
+
+	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+		TerraformDir: "../modules/rds",
+		Vars: map[string]interface{}{
+			"identifier":              "test-rds-replica",
+			"vpc_id":                  fixture.VPC().ID,
+			"subnet_ids":              fixture.VPC().DatabaseSubnetIDs,
+			"availability_zones":      fixture.VPC().AvailabilityZones,
+			"engine":                  "postgres",
+			"engine_version":          "15.4",
+			"instance_class":          "db.r6g.xlarge",
+			"allocated_storage":       500,
+			"database_name":           "replicadb",
+			"master_username":         "admin",
+			"multi_az":                true,
+			"storage_encrypted":       true,
+			"replicate_source_db":     sourceARN,
+			"replicate_source_region": "us-east-1",
+		},
+	})
+
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	// The source's key lives in us-east-1, so the replica brings a key and alias of its own
+	planassert.AssertCounts(t, plan, 9, 0, 0)
+	planassert.AssertResourceExists(t, plan, "aws_kms_key.replica[0]")
+	planassert.AssertAttributeEquals(t, plan, "aws_kms_alias.replica[0]", "name", "alias/test-rds-replica-rds-replica")
+	planassert.AssertAttributeUnknown(t, plan, "aws_db_instance.replica[0]", "kms_key_id")
+
+	rdsreplica.AssertReplica(t, plan, rdsreplica.Options{Region: fixture.Region, SourceARN: sourceARN})
 }
 
 func TestRDSModuleEncryption(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/rds",
 		Vars: map[string]interface{}{
 			"identifier":              "test-rds-encrypted",
-			"vpc_id":                  "vpc-12345678",
-			"subnet_ids":              []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":                  fixture.VPC().ID,
+			"subnet_ids":              fixture.VPC().DatabaseSubnetIDs,
 			"availability_zones":      []string{"us-east-1a", "us-east-1b", "us-east-1c"},
 			"engine":                  "postgres",
 			"engine_version":          "15.4",
@@ -129,22 +211,30 @@ func TestRDSModuleEncryption(t *testing.T) {
 			"multi_az":                false,
 			"storage_encrypted":       true,
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should include KMS encryption")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	planassert.AssertResourceCount(t, plan, "aws_kms_key.rds[*]", 1)
+	planassert.AssertResourceCount(t, plan, "aws_kms_alias.rds[*]", 1)
+	planassert.AssertAttributeEquals(t, plan, "aws_kms_key.rds[0]", "enable_key_rotation", true)
+	planassert.AssertAttributeEquals(t, plan, "aws_kms_alias.rds[0]", "name", "alias/test-rds-encrypted-rds")
+	planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "storage_encrypted", true)
+	planassert.AssertAttributeUnknown(t, plan, "aws_db_instance.main[0]", "kms_key_id")
+	planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "availability_zone", "us-east-1a")
 }
 
 func TestRDSModuleMySQLEngine(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/rds",
 		Vars: map[string]interface{}{
 			"identifier":              "test-rds-mysql",
-			"vpc_id":                  "vpc-12345678",
-			"subnet_ids":              []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":                  fixture.VPC().ID,
+			"subnet_ids":              fixture.VPC().DatabaseSubnetIDs,
 			"availability_zones":      []string{"us-east-1a", "us-east-1b", "us-east-1c"},
 			"engine":                  "mysql",
 			"engine_version":          "8.0.35",
@@ -156,22 +246,140 @@ func TestRDSModuleMySQLEngine(t *testing.T) {
 			"multi_az":                true,
 			"storage_encrypted":       true,
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should succeed with MySQL engine")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "engine", "mysql")
+	planassert.AssertAttributeEquals(t, plan, "aws_db_parameter_group.main", "family", "mysql8.0")
+	planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "enabled_cloudwatch_logs_exports", []string{"error", "general", "slowquery"})
+
+	assertGolden(t, plan)
+}
+
+// rdsEngineCase is an engine version with the settings the rds module derives from it.
+type rdsEngineCase struct {
+	engine  string
+	version string
+
+	port       int
+	family     string
+	logExports []string
+	parameters map[string]string
+}
+
+// postgresParameters are the parameters the rds module sets for every PostgreSQL version.
+var postgresParameters = map[string]string{
+	"shared_preload_libraries":   "pg_stat_statements",
+	"log_statement":              "all",
+	"log_min_duration_statement": "1000",
+}
+
+var rdsEngineCases = []rdsEngineCase{
+	{"postgres", "13.13", 5432, "postgres13", []string{"postgresql", "upgrade"}, postgresParameters},
+	{"postgres", "14.10", 5432, "postgres14", []string{"postgresql", "upgrade"}, postgresParameters},
+	{"postgres", "15.4", 5432, "postgres15", []string{"postgresql", "upgrade"}, postgresParameters},
+	{"postgres", "15", 5432, "postgres15", []string{"postgresql", "upgrade"}, postgresParameters},
+	{"postgres", "16.1", 5432, "postgres16", []string{"postgresql", "upgrade"}, postgresParameters},
+	{"mysql", "5.7.44", 3306, "mysql5.7", []string{"error", "general", "slowquery"}, map[string]string{}},
+	{"mysql", "8.0.35", 3306, "mysql8.0", []string{"error", "general", "slowquery"}, map[string]string{}},
+}
+
+// TestRDSModuleEngineMatrix checks, for every supported engine version, the port of the ingress rules, the parameter
+// group family and parameters, and the log exports the module derives from engine and engine_version. An unsupported
+// engine such as mariadb must fail validation rather than fall back to the postgres15 family and port 5432; see
+// RDSEngineSupported in validationCases.
+func TestRDSModuleEngineMatrix(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range rdsEngineCases {
+		tc := tc
+		t.Run(tc.engine+tc.version, func(t *testing.T) {
+			t.Parallel()
+
+			fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+			config := tfvars.BaselineRDSConfig().WithEngine(tc.engine, tc.version)
+			tfvars.Require(t, config)
+
+			terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+				TerraformDir: "../modules/rds",
+				Vars: map[string]interface{}{
+					"identifier":                 "test-rds-" + tc.engine,
+					"vpc_id":                     fixture.VPC().ID,
+					"subnet_ids":                 fixture.VPC().DatabaseSubnetIDs,
+					"availability_zones":         fixture.VPC().AvailabilityZones,
+					"engine":                     config.Engine,
+					"engine_version":             config.EngineVersion,
+					"instance_class":             config.InstanceClass,
+					"allocated_storage":          config.AllocatedStorage,
+					"database_name":              config.DatabaseName,
+					"master_username":            config.MasterUsername,
+					"allowed_security_group_ids": []string{"sg-eks-nodes"},
+				},
+			})
+
+			plan := initAndPlan(t, fixture, terraformOptions)
+
+			planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "engine", tc.engine)
+			planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "engine_version", tc.version)
+			planassert.AssertAttributeEquals(t, plan, `aws_security_group_rule.rds_ingress_eks["sg-eks-nodes"]`, "from_port", tc.port)
+			planassert.AssertAttributeEquals(t, plan, `aws_security_group_rule.rds_ingress_eks["sg-eks-nodes"]`, "to_port", tc.port)
+			planassert.AssertAttributeEquals(t, plan, "aws_db_parameter_group.main", "family", tc.family)
+			planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "enabled_cloudwatch_logs_exports", tc.logExports)
+			assert.Equal(t, tc.parameters, parameterGroupParameters(t, plan, "aws_db_parameter_group.main"))
+		})
+	}
+}
+
+// TestRDSModuleMySQLMajorVersion checks that a MySQL engine_version without a minor version, which names no parameter
+// group family, fails the parameter group's precondition rather than a slice() call.
+func TestRDSModuleMySQLMajorVersion(t *testing.T) {
+	t.Parallel()
+
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+	vars := rdsVars(fixture)
+	vars["engine"] = "mysql"
+	vars["engine_version"] = "8"
+
+	var failed []tfdiag.Diagnostic
+	for _, diagnostic := range planDiagnostics(t, fixture, &terraform.Options{TerraformDir: "../modules/rds", Vars: vars}) {
+		if diagnostic.Severity == tfdiag.SeverityError {
+			failed = append(failed, diagnostic)
+		}
+	}
+	require.Len(t, failed, 1, "%v", failed)
+	assert.Equal(t, "Resource precondition failed", failed[0].Summary)
+	assert.Equal(t, "A MySQL engine_version must give at least the major and minor version, e.g. 8.0 or 8.0.35, as its "+
+		"parameter group family is named after both.", failed[0].Detail)
+}
+
+// parameterGroupParameters returns the planned parameters of a DB parameter group by name.
+func parameterGroupParameters(t *testing.T, plan *planassert.Plan, address string) map[string]string {
+	t.Helper()
+
+	parameterGroup := planassert.RequireResourceExists(t, plan, address)
+	parameters := map[string]string{}
+	planned, _ := parameterGroup.Attribute("parameter")
+	list, _ := planned.([]interface{})
+	for _, parameter := range list {
+		parameter, _ := parameter.(map[string]interface{})
+		name, _ := parameter["name"].(string)
+		parameters[name], _ = parameter["value"].(string)
+	}
+	return parameters
 }
 
 func TestRDSModuleBackupRetention(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/rds",
 		Vars: map[string]interface{}{
 			"identifier":              "test-rds-backup",
-			"vpc_id":                  "vpc-12345678",
-			"subnet_ids":              []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":                  fixture.VPC().ID,
+			"subnet_ids":              fixture.VPC().DatabaseSubnetIDs,
 			"availability_zones":      []string{"us-east-1a", "us-east-1b", "us-east-1c"},
 			"engine":                  "postgres",
 			"engine_version":          "15.4",
@@ -183,22 +391,27 @@ func TestRDSModuleBackupRetention(t *testing.T) {
 			"multi_az":                true,
 			"storage_encrypted":       true,
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should succeed with extended backup retention")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "backup_retention_period", 35)
+	planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "backup_window", "03:00-04:00")
+	planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "maintenance_window", "mon:04:00-mon:05:00")
+	planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "skip_final_snapshot", false)
 }
 
 func TestRDSModuleSecurityGroups(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/rds",
 		Vars: map[string]interface{}{
 			"identifier":         "test-rds-sg",
-			"vpc_id":             "vpc-12345678",
-			"subnet_ids":         []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":             fixture.VPC().ID,
+			"subnet_ids":         fixture.VPC().DatabaseSubnetIDs,
 			"availability_zones": []string{"us-east-1a", "us-east-1b", "us-east-1c"},
 			"engine":             "postgres",
 			"engine_version":     "15.4",
@@ -213,25 +426,33 @@ func TestRDSModuleSecurityGroups(t *testing.T) {
 				"sg-eks-nodes-2",
 			},
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
+	plan := initAndPlan(t, fixture, terraformOptions)
 
 	// Should create security group rules for each allowed SG
-	assert.Greater(t, resourceCounts.Add, 10, "Should create security group rules")
+	planassert.AssertCounts(t, plan, 14, 0, 0)
+	planassert.AssertResourceCount(t, plan, "aws_security_group_rule.rds_ingress_eks[*]", 2)
+	for _, sg := range []string{"sg-eks-nodes-1", "sg-eks-nodes-2"} {
+		address := "aws_security_group_rule.rds_ingress_eks[\"" + sg + "\"]"
+		planassert.AssertAttributeEquals(t, plan, address, "source_security_group_id", sg)
+		planassert.AssertAttributeEquals(t, plan, address, "from_port", 5432)
+		planassert.AssertAttributeEquals(t, plan, address, "to_port", 5432)
+		planassert.AssertAttributeEquals(t, plan, address, "type", "ingress")
+	}
 }
 
 func TestRDSModulePerformanceInsights(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/rds",
 		Vars: map[string]interface{}{
 			"identifier":              "test-rds-pi",
-			"vpc_id":                  "vpc-12345678",
-			"subnet_ids":              []string{"subnet-1", "subnet-2", "subnet-3"},
+			"vpc_id":                  fixture.VPC().ID,
+			"subnet_ids":              fixture.VPC().DatabaseSubnetIDs,
 			"availability_zones":      []string{"us-east-1a", "us-east-1b", "us-east-1c"},
 			"engine":                  "postgres",
 			"engine_version":          "15.4",
@@ -243,9 +464,13 @@ func TestRDSModulePerformanceInsights(t *testing.T) {
 			"multi_az":                true,
 			"storage_encrypted":       true,
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should enable Performance Insights")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "performance_insights_enabled", true)
+	planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "performance_insights_retention_period", 7)
+	planassert.AssertAttributeEquals(t, plan, "aws_db_instance.main[0]", "monitoring_interval", 60)
+	planassert.AssertAttributeEquals(t, plan, "aws_iam_role_policy_attachment.rds_monitoring", "policy_arn",
+		"arn:aws:iam::aws:policy/service-role/AmazonRDSEnhancedMonitoringRole")
 }
@@ -0,0 +1,13 @@
+package rdsreplica
+
+import (
+	"github.com/gruntwork-io/terratest/modules/testing"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// AssertReplica checks that the rds module at options.Module plans a read replica of options.SourceARN and nothing
+// the replica takes from its source, encrypted with a key in its own region when the source is in another one.
+func AssertReplica(t testing.TestingT, plan *planassert.Plan, options Options) bool {
+	return planassert.AssertNoFindings(t, Verify(plan, options), "read replica is not set up correctly")
+}
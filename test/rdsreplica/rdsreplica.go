@@ -0,0 +1,129 @@
+// Package rdsreplica verifies the read replica path of the rds module. With replicate_source_db set, the module must
+// plan the replica alone: no master password, secret, KMS key or primary instance, all of which the replica takes from
+// its source. A replica of an encrypted source in another region cannot use the source's KMS key, which lives in the
+// source region, so it must plan a key of its own, in the replica's module and so in the replica region, and encrypt
+// with it; without one AWS rejects the replica.
+package rdsreplica
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// SourceOnly are the resources of the rds module a replica must not plan, as patterns relative to the module.
+var SourceOnly = []string{
+	"random_password.master[*]",
+	"aws_secretsmanager_secret.rds_password[*]",
+	"aws_secretsmanager_secret_version.rds_password[*]",
+	"aws_kms_key.rds[*]",
+	"aws_kms_alias.rds[*]",
+	"aws_db_instance.main[*]",
+}
+
+// Addresses of the replica and of its KMS key, relative to the module.
+const (
+	Replica    = "aws_db_instance.replica[0]"
+	ReplicaKey = "aws_kms_key.replica[0]"
+)
+
+// Options say where the replica is planned and what it replicates.
+type Options struct {
+	// Module is the address prefix of the rds module, e.g. "module.secondary_region.module.rds[0]." or empty when the
+	// module is planned directly.
+	Module string
+
+	// Region is the region the replica is planned in.
+	Region string
+
+	// SourceARN is the ARN of the source instance, which the planned replicate_source_db must equal when it is known
+	// at plan time. Empty when the source does not exist yet.
+	SourceARN string
+
+	// SourceRegion is the region of the source instance, by default the region of SourceARN.
+	SourceRegion string
+}
+
+// Finding is a resource of the replica path that is planned but should not be, is missing, or is set up wrongly.
+type Finding struct {
+	Address string
+	Message string
+}
+
+// String renders the finding as "<address>: <message>".
+func (f Finding) String() string {
+	return f.Address + ": " + f.Message
+}
+
+// Verify checks the read replica the rds module at options.Module plans.
+func Verify(plan *planassert.Plan, options Options) []Finding {
+	var findings []Finding
+	add := func(address, format string, a ...interface{}) {
+		findings = append(findings, Finding{Address: address, Message: fmt.Sprintf(format, a...)})
+	}
+
+	for _, pattern := range SourceOnly {
+		for _, resource := range plan.Match(options.Module + pattern) {
+			if !resource.Destroyed() {
+				add(resource.Address, "planned for a read replica, which takes it from its source")
+			}
+		}
+	}
+
+	address := options.Module + Replica
+	replica, ok := plan.Resource(address)
+	if !ok || replica.Destroyed() {
+		add(address, "not planned")
+		return findings
+	}
+
+	if options.SourceARN != "" && !replica.IsUnknown("replicate_source_db") {
+		if source, _ := replica.Attribute("replicate_source_db"); source != options.SourceARN {
+			add(address, "replicate_source_db is %v, expected %s", source, options.SourceARN)
+		}
+	}
+
+	sourceRegion := options.SourceRegion
+	if sourceRegion == "" {
+		region, err := arnRegion(options.SourceARN)
+		if err != nil {
+			add(address, "cannot tell the source region: %v", err)
+			return findings
+		}
+		sourceRegion = region
+	}
+	crossRegion := sourceRegion != options.Region
+	encrypted, _ := replica.Attribute("storage_encrypted")
+
+	keyAddress := options.Module + ReplicaKey
+	key, planned := plan.Resource(keyAddress)
+	planned = planned && !key.Destroyed()
+	switch {
+	case crossRegion && encrypted == true:
+		if !planned {
+			add(keyAddress, "not planned, an encrypted replica of a source in %s needs a KMS key in %s", sourceRegion, options.Region)
+		}
+		if replica.IsUnknown("kms_key_id") {
+			break
+		}
+		keyID, _ := replica.Attribute("kms_key_id")
+		if keyID == nil {
+			add(address, "kms_key_id is not set, an encrypted replica of a source in %s needs a KMS key in %s", sourceRegion, options.Region)
+		} else if region, err := arnRegion(fmt.Sprint(keyID)); err == nil && region != options.Region {
+			add(address, "kms_key_id %v is a key in %s, expected one in %s", keyID, region, options.Region)
+		}
+	case !crossRegion && planned:
+		add(keyAddress, "planned for a replica in the source region %s, which is encrypted with the source's key", sourceRegion)
+	}
+	return findings
+}
+
+// arnRegion returns the region of an ARN, arn:<partition>:<service>:<region>:<account>:<resource>.
+func arnRegion(arn string) (string, error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[3] == "" {
+		return "", fmt.Errorf("%q is not a regional ARN", arn)
+	}
+	return parts[3], nil
+}
@@ -0,0 +1,178 @@
+package rdsreplica
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// recordingT collects failures without failing the enclosing test.
+type recordingT struct {
+	failed bool
+}
+
+func (r *recordingT) Fail()                                  { r.failed = true }
+func (r *recordingT) FailNow()                               { r.failed = true }
+func (r *recordingT) Fatal(args ...interface{})              { r.failed = true }
+func (r *recordingT) Fatalf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Error(args ...interface{})              { r.failed = true }
+func (r *recordingT) Errorf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Name() string                           { return "recordingT" }
+
+type resource = map[string]interface{}
+
+const (
+	sourceARN = "arn:aws:rds:us-east-1:123456789012:db:primary-db"
+	module    = "module.rds[0]."
+)
+
+// planned is a resource of the rds module with its planned values and the attributes only known after apply.
+type planned struct {
+	address string
+	values  resource
+	unknown resource
+}
+
+// samplePlan renders a regional-eks plan of the rds module with the given resources.
+func samplePlan(t *testing.T, resources ...planned) *planassert.Plan {
+	t.Helper()
+
+	var values, changes []resource
+	for _, r := range resources {
+		parsed := strings.TrimPrefix(r.address, module)
+		typ, rest, _ := strings.Cut(parsed, ".")
+		name, _, _ := strings.Cut(rest, "[")
+		values = append(values, resource{
+			"address": module + parsed, "mode": "managed", "type": typ, "name": name, "index": 0, "values": r.values,
+		})
+		unknown := r.unknown
+		if unknown == nil {
+			unknown = resource{}
+		}
+		changes = append(changes, resource{
+			"address": module + parsed, "module_address": "module.rds[0]", "mode": "managed", "type": typ, "name": name,
+			"index": 0, "change": resource{"actions": []string{"create"}, "after": r.values, "after_unknown": unknown},
+		})
+	}
+	data, err := json.Marshal(resource{
+		"format_version": "1.2",
+		"planned_values": resource{"root_module": resource{"child_modules": []resource{
+			{"address": "module.rds[0]", "resources": values},
+		}}},
+		"resource_changes": changes,
+	})
+	require.NoError(t, err)
+	plan, err := planassert.ParseJSON(data)
+	require.NoError(t, err)
+	return plan
+}
+
+// crossRegionReplica is an encrypted replica in us-west-2 of the source in us-east-1, with its own key.
+func crossRegionReplica() []planned {
+	return []planned{
+		{address: module + Replica,
+			values:  resource{"replicate_source_db": sourceARN, "storage_encrypted": true},
+			unknown: resource{"kms_key_id": true}},
+		{address: module + ReplicaKey, values: resource{"enable_key_rotation": true}},
+	}
+}
+
+func verify(plan *planassert.Plan, options Options) []string {
+	var rendered []string
+	for _, finding := range Verify(plan, options) {
+		rendered = append(rendered, finding.String())
+	}
+	return rendered
+}
+
+func TestVerifyCrossRegionReplica(t *testing.T) {
+	t.Parallel()
+
+	options := Options{Module: module, Region: "us-west-2", SourceARN: sourceARN}
+	assert.Empty(t, verify(samplePlan(t, crossRegionReplica()...), options))
+
+	// The replica without a key of its own, as the module planned it before the key was added
+	replica := planned{address: module + Replica, values: resource{"replicate_source_db": sourceARN, "storage_encrypted": true, "kms_key_id": nil}}
+	assert.Equal(t, []string{
+		"module.rds[0].aws_kms_key.replica[0]: not planned, an encrypted replica of a source in us-east-1 needs a KMS key in us-west-2",
+		"module.rds[0].aws_db_instance.replica[0]: kms_key_id is not set, an encrypted replica of a source in us-east-1 needs a KMS key in us-west-2",
+	}, verify(samplePlan(t, replica), options))
+
+	// A key of the source region cannot encrypt the replica
+	replica.values = resource{"replicate_source_db": sourceARN, "storage_encrypted": true,
+		"kms_key_id": "arn:aws:kms:us-east-1:123456789012:key/source"}
+	assert.Equal(t, []string{
+		"module.rds[0].aws_kms_key.replica[0]: not planned, an encrypted replica of a source in us-east-1 needs a KMS key in us-west-2",
+		"module.rds[0].aws_db_instance.replica[0]: kms_key_id arn:aws:kms:us-east-1:123456789012:key/source is a key in us-east-1, expected one in us-west-2",
+	}, verify(samplePlan(t, replica), options))
+
+	// An unencrypted replica needs no key
+	replica.values = resource{"replicate_source_db": sourceARN, "storage_encrypted": false, "kms_key_id": nil}
+	assert.Empty(t, verify(samplePlan(t, replica), options))
+}
+
+func TestVerifySameRegionReplica(t *testing.T) {
+	t.Parallel()
+
+	options := Options{Module: module, Region: "us-east-1", SourceARN: sourceARN}
+	replica := planned{address: module + Replica, values: resource{"replicate_source_db": sourceARN, "storage_encrypted": true, "kms_key_id": nil}}
+	assert.Empty(t, verify(samplePlan(t, replica), options))
+
+	assert.Equal(t, []string{
+		"module.rds[0].aws_kms_key.replica[0]: planned for a replica in the source region us-east-1, which is encrypted with the source's key",
+	}, verify(samplePlan(t, crossRegionReplica()...), options))
+}
+
+func TestVerifySourceOnlyResources(t *testing.T) {
+	t.Parallel()
+
+	resources := append(crossRegionReplica(),
+		planned{address: module + "random_password.master[0]", values: resource{"length": 32}},
+		planned{address: module + "aws_secretsmanager_secret.rds_password[0]", values: resource{}},
+		planned{address: module + "aws_kms_key.rds[0]", values: resource{}},
+		planned{address: module + "aws_db_instance.main[0]", values: resource{}},
+	)
+	assert.Equal(t, []string{
+		"module.rds[0].random_password.master[0]: planned for a read replica, which takes it from its source",
+		"module.rds[0].aws_secretsmanager_secret.rds_password[0]: planned for a read replica, which takes it from its source",
+		"module.rds[0].aws_kms_key.rds[0]: planned for a read replica, which takes it from its source",
+		"module.rds[0].aws_db_instance.main[0]: planned for a read replica, which takes it from its source",
+	}, verify(samplePlan(t, resources...), Options{Module: module, Region: "us-west-2", SourceARN: sourceARN}))
+}
+
+func TestVerifyReplicaSource(t *testing.T) {
+	t.Parallel()
+
+	plan := samplePlan(t, crossRegionReplica()...)
+	assert.Equal(t, []string{
+		"module.rds[0].aws_db_instance.replica[0]: replicate_source_db is " + sourceARN + ", expected arn:aws:rds:us-east-1:123456789012:db:other-db",
+	}, verify(plan, Options{Module: module, Region: "us-west-2", SourceARN: "arn:aws:rds:us-east-1:123456789012:db:other-db"}))
+
+	// In the root module the source is the primary, whose ARN is only known after apply
+	resources := crossRegionReplica()
+	resources[0].values = resource{"storage_encrypted": true}
+	resources[0].unknown = resource{"replicate_source_db": true, "kms_key_id": true}
+	assert.Empty(t, verify(samplePlan(t, resources...), Options{Module: module, Region: "us-west-2", SourceRegion: "us-east-1"}))
+	assert.Equal(t, []string{
+		`module.rds[0].aws_db_instance.replica[0]: cannot tell the source region: "" is not a regional ARN`,
+	}, verify(samplePlan(t, resources...), Options{Module: module, Region: "us-west-2"}))
+
+	assert.Equal(t, []string{"module.rds[0].aws_db_instance.replica[0]: not planned"},
+		verify(samplePlan(t, resources[1]), Options{Module: module, Region: "us-west-2", SourceARN: sourceARN}))
+}
+
+func TestAssertReplica(t *testing.T) {
+	t.Parallel()
+
+	options := Options{Module: module, Region: "us-west-2", SourceARN: sourceARN}
+	assert.True(t, AssertReplica(t, samplePlan(t, crossRegionReplica()...), options))
+
+	recorder := &recordingT{}
+	assert.False(t, AssertReplica(recorder, samplePlan(t, crossRegionReplica()[0]), options))
+	assert.True(t, recorder.failed)
+}
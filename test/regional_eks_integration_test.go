@@ -4,273 +4,159 @@ import (
 	"testing"
 
 	"github.com/gruntwork-io/terratest/modules/terraform"
-	"github.com/stretchr/testify/assert"
+
+	"github.com/your-org/multi-az-eks-cluster/test/harness"
+	"github.com/your-org/multi-az-eks-cluster/test/ouaccess"
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+	"github.com/your-org/multi-az-eks-cluster/test/rdsreplica"
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
 )
 
 func TestRegionalEKSModule(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/regional-eks",
-		Vars: map[string]interface{}{
-			"region":             "us-east-1",
-			"cluster_name":       "test-regional-cluster",
-			"vpc_id":             "vpc-12345678",
-			"availability_zones": []string{"us-east-1a", "us-east-1b", "us-east-1c"},
-			"environment":        "test",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"admin"},
-				},
-			},
-			"kubernetes_version": "1.28",
-			"node_groups": map[string]interface{}{
-				"general": map[string]interface{}{
-					"desired_size":   6,
-					"min_size":       3,
-					"max_size":       15,
-					"instance_types": []string{"t3.large"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      50,
-				},
-			},
-			"create_rds": true,
-			"rds_config": map[string]interface{}{
-				"engine":                  "postgres",
-				"engine_version":          "15.4",
-				"instance_class":          "db.t3.medium",
-				"allocated_storage":       100,
-				"database_name":           "testdb",
-				"master_username":         "dbadmin",
-				"backup_retention_period": 7,
-				"multi_az":                true,
-				"storage_encrypted":       true,
-			},
-		},
-		PlanOnly: true,
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
+	runStages(t, fixture, func() *terraform.Options {
+		return regionalOptions(t, tfvars.BaselineRegional(fixture.Region, fixture.VPC().ID))
+	}, func(plan *planassert.Plan) {
+		// Should create resources for:
+		// - EKS cluster (14 + 3 per OU)
+		// - Node groups (10 + 2 per node group)
+		// - RDS primary (12 + 1 ingress rule per allowed security group)
+		// - IAM roles (11 + 3 per OU when RDS is created)
+		planassert.AssertCounts(t, plan, 57, 0, 0)
+
+		planassert.AssertResourceCount(t, plan, "module.eks.aws_eks_cluster.main", 1)
+		planassert.AssertResourceCount(t, plan, "module.node_groups.aws_eks_node_group.main[*]", 1)
+		planassert.AssertResourceCount(t, plan, "module.rds[0].aws_db_instance.main[*]", 1)
+		planassert.AssertResourceCount(t, plan, "module.rds[0].aws_security_group_rule.rds_ingress_eks[*]", 2)
+		planassert.AssertResourceCount(t, plan, "module.iam_roles.aws_iam_role.rds_access[*]", 1)
+		planassert.AssertNoResourcesMatching(t, plan, "module.rds[0].aws_db_instance.replica[*]")
+
+		planassert.AssertAttributeEquals(t, plan, "module.eks.aws_eks_cluster.main", "name", "test-regional-cluster")
+		planassert.AssertAttributeEquals(t, plan, "module.rds[0].aws_db_instance.main[0]", "identifier", "test-regional-cluster-db")
+
+		// Subnets are looked up by their Type tag in the existing VPC
+		planassert.AssertAttributeEquals(t, plan, "module.eks.aws_eks_cluster.main", "vpc_config.0.subnet_ids", fixture.VPC().PrivateSubnetIDs)
+		planassert.AssertAttributeEquals(t, plan, "module.rds[0].aws_db_subnet_group.main", "subnet_ids", fixture.VPC().DatabaseSubnetIDs)
+
+		assertPolicy(t, plan,
+			"eks-public-endpoint-open module.eks.aws_eks_cluster.main",
+			"sg-rule-ingress-open module.eks.aws_security_group_rule.cluster_ingress_workstation_https",
+			"rds-egress-open module.rds[0].aws_security_group_rule.rds_egress",
+		)
+		assertGolden(t, plan)
 	})
-
-	defer terraform.Destroy(t, terraformOptions)
-
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
-
-	// Should create resources for:
-	// - EKS cluster
-	// - Node groups
-	// - RDS
-	// - IAM roles
-	assert.Greater(t, resourceCounts.Add, 30, "Should create more than 30 resources for complete regional setup")
 }
 
 func TestRegionalEKSWithoutRDS(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/regional-eks",
-		Vars: map[string]interface{}{
-			"region":             "us-west-2",
-			"cluster_name":       "test-cluster-no-rds",
-			"vpc_id":             "vpc-87654321",
-			"availability_zones": []string{"us-west-2a", "us-west-2b", "us-west-2c"},
-			"environment":        "test",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"view"},
-				},
-			},
-			"kubernetes_version": "1.28",
-			"node_groups": map[string]interface{}{
-				"workers": map[string]interface{}{
-					"desired_size":   3,
-					"min_size":       1,
-					"max_size":       9,
-					"instance_types": []string{"t3.medium"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      50,
-				},
-			},
-			"create_rds": false, // No RDS
-		},
-		PlanOnly: true,
+	fixture := harness.NewFixture("us-west-2", "vpc-87654321")
+
+	vars := tfvars.BaselineRegional(fixture.Region, fixture.VPC().ID)
+	vars.ClusterName = "test-cluster-no-rds"
+	vars.OrganizationalUnits[0].Permissions = []string{"view"}
+	vars.NodeGroups = tfvars.NodeGroups{"workers": smallNodeGroup()}
+	vars.CreateRDS, vars.RDSConfig = false, nil
+
+	runStages(t, fixture, func() *terraform.Options {
+		return regionalOptions(t, vars)
+	}, func(plan *planassert.Plan) {
+		// Should create fewer resources without RDS
+		planassert.AssertCounts(t, plan, 40, 0, 0)
+		planassert.AssertNoResourcesMatching(t, plan, "module.rds[*].*")
+		planassert.AssertNoResourcesOfType(t, plan, "aws_db_instance")
+		planassert.AssertNoResourcesMatching(t, plan, "module.iam_roles.aws_iam_role.rds_access[*]")
 	})
-
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
-
-	// Should create fewer resources without RDS
-	assert.Greater(t, resourceCounts.Add, 20, "Should create resources for EKS without RDS")
 }
 
 func TestRegionalEKSWithReadReplica(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/regional-eks",
-		Vars: map[string]interface{}{
-			"region":             "eu-west-1",
-			"cluster_name":       "test-cluster-replica",
-			"vpc_id":             "vpc-replica123",
-			"availability_zones": []string{"eu-west-1a", "eu-west-1b", "eu-west-1c"},
-			"environment":        "test",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"deploy", "view"},
-				},
-			},
-			"kubernetes_version": "1.28",
-			"node_groups": map[string]interface{}{
-				"general": map[string]interface{}{
-					"desired_size":   6,
-					"min_size":       3,
-					"max_size":       15,
-					"instance_types": []string{"t3.large"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      50,
-				},
-			},
-			"create_rds":     true,
-			"rds_primary_arn": "arn:aws:rds:us-east-1:123456789012:db:primary-db",
-			"rds_config": map[string]interface{}{
-				"engine":                  "postgres",
-				"engine_version":          "15.4",
-				"instance_class":          "db.t3.medium",
-				"allocated_storage":       100,
-				"database_name":           "replicadb",
-				"master_username":         "dbadmin",
-				"backup_retention_period": 7,
-				"multi_az":                true,
-				"storage_encrypted":       true,
-			},
-		},
-		PlanOnly: true,
+	fixture := harness.NewFixture("eu-west-1", "vpc-replica123")
+
+	vars := tfvars.BaselineRegional(fixture.Region, fixture.VPC().ID)
+	vars.ClusterName = "test-cluster-replica"
+	vars.OrganizationalUnits[0].Permissions = []string{"deploy", "view"}
+	vars.RDSPrimaryARN = "arn:aws:rds:us-east-1:" + fixture.AccountID + ":db:primary-db"
+	vars.RDSPrimaryRegion = "us-east-1"
+	vars.RDSConfig.DatabaseName = "replicadb"
+
+	runStages(t, fixture, func() *terraform.Options {
+		return regionalOptions(t, vars)
+	}, func(plan *planassert.Plan) {
+		// The source is in us-east-1, so the replica brings its own KMS key and alias
+		planassert.AssertCounts(t, plan, 54, 0, 0)
+		planassert.AssertResourceCount(t, plan, "module.rds[0].aws_db_instance.replica[*]", 1)
+		planassert.AssertNoResourcesMatching(t, plan, "module.rds[0].aws_db_instance.main[*]")
+		planassert.AssertNoResourcesOfType(t, plan, "aws_secretsmanager_secret")
+		planassert.AssertAttributeEquals(t, plan, "module.rds[0].aws_db_instance.replica[0]", "replicate_source_db",
+			"arn:aws:rds:us-east-1:"+fixture.AccountID+":db:primary-db")
+		rdsreplica.AssertReplica(t, plan, rdsreplica.Options{Module: "module.rds[0].", Region: fixture.Region, SourceARN: vars.RDSPrimaryARN})
 	})
-
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should succeed with RDS read replica configuration")
 }
 
 func TestRegionalEKSMultipleNodeGroups(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/regional-eks",
-		Vars: map[string]interface{}{
-			"region":             "us-east-1",
-			"cluster_name":       "test-cluster-multi-ng",
-			"vpc_id":             "vpc-12345678",
-			"availability_zones": []string{"us-east-1a", "us-east-1b", "us-east-1c"},
-			"environment":        "test",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "test-ou",
-					"ou_id":       "ou-test-001",
-					"permissions": []string{"admin"},
-				},
-			},
-			"kubernetes_version": "1.28",
-			"node_groups": map[string]interface{}{
-				"general": map[string]interface{}{
-					"desired_size":   6,
-					"min_size":       3,
-					"max_size":       15,
-					"instance_types": []string{"t3.large"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      50,
-				},
-				"spot": map[string]interface{}{
-					"desired_size":   3,
-					"min_size":       0,
-					"max_size":       12,
-					"instance_types": []string{"t3.large", "t3.xlarge"},
-					"capacity_type":  "SPOT",
-					"disk_size":      50,
-				},
-				"compute": map[string]interface{}{
-					"desired_size":   6,
-					"min_size":       3,
-					"max_size":       18,
-					"instance_types": []string{"c5.2xlarge"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      100,
-				},
-			},
-			"create_rds": false,
-		},
-		PlanOnly: true,
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
+	compute := tfvars.BaselineNodeGroup().WithSizes(3, 6, 18).WithInstanceTypes("c5.2xlarge")
+	compute.DiskSize = 100
+
+	vars := tfvars.BaselineRegional(fixture.Region, fixture.VPC().ID)
+	vars.ClusterName = "test-cluster-multi-ng"
+	vars.NodeGroups = tfvars.NodeGroups{
+		"general": tfvars.BaselineNodeGroup(),
+		"spot":    tfvars.BaselineNodeGroup().WithCapacityType("SPOT").WithSizes(0, 3, 12).WithInstanceTypes("t3.large", "t3.xlarge"),
+		"compute": compute,
+	}
+	vars.CreateRDS, vars.RDSConfig = false, nil
+
+	runStages(t, fixture, func() *terraform.Options {
+		return regionalOptions(t, vars)
+	}, func(plan *planassert.Plan) {
+		// Should create resources for 3 node groups
+		planassert.AssertCounts(t, plan, 44, 0, 0)
+		planassert.AssertResourceCount(t, plan, "module.node_groups.aws_eks_node_group.main[*]", 3)
+		planassert.AssertResourceCount(t, plan, "module.node_groups.aws_launch_template.node_group[*]", 3)
+		planassert.AssertAttributeEquals(t, plan, "module.node_groups.aws_eks_node_group.main[\"compute\"]", "node_group_name", "test-cluster-multi-ng-compute")
 	})
-
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
-
-	// Should create resources for 3 node groups
-	assert.Greater(t, resourceCounts.Add, 25, "Should create resources for multiple node groups")
 }
 
 func TestRegionalEKSMultipleOUs(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/regional-eks",
-		Vars: map[string]interface{}{
-			"region":             "us-west-2",
-			"cluster_name":       "test-cluster-multi-ou",
-			"vpc_id":             "vpc-12345678",
-			"availability_zones": []string{"us-west-2a", "us-west-2b", "us-west-2c"},
-			"environment":        "production",
-			"organizational_units": []map[string]interface{}{
-				{
-					"name":        "platform-ops",
-					"ou_id":       "ou-ops-001",
-					"permissions": []string{"admin"},
-				},
-				{
-					"name":        "engineering",
-					"ou_id":       "ou-eng-001",
-					"permissions": []string{"deploy", "view"},
-				},
-				{
-					"name":        "sre",
-					"ou_id":       "ou-sre-001",
-					"permissions": []string{"admin", "deploy", "view"},
-				},
-			},
-			"kubernetes_version": "1.28",
-			"node_groups": map[string]interface{}{
-				"workers": map[string]interface{}{
-					"desired_size":   6,
-					"min_size":       3,
-					"max_size":       15,
-					"instance_types": []string{"m5.large"},
-					"capacity_type":  "ON_DEMAND",
-					"disk_size":      100,
-				},
-			},
-			"create_rds": true,
-			"rds_config": map[string]interface{}{
-				"engine":                  "postgres",
-				"engine_version":          "15.4",
-				"instance_class":          "db.r6g.xlarge",
-				"allocated_storage":       500,
-				"database_name":           "proddb",
-				"master_username":         "dbadmin",
-				"backup_retention_period": 30,
-				"multi_az":                true,
-				"storage_encrypted":       true,
-			},
-		},
-		PlanOnly: true,
+	fixture := harness.NewFixture("us-west-2", "vpc-12345678")
+
+	workers := tfvars.BaselineNodeGroup().WithInstanceTypes("m5.large")
+	workers.DiskSize = 100
+
+	vars := tfvars.BaselineRegional(fixture.Region, fixture.VPC().ID)
+	vars.ClusterName = "test-cluster-multi-ou"
+	vars.Environment = "production"
+	vars.OrganizationalUnits = tfvars.OUs{
+		{Name: "platform-ops", OUID: "ou-ops-001", Permissions: []string{"admin"}},
+		{Name: "engineering", OUID: "ou-eng-001", Permissions: []string{"deploy", "view"}},
+		{Name: "sre", OUID: "ou-sre-001", Permissions: []string{"admin", "deploy", "view"}},
+	}
+	vars.NodeGroups = tfvars.NodeGroups{"workers": workers}
+	vars.RDSConfig.InstanceClass = "db.r6g.xlarge"
+	vars.RDSConfig.AllocatedStorage = 500
+	vars.RDSConfig.DatabaseName = "proddb"
+	vars.RDSConfig.BackupRetentionPeriod = 30
+
+	runStages(t, fixture, func() *terraform.Options {
+		return regionalOptions(t, vars)
+	}, func(plan *planassert.Plan) {
+		// Should create IAM resources for 3 OUs plus RDS access
+		planassert.AssertCounts(t, plan, 69, 0, 0)
+		planassert.AssertResourceCount(t, plan, "module.eks.aws_eks_access_entry.ou_access[*]", 3)
+		planassert.AssertResourceCount(t, plan, "module.eks.aws_iam_role.ou_access[*]", 3)
+		planassert.AssertResourceCount(t, plan, "module.iam_roles.aws_iam_role.rds_access[*]", 3)
+		planassert.AssertResourceCount(t, plan, "module.iam_roles.aws_iam_policy.rds_access[*]", 3)
+		ouaccess.AssertPolicies(t, plan, "module.eks.", vars.OrganizationalUnits)
 	})
-
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
-
-	// Should create IAM resources for 3 OUs plus RDS access
-	assert.Greater(t, resourceCounts.Add, 35, "Should create resources for multiple OUs and RDS")
 }
@@ -0,0 +1,323 @@
+package release
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	titleVersionPattern  = regexp.MustCompile(`^# .* v(\S+)\s*$`)
+	sourceRefPattern     = regexp.MustCompile("\\?ref=([^\"`\\s]+)")
+	moduleVersionPattern = regexp.MustCompile(`^\*\*Module Version\*\*:\s*(\S+)`)
+)
+
+// Bump returns the kind of release going from one version to a newer one: Major when the major number grows, Minor
+// when the minor number does, else Patch. It reports false when the second version is not newer.
+func Bump(from, to Version) (Type, bool) {
+	switch {
+	case !from.Less(to):
+		return "", false
+	case to.Major > from.Major:
+		return Major, true
+	case to.Minor > from.Minor:
+		return Minor, true
+	default:
+		return Patch, true
+	}
+}
+
+// RequiredBump returns the smallest bump the interface changes need from the version, or "" when there are none. From
+// 1.0.0 on, breaking changes need a major bump and additive changes a minor bump. Before 1.0.0 every release is a
+// pre-release that may break callers, so breaking changes need a minor bump and additive changes a patch bump.
+func RequiredBump(from Version, changes []Change) Type {
+	if len(changes) == 0 {
+		return ""
+	}
+	breaking := false
+	for _, change := range changes {
+		breaking = breaking || change.Kind == Breaking
+	}
+	switch {
+	case from.Major == 0 && breaking:
+		return Minor
+	case from.Major == 0:
+		return Patch
+	case breaking:
+		return Major
+	default:
+		return Minor
+	}
+}
+
+var bumpRank = map[Type]int{"": 0, Patch: 1, Minor: 2, Major: 3}
+
+// Newest returns the newest released version of the changelog entries, or nil when there is none.
+func Newest(entries []Entry) *Version {
+	var newest *Version
+	for _, entry := range entries {
+		if entry.Version != nil && (newest == nil || newest.Less(*entry.Version)) {
+			newest = entry.Version
+		}
+	}
+	return newest
+}
+
+// Options tune CheckModule.
+type Options struct {
+	// AllowUnreleased accepts interface changes recorded under ## [Unreleased] instead of a new version, as on a
+	// branch that has not been released yet. The entry must mention every changed variable and output by name.
+	AllowUnreleased bool
+}
+
+// Report is the outcome of checking a module's CHANGELOG.md and RELEASE.md against its interface.
+type Report struct {
+	Module string
+	// Base and Head are the newest released versions in the CHANGELOG.md of each tree, nil when there is none.
+	Base, Head *Version
+	// Unreleased is true when the CHANGELOG.md of the head tree has an ## [Unreleased] entry.
+	Unreleased bool
+	Changes    []Change
+	Problems   []string
+}
+
+// OK reports whether the check found no problems.
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Write renders the report: the versions, then one line per change and per problem.
+func (r *Report) Write(w io.Writer) error {
+	versions := fmt.Sprintf("%s -> %s", versionOrNone(r.Base), versionOrNone(r.Head))
+	if r.Unreleased {
+		versions += " (unreleased changes)"
+	}
+	summary := "no interface changes"
+	if len(r.Changes) > 0 {
+		summary = fmt.Sprintf("%d interface changes", len(r.Changes))
+	}
+	if _, err := fmt.Fprintf(w, "modules/%s: %s, %s\n", r.Module, versions, summary); err != nil {
+		return err
+	}
+	for _, change := range r.Changes {
+		if _, err := fmt.Fprintf(w, "  %s\n", change); err != nil {
+			return err
+		}
+	}
+	for _, problem := range r.Problems {
+		if _, err := fmt.Fprintf(w, "  problem: %s\n", problem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func versionOrNone(v *Version) string {
+	if v == nil {
+		return "none"
+	}
+	return v.String()
+}
+
+// CheckModule diffs the variables and outputs of modules/<module> from the base to the head tree and checks that the
+// CHANGELOG.md of the head tree records a release whose bump over the newest release of the base tree is at least the
+// one the changes need (RequiredBump). When the module has a RELEASE.md, it must be for the newest release and its
+// inputs and outputs tables must list exactly the module's variables, split into required and optional, and outputs.
+func CheckModule(base, head Tree, module string, options Options) (*Report, error) {
+	dir := "modules/" + module
+	headFiles, err := head.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(headFiles) == 0 {
+		return nil, fmt.Errorf("%s does not exist in %s", dir, head)
+	}
+	baseFiles, err := base.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	before, err := ParseInterface(baseFiles)
+	if err != nil {
+		return nil, fmt.Errorf("%s in %s: %w", dir, base, err)
+	}
+	after, err := ParseInterface(headFiles)
+	if err != nil {
+		return nil, fmt.Errorf("%s in %s: %w", dir, head, err)
+	}
+
+	report := &Report{Module: module, Changes: Diff(before, after)}
+	var headEntries []Entry
+	if data, ok := headFiles["CHANGELOG.md"]; !ok {
+		report.Problems = append(report.Problems, "there is no CHANGELOG.md")
+	} else if headEntries, err = ParseChangelog(data); err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("CHANGELOG.md: %v", err))
+	}
+	report.Head = Newest(headEntries)
+	var unreleased Entry
+	for _, entry := range headEntries {
+		if entry.Unreleased() && !report.Unreleased {
+			unreleased, report.Unreleased = entry, true
+		}
+	}
+	if data, ok := baseFiles["CHANGELOG.md"]; ok {
+		baseEntries, err := ParseChangelog(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s/CHANGELOG.md in %s: %w", dir, base, err)
+		}
+		report.Base = Newest(baseEntries)
+	}
+
+	if report.Base != nil {
+		required := RequiredBump(*report.Base, report.Changes)
+		var bump Type
+		newer := false
+		if report.Head != nil {
+			bump, newer = Bump(*report.Base, *report.Head)
+		}
+		switch {
+		case required == "":
+		case !newer && options.AllowUnreleased && report.Unreleased:
+			report.Problems = append(report.Problems, checkUnreleased(unreleased, report.Changes)...)
+		case !newer:
+			report.Problems = append(report.Problems, fmt.Sprintf("CHANGELOG.md records no release after %s; the interface changes need a %s bump",
+				report.Base, required))
+		case bumpRank[bump] < bumpRank[required]:
+			report.Problems = append(report.Problems, fmt.Sprintf("CHANGELOG.md bumps %s to %s, a %s bump; the interface changes need a %s bump",
+				report.Base, report.Head, bump, required))
+		}
+	}
+
+	if data, ok := headFiles["RELEASE.md"]; ok {
+		report.Problems = append(report.Problems, checkReleaseNotes(data, module, report.Head, after)...)
+	}
+	return report, nil
+}
+
+// checkUnreleased checks that the ## [Unreleased] entry of a CHANGELOG.md mentions every variable and output the
+// changes are to, each as a whole word.
+func checkUnreleased(entry Entry, changes []Change) []string {
+	if entry.Body == "" {
+		return []string{"## [Unreleased] in CHANGELOG.md is empty; it must describe the interface changes"}
+	}
+	var problems []string
+	checked := map[string]bool{}
+	for _, change := range changes {
+		if checked[change.Subject] {
+			continue
+		}
+		checked[change.Subject] = true
+		_, name, _ := strings.Cut(change.Subject, " ")
+		word := regexp.MustCompile(`(^|[^A-Za-z0-9_])` + regexp.QuoteMeta(name) + `($|[^A-Za-z0-9_])`)
+		if !word.MatchString(entry.Body) {
+			problems = append(problems, fmt.Sprintf("## [Unreleased] in CHANGELOG.md does not mention %s", change.Subject))
+		}
+	}
+	return problems
+}
+
+// checkReleaseNotes checks a RELEASE.md against the newest release and the interface of its module.
+func checkReleaseNotes(data []byte, module string, newest *Version, iface *Interface) []string {
+	var problems []string
+	listed := map[string]string{}
+	outputs := map[string]bool{}
+	hasInputs, hasOutputs, hasTitle, fenced := false, false, false, false
+	var section, subsection string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "```"):
+			fenced = !fenced
+		case fenced:
+		case strings.HasPrefix(line, "# ") && !hasTitle:
+			hasTitle = true
+			match := titleVersionPattern.FindStringSubmatch(line)
+			if match == nil {
+				problems = append(problems, fmt.Sprintf("RELEASE.md title %q names no version", line))
+			} else if newest != nil && match[1] != newest.String() {
+				problems = append(problems, fmt.Sprintf("RELEASE.md is for v%s, but the newest release in CHANGELOG.md is %s", match[1], newest))
+			}
+		case moduleVersionPattern.MatchString(line):
+			version := moduleVersionPattern.FindStringSubmatch(line)[1]
+			if newest != nil && version != newest.String() {
+				problems = append(problems, fmt.Sprintf("RELEASE.md gives module version %s, but the newest release in CHANGELOG.md is %s", version, newest))
+			}
+		case strings.HasPrefix(line, "## "):
+			section, subsection = "", ""
+			if strings.Contains(line, "Inputs") {
+				section, hasInputs = "inputs", true
+			} else if strings.Contains(line, "Outputs") {
+				section, hasOutputs = "outputs", true
+			}
+		case strings.HasPrefix(line, "### "):
+			subsection = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(line, "### ")))
+		case strings.HasPrefix(line, "|") && section != "":
+			name := strings.Trim(strings.TrimSpace(strings.Split(line, "|")[1]), "`")
+			if name == "Name" || strings.HasPrefix(name, "-") {
+				continue
+			}
+			if section == "outputs" {
+				outputs[name] = true
+			} else {
+				listed[name] = subsection
+			}
+		}
+		for _, match := range sourceRefPattern.FindAllStringSubmatch(line, -1) {
+			tag, err := ParseTag(match[1])
+			switch {
+			case err != nil:
+				problems = append(problems, fmt.Sprintf("RELEASE.md: %v", err))
+			case tag.Module != module:
+				problems = append(problems, fmt.Sprintf("RELEASE.md references %s, a tag of another module", tag.Name))
+			case newest != nil && tag.Version != *newest:
+				problems = append(problems, fmt.Sprintf("RELEASE.md references %s, but the newest release in CHANGELOG.md is %s", tag.Name, newest))
+			}
+		}
+	}
+
+	if hasInputs {
+		for _, name := range sortedKeys(iface.Variables) {
+			expected := "optional"
+			if iface.Variables[name].Required {
+				expected = "required"
+			}
+			switch subsection, ok := listed[name]; {
+			case !ok:
+				problems = append(problems, fmt.Sprintf("RELEASE.md does not list variable %s", name))
+			case subsection != expected:
+				problems = append(problems, fmt.Sprintf("RELEASE.md lists variable %s as %s, but it is %s", name, subsection, expected))
+			}
+		}
+		for _, name := range sortedKeys(listed) {
+			if _, ok := iface.Variables[name]; !ok {
+				problems = append(problems, fmt.Sprintf("RELEASE.md lists variable %s, which the module does not declare", name))
+			}
+		}
+	}
+	if hasOutputs {
+		for _, name := range sortedKeys(iface.Outputs) {
+			if !outputs[name] {
+				problems = append(problems, fmt.Sprintf("RELEASE.md does not list output %s", name))
+			}
+		}
+		for _, name := range sortedKeys(outputs) {
+			if !iface.Outputs[name] {
+				problems = append(problems, fmt.Sprintf("RELEASE.md lists output %s, which the module does not declare", name))
+			}
+		}
+	}
+	return problems
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,381 @@
+package release
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	sampleVariables = `variable "cluster_name" {
+  description = "Name of the EKS cluster"
+  type        = string
+}
+
+variable "subnet_ids" {
+  type = list(
+    string
+  )
+}
+
+variable "vpc_cni_version" {
+  type    = string
+  default = null
+}
+
+variable "tags" {
+  type    = map(string)
+  default = {}
+}
+`
+	sampleOutputs = `output "cluster_id" {
+  value = aws_eks_cluster.main.id
+}
+
+output "cluster_endpoint" {
+  value = aws_eks_cluster.main.endpoint
+}
+`
+	sampleChangelog = `# Changelog - EKS Cluster Module
+
+## [1.0.0] - 2025-10-21
+
+### Added
+- EKS cluster
+`
+)
+
+// writeModule writes the files of modules/eks-cluster under the repository root.
+func writeModule(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+
+	dir := filepath.Join(root, "modules", "eks-cluster")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+}
+
+// gitRepo returns a git repository whose v1 tag has modules/eks-cluster with the sample files.
+func gitRepo(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := t.TempDir()
+	writeModule(t, repo, map[string]string{"variables.tf": sampleVariables, "outputs.tf": sampleOutputs, "CHANGELOG.md": sampleChangelog})
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "eks-cluster 1.0.0"},
+		{"tag", "v1"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	return repo
+}
+
+func TestParseInterface(t *testing.T) {
+	t.Parallel()
+
+	iface, err := ParseInterface(map[string][]byte{
+		"variables.tf": []byte(sampleVariables),
+		"outputs.tf":   []byte(sampleOutputs),
+		"README.md":    []byte("# not HCL {"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &Interface{
+		Variables: map[string]Variable{
+			"cluster_name":    {Name: "cluster_name", Type: "string", Required: true},
+			"subnet_ids":      {Name: "subnet_ids", Type: "list( string )", Required: true},
+			"vpc_cni_version": {Name: "vpc_cni_version", Type: "string"},
+			"tags":            {Name: "tags", Type: "map(string)"},
+		},
+		Outputs: map[string]bool{"cluster_id": true, "cluster_endpoint": true},
+	}, iface)
+
+	_, err = ParseInterface(map[string][]byte{"main.tf": []byte(`variable "x" {`)})
+	assert.Error(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	old := &Interface{
+		Variables: map[string]Variable{
+			"cluster_name":    {Name: "cluster_name", Type: "string", Required: true},
+			"environment":     {Name: "environment", Type: "string", Required: true},
+			"subnet_ids":      {Name: "subnet_ids", Type: "list(string)", Required: true},
+			"vpc_cni_version": {Name: "vpc_cni_version", Type: "string"},
+			"tags":            {Name: "tags", Type: "map(string)"},
+		},
+		Outputs: map[string]bool{"cluster_id": true, "cluster_endpoint": true},
+	}
+	new := &Interface{
+		Variables: map[string]Variable{
+			"cluster_name":    {Name: "cluster_name", Type: "string", Required: true},
+			"environment":     {Name: "environment", Type: "string"},
+			"subnet_ids":      {Name: "subnet_ids", Type: "set(string)", Required: true},
+			"vpc_cni_version": {Name: "vpc_cni_version", Type: "string", Required: true},
+			"vpc_id":          {Name: "vpc_id", Type: "string", Required: true},
+			"log_types":       {Name: "log_types", Type: "list(string)"},
+		},
+		Outputs: map[string]bool{"cluster_id": true, "cluster_arn": true},
+	}
+	var rendered []string
+	for _, change := range Diff(old, new) {
+		rendered = append(rendered, change.String())
+	}
+	assert.Equal(t, []string{
+		"breaking: output cluster_endpoint removed",
+		"breaking: variable subnet_ids changed type from list(string) to set(string)",
+		"breaking: variable tags removed",
+		"breaking: variable vpc_cni_version lost its default",
+		"breaking: variable vpc_id added as required",
+		"additive: output cluster_arn added",
+		"additive: variable environment gained a default",
+		"additive: variable log_types added as optional",
+	}, rendered)
+
+	assert.Empty(t, Diff(old, old))
+}
+
+func TestBump(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		from, to Version
+		expected Type
+		newer    bool
+	}{
+		{Version{1, 0, 0}, Version{2, 0, 0}, Major, true},
+		{Version{1, 2, 3}, Version{1, 3, 0}, Minor, true},
+		{Version{1, 2, 3}, Version{1, 2, 4}, Patch, true},
+		{Version{0, 0, 1}, Version{1, 0, 0}, Major, true},
+		{Version{1, 2, 3}, Version{1, 2, 3}, "", false},
+		{Version{1, 2, 3}, Version{1, 1, 9}, "", false},
+	} {
+		bump, newer := Bump(tc.from, tc.to)
+		assert.Equal(t, tc.expected, bump, "%s to %s", tc.from, tc.to)
+		assert.Equal(t, tc.newer, newer, "%s to %s", tc.from, tc.to)
+	}
+
+	breaking := []Change{{Additive, "output a", "added"}, {Breaking, "variable b", "removed"}}
+	additive := []Change{{Additive, "output a", "added"}}
+	assert.Equal(t, Major, RequiredBump(Version{1, 0, 0}, breaking))
+	assert.Equal(t, Minor, RequiredBump(Version{1, 0, 0}, additive))
+	assert.Equal(t, Minor, RequiredBump(Version{0, 1, 0}, breaking))
+	assert.Equal(t, Patch, RequiredBump(Version{0, 1, 0}, additive))
+	assert.Equal(t, Type(""), RequiredBump(Version{1, 0, 0}, nil))
+}
+
+func TestCheckModule(t *testing.T) {
+	t.Parallel()
+
+	repo := gitRepo(t)
+	base := GitRef{Repo: repo, Ref: "v1"}
+	removedOutput := strings.Replace(sampleOutputs, "output \"cluster_endpoint\"", "locals", 1)
+	removedOutput = strings.Replace(removedOutput, "value = aws_eks_cluster.main.endpoint", "endpoint = aws_eks_cluster.main.endpoint", 1)
+	optionalVariable := sampleVariables + "\nvariable \"log_types\" {\n  type    = list(string)\n  default = []\n}\n"
+	requiredVariable := sampleVariables + "\nvariable \"environment\" {\n  type = string\n}\n"
+	release := func(version string) string {
+		return strings.Replace(sampleChangelog, "## [1.0.0]", "## ["+version+"] - 2025-11-01\n\n### Changed\n- Interface\n\n## [1.0.0]", 1)
+	}
+	unreleasedWith := func(body string) string {
+		return strings.Replace(sampleChangelog, "## [1.0.0]", "## [Unreleased]\n\n"+body+"## [1.0.0]", 1)
+	}
+	unreleased := unreleasedWith("### Added\n- `log_types` variable\n\n")
+
+	for _, tc := range []struct {
+		name      string
+		variables string
+		outputs   string
+		changelog string
+		options   Options
+		changes   []string
+		problems  []string
+	}{
+		{
+			name:      "no changes",
+			variables: sampleVariables, outputs: sampleOutputs, changelog: sampleChangelog,
+		},
+		{
+			name:      "removed output with a major bump",
+			variables: sampleVariables, outputs: removedOutput, changelog: release("2.0.0"),
+			changes: []string{"breaking: output cluster_endpoint removed"},
+		},
+		{
+			name:      "removed output with a minor bump",
+			variables: sampleVariables, outputs: removedOutput, changelog: release("1.1.0"),
+			changes:  []string{"breaking: output cluster_endpoint removed"},
+			problems: []string{"CHANGELOG.md bumps 1.0.0 to 1.1.0, a minor bump; the interface changes need a major bump"},
+		},
+		{
+			name:      "new required variable with a patch bump",
+			variables: requiredVariable, outputs: sampleOutputs, changelog: release("1.0.1"),
+			changes:  []string{"breaking: variable environment added as required"},
+			problems: []string{"CHANGELOG.md bumps 1.0.0 to 1.0.1, a patch bump; the interface changes need a major bump"},
+		},
+		{
+			name:      "new optional variable with a minor bump",
+			variables: optionalVariable, outputs: sampleOutputs, changelog: release("1.1.0"),
+			changes: []string{"additive: variable log_types added as optional"},
+		},
+		{
+			name:      "new optional variable with a patch bump",
+			variables: optionalVariable, outputs: sampleOutputs, changelog: release("1.0.1"),
+			changes:  []string{"additive: variable log_types added as optional"},
+			problems: []string{"CHANGELOG.md bumps 1.0.0 to 1.0.1, a patch bump; the interface changes need a minor bump"},
+		},
+		{
+			name:      "new optional variable without a release",
+			variables: optionalVariable, outputs: sampleOutputs, changelog: sampleChangelog,
+			changes:  []string{"additive: variable log_types added as optional"},
+			problems: []string{"CHANGELOG.md records no release after 1.0.0; the interface changes need a minor bump"},
+		},
+		{
+			name:      "new optional variable under Unreleased",
+			variables: optionalVariable, outputs: sampleOutputs, changelog: unreleased,
+			changes:  []string{"additive: variable log_types added as optional"},
+			problems: []string{"CHANGELOG.md records no release after 1.0.0; the interface changes need a minor bump"},
+		},
+		{
+			name:      "new optional variable under Unreleased, allowed",
+			variables: optionalVariable, outputs: sampleOutputs, changelog: unreleased,
+			options: Options{AllowUnreleased: true},
+			changes: []string{"additive: variable log_types added as optional"},
+		},
+		{
+			name:      "new optional variable under an empty Unreleased, allowed",
+			variables: optionalVariable, outputs: sampleOutputs, changelog: unreleasedWith("\n"),
+			options:  Options{AllowUnreleased: true},
+			changes:  []string{"additive: variable log_types added as optional"},
+			problems: []string{"## [Unreleased] in CHANGELOG.md is empty; it must describe the interface changes"},
+		},
+		{
+			name:      "removed output and new variable under an Unreleased that names neither, allowed",
+			variables: optionalVariable, outputs: removedOutput, changelog: unreleasedWith("### Added\n- log_types_all\n- cluster_endpoints\n\n"),
+			options: Options{AllowUnreleased: true},
+			changes: []string{"breaking: output cluster_endpoint removed", "additive: variable log_types added as optional"},
+			problems: []string{
+				"## [Unreleased] in CHANGELOG.md does not mention output cluster_endpoint",
+				"## [Unreleased] in CHANGELOG.md does not mention variable log_types",
+			},
+		},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			head := t.TempDir()
+			writeModule(t, head, map[string]string{"variables.tf": tc.variables, "outputs.tf": tc.outputs, "CHANGELOG.md": tc.changelog})
+			report, err := CheckModule(base, Worktree(head), "eks-cluster", tc.options)
+			require.NoError(t, err)
+
+			var changes []string
+			for _, change := range report.Changes {
+				changes = append(changes, change.String())
+			}
+			assert.Equal(t, tc.changes, changes)
+			assert.Equal(t, tc.problems, report.Problems)
+			assert.Equal(t, len(tc.problems) == 0, report.OK())
+		})
+	}
+}
+
+func TestCheckModuleBetweenRefs(t *testing.T) {
+	t.Parallel()
+
+	repo := gitRepo(t)
+	writeModule(t, repo, map[string]string{"variables.tf": strings.Replace(sampleVariables, "  default = {}\n", "", 1)})
+	require.NoError(t, os.Remove(filepath.Join(repo, "modules", "eks-cluster", "outputs.tf")))
+	for _, args := range [][]string{
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "drop outputs"},
+	} {
+		out, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+
+	report, err := CheckModule(GitRef{Repo: repo, Ref: "v1"}, GitRef{Repo: repo, Ref: "HEAD"}, "eks-cluster", Options{})
+	require.NoError(t, err)
+	var output strings.Builder
+	require.NoError(t, report.Write(&output))
+	assert.Equal(t, `modules/eks-cluster: 1.0.0 -> 1.0.0, 3 interface changes
+  breaking: output cluster_endpoint removed
+  breaking: output cluster_id removed
+  breaking: variable tags lost its default
+  problem: CHANGELOG.md records no release after 1.0.0; the interface changes need a major bump
+`, output.String())
+
+	_, err = CheckModule(GitRef{Repo: repo, Ref: "v1"}, GitRef{Repo: repo, Ref: "HEAD"}, "vpc", Options{})
+	assert.EqualError(t, err, "modules/vpc does not exist in HEAD")
+	_, err = CheckModule(GitRef{Repo: repo, Ref: "v2"}, GitRef{Repo: repo, Ref: "HEAD"}, "eks-cluster", Options{})
+	assert.ErrorContains(t, err, "git ls-tree -z v2 -- modules/eks-cluster/: exit status 128")
+}
+
+func TestCheckReleaseNotes(t *testing.T) {
+	t.Parallel()
+
+	iface, err := ParseInterface(map[string][]byte{"variables.tf": []byte(sampleVariables), "outputs.tf": []byte(sampleOutputs)})
+	require.NoError(t, err)
+	notes := "# EKS Cluster Module v1.0.0\n\n" +
+		"```hcl\n" +
+		"# Update version\n" +
+		"module \"eks\" {\n  source = \"github.com/your-org/multi-az-eks-cluster//modules/eks-cluster?ref=eks-cluster-v1.0.0\"\n}\n" +
+		"```\n\n" +
+		"## 📝 Inputs\n\n### Required\n\n| Name | Description | Type |\n|------|-------------|------|\n" +
+		"| cluster_name | Name | `string` |\n| subnet_ids | Subnets | `list(string)` |\n\n" +
+		"### Optional\n\n| Name | Description | Type | Default |\n|------|-------------|------|---------|\n" +
+		"| vpc_cni_version | VPC CNI | `string` | `null` |\n| `tags` | Tags | `map(string)` | `{}` |\n\n" +
+		"## 📤 Outputs\n\n| Name | Description |\n|------|-------------|\n| cluster_id | ID |\n| cluster_endpoint | Endpoint |\n\n" +
+		"## 📊 Requirements\n\n| Component | Version |\n|-----------|---------|\n| Terraform | >= 1.0 |\n\n" +
+		"---\n\n**Module Version**: 1.0.0\n**Terraform Registry**: `github.com/your-org/multi-az-eks-cluster//modules/eks-cluster?ref=eks-cluster-v1.0.0`\n"
+	assert.Empty(t, checkReleaseNotes([]byte(notes), "eks-cluster", &Version{1, 0, 0}, iface))
+
+	stale := strings.NewReplacer(
+		"| subnet_ids | Subnets | `list(string)` |\n", "",
+		"| cluster_endpoint | Endpoint |\n", "| cluster_arn | ARN |\n",
+		"| vpc_cni_version | VPC CNI | `string` | `null` |\n", "",
+		"| cluster_name | Name | `string` |\n", "| cluster_name | Name | `string` |\n| vpc_cni_version | VPC CNI | `string` |\n",
+		"| `tags` | Tags | `map(string)` | `{}` |\n", "| `tags` | Tags | `map(string)` | `{}` |\n| environment | Environment | `string` | `\"dev\"` |\n",
+		"?ref=eks-cluster-v1.0.0\"", "?ref=eks-node-groups-v1.0.0\"",
+	).Replace(notes)
+	assert.Equal(t, []string{
+		"RELEASE.md is for v1.0.0, but the newest release in CHANGELOG.md is 1.1.0",
+		"RELEASE.md references eks-node-groups-v1.0.0, a tag of another module",
+		"RELEASE.md gives module version 1.0.0, but the newest release in CHANGELOG.md is 1.1.0",
+		"RELEASE.md references eks-cluster-v1.0.0, but the newest release in CHANGELOG.md is 1.1.0",
+		"RELEASE.md does not list variable subnet_ids",
+		"RELEASE.md lists variable vpc_cni_version as required, but it is optional",
+		"RELEASE.md lists variable environment, which the module does not declare",
+		"RELEASE.md does not list output cluster_endpoint",
+		"RELEASE.md lists output cluster_arn, which the module does not declare",
+	}, checkReleaseNotes([]byte(stale), "eks-cluster", &Version{1, 1, 0}, iface))
+
+	assert.Equal(t, []string{`RELEASE.md title "# EKS Cluster Module" names no version`},
+		checkReleaseNotes([]byte("# EKS Cluster Module\n"), "eks-cluster", &Version{1, 0, 0}, iface))
+}
+
+// TestModulesConsistent checks the CHANGELOG.md and RELEASE.md of every module against its variables and outputs.
+func TestModulesConsistent(t *testing.T) {
+	t.Parallel()
+
+	changelogs, err := filepath.Glob(filepath.Join(repositoryRoot, "modules", "*", "CHANGELOG.md"))
+	require.NoError(t, err)
+	require.NotEmpty(t, changelogs)
+	for _, changelog := range changelogs {
+		module := filepath.Base(filepath.Dir(changelog))
+		report, err := CheckModule(Worktree(repositoryRoot), Worktree(repositoryRoot), module, Options{})
+		require.NoError(t, err)
+		assert.Empty(t, report.Changes, module)
+		assert.Empty(t, report.Problems, module)
+	}
+}
@@ -0,0 +1,236 @@
+package release
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Tree reads the files of the repository at one revision, either the working tree or a git ref.
+type Tree interface {
+	// ReadDir returns the regular files directly in the directory, a slash-separated path relative to the repository
+	// root, keyed by name. A directory that does not exist has no files.
+	ReadDir(dir string) (map[string][]byte, error)
+	String() string
+}
+
+// Worktree is the working tree of the repository rooted at the directory.
+type Worktree string
+
+// ReadDir reads the directory from disk.
+func (w Worktree) ReadDir(dir string) (map[string][]byte, error) {
+	entries, err := os.ReadDir(filepath.Join(string(w), filepath.FromSlash(dir)))
+	if os.IsNotExist(err) {
+		return map[string][]byte{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(string(w), filepath.FromSlash(dir), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		files[entry.Name()] = data
+	}
+	return files, nil
+}
+
+// String names the tree in reports.
+func (w Worktree) String() string {
+	return "working tree"
+}
+
+// GitRef is the tree of a commit, branch or tag of the git repository at Repo, read with the git command.
+type GitRef struct {
+	Repo string
+	Ref  string
+}
+
+// ReadDir lists the directory with git ls-tree and reads each file with git cat-file.
+func (g GitRef) ReadDir(dir string) (map[string][]byte, error) {
+	listing, err := g.git("ls-tree", "-z", g.Ref, "--", dir+"/")
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	for _, line := range strings.Split(string(listing), "\x00") {
+		// <mode> SP <type> SP <object> TAB <path>
+		info, name, ok := strings.Cut(line, "\t")
+		if !ok || !strings.HasPrefix(info, "100") {
+			continue
+		}
+		data, err := g.git("cat-file", "blob", strings.Fields(info)[2])
+		if err != nil {
+			return nil, err
+		}
+		files[path.Base(name)] = data
+	}
+	return files, nil
+}
+
+// String names the tree in reports by its ref.
+func (g GitRef) String() string {
+	return g.Ref
+}
+
+func (g GitRef) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", g.Repo}, args...)...)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Variable is an input variable of a module.
+type Variable struct {
+	Name string
+	// Type is the source text of the type constraint, or empty when the variable has none.
+	Type string
+	// Required is true when the variable has no default; a null default makes it optional.
+	Required bool
+}
+
+// Interface is what a module exports: its input variables and its outputs, keyed by name.
+type Interface struct {
+	Variables map[string]Variable
+	Outputs   map[string]bool
+}
+
+// ParseInterface returns the variables and outputs declared in the .tf files of a module, keyed by file name.
+func ParseInterface(files map[string][]byte) (*Interface, error) {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if strings.HasSuffix(name, ".tf") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	module := &Interface{Variables: map[string]Variable{}, Outputs: map[string]bool{}}
+	parser := hclparse.NewParser()
+	for _, name := range names {
+		src := files[name]
+		file, diags := parser.ParseHCL(src, name)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			return nil, fmt.Errorf("%s: unexpected body type %T", name, file.Body)
+		}
+		for _, block := range body.Blocks {
+			if len(block.Labels) != 1 {
+				continue
+			}
+			switch block.Type {
+			case "variable":
+				variable := Variable{Name: block.Labels[0]}
+				if typ, ok := block.Body.Attributes["type"]; ok {
+					variable.Type = strings.Join(strings.Fields(string(typ.Expr.Range().SliceBytes(src))), " ")
+				}
+				_, hasDefault := block.Body.Attributes["default"]
+				variable.Required = !hasDefault
+				module.Variables[variable.Name] = variable
+			case "output":
+				module.Outputs[block.Labels[0]] = true
+			}
+		}
+	}
+	return module, nil
+}
+
+// ChangeKind tells whether a change to a module's interface breaks its callers.
+type ChangeKind string
+
+const (
+	Breaking ChangeKind = "breaking"
+	Additive ChangeKind = "additive"
+)
+
+// Change is a change to a variable or output of a module.
+type Change struct {
+	Kind ChangeKind
+	// Subject is "variable <name>" or "output <name>".
+	Subject string
+	Message string
+}
+
+// String renders the change as "<kind>: <subject> <message>".
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s %s", c.Kind, c.Subject, c.Message)
+}
+
+// Diff classifies the changes from the old to the new interface, breaking ones first, each sorted by subject. Removing
+// a variable or an output, adding a required variable, removing a variable's default and changing its type break
+// callers; adding an optional variable or an output, or giving a required variable a default, does not.
+func Diff(old, new *Interface) []Change {
+	var changes []Change
+	for name, before := range old.Variables {
+		subject := "variable " + name
+		after, ok := new.Variables[name]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Breaking, subject, "removed"})
+			continue
+		case !before.Required && after.Required:
+			changes = append(changes, Change{Breaking, subject, "lost its default"})
+		case before.Required && !after.Required:
+			changes = append(changes, Change{Additive, subject, "gained a default"})
+		}
+		if before.Type != after.Type {
+			changes = append(changes, Change{Breaking, subject, fmt.Sprintf("changed type from %s to %s", typeOf(before), typeOf(after))})
+		}
+	}
+	for name, after := range new.Variables {
+		if _, ok := old.Variables[name]; ok {
+			continue
+		}
+		if after.Required {
+			changes = append(changes, Change{Breaking, "variable " + name, "added as required"})
+		} else {
+			changes = append(changes, Change{Additive, "variable " + name, "added as optional"})
+		}
+	}
+	for name := range old.Outputs {
+		if !new.Outputs[name] {
+			changes = append(changes, Change{Breaking, "output " + name, "removed"})
+		}
+	}
+	for name := range new.Outputs {
+		if !old.Outputs[name] {
+			changes = append(changes, Change{Additive, "output " + name, "added"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return changes[i].Kind == Breaking
+		}
+		if changes[i].Subject != changes[j].Subject {
+			return changes[i].Subject < changes[j].Subject
+		}
+		return changes[i].Message < changes[j].Message
+	})
+	return changes
+}
+
+func typeOf(v Variable) string {
+	if v.Type == "" {
+		return "any"
+	}
+	return v.Type
+}
@@ -0,0 +1,251 @@
+// Package release parses the release tags of the modules, <module>-v<major>.<minor>.<patch> such as eks-cluster-v1.0.0
+// or <module>/v<major>.<minor>.<patch> such as regional-eks/v0.1.0, and checks a tag against the repository: the module
+// is a directory of modules/ and its CHANGELOG.md has an entry for the version. .github/workflows/release-monitor.yml
+// runs it on every published release. It also keeps each module's CHANGELOG.md and RELEASE.md in step with the
+// variables and outputs the module exports, see CheckModule.
+package release
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	tagPattern     = regexp.MustCompile(`^(.+)([-/])v([^-/]+)$`)
+	modulePattern  = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	versionPattern = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)$`)
+	headingPattern = regexp.MustCompile(`^## \[([^\]]+)\](?:\s+-\s+(.+?))?\s*$`)
+	linkRefPattern = regexp.MustCompile(`^\[[^\]]+\]:\s`)
+)
+
+// Version is the semantic version of a module release.
+type Version struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseVersion parses a version of the form 1.2.3, without a leading v, pre-release or build metadata.
+func ParseVersion(s string) (Version, error) {
+	match := versionPattern.FindStringSubmatch(s)
+	if match == nil {
+		return Version{}, fmt.Errorf("version %q is not of the form <major>.<minor>.<patch>, e.g. 1.0.0", s)
+	}
+	var version Version
+	for i, part := range []*int{&version.Major, &version.Minor, &version.Patch} {
+		n, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return Version{}, fmt.Errorf("version %q: %w", s, err)
+		}
+		*part = n
+	}
+	return version, nil
+}
+
+// String renders the version as 1.2.3.
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Less reports whether the version is older than the other.
+func (v Version) Less(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	if v.Minor != other.Minor {
+		return v.Minor < other.Minor
+	}
+	return v.Patch < other.Patch
+}
+
+// Type is the kind of release a version is.
+type Type string
+
+const (
+	Major      Type = "major"
+	Minor      Type = "minor"
+	Patch      Type = "patch"
+	PreRelease Type = "pre-release"
+)
+
+// Type classifies the version as the release monitor always has: any 0.x.y is a pre-release, x.0.0 a major release,
+// x.y.0 a minor release and anything else a patch release.
+func (v Version) Type() Type {
+	switch {
+	case v.Major == 0:
+		return PreRelease
+	case v.Minor == 0 && v.Patch == 0:
+		return Major
+	case v.Patch == 0:
+		return Minor
+	default:
+		return Patch
+	}
+}
+
+// Tag is a parsed module release tag.
+type Tag struct {
+	Name   string
+	Module string
+	// Separator is the "-" or "/" between the module and the version.
+	Separator string
+	Version   Version
+}
+
+// ParseTag parses and validates a release tag. The module must be a lowercase, hyphenated directory name, so a tag
+// cannot name a path outside modules/.
+func ParseTag(name string) (Tag, error) {
+	match := tagPattern.FindStringSubmatch(name)
+	if match == nil {
+		return Tag{}, fmt.Errorf("tag %q is not of the form <module>-v<version> or <module>/v<version>, e.g. eks-cluster-v1.0.0 or regional-eks/v0.1.0", name)
+	}
+	module, separator := match[1], match[2]
+	if !modulePattern.MatchString(module) {
+		return Tag{}, fmt.Errorf("tag %q: module %q is not a lowercase, hyphenated module name such as eks-cluster", name, module)
+	}
+	version, err := ParseVersion(match[3])
+	if err != nil {
+		return Tag{}, fmt.Errorf("tag %q: %w", name, err)
+	}
+	return Tag{Name: name, Module: module, Separator: separator, Version: version}, nil
+}
+
+// Path returns the directory of the tag's module, relative to the repository root.
+func (t Tag) Path() string {
+	return "modules/" + t.Module
+}
+
+// Entry is a version heading of a CHANGELOG.md, "## [1.0.0] - 2025-10-21" or "## [Unreleased]".
+type Entry struct {
+	// Version is empty for the Unreleased entry.
+	Version *Version
+	// Date is the text after the version, usually a date, e.g. 2025-10-21 or Initial Development.
+	Date string
+	// Line is the 1-based line of the heading.
+	Line int
+	// Body is the text under the heading up to the next one, trimmed, without link reference definitions such as
+	// "[1.0.0]: https://...".
+	Body string
+}
+
+// Unreleased reports whether the entry is the Unreleased one.
+func (e Entry) Unreleased() bool {
+	return e.Version == nil
+}
+
+// ParseChangelog returns the version entries of a CHANGELOG.md in Keep a Changelog format, in file order, newest first
+// by convention. A heading whose version is neither Unreleased nor a semantic version is an error.
+func ParseChangelog(data []byte) ([]Entry, error) {
+	var entries []Entry
+	var errs []error
+	var body []string
+	current := -1
+	closeEntry := func() {
+		if current >= 0 {
+			entries[current].Body = strings.TrimSpace(strings.Join(body, "\n"))
+		}
+		current, body = -1, nil
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		match := headingPattern.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			if current >= 0 && !linkRefPattern.MatchString(scanner.Text()) {
+				body = append(body, scanner.Text())
+			}
+			continue
+		}
+		closeEntry()
+		entry := Entry{Date: match[2], Line: line}
+		if match[1] != "Unreleased" {
+			version, err := ParseVersion(match[1])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("line %d: %w", line, err))
+				continue
+			}
+			entry.Version = &version
+		}
+		entries = append(entries, entry)
+		current = len(entries) - 1
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	closeEntry()
+	return entries, errors.Join(errs...)
+}
+
+// Find returns the entry of the version.
+func Find(entries []Entry, version Version) (Entry, bool) {
+	for _, entry := range entries {
+		if entry.Version != nil && *entry.Version == version {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Metadata describes a module release, as emitted by cmd/releasetag.
+type Metadata struct {
+	Tag     string `json:"tag"`
+	Module  string `json:"module"`
+	Version string `json:"version"`
+	Major   int    `json:"major"`
+	Minor   int    `json:"minor"`
+	Patch   int    `json:"patch"`
+	Type    Type   `json:"release_type"`
+	Path    string `json:"path"`
+
+	Changelog string `json:"changelog"`
+	// Date is the text after the version in the changelog entry.
+	Date string `json:"date,omitempty"`
+	// ReleaseNotes is the module's RELEASE.md, if it has one.
+	ReleaseNotes string `json:"release_notes,omitempty"`
+}
+
+// Check checks the tag against the repository at root: its module must be a directory with a CHANGELOG.md that has
+// an entry for the tag's version.
+func Check(root string, tag Tag) (*Metadata, error) {
+	metadata := &Metadata{
+		Tag:       tag.Name,
+		Module:    tag.Module,
+		Version:   tag.Version.String(),
+		Major:     tag.Version.Major,
+		Minor:     tag.Version.Minor,
+		Patch:     tag.Version.Patch,
+		Type:      tag.Version.Type(),
+		Path:      tag.Path(),
+		Changelog: tag.Path() + "/CHANGELOG.md",
+	}
+
+	info, err := os.Stat(filepath.Join(root, filepath.FromSlash(metadata.Path)))
+	if err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("tag %s: module %s does not exist", tag.Name, metadata.Path)
+	}
+	data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(metadata.Changelog)))
+	if err != nil {
+		return nil, fmt.Errorf("tag %s: %w", tag.Name, err)
+	}
+	entries, err := ParseChangelog(data)
+	if err != nil {
+		return nil, fmt.Errorf("tag %s: %s: %w", tag.Name, metadata.Changelog, err)
+	}
+	entry, ok := Find(entries, tag.Version)
+	if !ok {
+		return nil, fmt.Errorf("tag %s: %s has no ## [%s] entry", tag.Name, metadata.Changelog, metadata.Version)
+	}
+	metadata.Date = entry.Date
+
+	notes := tag.Path() + "/RELEASE.md"
+	if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(notes))); err == nil {
+		metadata.ReleaseNotes = notes
+	}
+	return metadata, nil
+}
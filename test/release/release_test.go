@@ -0,0 +1,217 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const repositoryRoot = "../.."
+
+func TestParseTag(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		tag      string
+		expected Tag
+	}{
+		{"eks-cluster-v1.0.0", Tag{Name: "eks-cluster-v1.0.0", Module: "eks-cluster", Separator: "-", Version: Version{1, 0, 0}}},
+		{"eks-node-groups-v1.0.1", Tag{Name: "eks-node-groups-v1.0.1", Module: "eks-node-groups", Separator: "-", Version: Version{1, 0, 1}}},
+		{"regional-eks/v0.1.0", Tag{Name: "regional-eks/v0.1.0", Module: "regional-eks", Separator: "/", Version: Version{0, 1, 0}}},
+		{"vpc/v0.0.1", Tag{Name: "vpc/v0.0.1", Module: "vpc", Separator: "/", Version: Version{0, 0, 1}}},
+		{"rds-v10.20.30", Tag{Name: "rds-v10.20.30", Module: "rds", Separator: "-", Version: Version{10, 20, 30}}},
+	} {
+		tc := tc
+		t.Run(tc.tag, func(t *testing.T) {
+			t.Parallel()
+
+			tag, err := ParseTag(tc.tag)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, tag)
+			assert.Equal(t, "modules/"+tc.expected.Module, tag.Path())
+		})
+	}
+}
+
+func TestParseTagMalformed(t *testing.T) {
+	t.Parallel()
+
+	const form = " is not of the form <module>-v<version> or <module>/v<version>, e.g. eks-cluster-v1.0.0 or regional-eks/v0.1.0"
+	for _, tc := range []struct {
+		name     string
+		tag      string
+		expected string
+	}{
+		{"empty", "", `tag ""` + form},
+		{"version only", "v1.0.0", `tag "v1.0.0"` + form},
+		{"no v", "eks-cluster-1.0.0", `tag "eks-cluster-1.0.0"` + form},
+		{"underscore separator", "eks-cluster_v1.0.0", `tag "eks-cluster_v1.0.0"` + form},
+		{"pre-release", "eks-cluster-v1.0.0-rc.1", `tag "eks-cluster-v1.0.0-rc.1"` + form},
+		{"two parts", "eks-cluster-v1.0", `tag "eks-cluster-v1.0": version "1.0" is not of the form <major>.<minor>.<patch>, e.g. 1.0.0`},
+		{"four parts", "vpc/v1.0.0.1", `tag "vpc/v1.0.0.1": version "1.0.0.1" is not of the form <major>.<minor>.<patch>, e.g. 1.0.0`},
+		{"leading zero", "rds-v01.0.0", `tag "rds-v01.0.0": version "01.0.0" is not of the form <major>.<minor>.<patch>, e.g. 1.0.0`},
+		{"build metadata", "rds-v1.0.0+build.5", `tag "rds-v1.0.0+build.5": version "1.0.0+build.5" is not of the form <major>.<minor>.<patch>, e.g. 1.0.0`},
+		{"uppercase module", "EKS-Cluster-v1.0.0", `tag "EKS-Cluster-v1.0.0": module "EKS-Cluster" is not a lowercase, hyphenated module name such as eks-cluster`},
+		{"module path", "modules/vpc/v1.0.0", `tag "modules/vpc/v1.0.0": module "modules/vpc" is not a lowercase, hyphenated module name such as eks-cluster`},
+		{"parent directory", "../vpc/v1.0.0", `tag "../vpc/v1.0.0": module "../vpc" is not a lowercase, hyphenated module name such as eks-cluster`},
+		{"trailing hyphen", "eks--v1.0.0", `tag "eks--v1.0.0": module "eks-" is not a lowercase, hyphenated module name such as eks-cluster`},
+	} {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseTag(tc.tag)
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestVersionType(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		version  Version
+		expected Type
+	}{
+		{Version{0, 0, 1}, PreRelease},
+		{Version{0, 1, 0}, PreRelease},
+		{Version{1, 0, 0}, Major},
+		{Version{2, 1, 0}, Minor},
+		{Version{1, 0, 1}, Patch},
+	} {
+		assert.Equal(t, tc.expected, tc.version.Type(), tc.version.String())
+	}
+	assert.True(t, Version{1, 0, 9}.Less(Version{1, 1, 0}))
+	assert.False(t, Version{2, 0, 0}.Less(Version{1, 9, 9}))
+}
+
+func TestParseChangelog(t *testing.T) {
+	t.Parallel()
+
+	entries, err := ParseChangelog([]byte(`# Changelog
+
+## [Unreleased]
+
+### Added
+- Something
+
+## [1.0.1] - 2025-10-29
+## [1.0.0] - 2025-10-21
+
+## [0.0.0] - Initial Development
+
+[1.0.0]: https://github.com/asarkar157/Multi-AZ-EKS-Cluster/releases/tag/eks-cluster-v1.0.0
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{
+		{Line: 3, Body: "### Added\n- Something"},
+		{Version: &Version{1, 0, 1}, Date: "2025-10-29", Line: 8},
+		{Version: &Version{1, 0, 0}, Date: "2025-10-21", Line: 9},
+		{Version: &Version{0, 0, 0}, Date: "Initial Development", Line: 11},
+	}, entries)
+	assert.True(t, entries[0].Unreleased())
+
+	entry, ok := Find(entries, Version{1, 0, 0})
+	assert.True(t, ok)
+	assert.Equal(t, 9, entry.Line)
+	_, ok = Find(entries, Version{1, 1, 0})
+	assert.False(t, ok)
+
+	_, err = ParseChangelog([]byte("## [1.0] - 2025-10-29\n## [v1.0.0]\n"))
+	assert.EqualError(t, err, "line 1: version \"1.0\" is not of the form <major>.<minor>.<patch>, e.g. 1.0.0\n"+
+		"line 2: version \"v1.0.0\" is not of the form <major>.<minor>.<patch>, e.g. 1.0.0")
+}
+
+// TestChangelogs parses the CHANGELOG.md of every module and checks the tag of its newest release.
+func TestChangelogs(t *testing.T) {
+	t.Parallel()
+
+	modules, err := filepath.Glob(filepath.Join(repositoryRoot, "modules", "*", "CHANGELOG.md"))
+	require.NoError(t, err)
+	require.Len(t, modules, 6)
+	for _, changelog := range modules {
+		data, err := os.ReadFile(changelog)
+		require.NoError(t, err)
+		entries, err := ParseChangelog(data)
+		require.NoError(t, err, changelog)
+
+		var newest *Version
+		for _, entry := range entries {
+			if !entry.Unreleased() {
+				newest = entry.Version
+				break
+			}
+		}
+		require.NotNil(t, newest, changelog)
+		tag, err := ParseTag(filepath.Base(filepath.Dir(changelog)) + "-v" + newest.String())
+		require.NoError(t, err)
+		_, err = Check(repositoryRoot, tag)
+		assert.NoError(t, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	tag, err := ParseTag("eks-cluster-v1.0.0")
+	require.NoError(t, err)
+	metadata, err := Check(repositoryRoot, tag)
+	require.NoError(t, err)
+	assert.Equal(t, &Metadata{
+		Tag:          "eks-cluster-v1.0.0",
+		Module:       "eks-cluster",
+		Version:      "1.0.0",
+		Major:        1,
+		Type:         Major,
+		Path:         "modules/eks-cluster",
+		Changelog:    "modules/eks-cluster/CHANGELOG.md",
+		Date:         "2025-10-21",
+		ReleaseNotes: "modules/eks-cluster/RELEASE.md",
+	}, metadata)
+
+	tag, err = ParseTag("regional-eks/v0.1.0")
+	require.NoError(t, err)
+	metadata, err = Check(repositoryRoot, tag)
+	require.NoError(t, err)
+	assert.Equal(t, PreRelease, metadata.Type)
+	assert.Empty(t, metadata.ReleaseNotes)
+}
+
+func TestCheckFails(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	for path, content := range map[string]string{
+		"modules/vpc/CHANGELOG.md":       "## [Unreleased]\n\n## [0.0.1] - 2025-10-30\n",
+		"modules/rds/main.tf":            "",
+		"modules/iam-roles/CHANGELOG.md": "## [0.0.x] - 2025-10-29\n",
+		"modules/notes":                  "",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(root, filepath.Dir(path)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(root, path), []byte(content), 0o644))
+	}
+
+	for _, tc := range []struct {
+		tag      string
+		expected string
+	}{
+		{"vpc/v0.0.2", "tag vpc/v0.0.2: modules/vpc/CHANGELOG.md has no ## [0.0.2] entry"},
+		{"eks-addons-v1.0.0", "tag eks-addons-v1.0.0: module modules/eks-addons does not exist"},
+		{"notes-v1.0.0", "tag notes-v1.0.0: module modules/notes does not exist"},
+		{"rds-v0.0.1", "tag rds-v0.0.1: open " + filepath.Join(root, "modules", "rds", "CHANGELOG.md") + ": no such file or directory"},
+		{"iam-roles-v0.0.1", "tag iam-roles-v0.0.1: modules/iam-roles/CHANGELOG.md: line 1: version \"0.0.x\" is not of the form <major>.<minor>.<patch>, e.g. 1.0.0"},
+	} {
+		tc := tc
+		t.Run(tc.tag, func(t *testing.T) {
+			t.Parallel()
+
+			tag, err := ParseTag(tc.tag)
+			require.NoError(t, err)
+			_, err = Check(root, tag)
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
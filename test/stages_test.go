@@ -0,0 +1,191 @@
+package test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	test_structure "github.com/gruntwork-io/terratest/modules/test-structure"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/your-org/multi-az-eks-cluster/test/harness"
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// The module and integration tests run in test_structure stages, each skipped when SKIP_<stage> is set: setup saves
+// the terraform options, init_plan plans them, checks the plan and saves it, apply deploys the saved plan, validate
+// checks what apply deployed against the saved plan and destroy tears the deployment down. The stages hand over through
+// .test-data/<TestName>, so validation can be rerun against an already deployed stack without deploying it again.
+// Whether apply, validate and destroy do anything is up to the run mode TERRATEST_RUN_MODE selects, plan-only by
+// default.
+const (
+	stageSetup    = "setup"
+	stageInitPlan = "init_plan"
+	stageApply    = "apply"
+	stageValidate = "validate"
+	stageDestroy  = "destroy"
+)
+
+// testDataDir holds the state the stages of each test hand over, one folder per test, together with the test's working
+// copy of the modules, in which terraform keeps the state of what apply deployed.
+const testDataDir = ".test-data"
+
+// runStages runs a test as stages. setup returns the options to plan with, and checkPlan checks the plan.
+func runStages(t *testing.T, fixture *harness.Fixture, setup func() *terraform.Options, checkPlan func(plan *planassert.Plan)) {
+	t.Helper()
+
+	lifecycle := stageLifecycle(t)
+
+	defer test_structure.RunTestStage(t, stageDestroy, func() {
+		// Only a mode that destroys needs the saved options, and without them the setup stage never ran, so there is
+		// nothing to destroy
+		if !t.Skipped() && lifecycle.Mode.Destroys() {
+			if test_structure.IsTestDataPresent(t, stageDataPath(t, "TerraformOptions.json")) {
+				lifecycle.Destroy(t, loadStageOptions(t))
+			} else {
+				t.Logf("%s has no saved terraform options, nothing to destroy", t.Name())
+			}
+		}
+		// Keep the state of a failed test, to rerun its stages with SKIP_setup and SKIP_init_plan, and of a deployment
+		// that is kept, to destroy it later
+		if !t.Failed() && lifecycle.Mode != harness.ApplyAndKeep {
+			if err := os.RemoveAll(stageDataDir(t)); err != nil {
+				t.Errorf("cleaning up %s: %v", stageDataDir(t), err)
+			}
+		}
+	})
+
+	test_structure.RunTestStage(t, stageSetup, func() {
+		terraformOptions := setup()
+		isolate(t, terraformOptions, mustAbs(stageDataPath(t, "terraform")))
+		test_structure.SaveTestData(t, stageDataPath(t, "TerraformOptions.json"), true, terraformOptions)
+	})
+
+	test_structure.RunTestStage(t, stageInitPlan, func() {
+		terraformOptions := loadStageOptions(t)
+		terraformOptions.PlanFilePath = stagePlanFile(t)
+		withFixture(t, fixture, terraformOptions)
+		lifecycle.Init(t, terraformOptions)
+		terraform.Plan(t, terraformOptions)
+		plan := planassert.New(terraform.ShowWithStruct(t, terraformOptions))
+
+		// The plan is written as is rather than with SaveTestData, which would log all of it
+		data, err := json.Marshal(plan.Raw().RawPlan)
+		if err != nil {
+			t.Fatalf("encoding plan: %v", err)
+		}
+		if err := os.WriteFile(stageDataPath(t, "plan.json"), data, 0o644); err != nil {
+			t.Fatalf("saving plan: %v", err)
+		}
+		checkPlan(plan)
+	})
+
+	test_structure.RunTestStage(t, stageApply, func() {
+		if !lifecycle.Mode.Applies() {
+			t.Logf("%s run, set %s=%s or %s to deploy", lifecycle.Mode, harness.RunModeEnvVar, harness.ApplyAndDestroy, harness.ApplyAndKeep)
+			return
+		}
+		if !liveAWS() {
+			t.Fatalf("%s needs %s=true: the offline fake cannot create resources", lifecycle.Mode, liveAWSEnvVar)
+		}
+		terraformOptions := loadStageOptions(t)
+		terraformOptions.PlanFilePath = stagePlanFile(t)
+		lifecycle.Apply(t, terraformOptions)
+	})
+
+	test_structure.RunTestStage(t, stageValidate, func() {
+		deployment, ok := lifecycle.Deployment(t, loadStageOptions(t))
+		if !ok {
+			t.Logf("%s run, nothing deployed to validate", lifecycle.Mode)
+			return
+		}
+		validateDeployment(t, deployment, loadStagePlan(t))
+	})
+}
+
+// validateDeployment checks the state and outputs of the test's workspace, read with terraform show and terraform
+// output, against the saved plan: every resource the plan keeps or creates is deployed and every output it plans is set.
+func validateDeployment(t *testing.T, deployment *terraform.Options, plan *planassert.Plan) {
+	t.Helper()
+
+	state, err := planassert.ParseStateJSON([]byte(terraform.Show(t, deployment)))
+	if err != nil {
+		t.Fatalf("reading deployed state: %v", err)
+	}
+	for _, resource := range plan.Managed() {
+		planassert.AssertResourceExists(t, state, resource.Address)
+	}
+
+	outputs := terraform.OutputAll(t, deployment)
+	for name, change := range plan.Raw().RawPlan.OutputChanges {
+		if change != nil && !change.Actions.Delete() {
+			assert.Contains(t, outputs, name, "output %s is not set in the deployed state", name)
+		}
+	}
+}
+
+// stageLifecycle returns the lifecycle of the test in the run mode TERRATEST_RUN_MODE selects, which deploys into a
+// workspace named after the test.
+func stageLifecycle(t *testing.T) *harness.Lifecycle {
+	t.Helper()
+
+	mode, err := harness.RunModeFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return harness.NewLifecycle(mode, t.Name())
+}
+
+// stageDataDir returns the folder the stages of the test hand over through.
+func stageDataDir(t *testing.T) string {
+	return filepath.Join(testDataDir, t.Name())
+}
+
+func stageDataPath(t *testing.T, name string) string {
+	return filepath.Join(stageDataDir(t), name)
+}
+
+// stagePlanFile returns the absolute path of the plan file init_plan writes and apply applies, since terraform runs in
+// the module directory.
+func stagePlanFile(t *testing.T) string {
+	t.Helper()
+
+	path, err := filepath.Abs(stageDataPath(t, "plan.out"))
+	if err != nil {
+		t.Fatalf("resolving plan file: %v", err)
+	}
+	return path
+}
+
+// loadStageOptions loads the terraform options saved by the setup stage.
+func loadStageOptions(t *testing.T) *terraform.Options {
+	t.Helper()
+
+	path := stageDataPath(t, "TerraformOptions.json")
+	if !test_structure.IsTestDataPresent(t, path) {
+		t.Fatalf("%s has no saved terraform options, run the %s stage first", t.Name(), stageSetup)
+	}
+	var terraformOptions terraform.Options
+	test_structure.LoadTestData(t, path, &terraformOptions)
+	return &terraformOptions
+}
+
+// loadStagePlan loads the plan saved by the init_plan stage.
+func loadStagePlan(t *testing.T) *planassert.Plan {
+	t.Helper()
+
+	data, err := os.ReadFile(stageDataPath(t, "plan.json"))
+	if os.IsNotExist(err) {
+		t.Fatalf("%s has no saved plan, run the %s stage first", t.Name(), stageInitPlan)
+	}
+	if err != nil {
+		t.Fatalf("loading plan: %v", err)
+	}
+	plan, err := planassert.ParseJSON(data)
+	if err != nil {
+		t.Fatalf("parsing saved plan: %v", err)
+	}
+	return plan
+}
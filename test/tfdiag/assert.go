@@ -0,0 +1,33 @@
+package tfdiag
+
+import (
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// AssertValidationError checks that the only variable the diagnostics report as invalid is the one the validation
+// checks, with the validation's exact error_message, printing every error diagnostic when it is not.
+func AssertValidationError(t testing.TestingT, diagnostics []Diagnostic, validation Validation) bool {
+	errs := Errors(diagnostics)
+	rendered := make([]string, len(errs))
+	for i, d := range errs {
+		rendered[i] = d.String()
+	}
+
+	var matched, otherInvalid []string
+	for i, d := range errs {
+		switch {
+		case validation.Matches(d):
+			matched = append(matched, rendered[i])
+		case d.Summary == InvalidVariableSummary:
+			otherInvalid = append(otherInvalid, rendered[i])
+		}
+	}
+	if !assert.Lenf(t, matched, 1, "expected the plan to fail %s with %q, got errors:\n%s",
+		validation, validation.ErrorMessage, strings.Join(rendered, "\n")) {
+		return false
+	}
+	return assert.Emptyf(t, otherInvalid, "other variables failed validation too:\n%s", strings.Join(otherInvalid, "\n"))
+}
@@ -0,0 +1,151 @@
+// Package tfdiag parses the diagnostics of Terraform's machine-readable output and catalogues the validation blocks of
+// a module's variables, so a test can check that a plan failed on a specific validation with its exact error_message
+// rather than for any reason at all.
+package tfdiag
+
+import (
+	"bufio"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// InvalidVariableSummary is the summary of the diagnostic Terraform reports when a variable fails a validation block.
+const InvalidVariableSummary = "Invalid value for variable"
+
+// Severities of a diagnostic.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// checkedByPrefix starts the sentence Terraform appends to the error_message of a failed validation.
+const checkedByPrefix = "\n\nThis was checked by the validation rule at "
+
+var (
+	ruleLocationPattern  = regexp.MustCompile(`^(.+):(\d+),\d+-\d+\.?$`)
+	valueForVarPattern   = regexp.MustCompile(`^<value for var\.([A-Za-z0-9_-]+)>$`)
+	variableBlockPattern = regexp.MustCompile(`^\s*variable\s+"([A-Za-z0-9_-]+)"`)
+)
+
+// Diagnostic is a diagnostic of the -json output of terraform plan, apply or validate.
+type Diagnostic struct {
+	Severity string   `json:"severity"`
+	Summary  string   `json:"summary"`
+	Detail   string   `json:"detail"`
+	Range    *Range   `json:"range,omitempty"`
+	Snippet  *Snippet `json:"snippet,omitempty"`
+}
+
+// Range is the source range a diagnostic points to.
+type Range struct {
+	Filename string `json:"filename"`
+	Start    Pos    `json:"start"`
+	End      Pos    `json:"end"`
+}
+
+// Pos is a position in a source file.
+type Pos struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+	Byte   int `json:"byte"`
+}
+
+// Snippet is the source code around the range of a diagnostic.
+type Snippet struct {
+	Context   *string `json:"context"`
+	Code      string  `json:"code"`
+	StartLine int     `json:"start_line"`
+}
+
+// String renders the diagnostic as "<severity>: <summary>: <detail>", followed by its location when it has one.
+func (d Diagnostic) String() string {
+	s := d.Severity + ": " + d.Summary
+	if d.Detail != "" {
+		s += ": " + d.Detail
+	}
+	if d.Range != nil {
+		s += " (" + d.Range.Filename + ":" + strconv.Itoa(d.Range.Start.Line) + ")"
+	}
+	return s
+}
+
+// Variable returns the name of the variable the diagnostic points to, or "" when it points elsewhere. Terraform points
+// a failed validation of a root module variable at the variable block, and one of a value set on the command line at
+// a pseudo-file named after the variable.
+func (d Diagnostic) Variable() string {
+	if d.Range != nil {
+		if match := valueForVarPattern.FindStringSubmatch(d.Range.Filename); match != nil {
+			return match[1]
+		}
+	}
+	if d.Snippet != nil {
+		if match := variableBlockPattern.FindStringSubmatch(d.Snippet.Code); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+// ErrorMessage returns the error_message of the validation block a variable failed, which is the detail of the
+// diagnostic without the location of the rule Terraform appends to it.
+func (d Diagnostic) ErrorMessage() string {
+	message, _, _ := strings.Cut(d.Detail, checkedByPrefix)
+	return message
+}
+
+// Rule returns the file and line of the validation block the detail says the variable was checked by, or ok false
+// when the detail does not say.
+func (d Diagnostic) Rule() (filename string, line int, ok bool) {
+	_, location, found := strings.Cut(d.Detail, checkedByPrefix)
+	if !found {
+		return "", 0, false
+	}
+	match := ruleLocationPattern.FindStringSubmatch(strings.TrimSpace(location))
+	if match == nil {
+		return "", 0, false
+	}
+	line, err := strconv.Atoi(match[2])
+	if err != nil {
+		return "", 0, false
+	}
+	return match[1], line, true
+}
+
+// message is a line of the -json output; only diagnostic messages are decoded further.
+type message struct {
+	Type       string      `json:"type"`
+	Diagnostic *Diagnostic `json:"diagnostic"`
+}
+
+// Parse returns the diagnostics in the -json output of a terraform command. Lines that are not JSON, such as the
+// output of terraform init or of the test framework, are skipped.
+func Parse(output string) []Diagnostic {
+	var diagnostics []Diagnostic
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var m message
+		if err := json.Unmarshal([]byte(line), &m); err != nil || m.Type != "diagnostic" || m.Diagnostic == nil {
+			continue
+		}
+		diagnostics = append(diagnostics, *m.Diagnostic)
+	}
+	return diagnostics
+}
+
+// Errors returns the diagnostics of error severity.
+func Errors(diagnostics []Diagnostic) []Diagnostic {
+	var errs []Diagnostic
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	return errs
+}
@@ -0,0 +1,137 @@
+package tfdiag
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingT struct {
+	failed bool
+}
+
+func (r *recordingT) Fail()                                  { r.failed = true }
+func (r *recordingT) FailNow()                               { r.failed = true }
+func (r *recordingT) Fatal(args ...interface{})              { r.failed = true }
+func (r *recordingT) Fatalf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Error(args ...interface{})              { r.failed = true }
+func (r *recordingT) Errorf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Name() string                           { return "recordingT" }
+
+// sampleOutput is the output of terraform plan -json for the vpc module with two availability zones, preceded by a
+// line of terraform init output.
+const sampleOutput = `Terraform has been successfully initialized!
+{"@level":"info","@message":"Terraform 1.6.6","@module":"terraform.ui","terraform":"1.6.6","type":"version","ui":"1.2"}
+{"@level":"warning","@message":"Warning: Deprecated attribute","@module":"terraform.ui","diagnostic":{"severity":"warning","summary":"Deprecated attribute","detail":"The attribute \"name\" is deprecated."},"type":"diagnostic"}
+{"@level":"error","@message":"Error: Invalid value for variable","@module":"terraform.ui","diagnostic":{"severity":"error","summary":"Invalid value for variable","detail":"Exactly 3 availability zones must be specified.\n\nThis was checked by the validation rule at variables.tf:19,3-13.","range":{"filename":"variables.tf","start":{"line":16,"column":1,"byte":402},"end":{"line":16,"column":30,"byte":431}},"snippet":{"context":null,"code":"variable \"availability_zones\" {","start_line":16,"highlight_start_offset":0,"highlight_end_offset":29,"values":[]}},"type":"diagnostic"}
+`
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	diagnostics := Parse(sampleOutput)
+	require.Len(t, diagnostics, 2)
+	assert.Equal(t, SeverityWarning, diagnostics[0].Severity)
+
+	errs := Errors(diagnostics)
+	require.Len(t, errs, 1)
+	d := errs[0]
+	assert.Equal(t, InvalidVariableSummary, d.Summary)
+	assert.Equal(t, "availability_zones", d.Variable())
+	assert.Equal(t, "Exactly 3 availability zones must be specified.", d.ErrorMessage())
+	filename, line, ok := d.Rule()
+	assert.True(t, ok)
+	assert.Equal(t, "variables.tf", filename)
+	assert.Equal(t, 19, line)
+	assert.Equal(t, "error: Invalid value for variable: Exactly 3 availability zones must be specified.\n\n"+
+		"This was checked by the validation rule at variables.tf:19,3-13. (variables.tf:16)", d.String())
+}
+
+func TestDiagnosticVariable(t *testing.T) {
+	t.Parallel()
+
+	// Values set with -var are reported at a pseudo-file named after the variable
+	d := Diagnostic{Range: &Range{Filename: "<value for var.region>"}, Detail: "Region must be valid."}
+	assert.Equal(t, "region", d.Variable())
+	assert.Equal(t, "Region must be valid.", d.ErrorMessage())
+	_, _, ok := d.Rule()
+	assert.False(t, ok)
+
+	assert.Empty(t, Diagnostic{Snippet: &Snippet{Code: `  vpc_id = var.vpc_id`}}.Variable())
+	assert.Empty(t, Diagnostic{}.Variable())
+}
+
+func TestParseValidations(t *testing.T) {
+	t.Parallel()
+
+	validations, err := ParseValidations("../../modules/vpc")
+	require.NoError(t, err)
+	require.Len(t, validations, 2)
+
+	assert.Equal(t, "vpc_cidr", validations[0].Variable)
+	assert.Equal(t, "VPC CIDR must be a valid IPv4 CIDR block (e.g., 10.0.0.0/16).", validations[0].ErrorMessage)
+	assert.Equal(t, "can(cidrhost(var.vpc_cidr, 0))", validations[0].Condition)
+	assert.Equal(t, 10, validations[0].Line)
+
+	zones := validations[1]
+	assert.Equal(t, "availability_zones", zones.Variable)
+	assert.Equal(t, 19, zones.Line)
+	assert.Equal(t, "../../modules/vpc var.availability_zones (variables.tf:19)", zones.String())
+}
+
+func TestValidationMatches(t *testing.T) {
+	t.Parallel()
+
+	validations, err := ParseValidations("../../modules/vpc")
+	require.NoError(t, err)
+	cidr, zones := validations[0], validations[1]
+
+	diagnostics := Parse(sampleOutput)
+	assert.True(t, zones.Matches(diagnostics[1]))
+	assert.False(t, cidr.Matches(diagnostics[1]))
+	assert.False(t, zones.Matches(diagnostics[0]))
+
+	// The same message checked by a rule at another line is a different validation
+	moved := diagnostics[1]
+	moved.Detail = "Exactly 3 availability zones must be specified.\n\nThis was checked by the validation rule at variables.tf:20,3-13."
+	assert.False(t, zones.Matches(moved))
+
+	reworded := diagnostics[1]
+	reworded.Detail = "Exactly three availability zones must be specified."
+	assert.False(t, zones.Matches(reworded))
+}
+
+func TestAssertValidationError(t *testing.T) {
+	t.Parallel()
+
+	validations, err := ParseValidations("../../modules/vpc")
+	require.NoError(t, err)
+	cidr, zones := validations[0], validations[1]
+	diagnostics := Parse(sampleOutput)
+
+	assert.True(t, AssertValidationError(t, diagnostics, zones))
+
+	r := &recordingT{}
+	assert.False(t, AssertValidationError(r, diagnostics, cidr))
+	assert.True(t, r.failed)
+
+	// An error that is not a validation failure, e.g. missing credentials, does not count
+	r = &recordingT{}
+	assert.False(t, AssertValidationError(r, []Diagnostic{{
+		Severity: SeverityError,
+		Summary:  "No valid credential sources found",
+	}}, zones))
+	assert.True(t, r.failed)
+
+	// Nor does the expected validation failing alongside another
+	r = &recordingT{}
+	both := append(diagnostics, Diagnostic{
+		Severity: SeverityError,
+		Summary:  InvalidVariableSummary,
+		Detail:   cidr.ErrorMessage,
+		Range:    &Range{Filename: "<value for var.vpc_cidr>"},
+	})
+	assert.False(t, AssertValidationError(r, both, zones))
+	assert.True(t, r.failed)
+}
@@ -0,0 +1,112 @@
+package tfdiag
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Validation is a validation block of a module variable.
+type Validation struct {
+	// Module is the directory of the module, as passed to ParseValidations.
+	Module string
+
+	Variable     string
+	ErrorMessage string
+
+	// Condition is the source text of the condition expression.
+	Condition string
+
+	// Filename and Line locate the validation block, as Terraform reports the rule a variable was checked by.
+	Filename string
+	Line     int
+}
+
+// String renders the validation as "<module> var.<variable> (<file>:<line>)".
+func (v Validation) String() string {
+	return fmt.Sprintf("%s var.%s (%s:%d)", v.Module, v.Variable, filepath.Base(v.Filename), v.Line)
+}
+
+// ParseValidations returns every validation block of the variables declared in the .tf files of the module directory,
+// in file and line order. error_message must be a literal string, as Terraform requires before 1.9 and this
+// repository's modules all use.
+func ParseValidations(dir string) ([]Validation, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var validations []Validation
+	parser := hclparse.NewParser()
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		file, diags := parser.ParseHCL(src, path)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			return nil, fmt.Errorf("%s: unexpected body type %T", path, file.Body)
+		}
+		for _, block := range body.Blocks {
+			if block.Type != "variable" || len(block.Labels) != 1 {
+				continue
+			}
+			for _, nested := range block.Body.Blocks {
+				if nested.Type != "validation" {
+					continue
+				}
+				validation, err := parseValidation(src, block.Labels[0], nested)
+				if err != nil {
+					return nil, fmt.Errorf("%s: variable %s: %w", path, block.Labels[0], err)
+				}
+				validation.Module = dir
+				validation.Filename = path
+				validations = append(validations, validation)
+			}
+		}
+	}
+	return validations, nil
+}
+
+func parseValidation(src []byte, variable string, block *hclsyntax.Block) (Validation, error) {
+	validation := Validation{Variable: variable, Line: block.DefRange().Start.Line}
+
+	condition, ok := block.Body.Attributes["condition"]
+	if !ok {
+		return Validation{}, fmt.Errorf("validation at line %d has no condition", validation.Line)
+	}
+	validation.Condition = string(condition.Expr.Range().SliceBytes(src))
+
+	errorMessage, ok := block.Body.Attributes["error_message"]
+	if !ok {
+		return Validation{}, fmt.Errorf("validation at line %d has no error_message", validation.Line)
+	}
+	value, diags := errorMessage.Expr.Value(nil)
+	if diags.HasErrors() || value.Type() != cty.String || value.IsNull() {
+		return Validation{}, fmt.Errorf("error_message at line %d is not a literal string", validation.Line)
+	}
+	validation.ErrorMessage = value.AsString()
+	return validation, nil
+}
+
+// Matches reports whether the diagnostic is the failure of this validation: an invalid variable diagnostic pointing
+// to the variable, with the error_message as its detail and, when the detail locates the rule, this block as the rule.
+func (v Validation) Matches(d Diagnostic) bool {
+	if d.Summary != InvalidVariableSummary || d.Variable() != v.Variable || d.ErrorMessage() != v.ErrorMessage {
+		return false
+	}
+	if filename, line, ok := d.Rule(); ok {
+		return filepath.Base(filename) == filepath.Base(v.Filename) && line == v.Line
+	}
+	return true
+}
@@ -0,0 +1,259 @@
+package tfvars
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RootVars mirrors the variables of the root module. Empty strings and nil slices, maps and pointers are left out of
+// Vars so the variable default applies; an empty non-nil OrganizationalUnits passes an empty list.
+type RootVars struct {
+	PrimaryRegion              string
+	SecondaryRegion            string
+	ClusterNamePrefix          string
+	PrimaryVPCID               string
+	SecondaryVPCID             string
+	PrimaryAvailabilityZones   []string
+	SecondaryAvailabilityZones []string
+	Environment                string
+	OrganizationalUnits        OUs
+	KubernetesVersion          string
+	NodeGroups                 NodeGroups
+	RDSConfig                  *RDSConfig
+	Tags                       map[string]string
+}
+
+// BaselineRoot is a small test deployment in us-east-1 and us-west-2 into the given VPCs: one general node group, a
+// PostgreSQL database and one admin OU.
+func BaselineRoot(primaryVPCID, secondaryVPCID string) RootVars {
+	rds := BaselineRDSConfig()
+	return RootVars{
+		PrimaryRegion:              "us-east-1",
+		SecondaryRegion:            "us-west-2",
+		ClusterNamePrefix:          "test-multi-region",
+		PrimaryVPCID:               primaryVPCID,
+		SecondaryVPCID:             secondaryVPCID,
+		PrimaryAvailabilityZones:   Zones("us-east-1"),
+		SecondaryAvailabilityZones: Zones("us-west-2"),
+		Environment:                "test",
+		OrganizationalUnits:        OUs{BaselineOU()},
+		KubernetesVersion:          "1.28",
+		NodeGroups:                 NodeGroups{"general": BaselineNodeGroup()},
+		RDSConfig:                  &rds,
+	}
+}
+
+// WithSecondaryRegion returns a copy of the variables with the secondary region and its a, b and c zones.
+func (v RootVars) WithSecondaryRegion(region string) RootVars {
+	v.SecondaryRegion, v.SecondaryAvailabilityZones = region, Zones(region)
+	return v
+}
+
+// Zones returns the a, b and c availability zones of the region.
+func Zones(region string) []string {
+	return []string{region + "a", region + "b", region + "c"}
+}
+
+// Validate checks the variables against the root module's types and the nested builders.
+func (v RootVars) Validate() error {
+	var errs []error
+	if v.PrimaryVPCID == "" {
+		errs = append(errs, errors.New("primary_vpc_id is required"))
+	}
+	if v.SecondaryVPCID == "" {
+		errs = append(errs, errors.New("secondary_vpc_id is required"))
+	}
+	primary, secondary := orDefault(v.PrimaryRegion, "us-east-1"), orDefault(v.SecondaryRegion, "us-west-2")
+	if primary == secondary {
+		errs = append(errs, fmt.Errorf("primary_region and secondary_region are both %s", primary))
+	}
+	if v.PrimaryAvailabilityZones != nil {
+		errs = append(errs, validateZones("primary_availability_zones", primary, v.PrimaryAvailabilityZones))
+	}
+	if v.SecondaryAvailabilityZones != nil {
+		errs = append(errs, validateZones("secondary_availability_zones", secondary, v.SecondaryAvailabilityZones))
+	}
+	errs = append(errs, validateRegion("primary_region", v.PrimaryRegion), validateRegion("secondary_region", v.SecondaryRegion))
+	errs = append(errs, v.OrganizationalUnits.Validate(), v.NodeGroups.Validate())
+	if v.RDSConfig != nil {
+		if err := v.RDSConfig.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("rds_config: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Vars renders the variables for terraform.Options.
+func (v RootVars) Vars() map[string]interface{} {
+	vars := map[string]interface{}{}
+	setString(vars, "primary_region", v.PrimaryRegion)
+	setString(vars, "secondary_region", v.SecondaryRegion)
+	setString(vars, "cluster_name_prefix", v.ClusterNamePrefix)
+	setString(vars, "primary_vpc_id", v.PrimaryVPCID)
+	setString(vars, "secondary_vpc_id", v.SecondaryVPCID)
+	setString(vars, "environment", v.Environment)
+	setString(vars, "kubernetes_version", v.KubernetesVersion)
+	if v.PrimaryAvailabilityZones != nil {
+		vars["primary_availability_zones"] = append([]string{}, v.PrimaryAvailabilityZones...)
+	}
+	if v.SecondaryAvailabilityZones != nil {
+		vars["secondary_availability_zones"] = append([]string{}, v.SecondaryAvailabilityZones...)
+	}
+	if v.OrganizationalUnits != nil {
+		vars["organizational_units"] = v.OrganizationalUnits.Vars()
+	}
+	if v.NodeGroups != nil {
+		vars["node_groups"] = v.NodeGroups.Vars()
+	}
+	if v.RDSConfig != nil {
+		vars["rds_config"] = v.RDSConfig.Vars()
+	}
+	if v.Tags != nil {
+		vars["tags"] = copyTags(v.Tags)
+	}
+	return vars
+}
+
+// RegionalVars mirrors the variables of modules/regional-eks.
+type RegionalVars struct {
+	Region              string
+	ClusterName         string
+	VPCID               string
+	AvailabilityZones   []string
+	Environment         string
+	OrganizationalUnits OUs
+	KubernetesVersion   string
+	NodeGroups          NodeGroups
+	CreateRDS           bool
+	RDSConfig           *RDSConfig
+	RDSPrimaryARN       string
+	RDSPrimaryRegion    string
+	Tags                map[string]string
+}
+
+// BaselineRegional is a regional deployment into the given VPC with one general node group, a PostgreSQL database
+// and one admin OU.
+func BaselineRegional(region, vpcID string) RegionalVars {
+	rds := BaselineRDSConfig()
+	return RegionalVars{
+		Region:              region,
+		ClusterName:         "test-regional-cluster",
+		VPCID:               vpcID,
+		AvailabilityZones:   Zones(region),
+		Environment:         "test",
+		OrganizationalUnits: OUs{BaselineOU()},
+		KubernetesVersion:   "1.28",
+		NodeGroups:          NodeGroups{"general": BaselineNodeGroup()},
+		CreateRDS:           true,
+		RDSConfig:           &rds,
+	}
+}
+
+// Validate checks the variables against the regional-eks module's types and validation blocks.
+func (v RegionalVars) Validate() error {
+	var errs []error
+	if v.Region == "" {
+		errs = append(errs, errors.New("region is required"))
+	}
+	errs = append(errs, validateRegion("region", v.Region), validateRegion("rds_primary_region", v.RDSPrimaryRegion))
+	for name, value := range map[string]string{
+		"cluster_name":       v.ClusterName,
+		"vpc_id":             v.VPCID,
+		"environment":        v.Environment,
+		"kubernetes_version": v.KubernetesVersion,
+	} {
+		if value == "" {
+			errs = append(errs, fmt.Errorf("%s is required", name))
+		}
+	}
+	errs = append(errs, validateZones("availability_zones", v.Region, v.AvailabilityZones))
+	if v.OrganizationalUnits == nil {
+		errs = append(errs, errors.New("organizational_units is required"))
+	}
+	if v.NodeGroups == nil {
+		errs = append(errs, errors.New("node_groups is required"))
+	}
+	errs = append(errs, v.OrganizationalUnits.Validate(), v.NodeGroups.Validate())
+	if v.RDSConfig != nil {
+		if err := v.RDSConfig.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("rds_config: %w", err))
+		}
+	}
+	if v.RDSPrimaryARN != "" && !strings.HasPrefix(v.RDSPrimaryARN, "arn:") {
+		errs = append(errs, fmt.Errorf("rds_primary_arn %q is not an ARN", v.RDSPrimaryARN))
+	}
+	return errors.Join(errs...)
+}
+
+// Vars renders the variables for terraform.Options.
+func (v RegionalVars) Vars() map[string]interface{} {
+	vars := map[string]interface{}{
+		"region":               v.Region,
+		"cluster_name":         v.ClusterName,
+		"vpc_id":               v.VPCID,
+		"availability_zones":   append([]string{}, v.AvailabilityZones...),
+		"environment":          v.Environment,
+		"organizational_units": v.OrganizationalUnits.Vars(),
+		"kubernetes_version":   v.KubernetesVersion,
+		"node_groups":          v.NodeGroups.Vars(),
+		"create_rds":           v.CreateRDS,
+	}
+	if v.RDSConfig != nil {
+		vars["rds_config"] = v.RDSConfig.Vars()
+	}
+	setString(vars, "rds_primary_arn", v.RDSPrimaryARN)
+	setString(vars, "rds_primary_region", v.RDSPrimaryRegion)
+	if v.Tags != nil {
+		vars["tags"] = copyTags(v.Tags)
+	}
+	return vars
+}
+
+// validateRegion mirrors the region validation block of modules/regional-eks. Empty regions are left to the default.
+func validateRegion(name, region string) error {
+	if region != "" && !regionPattern.MatchString(region) {
+		return fmt.Errorf("%s %q is not a valid AWS region", name, region)
+	}
+	return nil
+}
+
+// validateZones checks that exactly three zones of the region are given, as the modules require.
+func validateZones(name, region string, zones []string) error {
+	var errs []error
+	if len(zones) != 3 {
+		errs = append(errs, fmt.Errorf("%s must list exactly 3 availability zones, got %d", name, len(zones)))
+	}
+	seen := map[string]bool{}
+	for _, zone := range zones {
+		if region != "" && (!strings.HasPrefix(zone, region) || len(zone) != len(region)+1) {
+			errs = append(errs, fmt.Errorf("%s: %q is not an availability zone of %s", name, zone, region))
+		}
+		if seen[zone] {
+			errs = append(errs, fmt.Errorf("%s: %q is listed twice", name, zone))
+		}
+		seen[zone] = true
+	}
+	return errors.Join(errs...)
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func setString(vars map[string]interface{}, name, value string) {
+	if value != "" {
+		vars[name] = value
+	}
+}
+
+func copyTags(tags map[string]string) map[string]string {
+	out := make(map[string]string, len(tags))
+	for key, value := range tags {
+		out[key] = value
+	}
+	return out
+}
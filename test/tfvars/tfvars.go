@@ -0,0 +1,323 @@
+// Package tfvars provides typed builders for the input variables of the root module and modules/regional-eks. Each
+// builder mirrors a variable type in variables.tf, validates itself against that type and the constraints AWS puts on
+// the values, and renders the map terraform.Options.Vars expects. Tests start from a Baseline and override only the
+// fields they care about:
+//
+//	vars := tfvars.BaselineRoot(fixture.VPCs[0].ID, fixture.VPCs[1].ID)
+//	vars.NodeGroups["general"] = vars.NodeGroups["general"].WithCapacityType("SPOT")
+package tfvars
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+)
+
+// Validator is implemented by every builder.
+type Validator interface {
+	Validate() error
+}
+
+// Require fails the test immediately if any of the builders is invalid.
+func Require(t testing.TestingT, builders ...Validator) {
+	for _, builder := range builders {
+		if err := builder.Validate(); err != nil {
+			t.Fatalf("invalid terraform variables: %v", err)
+		}
+	}
+}
+
+// CapacityTypes are the capacity types EKS accepts for a managed node group.
+var CapacityTypes = []string{"ON_DEMAND", "SPOT"}
+
+// RDSEngines are the engines the rds module derives a port, parameter group family and log exports for.
+var RDSEngines = []string{"postgres", "mysql"}
+
+// Permissions are the OU permissions the eks-cluster module maps to an EKS access policy.
+var Permissions = []string{"admin", "deploy", "view"}
+
+// NodeGroup mirrors an element of the node_groups variable.
+type NodeGroup struct {
+	DesiredSize   int
+	MinSize       int
+	MaxSize       int
+	InstanceTypes []string
+	CapacityType  string
+	DiskSize      int
+}
+
+// BaselineNodeGroup is an on-demand group of t3.large nodes, two per availability zone.
+func BaselineNodeGroup() NodeGroup {
+	return NodeGroup{
+		DesiredSize:   6,
+		MinSize:       3,
+		MaxSize:       15,
+		InstanceTypes: []string{"t3.large"},
+		CapacityType:  "ON_DEMAND",
+		DiskSize:      50,
+	}
+}
+
+// WithSizes returns a copy of the node group with the given minimum, desired and maximum sizes.
+func (g NodeGroup) WithSizes(min, desired, max int) NodeGroup {
+	g.MinSize, g.DesiredSize, g.MaxSize = min, desired, max
+	return g
+}
+
+// WithInstanceTypes returns a copy of the node group with the given instance types.
+func (g NodeGroup) WithInstanceTypes(instanceTypes ...string) NodeGroup {
+	g.InstanceTypes = instanceTypes
+	return g
+}
+
+// WithCapacityType returns a copy of the node group with the given capacity type.
+func (g NodeGroup) WithCapacityType(capacityType string) NodeGroup {
+	g.CapacityType = capacityType
+	return g
+}
+
+// Validate checks the node group against the constraints of EKS managed node groups.
+func (g NodeGroup) Validate() error {
+	var errs []error
+	if !contains(CapacityTypes, g.CapacityType) {
+		errs = append(errs, fmt.Errorf("capacity_type %q must be one of %s", g.CapacityType, strings.Join(CapacityTypes, ", ")))
+	}
+	if g.MinSize < 0 {
+		errs = append(errs, fmt.Errorf("min_size %d must not be negative", g.MinSize))
+	}
+	if g.MaxSize < 1 {
+		errs = append(errs, fmt.Errorf("max_size %d must be at least 1", g.MaxSize))
+	}
+	if g.DesiredSize < g.MinSize || g.DesiredSize > g.MaxSize {
+		errs = append(errs, fmt.Errorf("desired_size %d must be between min_size %d and max_size %d", g.DesiredSize, g.MinSize, g.MaxSize))
+	}
+	if len(g.InstanceTypes) == 0 {
+		errs = append(errs, errors.New("instance_types must not be empty"))
+	}
+	for _, instanceType := range g.InstanceTypes {
+		if !instanceTypePattern.MatchString(instanceType) {
+			errs = append(errs, fmt.Errorf("instance type %q is not of the form family.size", instanceType))
+		}
+	}
+	if g.DiskSize < 1 {
+		errs = append(errs, fmt.Errorf("disk_size %d must be at least 1 GiB", g.DiskSize))
+	}
+	return errors.Join(errs...)
+}
+
+// Vars renders the node group as a node_groups object.
+func (g NodeGroup) Vars() map[string]interface{} {
+	return map[string]interface{}{
+		"desired_size":   g.DesiredSize,
+		"min_size":       g.MinSize,
+		"max_size":       g.MaxSize,
+		"instance_types": append([]string{}, g.InstanceTypes...),
+		"capacity_type":  g.CapacityType,
+		"disk_size":      g.DiskSize,
+	}
+}
+
+// NodeGroups mirrors the node_groups variable, keyed by node group name.
+type NodeGroups map[string]NodeGroup
+
+// Validate checks every node group.
+func (n NodeGroups) Validate() error {
+	var errs []error
+	for _, name := range n.names() {
+		if name == "" {
+			errs = append(errs, errors.New("node group name must not be empty"))
+			continue
+		}
+		if err := n[name].Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("node group %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Vars renders the node_groups map.
+func (n NodeGroups) Vars() map[string]interface{} {
+	out := make(map[string]interface{}, len(n))
+	for name, group := range n {
+		out[name] = group.Vars()
+	}
+	return out
+}
+
+func (n NodeGroups) names() []string {
+	names := make([]string, 0, len(n))
+	for name := range n {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RDSConfig mirrors the rds_config variable.
+type RDSConfig struct {
+	Engine                string
+	EngineVersion         string
+	InstanceClass         string
+	AllocatedStorage      int
+	DatabaseName          string
+	MasterUsername        string
+	BackupRetentionPeriod int
+	MultiAZ               bool
+	StorageEncrypted      bool
+}
+
+// BaselineRDSConfig is an encrypted multi-AZ PostgreSQL 15 instance.
+func BaselineRDSConfig() RDSConfig {
+	return RDSConfig{
+		Engine:                "postgres",
+		EngineVersion:         "15.4",
+		InstanceClass:         "db.t3.medium",
+		AllocatedStorage:      100,
+		DatabaseName:          "testdb",
+		MasterUsername:        "dbadmin",
+		BackupRetentionPeriod: 7,
+		MultiAZ:               true,
+		StorageEncrypted:      true,
+	}
+}
+
+// WithEngine returns a copy of the configuration with the given engine and version.
+func (c RDSConfig) WithEngine(engine, version string) RDSConfig {
+	c.Engine, c.EngineVersion = engine, version
+	return c
+}
+
+// Validate checks the configuration against the constraints RDS puts on a DB instance.
+func (c RDSConfig) Validate() error {
+	var errs []error
+	if !contains(RDSEngines, c.Engine) {
+		errs = append(errs, fmt.Errorf("engine %q must be one of %s", c.Engine, strings.Join(RDSEngines, ", ")))
+	}
+	switch {
+	case !versionPattern.MatchString(c.EngineVersion):
+		errs = append(errs, fmt.Errorf("engine_version %q is not a dotted version number", c.EngineVersion))
+	case c.Engine == "mysql" && !strings.Contains(c.EngineVersion, "."):
+		errs = append(errs, fmt.Errorf("mysql engine_version %q must give at least the major and minor version", c.EngineVersion))
+	}
+	if !strings.HasPrefix(c.InstanceClass, "db.") || !instanceTypePattern.MatchString(strings.TrimPrefix(c.InstanceClass, "db.")) {
+		errs = append(errs, fmt.Errorf("instance_class %q is not of the form db.family.size", c.InstanceClass))
+	}
+	if c.AllocatedStorage < 20 || c.AllocatedStorage > 65536 {
+		errs = append(errs, fmt.Errorf("allocated_storage %d must be between 20 and 65536 GiB", c.AllocatedStorage))
+	}
+	if !identifierPattern.MatchString(c.DatabaseName) {
+		errs = append(errs, fmt.Errorf("database_name %q must start with a letter and contain only letters, digits and underscores", c.DatabaseName))
+	}
+	if !identifierPattern.MatchString(c.MasterUsername) {
+		errs = append(errs, fmt.Errorf("master_username %q must start with a letter and contain only letters, digits and underscores", c.MasterUsername))
+	}
+	if c.BackupRetentionPeriod < 0 || c.BackupRetentionPeriod > 35 {
+		errs = append(errs, fmt.Errorf("backup_retention_period %d must be between 0 and 35 days", c.BackupRetentionPeriod))
+	}
+	return errors.Join(errs...)
+}
+
+// Vars renders the rds_config object.
+func (c RDSConfig) Vars() map[string]interface{} {
+	return map[string]interface{}{
+		"engine":                  c.Engine,
+		"engine_version":          c.EngineVersion,
+		"instance_class":          c.InstanceClass,
+		"allocated_storage":       c.AllocatedStorage,
+		"database_name":           c.DatabaseName,
+		"master_username":         c.MasterUsername,
+		"backup_retention_period": c.BackupRetentionPeriod,
+		"multi_az":                c.MultiAZ,
+		"storage_encrypted":       c.StorageEncrypted,
+	}
+}
+
+// OU mirrors an element of the organizational_units variable.
+type OU struct {
+	Name        string
+	OUID        string
+	Permissions []string
+}
+
+// BaselineOU is an OU with admin access.
+func BaselineOU() OU {
+	return OU{Name: "test-ou", OUID: "ou-test-001", Permissions: []string{"admin"}}
+}
+
+// Validate checks that the OU is named and only has permissions the eks-cluster module knows; any other permission
+// would silently be granted view access.
+func (o OU) Validate() error {
+	var errs []error
+	if o.Name == "" {
+		errs = append(errs, errors.New("name must not be empty"))
+	}
+	if o.OUID == "" {
+		errs = append(errs, errors.New("ou_id must not be empty"))
+	}
+	if len(o.Permissions) == 0 {
+		errs = append(errs, errors.New("permissions must not be empty"))
+	}
+	for _, permission := range o.Permissions {
+		if !contains(Permissions, permission) {
+			errs = append(errs, fmt.Errorf("permission %q must be one of %s", permission, strings.Join(Permissions, ", ")))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Vars renders the OU as an organizational_units object.
+func (o OU) Vars() map[string]interface{} {
+	return map[string]interface{}{
+		"name":        o.Name,
+		"ou_id":       o.OUID,
+		"permissions": append([]string{}, o.Permissions...),
+	}
+}
+
+// OUs mirrors the organizational_units variable.
+type OUs []OU
+
+// Validate checks every OU, and that OU IDs are unique since the modules key resources by them.
+func (o OUs) Validate() error {
+	var errs []error
+	seen := map[string]bool{}
+	for i, ou := range o {
+		if err := ou.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("organizational unit %d (%s): %w", i, ou.OUID, err))
+		}
+		if seen[ou.OUID] {
+			errs = append(errs, fmt.Errorf("duplicate ou_id %q", ou.OUID))
+		}
+		seen[ou.OUID] = true
+	}
+	return errors.Join(errs...)
+}
+
+// Vars renders the organizational_units list.
+func (o OUs) Vars() []map[string]interface{} {
+	out := make([]map[string]interface{}, len(o))
+	for i, ou := range o {
+		out[i] = ou.Vars()
+	}
+	return out
+}
+
+var (
+	instanceTypePattern = regexp.MustCompile(`^[a-z][a-z0-9-]*\.[a-z0-9]+$`)
+	versionPattern      = regexp.MustCompile(`^\d+(\.\d+)*$`)
+	identifierPattern   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,62}$`)
+	regionPattern       = regexp.MustCompile(`^[a-z]{2}-[a-z]+-[0-9]{1}$`)
+)
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
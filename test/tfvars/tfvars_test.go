@@ -0,0 +1,169 @@
+package tfvars
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBaselinesAreValid(t *testing.T) {
+	t.Parallel()
+
+	for name, builder := range map[string]Validator{
+		"node group": BaselineNodeGroup(),
+		"rds config": BaselineRDSConfig(),
+		"ou":         BaselineOU(),
+		"root":       BaselineRoot("vpc-primary", "vpc-secondary"),
+		"regional":   BaselineRegional("eu-west-1", "vpc-12345678"),
+	} {
+		assert.NoError(t, builder.Validate(), name)
+	}
+}
+
+func TestNodeGroupValidate(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		group NodeGroup
+		err   string
+	}{
+		"capacity type":       {BaselineNodeGroup().WithCapacityType("on-demand"), `capacity_type "on-demand" must be one of ON_DEMAND, SPOT`},
+		"desired below min":   {BaselineNodeGroup().WithSizes(3, 2, 15), "desired_size 2 must be between min_size 3 and max_size 15"},
+		"desired above max":   {BaselineNodeGroup().WithSizes(0, 16, 15), "desired_size 16 must be between min_size 0 and max_size 15"},
+		"negative min":        {BaselineNodeGroup().WithSizes(-1, 0, 3), "min_size -1 must not be negative"},
+		"no instance types":   {BaselineNodeGroup().WithInstanceTypes(), "instance_types must not be empty"},
+		"bad instance type":   {BaselineNodeGroup().WithInstanceTypes("t3large"), `instance type "t3large" is not of the form family.size`},
+		"spot is fine":        {BaselineNodeGroup().WithCapacityType("SPOT"), ""},
+		"scale to zero is ok": {BaselineNodeGroup().WithSizes(0, 0, 12), ""},
+	}
+	for name, testCase := range testCases {
+		err := testCase.group.Validate()
+		if testCase.err == "" {
+			assert.NoError(t, err, name)
+		} else if assert.Error(t, err, name) {
+			assert.Contains(t, err.Error(), testCase.err, name)
+		}
+	}
+}
+
+func TestRDSConfigValidate(t *testing.T) {
+	t.Parallel()
+
+	config := BaselineRDSConfig()
+	config.InstanceClass = "t3.medium"
+	config.AllocatedStorage = 10
+	config.DatabaseName = "1db"
+	config.BackupRetentionPeriod = 36
+	config.EngineVersion = "latest"
+	config.Engine = "mariadb"
+
+	err := config.Validate()
+	require.Error(t, err)
+	for _, message := range []string{
+		`instance_class "t3.medium" is not of the form db.family.size`,
+		"allocated_storage 10 must be between 20 and 65536 GiB",
+		`database_name "1db" must start with a letter`,
+		"backup_retention_period 36 must be between 0 and 35 days",
+		`engine_version "latest" is not a dotted version number`,
+		`engine "mariadb" must be one of postgres, mysql`,
+	} {
+		assert.Contains(t, err.Error(), message)
+	}
+
+	assert.NoError(t, BaselineRDSConfig().WithEngine("mysql", "8.0").Validate())
+	assert.EqualError(t, BaselineRDSConfig().WithEngine("mysql", "8").Validate(),
+		`mysql engine_version "8" must give at least the major and minor version`)
+	assert.NoError(t, BaselineRDSConfig().WithEngine("postgres", "15").Validate())
+}
+
+func TestOUsValidate(t *testing.T) {
+	t.Parallel()
+
+	ous := OUs{BaselineOU(), {Name: "dev", OUID: "ou-test-001", Permissions: []string{"deploy", "write"}}}
+	err := ous.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `permission "write" must be one of admin, deploy, view`)
+	assert.Contains(t, err.Error(), `duplicate ou_id "ou-test-001"`)
+
+	assert.Error(t, OUs{{Name: "empty", OUID: "ou-empty"}}.Validate())
+}
+
+func TestRootVars(t *testing.T) {
+	t.Parallel()
+
+	vars := BaselineRoot("vpc-primary", "vpc-secondary").WithSecondaryRegion("eu-west-1")
+	vars.NodeGroups["spot"] = BaselineNodeGroup().WithCapacityType("SPOT").WithSizes(0, 3, 12)
+	vars.KubernetesVersion = ""
+	require.NoError(t, vars.Validate())
+
+	rendered := vars.Vars()
+	assert.Equal(t, "eu-west-1", rendered["secondary_region"])
+	assert.Equal(t, []string{"eu-west-1a", "eu-west-1b", "eu-west-1c"}, rendered["secondary_availability_zones"])
+	assert.NotContains(t, rendered, "kubernetes_version", "empty fields fall back to the variable default")
+	assert.NotContains(t, rendered, "tags")
+	assert.Equal(t, map[string]interface{}{
+		"desired_size":   3,
+		"min_size":       0,
+		"max_size":       12,
+		"instance_types": []string{"t3.large"},
+		"capacity_type":  "SPOT",
+		"disk_size":      50,
+	}, rendered["node_groups"].(map[string]interface{})["spot"])
+	assert.Equal(t, []map[string]interface{}{{"name": "test-ou", "ou_id": "ou-test-001", "permissions": []string{"admin"}}},
+		rendered["organizational_units"])
+	assert.Equal(t, "postgres", rendered["rds_config"].(map[string]interface{})["engine"])
+
+	// Overrides do not leak into later baselines
+	assert.Len(t, BaselineRoot("vpc-primary", "vpc-secondary").NodeGroups, 1)
+
+	// An explicitly empty OU list is passed through rather than falling back to the default OUs
+	vars.OrganizationalUnits = OUs{}
+	assert.Equal(t, []map[string]interface{}{}, vars.Vars()["organizational_units"])
+}
+
+func TestRootVarsValidate(t *testing.T) {
+	t.Parallel()
+
+	vars := BaselineRoot("", "vpc-secondary")
+	vars.SecondaryRegion = "us-east-1"
+	vars.PrimaryAvailabilityZones = []string{"us-east-1a", "us-west-2b"}
+	vars.NodeGroups["bad"] = BaselineNodeGroup().WithCapacityType("RESERVED")
+
+	err := vars.Validate()
+	require.Error(t, err)
+	for _, message := range []string{
+		"primary_vpc_id is required",
+		"primary_region and secondary_region are both us-east-1",
+		"primary_availability_zones must list exactly 3 availability zones, got 2",
+		`primary_availability_zones: "us-west-2b" is not an availability zone of us-east-1`,
+		`node group bad: capacity_type "RESERVED" must be one of ON_DEMAND, SPOT`,
+	} {
+		assert.Contains(t, err.Error(), message)
+	}
+}
+
+func TestRegionalVars(t *testing.T) {
+	t.Parallel()
+
+	vars := BaselineRegional("us-west-2", "vpc-87654321")
+	vars.CreateRDS, vars.RDSConfig = false, nil
+	require.NoError(t, vars.Validate())
+
+	rendered := vars.Vars()
+	assert.Equal(t, false, rendered["create_rds"])
+	assert.NotContains(t, rendered, "rds_config")
+	assert.NotContains(t, rendered, "rds_primary_arn")
+	assert.NotContains(t, rendered, "rds_primary_region")
+
+	vars.RDSPrimaryARN, vars.RDSPrimaryRegion = "arn:aws:rds:us-east-1:123456789012:db:primary-db", "us-east-1"
+	assert.Equal(t, "us-east-1", vars.Vars()["rds_primary_region"])
+
+	vars.Region, vars.RDSPrimaryRegion = "uswest2", "useast1"
+	vars.AvailabilityZones = nil
+	err := vars.Validate()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `region "uswest2" is not a valid AWS region`)
+	assert.Contains(t, err.Error(), `rds_primary_region "useast1" is not a valid AWS region`)
+	assert.Contains(t, err.Error(), "availability_zones must list exactly 3 availability zones, got 0")
+}
@@ -0,0 +1,44 @@
+package topology
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/your-org/multi-az-eks-cluster/test/crossregion"
+)
+
+// AssertConnected checks that every node of the graph is reachable from every other, ignoring edge direction, listing
+// the components when it is not.
+func AssertConnected(t testing.TestingT, g *Graph) bool {
+	components := g.Components()
+	if len(components) == 1 {
+		return true
+	}
+	var b strings.Builder
+	for i, component := range components {
+		fmt.Fprintf(&b, "  %d: %s\n", i+1, strings.Join(component, ", "))
+	}
+	return assert.Fail(t, fmt.Sprintf("topology has %d components, expected 1:\n%s", len(components), b.String()))
+}
+
+// AssertReplicaEdges checks that the graph has a replication edge and that each one runs from a replica in the
+// secondary region to a primary in the primary region.
+func AssertReplicaEdges(t testing.TestingT, g *Graph) bool {
+	edges := g.EdgesOfKind(EdgeReplicates)
+	if len(edges) == 0 {
+		return assert.Fail(t, "topology has no "+EdgeReplicates+" edge")
+	}
+	ok := true
+	for _, edge := range edges {
+		from, _ := g.Node(edge.From)
+		to, _ := g.Node(edge.To)
+		ok = assert.Truef(t, from.Kind == KindDBReplica && from.Module == crossregion.SecondaryModule &&
+			to.Kind == KindDBPrimary && to.Module == crossregion.PrimaryModule,
+			"%s -> %s: replication must run from a %s in %s to a %s in %s", edge.From, edge.To,
+			KindDBReplica, crossregion.SecondaryModule, KindDBPrimary, crossregion.PrimaryModule) && ok
+	}
+	return ok
+}
@@ -0,0 +1,210 @@
+package topology
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Formats are the output formats Write supports.
+var Formats = []string{"dot", "mermaid", "json"}
+
+// kindTitles name the node kinds in rendered labels.
+var kindTitles = map[string]string{
+	KindRegion:         "region",
+	KindVPC:            "VPC",
+	KindCluster:        "EKS cluster",
+	KindNodeGroup:      "node group",
+	KindDBPrimary:      "RDS primary",
+	KindDBReplica:      "RDS read replica",
+	KindRole:           "IAM role",
+	KindServiceAccount: "service account",
+}
+
+// dotShapes and the Mermaid shapes below draw each kind of node differently.
+var dotShapes = map[string]string{
+	KindRegion:         "folder",
+	KindVPC:            "box",
+	KindCluster:        "component",
+	KindNodeGroup:      "box3d",
+	KindDBPrimary:      "cylinder",
+	KindDBReplica:      "cylinder",
+	KindRole:           "hexagon",
+	KindServiceAccount: "ellipse",
+}
+
+var mermaidShapes = map[string][2]string{
+	KindRegion:         {"[/", "/]"},
+	KindCluster:        {"[[", "]]"},
+	KindDBPrimary:      {"[(", ")]"},
+	KindDBReplica:      {"[(", ")]"},
+	KindRole:           {"{{", "}}"},
+	KindServiceAccount: {"([", "])"},
+}
+
+var dotEdgeStyles = map[string]string{
+	EdgePeering:    `style=dashed, dir=both`,
+	EdgeReplicates: `style=bold, color=blue`,
+	EdgeAssumes:    `style=dotted`,
+}
+
+var mermaidArrows = map[string]string{
+	EdgePeering:    "<-.->",
+	EdgeReplicates: "==>",
+	EdgeAssumes:    "-.->",
+}
+
+// Write renders the graph in one of Formats.
+func (g *Graph) Write(w io.Writer, format string) error {
+	switch format {
+	case "dot":
+		return g.WriteDOT(w)
+	case "mermaid":
+		return g.WriteMermaid(w)
+	case "json":
+		return g.WriteJSON(w)
+	default:
+		return fmt.Errorf("unknown format %q, must be one of %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+// WriteJSON renders the graph as indented JSON with its nodes and edges.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(g)
+}
+
+// WriteDOT renders the graph as a Graphviz digraph with a cluster per region module.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph topology {\n  rankdir=LR;\n  node [fontname=\"Helvetica\", fontsize=10];\n  edge [fontsize=9];\n")
+	for i, module := range g.modules() {
+		nodes := g.nodesIn(module)
+		if module == "" {
+			for _, node := range nodes {
+				writeDOTNode(&b, node, "  ")
+			}
+			continue
+		}
+		fmt.Fprintf(&b, "\n  subgraph cluster_%d {\n    label=%s;\n", i, dotQuote(g.regionLabel(module)))
+		for _, node := range nodes {
+			writeDOTNode(&b, node, "    ")
+		}
+		b.WriteString("  }\n")
+	}
+	b.WriteString("\n")
+	for _, edge := range g.Edges {
+		attributes := "label=" + dotQuote(edge.Kind)
+		if style, ok := dotEdgeStyles[edge.Kind]; ok {
+			attributes += ", " + style
+		}
+		fmt.Fprintf(&b, "  %s -> %s [%s];\n", dotQuote(edge.From), dotQuote(edge.To), attributes)
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeDOTNode(b *strings.Builder, node Node, indent string) {
+	fmt.Fprintf(b, "%s%s [label=%s, shape=%s];\n", indent, dotQuote(node.ID), dotQuote(strings.Join(describe(node), "\n")),
+		dotShapes[node.Kind])
+}
+
+// WriteMermaid renders the graph as a Mermaid flowchart with a subgraph per region module.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	ids := make(map[string]string, len(g.Nodes))
+	for i, node := range g.Nodes {
+		ids[node.ID] = fmt.Sprintf("n%d", i)
+	}
+
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, module := range g.modules() {
+		nodes := g.nodesIn(module)
+		indent := "  "
+		if module != "" {
+			fmt.Fprintf(&b, "  subgraph region%d[%s]\n", i, mermaidQuote(g.regionLabel(module)))
+			indent = "    "
+		}
+		for _, node := range nodes {
+			shape, ok := mermaidShapes[node.Kind]
+			if !ok {
+				shape = [2]string{"[", "]"}
+			}
+			fmt.Fprintf(&b, "%s%s%s%s%s\n", indent, ids[node.ID], shape[0],
+				mermaidQuote(strings.Join(describe(node), "<br/>")), shape[1])
+		}
+		if module != "" {
+			b.WriteString("  end\n")
+		}
+	}
+	for _, edge := range g.Edges {
+		arrow, ok := mermaidArrows[edge.Kind]
+		if !ok {
+			arrow = "-->"
+		}
+		fmt.Fprintf(&b, "  %s %s|%s| %s\n", ids[edge.From], arrow, edge.Kind, ids[edge.To])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// modules returns the region modules of the nodes in the order they first appear, nodes outside one first.
+func (g *Graph) modules() []string {
+	var modules []string
+	seen := map[string]bool{}
+	for _, node := range g.Nodes {
+		if !seen[node.Module] {
+			seen[node.Module] = true
+			modules = append(modules, node.Module)
+		}
+	}
+	sort.SliceStable(modules, func(i, j int) bool { return modules[i] == "" && modules[j] != "" })
+	return modules
+}
+
+func (g *Graph) nodesIn(module string) []Node {
+	var nodes []Node
+	for _, node := range g.Nodes {
+		if node.Module == module {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// regionLabel names a region module after its region, e.g. "us-east-1 (module.primary_region)".
+func (g *Graph) regionLabel(module string) string {
+	if region, ok := g.Node(nodeID(KindRegion, module)); ok && region.Label != module {
+		return region.Label + " (" + module + ")"
+	}
+	return module
+}
+
+// describe returns the lines of a node's label: its name, its kind and its attributes in name order.
+func describe(node Node) []string {
+	lines := []string{node.Label, kindTitles[node.Kind]}
+	names := make([]string, 0, len(node.Attributes))
+	for name := range node.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if value := node.Attributes[name]; value != "" {
+			lines = append(lines, name+": "+value)
+		}
+	}
+	return lines
+}
+
+func dotQuote(s string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + replacer.Replace(s) + `"`
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
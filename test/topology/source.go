@@ -0,0 +1,96 @@
+package topology
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// sourceServiceAccounts returns the service accounts named by string literals in the trust policy of each
+// aws_iam_role of the module instance at the address, keyed by "aws_iam_role.<name>". The module's directory is found
+// by following the source of each module call from rootDir through the plan's configuration.
+func sourceServiceAccounts(plan *planassert.Plan, rootDir, module string) (map[string][]string, error) {
+	dir, err := moduleDir(plan, rootDir, module)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := map[string][]string{}
+	parser := hclparse.NewParser()
+	for _, path := range paths {
+		file, diags := parser.ParseHCLFile(path)
+		if diags.HasErrors() {
+			return nil, diags
+		}
+		body, ok := file.Body.(*hclsyntax.Body)
+		if !ok {
+			return nil, fmt.Errorf("%s: unexpected body type %T", path, file.Body)
+		}
+		for _, block := range body.Blocks {
+			if block.Type != "resource" || len(block.Labels) != 2 || block.Labels[0] != "aws_iam_role" {
+				continue
+			}
+			trust, ok := block.Body.Attributes["assume_role_policy"]
+			if !ok {
+				continue
+			}
+			set := map[string]bool{}
+			hclsyntax.VisitAll(trust.Expr, func(node hclsyntax.Node) hcl.Diagnostics {
+				literal, ok := node.(*hclsyntax.LiteralValueExpr)
+				if !ok || literal.Val.Type() != cty.String {
+					return nil
+				}
+				if account, ok := strings.CutPrefix(literal.Val.AsString(), serviceAccountPrefix); ok {
+					set[strings.Replace(account, ":", "/", 1)] = true
+				}
+				return nil
+			})
+			key := "aws_iam_role." + block.Labels[1]
+			for account := range set {
+				accounts[key] = append(accounts[key], account)
+			}
+			sort.Strings(accounts[key])
+		}
+	}
+	return accounts, nil
+}
+
+// moduleDir resolves the directory of a module instance, e.g. module.primary_region.module.iam_roles, from the module
+// call sources in the plan's configuration.
+func moduleDir(plan *planassert.Plan, rootDir, module string) (string, error) {
+	raw := plan.Raw()
+	if raw == nil || raw.RawPlan.Config == nil || raw.RawPlan.Config.RootModule == nil {
+		return "", fmt.Errorf("the plan has no configuration to find the source of %s in", module)
+	}
+	dir := rootDir
+	config := raw.RawPlan.Config.RootModule
+	parts := strings.Split(module, ".")
+	for i := 0; i+1 < len(parts); i += 2 {
+		name := parts[i+1]
+		if bracket := strings.IndexByte(name, '['); bracket >= 0 {
+			name = name[:bracket]
+		}
+		call, ok := config.ModuleCalls[name]
+		if parts[i] != "module" || !ok || call == nil || call.Module == nil {
+			return "", fmt.Errorf("the plan's configuration has no module call for %s", module)
+		}
+		if !strings.HasPrefix(call.Source, "./") && !strings.HasPrefix(call.Source, "../") {
+			return "", fmt.Errorf("%s has source %q, only local modules can be read", module, call.Source)
+		}
+		dir = filepath.Join(dir, call.Source)
+		config = call.Module
+	}
+	return dir, nil
+}
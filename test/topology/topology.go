@@ -0,0 +1,404 @@
+// Package topology builds a graph of the multi-region deployment a root module plan or state describes: the regions
+// and their VPCs joined by the peering connection, the EKS cluster and node groups in each, the RDS primary and its
+// cross-region replica, and the IRSA roles with the service accounts that can assume them. The graph renders as DOT,
+// Mermaid or JSON for design reviews.
+package topology
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/your-org/multi-az-eks-cluster/test/crossregion"
+	"github.com/your-org/multi-az-eks-cluster/test/iampolicy"
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// Node kinds.
+const (
+	KindRegion         = "region"
+	KindVPC            = "vpc"
+	KindCluster        = "eks_cluster"
+	KindNodeGroup      = "node_group"
+	KindDBPrimary      = "rds_primary"
+	KindDBReplica      = "rds_replica"
+	KindRole           = "iam_role"
+	KindServiceAccount = "service_account"
+)
+
+// Edge kinds. Edges point from the container to what it contains, from the peering requester to the accepter, from a
+// replica to the database it replicates, from a role to the cluster whose OIDC provider it trusts, and from a service
+// account to the role it assumes.
+const (
+	EdgeContains   = "contains"
+	EdgePeering    = "peering"
+	EdgeReplicates = "replicates"
+	EdgeTrusts     = "trusts_oidc"
+	EdgeAssumes    = "assumes"
+)
+
+// serviceAccountPrefix starts the sub claim of a Kubernetes service account token.
+const serviceAccountPrefix = "system:serviceaccount:"
+
+// Node is a component of the deployment.
+type Node struct {
+	ID    string `json:"id"`
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+
+	// Module is the region module the node belongs to, empty for nodes outside one.
+	Module string `json:"module,omitempty"`
+
+	// Address is the resource the node was built from, empty for regions and service accounts.
+	Address string `json:"address,omitempty"`
+
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// Edge is a directed relationship between two nodes.
+type Edge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Kind string `json:"kind"`
+}
+
+// Graph is the topology of a deployment. Nodes are in the order they were added, region by region.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+
+	index map[string]int
+}
+
+// Node returns the node with the given ID.
+func (g *Graph) Node(id string) (Node, bool) {
+	i, ok := g.index[id]
+	if !ok {
+		return Node{}, false
+	}
+	return g.Nodes[i], true
+}
+
+// EdgesOfKind returns the edges of the given kind.
+func (g *Graph) EdgesOfKind(kind string) []Edge {
+	var out []Edge
+	for _, edge := range g.Edges {
+		if edge.Kind == kind {
+			out = append(out, edge)
+		}
+	}
+	return out
+}
+
+// Components returns the IDs of the nodes in each connected component of the graph, ignoring edge direction. A graph
+// of a complete deployment has exactly one.
+func (g *Graph) Components() [][]string {
+	neighbours := map[string][]string{}
+	for _, edge := range g.Edges {
+		neighbours[edge.From] = append(neighbours[edge.From], edge.To)
+		neighbours[edge.To] = append(neighbours[edge.To], edge.From)
+	}
+	seen := map[string]bool{}
+	var components [][]string
+	for _, node := range g.Nodes {
+		if seen[node.ID] {
+			continue
+		}
+		var component []string
+		queue := []string{node.ID}
+		seen[node.ID] = true
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			component = append(component, id)
+			for _, next := range neighbours[id] {
+				if !seen[next] {
+					seen[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+		sort.Strings(component)
+		components = append(components, component)
+	}
+	return components
+}
+
+func (g *Graph) add(node Node) {
+	if g.index == nil {
+		g.index = map[string]int{}
+	}
+	if _, ok := g.index[node.ID]; ok {
+		return
+	}
+	g.index[node.ID] = len(g.Nodes)
+	g.Nodes = append(g.Nodes, node)
+}
+
+func (g *Graph) connect(from, to, kind string) {
+	for _, edge := range g.Edges {
+		if edge == (Edge{From: from, To: to, Kind: kind}) {
+			return
+		}
+	}
+	g.Edges = append(g.Edges, Edge{From: from, To: to, Kind: kind})
+}
+
+// Options says where to find what the plan does not know.
+type Options struct {
+	// SourceDir is the directory of the root module. When set, the service accounts of roles whose trust policy is
+	// only known after apply are read from the module source instead.
+	SourceDir string
+}
+
+// Modules are the region modules of the root module, primary first.
+var Modules = []string{crossregion.PrimaryModule, crossregion.SecondaryModule}
+
+// Build reads the topology of a root module plan, or of a state indexed with planassert.ParseStateJSON.
+func Build(plan *planassert.Plan, options Options) (*Graph, error) {
+	g := &Graph{}
+	regions := map[string]crossregion.Region{}
+	for _, module := range Modules {
+		region, err := crossregion.Extract(plan, module)
+		if err != nil {
+			return nil, err
+		}
+		regions[module] = region
+		if err := addRegion(g, plan, region, options); err != nil {
+			return nil, err
+		}
+	}
+
+	if peering, ok := plan.Resource(crossregion.PeeringConnection); ok && !peering.Destroyed() {
+		requester := vpcFor(regions, stringAttribute(peering, "vpc_id"))
+		accepter := vpcFor(regions, stringAttribute(peering, "peer_vpc_id"))
+		if requester == "" || accepter == "" {
+			return nil, fmt.Errorf("%s does not join the VPCs of the regions", crossregion.PeeringConnection)
+		}
+		g.connect(requester, accepter, EdgePeering)
+	}
+
+	for _, module := range Modules {
+		for _, replica := range plan.Match(module + ".module.rds[*].aws_db_instance.replica[*]") {
+			source, err := replicaSource(plan, replica, module)
+			if err != nil {
+				return nil, err
+			}
+			g.connect(nodeID(KindDBReplica, replica.Address), nodeID(KindDBPrimary, source.Address), EdgeReplicates)
+		}
+	}
+	return g, nil
+}
+
+func addRegion(g *Graph, plan *planassert.Plan, region crossregion.Region, options Options) error {
+	module := region.Module
+	regionID := nodeID(KindRegion, module)
+	vpcID := nodeID(KindVPC, module)
+	label := region.Name
+	if label == "" {
+		label = module
+	}
+	g.add(Node{ID: regionID, Kind: KindRegion, Label: label, Module: module})
+	g.add(Node{ID: vpcID, Kind: KindVPC, Label: region.VPCID, Module: module})
+	g.connect(regionID, vpcID, EdgeContains)
+
+	clusterAddress := module + ".module.eks.aws_eks_cluster.main"
+	cluster, _ := plan.Resource(clusterAddress)
+	clusterID := nodeID(KindCluster, clusterAddress)
+	g.add(Node{
+		ID: clusterID, Kind: KindCluster, Label: stringAttribute(cluster, "name"), Module: module, Address: clusterAddress,
+		Attributes: map[string]string{"version": region.KubernetesVersion},
+	})
+	g.connect(vpcID, clusterID, EdgeContains)
+
+	for _, group := range plan.Match(module + ".module.node_groups.aws_eks_node_group.main[*]") {
+		id := nodeID(KindNodeGroup, group.Address)
+		g.add(Node{
+			ID: id, Kind: KindNodeGroup, Label: fmt.Sprint(group.Index), Module: module, Address: group.Address,
+			Attributes: map[string]string{
+				"capacity_type":  stringAttribute(group, "capacity_type"),
+				"instance_types": strings.Join(stringsAttribute(group, "instance_types"), ", "),
+				"desired_size":   stringAttribute(group, "scaling_config.0.desired_size"),
+			},
+		})
+		g.connect(clusterID, id, EdgeContains)
+	}
+
+	for _, db := range plan.OfType("aws_db_instance") {
+		if !strings.HasPrefix(db.Address, module+".") {
+			continue
+		}
+		kind := KindDBPrimary
+		if db.Name == "replica" {
+			kind = KindDBReplica
+		}
+		id := nodeID(kind, db.Address)
+		g.add(Node{
+			ID: id, Kind: kind, Label: stringAttribute(db, "identifier"), Module: module, Address: db.Address,
+			Attributes: map[string]string{
+				"engine":         stringAttribute(db, "engine"),
+				"instance_class": stringAttribute(db, "instance_class"),
+			},
+		})
+		g.connect(vpcID, id, EdgeContains)
+	}
+
+	return addRoles(g, plan, module, clusterID, options)
+}
+
+// addRoles adds the IRSA roles of the region's iam_roles module, each trusting the cluster's OIDC provider, and the
+// service accounts that can assume them.
+func addRoles(g *Graph, plan *planassert.Plan, module, clusterID string, options Options) error {
+	rolesModule := module + ".module.iam_roles"
+	var fromSource map[string][]string
+	for _, role := range plan.OfType("aws_iam_role") {
+		if role.ModuleAddress != rolesModule {
+			continue
+		}
+		id := nodeID(KindRole, role.Address)
+		label := stringAttribute(role, "name")
+		if label == crossregion.UnknownValue {
+			label = role.Type + "." + role.Name
+			if role.Index != nil {
+				label += fmt.Sprintf("[%q]", fmt.Sprint(role.Index))
+			}
+		}
+		g.add(Node{ID: id, Kind: KindRole, Label: label, Module: module, Address: role.Address})
+		g.connect(id, clusterID, EdgeTrusts)
+
+		var accounts []string
+		if !role.IsUnknown("assume_role_policy") {
+			document, err := iampolicy.FromPlan(plan, role.Address, "assume_role_policy")
+			if err != nil {
+				return err
+			}
+			accounts = serviceAccounts(document)
+		} else if options.SourceDir != "" {
+			if fromSource == nil {
+				var err error
+				if fromSource, err = sourceServiceAccounts(plan, options.SourceDir, rolesModule); err != nil {
+					return err
+				}
+			}
+			accounts = fromSource[role.Type+"."+role.Name]
+		}
+		for _, account := range accounts {
+			accountID := nodeID(KindServiceAccount, module+":"+account)
+			g.add(Node{ID: accountID, Kind: KindServiceAccount, Label: account, Module: module})
+			g.connect(accountID, id, EdgeAssumes)
+		}
+	}
+	return nil
+}
+
+// serviceAccounts returns the namespace/name of each service account whose token the trust policy accepts.
+func serviceAccounts(document *iampolicy.Document) []string {
+	set := map[string]bool{}
+	for _, statement := range document.Statements {
+		for _, keys := range statement.Condition {
+			for key, values := range keys {
+				if !strings.HasSuffix(key, ":sub") {
+					continue
+				}
+				for _, value := range values {
+					if account, ok := strings.CutPrefix(value, serviceAccountPrefix); ok {
+						set[strings.Replace(account, ":", "/", 1)] = true
+					}
+				}
+			}
+		}
+	}
+	accounts := make([]string, 0, len(set))
+	for account := range set {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+	return accounts
+}
+
+// replicaSource returns the primary database the replica replicates: the one whose ARN is its replicate_source_db,
+// or, while that is only known after apply, the primary of the region module the root module passes as the region's
+// rds_primary_arn.
+func replicaSource(plan *planassert.Plan, replica *planassert.Resource, module string) (*planassert.Resource, error) {
+	primaries := plan.Match("module.*.module.rds[*].aws_db_instance.main[*]")
+	if source := stringAttribute(replica, "replicate_source_db"); source != "" && source != crossregion.UnknownValue {
+		for _, primary := range primaries {
+			if arn := stringAttribute(primary, "arn"); arn == source || stringAttribute(primary, "identifier") == source {
+				return primary, nil
+			}
+		}
+		return nil, fmt.Errorf("%s replicates %s, which is not in the plan", replica.Address, source)
+	}
+
+	sourceModule := moduleReferencedBy(plan, module, "rds_primary_arn")
+	for _, primary := range primaries {
+		if sourceModule != "" && strings.HasPrefix(primary.Address, sourceModule+".") {
+			return primary, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: cannot tell which database it replicates", replica.Address)
+}
+
+// moduleReferencedBy returns the root module call the argument of the module call references, e.g.
+// module.primary_region for module.secondary_region's rds_primary_arn, or "" when the plan carries no configuration.
+func moduleReferencedBy(plan *planassert.Plan, module, argument string) string {
+	raw := plan.Raw()
+	if raw == nil || raw.RawPlan.Config == nil || raw.RawPlan.Config.RootModule == nil {
+		return ""
+	}
+	call, ok := raw.RawPlan.Config.RootModule.ModuleCalls[strings.TrimPrefix(module, "module.")]
+	if !ok || call == nil {
+		return ""
+	}
+	expression, ok := call.Expressions[argument]
+	if !ok || expression == nil || expression.ExpressionData == nil {
+		return ""
+	}
+	for _, reference := range expression.References {
+		parts := strings.SplitN(reference, ".", 3)
+		if len(parts) >= 2 && parts[0] == "module" {
+			return "module." + parts[1]
+		}
+	}
+	return ""
+}
+
+func vpcFor(regions map[string]crossregion.Region, vpc string) string {
+	for _, module := range Modules {
+		if region, ok := regions[module]; ok && region.VPCID == vpc {
+			return nodeID(KindVPC, module)
+		}
+	}
+	return ""
+}
+
+func nodeID(kind, key string) string {
+	return kind + ":" + key
+}
+
+func stringAttribute(resource *planassert.Resource, path string) string {
+	if resource == nil {
+		return ""
+	}
+	if resource.IsUnknown(path) {
+		return crossregion.UnknownValue
+	}
+	value, ok := resource.Attribute(path)
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+func stringsAttribute(resource *planassert.Resource, path string) []string {
+	if resource.IsUnknown(path) {
+		return []string{crossregion.UnknownValue}
+	}
+	value, _ := resource.Attribute(path)
+	list, _ := value.([]interface{})
+	out := make([]string, 0, len(list))
+	for _, element := range list {
+		out = append(out, fmt.Sprint(element))
+	}
+	return out
+}
@@ -0,0 +1,352 @@
+package topology
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/crossregion"
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// recordingT collects failures without failing the enclosing test.
+type recordingT struct {
+	failed bool
+}
+
+func (r *recordingT) Fail()                                  { r.failed = true }
+func (r *recordingT) FailNow()                               { r.failed = true }
+func (r *recordingT) Fatal(args ...interface{})              { r.failed = true }
+func (r *recordingT) Fatalf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Error(args ...interface{})              { r.failed = true }
+func (r *recordingT) Errorf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Name() string                           { return "recordingT" }
+
+type resource = map[string]interface{}
+
+const (
+	primaryARN = "arn:aws:rds:us-east-1:123456789012:db:primary-cluster-db"
+	albTrust   = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"sts:AssumeRoleWithWebIdentity",` +
+		`"Principal":{"Federated":"arn:aws:iam::123456789012:oidc-provider/oidc.eks.us-east-1.amazonaws.com/id/ABC"},` +
+		`"Condition":{"StringEquals":{"oidc.eks.us-east-1.amazonaws.com/id/ABC:sub":"system:serviceaccount:kube-system:aws-load-balancer-controller"}}}]}`
+)
+
+func managed(address, resourceType, name string, index interface{}, values resource) resource {
+	r := resource{"address": address, "mode": "managed", "type": resourceType, "name": name, "values": values}
+	if index != nil {
+		r["index"] = index
+	}
+	return r
+}
+
+// regionResources returns the resources a region module plans with one node group, its database and the ALB
+// controller role.
+func regionResources(module, region, vpc, db string) []resource {
+	cluster := region[3:7] + "-cluster"
+	return []resource{
+		managed(module+".module.eks.aws_eks_cluster.main", "aws_eks_cluster", "main", nil,
+			resource{"name": cluster, "version": "1.28", "tags": resource{"Region": region}}),
+		managed(module+".module.eks.aws_security_group.cluster", "aws_security_group", "cluster", nil, resource{"vpc_id": vpc}),
+		managed(module+`.module.node_groups.aws_eks_node_group.main["general"]`, "aws_eks_node_group", "main", "general",
+			resource{"instance_types": []string{"t3.large"}, "capacity_type": "ON_DEMAND", "version": "1.28",
+				"scaling_config": []resource{{"desired_size": 3, "min_size": 1, "max_size": 5}}}),
+		managed(module+".module.rds[0].aws_db_instance."+db+"[0]", "aws_db_instance", db, 0,
+			resource{"identifier": cluster + "-db", "engine": "postgres", "instance_class": "db.t3.medium"}),
+		managed(module+".module.iam_roles.aws_iam_role.alb_controller", "aws_iam_role", "alb_controller", nil,
+			resource{"name": cluster + "-alb-controller", "assume_role_policy": albTrust}),
+	}
+}
+
+// samplePlan renders a root module plan in which the secondary region's trust policy and replication source are only
+// known after apply, letting the test edit the plan first.
+func samplePlan(t *testing.T, edit func(plan resource)) *planassert.Plan {
+	t.Helper()
+
+	primary := regionResources(crossregion.PrimaryModule, "us-east-1", "vpc-primary", "main")
+	primary[3]["values"].(resource)["arn"] = primaryARN
+	secondary := regionResources(crossregion.SecondaryModule, "us-west-2", "vpc-secondary", "replica")
+	delete(secondary[4]["values"].(resource), "assume_role_policy")
+
+	regionCall := func(expressions resource) resource {
+		return resource{
+			"source":      "./modules/regional-eks",
+			"expressions": expressions,
+			"module":      resource{"module_calls": resource{"iam_roles": resource{"source": "../iam-roles", "module": resource{}}}},
+		}
+	}
+	plan := resource{
+		"format_version": "1.2",
+		"planned_values": resource{"root_module": resource{
+			"resources": []resource{
+				managed(crossregion.PeeringConnection, "aws_vpc_peering_connection", "primary_to_secondary", nil,
+					resource{"vpc_id": "vpc-primary", "peer_vpc_id": "vpc-secondary", "peer_region": "us-west-2"}),
+			},
+			"child_modules": []resource{
+				{"address": crossregion.PrimaryModule, "resources": primary},
+				{"address": crossregion.SecondaryModule, "resources": secondary},
+			},
+		}},
+		"resource_changes": []resource{
+			{
+				"address":        crossregion.SecondaryModule + ".module.iam_roles.aws_iam_role.alb_controller",
+				"module_address": crossregion.SecondaryModule + ".module.iam_roles",
+				"mode":           "managed", "type": "aws_iam_role", "name": "alb_controller",
+				"change": resource{"actions": []string{"create"}, "after_unknown": resource{"assume_role_policy": true}},
+			},
+			{
+				"address":        crossregion.SecondaryModule + ".module.rds[0].aws_db_instance.replica[0]",
+				"module_address": crossregion.SecondaryModule + ".module.rds[0]",
+				"mode":           "managed", "type": "aws_db_instance", "name": "replica", "index": 0,
+				"change": resource{"actions": []string{"create"}, "after_unknown": resource{"replicate_source_db": true}},
+			},
+		},
+		"configuration": resource{"root_module": resource{"module_calls": resource{
+			"primary_region": regionCall(resource{}),
+			"secondary_region": regionCall(resource{"rds_primary_arn": resource{
+				"references": []string{"module.primary_region.rds_instance_arn", "module.primary_region"},
+			}}),
+		}}},
+	}
+	if edit != nil {
+		edit(plan)
+	}
+	nest(plan["planned_values"].(resource))
+
+	data, err := json.Marshal(plan)
+	require.NoError(t, err)
+	parsed, err := planassert.ParseJSON(data)
+	require.NoError(t, err)
+	return parsed
+}
+
+// nest moves the resources of each region module into child modules by address, as terraform show -json renders
+// them.
+func nest(values resource) {
+	root := values["root_module"].(resource)
+	for _, region := range root["child_modules"].([]resource) {
+		children := map[string][]resource{}
+		var order []string
+		for _, r := range region["resources"].([]resource) {
+			parts := strings.Split(r["address"].(string), ".")
+			child := strings.Join(parts[:len(parts)-2], ".")
+			if _, ok := children[child]; !ok {
+				order = append(order, child)
+			}
+			children[child] = append(children[child], r)
+		}
+		var childModules []resource
+		for _, child := range order {
+			childModules = append(childModules, resource{"address": child, "resources": children[child]})
+		}
+		region["resources"] = []resource{}
+		region["child_modules"] = childModules
+	}
+}
+
+// children returns the resources of the region modules of a sample plan.
+func children(plan resource) (primary, secondary []resource) {
+	modules := plan["planned_values"].(resource)["root_module"].(resource)["child_modules"].([]resource)
+	return modules[0]["resources"].([]resource), modules[1]["resources"].([]resource)
+}
+
+func TestBuild(t *testing.T) {
+	t.Parallel()
+
+	g, err := Build(samplePlan(t, nil), Options{SourceDir: "../.."})
+	require.NoError(t, err)
+
+	kinds := map[string]int{}
+	for _, node := range g.Nodes {
+		kinds[node.Kind]++
+	}
+	assert.Equal(t, map[string]int{
+		KindRegion: 2, KindVPC: 2, KindCluster: 2, KindNodeGroup: 2, KindDBPrimary: 1, KindDBReplica: 1, KindRole: 2,
+		KindServiceAccount: 2,
+	}, kinds)
+
+	assert.Len(t, g.Components(), 1)
+	assert.True(t, AssertConnected(t, g))
+	assert.True(t, AssertReplicaEdges(t, g))
+	assert.Equal(t, []Edge{{
+		From: "rds_replica:module.secondary_region.module.rds[0].aws_db_instance.replica[0]",
+		To:   "rds_primary:module.primary_region.module.rds[0].aws_db_instance.main[0]",
+		Kind: EdgeReplicates,
+	}}, g.EdgesOfKind(EdgeReplicates))
+	assert.Equal(t, []Edge{{From: "vpc:module.primary_region", To: "vpc:module.secondary_region", Kind: EdgePeering}},
+		g.EdgesOfKind(EdgePeering))
+
+	// The primary's service account comes from its trust policy, the secondary's from the iam-roles module source
+	for _, module := range Modules {
+		account, ok := g.Node("service_account:" + module + ":kube-system/aws-load-balancer-controller")
+		require.True(t, ok, module)
+		assert.Equal(t, module, account.Module)
+		assert.Contains(t, g.Edges, Edge{
+			From: account.ID, To: "iam_role:" + module + ".module.iam_roles.aws_iam_role.alb_controller", Kind: EdgeAssumes,
+		})
+	}
+
+	group, ok := g.Node(`node_group:module.primary_region.module.node_groups.aws_eks_node_group.main["general"]`)
+	require.True(t, ok)
+	assert.Equal(t, "general", group.Label)
+	assert.Equal(t, map[string]string{"capacity_type": "ON_DEMAND", "instance_types": "t3.large", "desired_size": "3"},
+		group.Attributes)
+}
+
+func TestBuildWithoutSource(t *testing.T) {
+	t.Parallel()
+
+	// Without the module source the secondary role trusting an unknown policy has no service accounts
+	g, err := Build(samplePlan(t, nil), Options{})
+	require.NoError(t, err)
+	assert.Len(t, g.EdgesOfKind(EdgeAssumes), 1)
+	assert.True(t, AssertConnected(t, g))
+}
+
+func TestBuildWithoutPeering(t *testing.T) {
+	t.Parallel()
+
+	plan := samplePlan(t, func(plan resource) {
+		plan["planned_values"].(resource)["root_module"].(resource)["resources"] = []resource{}
+	})
+	g, err := Build(plan, Options{})
+	require.NoError(t, err)
+
+	// Replication still joins the regions, but only through their databases
+	assert.Len(t, g.Components(), 1)
+	assert.Empty(t, g.EdgesOfKind(EdgePeering))
+
+	plan = samplePlan(t, func(plan resource) {
+		plan["planned_values"].(resource)["root_module"].(resource)["resources"] = []resource{}
+		_, secondary := children(plan)
+		secondary[3]["values"].(resource)["replicate_source_db"] = primaryARN
+		plan["resource_changes"] = plan["resource_changes"].([]resource)[:1]
+		plan["configuration"] = resource{"root_module": resource{}}
+	})
+	g, err = Build(plan, Options{})
+	require.NoError(t, err)
+	assert.True(t, AssertReplicaEdges(t, g))
+
+	plan = samplePlan(t, func(plan resource) {
+		plan["planned_values"].(resource)["root_module"].(resource)["resources"] = []resource{}
+		_, secondary := children(plan)
+		secondary[3] = managed(crossregion.SecondaryModule+".module.rds[0].aws_db_instance.main[0]", "aws_db_instance",
+			"main", 0, resource{"identifier": "west-cluster-db"})
+		plan["resource_changes"] = plan["resource_changes"].([]resource)[:1]
+	})
+	g, err = Build(plan, Options{})
+	require.NoError(t, err)
+	require.Len(t, g.Components(), 2)
+	assert.Contains(t, g.Components()[0], "region:module.primary_region")
+	assert.Contains(t, g.Components()[1], "region:module.secondary_region")
+
+	mock := &recordingT{}
+	assert.False(t, AssertConnected(mock, g))
+	assert.True(t, mock.failed)
+	mock = &recordingT{}
+	assert.False(t, AssertReplicaEdges(mock, g))
+	assert.True(t, mock.failed)
+}
+
+func TestReplicaEdgeDirection(t *testing.T) {
+	t.Parallel()
+
+	// A replica planned in the primary region, replicating the secondary's database
+	plan := samplePlan(t, func(plan resource) {
+		primary, secondary := children(plan)
+		primary[3] = managed(crossregion.PrimaryModule+".module.rds[0].aws_db_instance.replica[0]", "aws_db_instance",
+			"replica", 0, resource{"identifier": "east-cluster-db", "replicate_source_db": "arn:aws:rds:us-west-2:123456789012:db:west-cluster-db"})
+		secondary[3] = managed(crossregion.SecondaryModule+".module.rds[0].aws_db_instance.main[0]", "aws_db_instance",
+			"main", 0, resource{"identifier": "west-cluster-db", "arn": "arn:aws:rds:us-west-2:123456789012:db:west-cluster-db"})
+		plan["resource_changes"] = plan["resource_changes"].([]resource)[:1]
+	})
+	g, err := Build(plan, Options{})
+	require.NoError(t, err)
+	assert.True(t, AssertConnected(t, g))
+
+	mock := &recordingT{}
+	assert.False(t, AssertReplicaEdges(mock, g))
+	assert.True(t, mock.failed)
+}
+
+func TestReplicaSourceNotPlanned(t *testing.T) {
+	t.Parallel()
+
+	plan := samplePlan(t, func(plan resource) {
+		_, secondary := children(plan)
+		secondary[3]["values"].(resource)["replicate_source_db"] = "arn:aws:rds:us-east-1:123456789012:db:elsewhere"
+		plan["resource_changes"] = plan["resource_changes"].([]resource)[:1]
+	})
+	_, err := Build(plan, Options{})
+	assert.EqualError(t, err, "module.secondary_region.module.rds[0].aws_db_instance.replica[0] replicates "+
+		"arn:aws:rds:us-east-1:123456789012:db:elsewhere, which is not in the plan")
+}
+
+func TestBuildFromState(t *testing.T) {
+	t.Parallel()
+
+	primary := regionResources(crossregion.PrimaryModule, "us-east-1", "vpc-primary", "main")
+	primary[3]["values"].(resource)["arn"] = primaryARN
+	secondary := regionResources(crossregion.SecondaryModule, "us-west-2", "vpc-secondary", "replica")
+	secondary[3]["values"].(resource)["replicate_source_db"] = primaryARN
+	values := resource{"root_module": resource{
+		"resources": []resource{
+			managed(crossregion.PeeringConnection, "aws_vpc_peering_connection", "primary_to_secondary", nil,
+				resource{"vpc_id": "vpc-primary", "peer_vpc_id": "vpc-secondary"}),
+		},
+		"child_modules": []resource{
+			{"address": crossregion.PrimaryModule, "resources": primary},
+			{"address": crossregion.SecondaryModule, "resources": secondary},
+		},
+	}}
+	nest(values)
+	data, err := json.Marshal(resource{"format_version": "1.0", "values": values})
+	require.NoError(t, err)
+	state, err := planassert.ParseStateJSON(data)
+	require.NoError(t, err)
+
+	g, err := Build(state, Options{})
+	require.NoError(t, err)
+	assert.True(t, AssertConnected(t, g))
+	assert.True(t, AssertReplicaEdges(t, g))
+	assert.Len(t, g.EdgesOfKind(EdgeAssumes), 2)
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	g, err := Build(samplePlan(t, nil), Options{SourceDir: "../.."})
+	require.NoError(t, err)
+
+	var dot bytes.Buffer
+	require.NoError(t, g.Write(&dot, "dot"))
+	assert.True(t, strings.HasPrefix(dot.String(), "digraph topology {\n"))
+	assert.Contains(t, dot.String(), "  subgraph cluster_0 {\n    label=\"us-east-1 (module.primary_region)\";\n")
+	assert.Contains(t, dot.String(), "  subgraph cluster_1 {\n    label=\"us-west-2 (module.secondary_region)\";\n")
+	assert.Contains(t, dot.String(), `    "rds_primary:module.primary_region.module.rds[0].aws_db_instance.main[0]" `+
+		`[label="east-cluster-db\nRDS primary\nengine: postgres\ninstance_class: db.t3.medium", shape=cylinder];`)
+	assert.Contains(t, dot.String(), `    "node_group:module.primary_region.module.node_groups.aws_eks_node_group.main[\"general\"]"`)
+	assert.Contains(t, dot.String(), `  "vpc:module.primary_region" -> "vpc:module.secondary_region" [label="peering", style=dashed, dir=both];`)
+	assert.Contains(t, dot.String(), `  "rds_replica:module.secondary_region.module.rds[0].aws_db_instance.replica[0]" -> `+
+		`"rds_primary:module.primary_region.module.rds[0].aws_db_instance.main[0]" [label="replicates", style=bold, color=blue];`)
+
+	var mermaid bytes.Buffer
+	require.NoError(t, g.Write(&mermaid, "mermaid"))
+	assert.True(t, strings.HasPrefix(mermaid.String(), "flowchart LR\n  subgraph region0[\"us-east-1 (module.primary_region)\"]\n"+
+		"    n0[/\"us-east-1<br/>region\"/]\n"))
+	assert.Contains(t, mermaid.String(), "  n1 <-.->|peering| n8\n")
+	assert.Regexp(t, `  n\d+ ==>\|replicates\| n\d+\n`, mermaid.String())
+	assert.Equal(t, 2, strings.Count(mermaid.String(), "  end\n"))
+
+	var out bytes.Buffer
+	require.NoError(t, g.Write(&out, "json"))
+	var decoded Graph
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, g.Nodes, decoded.Nodes)
+	assert.Equal(t, g.Edges, decoded.Edges)
+
+	assert.EqualError(t, g.Write(&out, "svg"), `unknown format "svg", must be one of dot, mermaid, json`)
+}
@@ -0,0 +1,22 @@
+package upgrade
+
+import (
+	"github.com/gruntwork-io/terratest/modules/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+// AssertAction checks that the impact classifies the resource at the exact address with the given action, printing
+// the whole impact when it does not.
+func AssertAction(t testing.TestingT, impact *Impact, address string, action Action) bool {
+	change, ok := impact.Change(address)
+	if !assert.Truef(t, ok, "%s is in neither plan:\n%s", address, impact) {
+		return false
+	}
+	return assert.Equalf(t, action, change.Action, "action of %s:\n%s", address, impact)
+}
+
+// AssertRiskAtMost checks that the impact is no more disruptive than the limit, printing the impact when it is.
+func AssertRiskAtMost(t testing.TestingT, impact *Impact, limit Risk) bool {
+	risk := impact.Risk()
+	return assert.Falsef(t, risk.Exceeds(limit), "risk %s exceeds %s:\n%s", risk, limit, impact)
+}
@@ -0,0 +1,77 @@
+package upgrade
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// AWSProvider is the address of the AWS provider in `terraform providers schema -json`.
+const AWSProvider = "registry.terraform.io/hashicorp/aws"
+
+// CheckSchema checks ReplacedBy, RolledOutBy and IgnoredChanges against the resource schemas of the AWS provider:
+// every path must name an argument or a block of arguments of its resource type, not an attribute that is only
+// computed, which no change to the inputs can change. A provider release that renames or drops one fails the check.
+func CheckSchema(schema *tfjson.ProviderSchema) error {
+	tables := map[string]map[string][]string{
+		"ReplacedBy":     ReplacedBy,
+		"RolledOutBy":    {"aws_eks_node_group": RolledOutBy},
+		"IgnoredChanges": IgnoredChanges,
+	}
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		types := make([]string, 0, len(tables[name]))
+		for resourceType := range tables[name] {
+			types = append(types, resourceType)
+		}
+		sort.Strings(types)
+		for _, resourceType := range types {
+			resource, ok := schema.ResourceSchemas[resourceType]
+			if !ok || resource.Block == nil {
+				errs = append(errs, fmt.Errorf("%s: the provider has no resource %s", name, resourceType))
+				continue
+			}
+			for _, path := range tables[name][resourceType] {
+				if err := checkPath(resource.Block, path); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %s.%s: %w", name, resourceType, path, err))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkPath walks a dotted path down the nested blocks of a schema, skipping list indexes, to an argument or a block.
+func checkPath(block *tfjson.SchemaBlock, path string) error {
+	segments := strings.Split(path, ".")
+	for i, segment := range segments {
+		if _, err := strconv.Atoi(segment); err == nil {
+			continue
+		}
+		if attribute, ok := block.Attributes[segment]; ok {
+			switch {
+			case i != len(segments)-1:
+				return fmt.Errorf("%s is an attribute, not a block", segment)
+			case !attribute.Required && !attribute.Optional:
+				return fmt.Errorf("%s is computed by the provider, not an argument", segment)
+			}
+			return nil
+		}
+		nested, ok := block.NestedBlocks[segment]
+		if !ok || nested.Block == nil {
+			return fmt.Errorf("the schema has no argument or block %s", segment)
+		}
+		block = nested.Block
+	}
+	return nil
+}
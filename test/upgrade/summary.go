@@ -0,0 +1,187 @@
+package upgrade
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Risk rates how disruptive applying the modified plan over the baseline is.
+type Risk string
+
+const (
+	// RiskNone means nothing of Types changes.
+	RiskNone Risk = "none"
+
+	// RiskLow means updates in place that leave the nodes and databases running, or new resources.
+	RiskLow Risk = "low"
+
+	// RiskMedium means nodes are replaced: a node rollout, a replaced node group or a replaced launch template.
+	RiskMedium Risk = "medium"
+
+	// RiskHigh means a database is replaced or destroyed, losing its data, or a node group is destroyed.
+	RiskHigh Risk = "high"
+)
+
+// Risks are the risk levels, from the least to the most disruptive.
+var Risks = []Risk{RiskNone, RiskLow, RiskMedium, RiskHigh}
+
+// ParseRisk returns the risk level with the given name.
+func ParseRisk(name string) (Risk, error) {
+	for _, risk := range Risks {
+		if string(risk) == name {
+			return risk, nil
+		}
+	}
+	return "", fmt.Errorf("unknown risk %q, want one of none, low, medium, high", name)
+}
+
+// Exceeds reports whether the risk is more disruptive than the limit.
+func (r Risk) Exceeds(limit Risk) bool {
+	return r.rank() > limit.rank()
+}
+
+func (r Risk) rank() int {
+	for i, risk := range Risks {
+		if risk == r {
+			return i
+		}
+	}
+	return len(Risks)
+}
+
+// Risk rates the impact by its most disruptive change.
+func (i *Impact) Risk() Risk {
+	risk := RiskNone
+	raise := func(to Risk) {
+		if to.Exceeds(risk) {
+			risk = to
+		}
+	}
+	for _, change := range i.Changes {
+		switch {
+		case change.Action == NoOp:
+		case change.Type == "aws_db_instance" && (change.Action == Replace || change.Action == Destroy),
+			change.Type == "aws_eks_node_group" && change.Action == Destroy:
+			raise(RiskHigh)
+		case change.Rollout, change.Action == Replace, change.Action == Destroy:
+			raise(RiskMedium)
+		default:
+			raise(RiskLow)
+		}
+	}
+	return risk
+}
+
+// nouns name the resource types in the summary, singular and plural.
+var nouns = map[string][2]string{
+	"aws_eks_node_group":  {"node group", "node groups"},
+	"aws_launch_template": {"launch template", "launch templates"},
+	"aws_db_instance":     {"DB instance", "DB instances"},
+}
+
+// verbs are the actions the summary reports, from the most to the least disruptive.
+var verbs = []struct {
+	action Action
+	verb   string
+}{
+	{Destroy, "destroys"},
+	{Replace, "replaces"},
+	{Update, "updates"},
+	{Create, "creates"},
+}
+
+// Summary describes the impact in one sentence, e.g. "changing node_groups.general.disk_size updates 2 launch templates
+// and 2 node groups and triggers a node rollout in 2 regions".
+func (i *Impact) Summary() string {
+	subject := "the change"
+	if len(i.Variables) > 0 {
+		subject = "changing " + strings.Join(i.Variables, ", ")
+	}
+
+	var clauses []string
+	for _, verb := range verbs {
+		var objects []string
+		for _, resourceType := range Types {
+			if n := i.count(verb.action, resourceType); n > 0 {
+				noun := nouns[resourceType][1]
+				if n == 1 {
+					noun = nouns[resourceType][0]
+				}
+				objects = append(objects, fmt.Sprintf("%d %s", n, noun))
+			}
+		}
+		if len(objects) > 0 {
+			clauses = append(clauses, verb.verb+" "+list(objects))
+		}
+	}
+
+	regions := map[string]bool{}
+	for _, change := range i.Changes {
+		if change.Rollout {
+			regions[change.Region] = true
+		}
+	}
+	switch len(regions) {
+	case 0:
+	case 1:
+		clauses = append(clauses, "triggers a node rollout in 1 region")
+	default:
+		clauses = append(clauses, fmt.Sprintf("triggers a node rollout in %d regions", len(regions)))
+	}
+
+	if len(clauses) == 0 {
+		return subject + " changes no node groups, launch templates or DB instances"
+	}
+	return subject + " " + list(clauses)
+}
+
+func (i *Impact) count(action Action, resourceType string) int {
+	n := 0
+	for _, change := range i.Changes {
+		if change.Action == action && change.Type == resourceType {
+			n++
+		}
+	}
+	return n
+}
+
+// list joins items as "a", "a and b" or "a, b and c".
+func list(items []string) string {
+	if len(items) < 2 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
+
+// Write prints the summary, the risk and a table of every classified resource.
+func (i *Impact) Write(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%s\nrisk: %s\n\n", i.Summary(), i.Risk()); err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REGION\tACTION\tRESOURCE\tATTRIBUTES")
+	for _, change := range i.Changes {
+		action := string(change.Action)
+		if change.Rollout {
+			action += " (rollout)"
+		}
+		attributes := make([]string, len(change.Attributes))
+		for j, attribute := range change.Attributes {
+			attributes[j] = attribute
+			if matches(attribute, change.ForcedBy) {
+				attributes[j] += " (forces replacement)"
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", change.Region, action, change.Address, strings.Join(attributes, ", "))
+	}
+	return tw.Flush()
+}
+
+// String renders the impact as Write does.
+func (i *Impact) String() string {
+	var b strings.Builder
+	_ = i.Write(&b)
+	return b.String()
+}
@@ -0,0 +1,427 @@
+// Package upgrade tells which resources a change to the inputs of a module, such as a kubernetes_version bump or a
+// node_groups edit, replaces and which it updates in place, before anything is applied. It compares the modified plan
+// with a baseline, either the deployed state or a plan of the same configuration against the same fixture that stands
+// in for it, and classifies every aws_eks_node_group, aws_launch_template and aws_db_instance. When the modified plan
+// was made against the deployed state, Terraform has already decided what it replaces and the plan's actions are
+// taken as they are. Two plans from empty state only say what each creates, so the modified one is diffed against the
+// baseline the way Terraform diffs a plan against state, using the replacement rules of the AWS provider in
+// ReplacedBy.
+package upgrade
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// Action is what applying the modified plan over the baseline does to a resource.
+type Action string
+
+const (
+	NoOp    Action = "no-op"
+	Create  Action = "create"
+	Update  Action = "update"
+	Replace Action = "replace"
+	Destroy Action = "destroy"
+)
+
+// Types are the resource types whose changes are classified.
+var Types = []string{"aws_eks_node_group", "aws_launch_template", "aws_db_instance"}
+
+// ReplacedBy lists, per resource type, the attributes that force Terraform to replace the resource when they change,
+// as the 5.x AWS provider the modules pin declares them. Paths are dotted with numeric list indexes, as in
+// planassert, and cover every attribute below them. Any other change is an update in place; for a launch template that
+// is a new version.
+//
+// Each entry is an argument, or a block of arguments, that the provider's resource schema marks ForceNew: in
+// internal/service/eks/node_group.go for aws_eks_node_group, internal/service/ec2/ec2_launch_template.go for
+// aws_launch_template and internal/service/rds/instance.go for aws_db_instance. `terraform providers schema -json` does
+// not say which arguments force a replacement, so CheckSchema can only check that every entry is still an argument of
+// the provider the lock file selects; TestUpgradeTablesMatchProviderSchema runs it. Review the entries against those
+// files when the lock file moves to a new provider version.
+var ReplacedBy = map[string][]string{
+	"aws_eks_node_group": {
+		"ami_type",
+		"capacity_type",
+		"cluster_name",
+		"disk_size",
+		"instance_types",
+		"launch_template.0.id",
+		"launch_template.0.name",
+		"node_group_name",
+		"node_group_name_prefix",
+		"node_role_arn",
+		"remote_access",
+		"subnet_ids",
+	},
+	"aws_launch_template": {
+		"name",
+		"name_prefix",
+	},
+	"aws_db_instance": {
+		"availability_zone",
+		"character_set_name",
+		"custom_iam_instance_profile",
+		"db_name",
+		"engine",
+		"identifier",
+		"identifier_prefix",
+		"kms_key_id",
+		"nchar_character_set_name",
+		"restore_to_point_in_time",
+		"s3_import",
+		"snapshot_identifier",
+		"storage_encrypted",
+		"timezone",
+		"username",
+	},
+}
+
+// IgnoredChanges lists, per resource type, the attributes the modules' lifecycle blocks tell Terraform to ignore, so
+// changing them plans nothing. It mirrors ignore_changes of aws_eks_node_group.main in modules/eks-node-groups.
+var IgnoredChanges = map[string][]string{
+	"aws_eks_node_group": {"scaling_config.0.desired_size"},
+}
+
+// RolledOutBy lists the attributes of aws_eks_node_group that EKS applies in place by replacing every node of the
+// group, a rolling update bounded by update_config.
+var RolledOutBy = []string{"ami_type", "launch_template.0.version", "release_version", "version"}
+
+// Paths of aws_eks_node_group that follow its launch template: the id changes when the template is replaced and the
+// version when it is updated.
+const (
+	launchTemplateID      = "launch_template.0.id"
+	launchTemplateVersion = "launch_template.0.version"
+)
+
+// Options say which region each resource of the plans is in.
+type Options struct {
+	// Region is the region of resources outside ModuleRegions, usually the region of the default provider.
+	Region string
+
+	// ModuleRegions maps a module address to the region of every resource in it, for plans that configure one
+	// provider per module like the root module.
+	ModuleRegions map[string]string
+}
+
+// RootOptions returns the options for a root module plan: module.primary_region and module.secondary_region in the
+// primary_region and secondary_region variables.
+func RootOptions(plan *planassert.Plan) (Options, error) {
+	regions := map[string]string{}
+	for _, name := range []string{"primary_region", "secondary_region"} {
+		variable, ok := plan.Raw().RawPlan.Variables[name]
+		if !ok || variable == nil {
+			return Options{}, fmt.Errorf("plan has no %s variable", name)
+		}
+		region, ok := variable.Value.(string)
+		if !ok {
+			return Options{}, fmt.Errorf("%s is a %T, not a string", name, variable.Value)
+		}
+		regions[name] = region
+	}
+	return Options{
+		Region: regions["primary_region"],
+		ModuleRegions: map[string]string{
+			"module.primary_region":   regions["primary_region"],
+			"module.secondary_region": regions["secondary_region"],
+		},
+	}, nil
+}
+
+// regionOf returns the region of the module with the longest address that contains the resource.
+func (o Options) regionOf(address string) string {
+	region, longest := o.Region, -1
+	for module, moduleRegion := range o.ModuleRegions {
+		if strings.HasPrefix(address, module+".") && len(module) > longest {
+			region, longest = moduleRegion, len(module)
+		}
+	}
+	return region
+}
+
+// Change is what the modified plan does to one resource of the baseline.
+type Change struct {
+	Address string `json:"address"`
+	Type    string `json:"type"`
+	Region  string `json:"region"`
+	Action  Action `json:"action"`
+
+	// Attributes are the paths of the attributes that change, sorted.
+	Attributes []string `json:"attributes,omitempty"`
+
+	// ForcedBy are the changed attributes ReplacedBy says force the replacement, sorted.
+	ForcedBy []string `json:"forced_by,omitempty"`
+
+	// Rollout reports that the change replaces every node of a node group.
+	Rollout bool `json:"rollout,omitempty"`
+
+	// planned is true when Action is the one Terraform planned against the deployed state, rather than one derived
+	// from ReplacedBy.
+	planned bool
+}
+
+// Impact is what applying the modified plan over the baseline does.
+type Impact struct {
+	// Variables are the paths of the input variables that differ between the plans, e.g. node_groups.general.disk_size.
+	Variables []string `json:"variables"`
+
+	// Changes are the classified resources, sorted by address.
+	Changes []Change `json:"changes"`
+}
+
+// Compare classifies what the modified plan does to every resource of Types that either plan has, taking the action
+// Terraform planned for a resource the modified plan was made against the state of. Attributes only known after apply
+// in either plan are not compared, except the launch template of a node group: the module keys both by node group
+// name, and a node group follows every change to its template.
+func Compare(baseline, modified *planassert.Plan, options Options) *Impact {
+	impact := &Impact{Variables: changedVariables(baseline, modified)}
+
+	changes := map[string]*Change{}
+	for _, resourceType := range Types {
+		addresses := map[string]bool{}
+		for _, resource := range baseline.OfType(resourceType) {
+			addresses[resource.Address] = true
+		}
+		for _, resource := range modified.OfType(resourceType) {
+			addresses[resource.Address] = true
+		}
+		for address := range addresses {
+			before, _ := baseline.Resource(address)
+			after, _ := modified.Resource(address)
+			changes[address] = classify(address, resourceType, before, after, options)
+		}
+	}
+
+	for _, change := range changes {
+		if change.Type != "aws_eks_node_group" || change.Action == Create || change.Action == Destroy ||
+			change.planned && change.Action == NoOp {
+			continue
+		}
+		group, _ := modified.Resource(change.Address)
+		template, ok := launchTemplateOf(group, changes)
+		if !ok {
+			continue
+		}
+		switch template.Action {
+		case Replace:
+			change.add(launchTemplateID)
+		case Update:
+			change.add(launchTemplateVersion)
+		}
+	}
+
+	for _, change := range changes {
+		impact.Changes = append(impact.Changes, *change)
+	}
+	sort.Slice(impact.Changes, func(i, j int) bool { return impact.Changes[i].Address < impact.Changes[j].Address })
+	return impact
+}
+
+// Change returns the change of the resource at the exact address.
+func (i *Impact) Change(address string) (Change, bool) {
+	for _, change := range i.Changes {
+		if change.Address == address {
+			return change, true
+		}
+	}
+	return Change{}, false
+}
+
+func classify(address, resourceType string, before, after *planassert.Resource, options Options) *Change {
+	change := &Change{Address: address, Type: resourceType, Region: options.regionOf(address), Action: NoOp}
+	switch {
+	case before == nil || before.Destroyed():
+		change.Action = Create
+		return change
+	case after == nil || after.Destroyed():
+		change.Action = Destroy
+		return change
+	}
+
+	// A modified plan made against a state that holds the resource plans an update, a replacement or nothing for it,
+	// where one made from empty state would create it
+	if len(after.Actions) > 0 && !after.Actions.Create() {
+		change.planned = true
+		switch {
+		case after.Actions.Replace():
+			change.Action = Replace
+		case after.Actions.Update():
+			change.Action = Update
+		}
+		change.Rollout = resourceType == "aws_eks_node_group" && change.Action == Replace
+	}
+
+	previous, planned := flatten(before.Values), flatten(after.Values)
+	for path, value := range planned {
+		old, known := previous[path]
+		if !known || matches(path, IgnoredChanges[resourceType]) {
+			continue
+		}
+		if !reflect.DeepEqual(old, value) {
+			change.add(path)
+		}
+	}
+	return change
+}
+
+// add records a changed attribute and reclassifies the change, unless Terraform planned its action.
+func (c *Change) add(path string) {
+	c.Attributes = append(c.Attributes, path)
+	sort.Strings(c.Attributes)
+	if matches(path, ReplacedBy[c.Type]) {
+		c.ForcedBy = append(c.ForcedBy, path)
+		sort.Strings(c.ForcedBy)
+	}
+
+	if !c.planned {
+		c.Action = Update
+		if len(c.ForcedBy) > 0 {
+			c.Action = Replace
+		}
+	}
+	if c.Type == "aws_eks_node_group" {
+		c.Rollout = c.Action == Replace || c.Rollout || matches(path, RolledOutBy)
+	}
+}
+
+// launchTemplateOf returns the change of the launch template in the node group's module with the node group's key.
+func launchTemplateOf(group *planassert.Resource, changes map[string]*Change) (*Change, bool) {
+	if group == nil || group.Index == nil {
+		return nil, false
+	}
+	prefix := ""
+	if group.ModuleAddress != "" {
+		prefix = group.ModuleAddress + "."
+	}
+	var found *Change
+	for _, change := range changes {
+		if change.Type != "aws_launch_template" || !strings.HasPrefix(change.Address, prefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(change.Address, prefix)
+		if strings.HasPrefix(rest, "module.") || !strings.HasSuffix(rest, indexSuffix(group.Index)) {
+			continue
+		}
+		if found != nil {
+			return nil, false
+		}
+		found = change
+	}
+	return found, found != nil
+}
+
+func indexSuffix(index interface{}) string {
+	if key, ok := index.(string); ok {
+		return "[" + strconv.Quote(key) + "]"
+	}
+	return fmt.Sprintf("[%v]", index)
+}
+
+// matches reports whether the path is one of the attributes or below one of them.
+func matches(path string, attributes []string) bool {
+	for _, attribute := range attributes {
+		if path == attribute || strings.HasPrefix(path, attribute+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// flatten maps the dotted path of every leaf of the values to its value. Lists of blocks are descended into by index;
+// lists and sets of plain values, such as instance_types, are leaves.
+func flatten(values map[string]interface{}) map[string]interface{} {
+	leaves := map[string]interface{}{}
+	var walk func(prefix string, value interface{})
+	walk = func(prefix string, value interface{}) {
+		switch node := value.(type) {
+		case map[string]interface{}:
+			if len(node) == 0 {
+				leaves[prefix] = node
+			}
+			for key, child := range node {
+				walk(join(prefix, key), child)
+			}
+		case []interface{}:
+			if len(node) == 0 || !isBlock(node[0]) {
+				leaves[prefix] = node
+				return
+			}
+			for i, child := range node {
+				walk(join(prefix, strconv.Itoa(i)), child)
+			}
+		default:
+			leaves[prefix] = node
+		}
+	}
+	for key, value := range values {
+		walk(key, value)
+	}
+	return leaves
+}
+
+func isBlock(value interface{}) bool {
+	_, ok := value.(map[string]interface{})
+	return ok
+}
+
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// changedVariables returns the leaf paths of the input variables whose values differ between the plans, sorted, or
+// nil when either has no variables, as a state does not.
+func changedVariables(baseline, modified *planassert.Plan) []string {
+	if len(baseline.Raw().RawPlan.Variables) == 0 || len(modified.Raw().RawPlan.Variables) == 0 {
+		return nil
+	}
+	values := func(plan *planassert.Plan) map[string]interface{} {
+		out := map[string]interface{}{}
+		for name, variable := range plan.Raw().RawPlan.Variables {
+			if variable != nil {
+				out[name] = normalize(variable.Value)
+			}
+		}
+		return flatten(out)
+	}
+	before, after := values(baseline), values(modified)
+
+	changed := map[string]bool{}
+	for path, value := range after {
+		if old, ok := before[path]; !ok || !reflect.DeepEqual(old, value) {
+			changed[path] = true
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed[path] = true
+		}
+	}
+	paths := make([]string, 0, len(changed))
+	for path := range changed {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// normalize round-trips a variable value through JSON, so values decoded from a plan file and values built in Go
+// compare equal.
+func normalize(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return value
+	}
+	return out
+}
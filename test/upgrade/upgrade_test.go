@@ -0,0 +1,413 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// recordingT collects failures without failing the enclosing test.
+type recordingT struct {
+	failed bool
+}
+
+func (r *recordingT) Fail()                                  { r.failed = true }
+func (r *recordingT) FailNow()                               { r.failed = true }
+func (r *recordingT) Fatal(args ...interface{})              { r.failed = true }
+func (r *recordingT) Fatalf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Error(args ...interface{})              { r.failed = true }
+func (r *recordingT) Errorf(format string, a ...interface{}) { r.failed = true }
+func (r *recordingT) Name() string                           { return "recordingT" }
+
+type object = map[string]interface{}
+
+// nodeGroup is the part of a node_groups entry the sample plans render.
+type nodeGroup struct {
+	InstanceTypes []string
+	DesiredSize   int
+	MaxSize       int
+	DiskSize      int
+}
+
+// inputs are the root module variables the sample plans are rendered from.
+type inputs struct {
+	KubernetesVersion string
+	NodeGroups        map[string]nodeGroup
+	StorageEncrypted  bool
+}
+
+func baselineInputs() inputs {
+	return inputs{
+		KubernetesVersion: "1.28",
+		NodeGroups:        map[string]nodeGroup{"general": {InstanceTypes: []string{"t3.large"}, DesiredSize: 3, MaxSize: 9, DiskSize: 50}},
+		StorageEncrypted:  true,
+	}
+}
+
+// rootPlan renders a root module plan of both regions with the node groups, their launch templates and the database
+// as the modules plan them. The launch template of a node group, the template's name and the database's KMS key are
+// only known after apply.
+func rootPlan(t *testing.T, in inputs) *planassert.Plan {
+	t.Helper()
+
+	groups := object{}
+	var modules []object
+	for _, region := range []string{"primary_region", "secondary_region"} {
+		var nodeGroups []object
+		for name, group := range in.NodeGroups {
+			groups[name] = object{"instance_types": group.InstanceTypes, "desired_size": group.DesiredSize,
+				"max_size": group.MaxSize, "disk_size": group.DiskSize}
+			nodeGroups = append(nodeGroups, resource(region, "node_groups", "aws_launch_template", "node_group", name, object{
+				"name_prefix": "test-" + region + "-" + name + "-",
+				"block_device_mappings": []object{{"device_name": "/dev/xvda", "ebs": []object{{
+					"volume_size": group.DiskSize, "volume_type": "gp3"}}}},
+			}), resource(region, "node_groups", "aws_eks_node_group", "main", name, object{
+				"node_group_name": "test-" + region + "-" + name,
+				"version":         in.KubernetesVersion,
+				"instance_types":  group.InstanceTypes,
+				"scaling_config":  []object{{"desired_size": group.DesiredSize, "max_size": group.MaxSize, "min_size": 1}},
+				"launch_template": []object{{}},
+			}))
+		}
+		modules = append(modules, object{"address": "module." + region + ".module.node_groups", "resources": nodeGroups})
+
+		name := map[string]string{"primary_region": "main", "secondary_region": "replica"}[region]
+		modules = append(modules, object{"address": "module." + region + ".module.rds[0]", "resources": []object{
+			resource(region, "rds[0]", "aws_db_instance", name, 0, object{
+				"identifier": "test-" + region + "-db", "instance_class": "db.r6g.large", "storage_encrypted": in.StorageEncrypted,
+			}),
+		}})
+	}
+
+	data, err := json.Marshal(object{
+		"format_version": "1.2",
+		"variables": object{
+			"primary_region":     object{"value": "us-east-1"},
+			"secondary_region":   object{"value": "us-west-2"},
+			"kubernetes_version": object{"value": in.KubernetesVersion},
+			"node_groups":        object{"value": groups},
+			"rds_config":         object{"value": object{"storage_encrypted": in.StorageEncrypted}},
+		},
+		"planned_values": object{"root_module": object{"child_modules": modules}},
+	})
+	require.NoError(t, err)
+	plan, err := planassert.ParseJSON(data)
+	require.NoError(t, err)
+	return plan
+}
+
+func resource(region, module, resourceType, name string, index interface{}, values object) object {
+	address := "module." + region + ".module." + module + "." + resourceType + "." + name
+	if key, ok := index.(string); ok {
+		address += `["` + key + `"]`
+	} else {
+		address += "[0]"
+	}
+	return object{"address": address, "mode": "managed", "type": resourceType, "name": name, "index": index, "values": values}
+}
+
+const (
+	primaryGroup    = `module.primary_region.module.node_groups.aws_eks_node_group.main["general"]`
+	primaryTemplate = `module.primary_region.module.node_groups.aws_launch_template.node_group["general"]`
+	primaryDB       = "module.primary_region.module.rds[0].aws_db_instance.main[0]"
+	secondaryGroup  = `module.secondary_region.module.node_groups.aws_eks_node_group.main["general"]`
+	secondaryDB     = "module.secondary_region.module.rds[0].aws_db_instance.replica[0]"
+)
+
+// compare plans the baseline and the inputs modify returns, and compares the plans.
+func compare(t *testing.T, modify func(*inputs)) *Impact {
+	t.Helper()
+
+	baseline := rootPlan(t, baselineInputs())
+	in := baselineInputs()
+	modify(&in)
+	modified := rootPlan(t, in)
+	options, err := RootOptions(modified)
+	require.NoError(t, err)
+	return Compare(baseline, modified, options)
+}
+
+func TestCompareDiskSize(t *testing.T) {
+	t.Parallel()
+
+	impact := compare(t, func(in *inputs) {
+		in.NodeGroups = map[string]nodeGroup{"general": {InstanceTypes: []string{"t3.large"}, DesiredSize: 3, MaxSize: 9, DiskSize: 100}}
+	})
+
+	// The template gets a new version, which the node group rolls out to every node
+	template, ok := impact.Change(primaryTemplate)
+	require.True(t, ok)
+	assert.Equal(t, Change{Address: primaryTemplate, Type: "aws_launch_template", Region: "us-east-1", Action: Update,
+		Attributes: []string{"block_device_mappings.0.ebs.0.volume_size"}}, template)
+	group, ok := impact.Change(secondaryGroup)
+	require.True(t, ok)
+	assert.Equal(t, Change{Address: secondaryGroup, Type: "aws_eks_node_group", Region: "us-west-2", Action: Update,
+		Attributes: []string{"launch_template.0.version"}, Rollout: true}, group)
+	AssertAction(t, impact, primaryDB, NoOp)
+
+	assert.Equal(t, []string{"node_groups.general.disk_size"}, impact.Variables)
+	assert.Equal(t, "changing node_groups.general.disk_size updates 2 node groups and 2 launch templates and triggers a node rollout in 2 regions",
+		impact.Summary())
+	assert.Equal(t, RiskMedium, impact.Risk())
+}
+
+func TestCompareKubernetesVersion(t *testing.T) {
+	t.Parallel()
+
+	impact := compare(t, func(in *inputs) { in.KubernetesVersion = "1.29" })
+
+	AssertAction(t, impact, primaryGroup, Update)
+	AssertAction(t, impact, primaryTemplate, NoOp)
+	group, _ := impact.Change(primaryGroup)
+	assert.Equal(t, []string{"version"}, group.Attributes)
+	assert.True(t, group.Rollout)
+	assert.Equal(t, "changing kubernetes_version updates 2 node groups and triggers a node rollout in 2 regions", impact.Summary())
+	assert.Equal(t, RiskMedium, impact.Risk())
+}
+
+func TestCompareInstanceTypes(t *testing.T) {
+	t.Parallel()
+
+	impact := compare(t, func(in *inputs) {
+		in.NodeGroups = map[string]nodeGroup{"general": {InstanceTypes: []string{"m5.large"}, DesiredSize: 3, MaxSize: 9, DiskSize: 50}}
+	})
+
+	group, _ := impact.Change(primaryGroup)
+	assert.Equal(t, Replace, group.Action)
+	assert.Equal(t, []string{"instance_types"}, group.ForcedBy)
+	assert.True(t, group.Rollout)
+	assert.Equal(t, "changing node_groups.general.instance_types replaces 2 node groups and triggers a node rollout in 2 regions", impact.Summary())
+	assert.Equal(t, RiskMedium, impact.Risk())
+	assert.Regexp(t, `us-east-1\s+replace \(rollout\)\s+`+regexp.QuoteMeta(primaryGroup)+`\s+instance_types \(forces replacement\)`, impact.String())
+}
+
+func TestCompareScaling(t *testing.T) {
+	t.Parallel()
+
+	// desired_size is ignored by the node group's lifecycle, so only max_size changes
+	impact := compare(t, func(in *inputs) {
+		in.NodeGroups = map[string]nodeGroup{"general": {InstanceTypes: []string{"t3.large"}, DesiredSize: 6, MaxSize: 12, DiskSize: 50}}
+	})
+
+	group, _ := impact.Change(primaryGroup)
+	assert.Equal(t, Update, group.Action)
+	assert.Equal(t, []string{"scaling_config.0.max_size"}, group.Attributes)
+	assert.False(t, group.Rollout)
+	assert.Equal(t, "changing node_groups.general.desired_size, node_groups.general.max_size updates 2 node groups", impact.Summary())
+	assert.Equal(t, RiskLow, impact.Risk())
+
+	impact = compare(t, func(in *inputs) {
+		in.NodeGroups = map[string]nodeGroup{"general": {InstanceTypes: []string{"t3.large"}, DesiredSize: 6, MaxSize: 9, DiskSize: 50}}
+	})
+	assert.Equal(t, "changing node_groups.general.desired_size changes no node groups, launch templates or DB instances", impact.Summary())
+	assert.Equal(t, RiskNone, impact.Risk())
+}
+
+func TestCompareStorageEncrypted(t *testing.T) {
+	t.Parallel()
+
+	impact := compare(t, func(in *inputs) { in.StorageEncrypted = false })
+
+	AssertAction(t, impact, primaryDB, Replace)
+	AssertAction(t, impact, secondaryDB, Replace)
+	db, _ := impact.Change(primaryDB)
+	assert.Equal(t, []string{"storage_encrypted"}, db.ForcedBy)
+	assert.Equal(t, "changing rds_config.storage_encrypted replaces 2 DB instances", impact.Summary())
+	assert.Equal(t, RiskHigh, impact.Risk())
+}
+
+func TestCompareNodeGroups(t *testing.T) {
+	t.Parallel()
+
+	spot := nodeGroup{InstanceTypes: []string{"t3.large"}, DesiredSize: 0, MaxSize: 6, DiskSize: 50}
+	impact := compare(t, func(in *inputs) { in.NodeGroups["spot"] = spot })
+	AssertAction(t, impact, `module.primary_region.module.node_groups.aws_eks_node_group.main["spot"]`, Create)
+	AssertAction(t, impact, primaryGroup, NoOp)
+	assert.Equal(t, "changing node_groups.spot.desired_size, node_groups.spot.disk_size, node_groups.spot.instance_types, "+
+		"node_groups.spot.max_size creates 2 node groups and 2 launch templates", impact.Summary())
+	assert.Equal(t, RiskLow, impact.Risk())
+
+	impact = compare(t, func(in *inputs) { in.NodeGroups = map[string]nodeGroup{"spot": spot} })
+	AssertAction(t, impact, primaryGroup, Destroy)
+	assert.Contains(t, impact.Summary(), "destroys 2 node groups and 2 launch templates and creates 2 node groups and 2 launch templates")
+	assert.Equal(t, RiskHigh, impact.Risk())
+}
+
+func TestCompareStateBaseline(t *testing.T) {
+	t.Parallel()
+
+	// The deployed state as `terraform show -json` prints it, which has no variables
+	state, err := json.Marshal(object{"format_version": "1.0", "values": rootPlan(t, baselineInputs()).Raw().RawPlan.PlannedValues})
+	require.NoError(t, err)
+	baseline, err := planassert.ParseStateJSON(state)
+	require.NoError(t, err)
+
+	in := baselineInputs()
+	in.KubernetesVersion = "1.29"
+	modified := rootPlan(t, in)
+	options, err := RootOptions(modified)
+	require.NoError(t, err)
+
+	impact := Compare(baseline, modified, options)
+	assert.Empty(t, impact.Variables)
+	assert.Equal(t, "the change updates 2 node groups and triggers a node rollout in 2 regions", impact.Summary())
+}
+
+func TestComparePlannedAgainstState(t *testing.T) {
+	t.Parallel()
+
+	state, err := json.Marshal(object{"format_version": "1.0", "values": rootPlan(t, baselineInputs()).Raw().RawPlan.PlannedValues})
+	require.NoError(t, err)
+	baseline, err := planassert.ParseStateJSON(state)
+	require.NoError(t, err)
+
+	// A plan made against that state, in which Terraform replaces the primary database although none of its values
+	// change, as replace_triggered_by or a provider's CustomizeDiff would
+	in := baselineInputs()
+	in.KubernetesVersion = "1.29"
+	planned := rootPlan(t, in)
+	var changes []object
+	for address, actions := range map[string][]string{
+		primaryGroup:    {"update"},
+		primaryTemplate: {"no-op"},
+		primaryDB:       {"delete", "create"},
+	} {
+		resource, ok := planned.Resource(address)
+		require.True(t, ok, address)
+		changes = append(changes, object{"address": address, "module_address": resource.ModuleAddress, "mode": "managed",
+			"type": resource.Type, "name": resource.Name, "index": resource.Index,
+			"change": object{"actions": actions, "before": object{}, "after": resource.Values, "after_unknown": object{}}})
+	}
+	data, err := json.Marshal(object{
+		"format_version":   "1.2",
+		"variables":        planned.Raw().RawPlan.Variables,
+		"planned_values":   planned.Raw().RawPlan.PlannedValues,
+		"resource_changes": changes,
+	})
+	require.NoError(t, err)
+	modified, err := planassert.ParseJSON(data)
+	require.NoError(t, err)
+	options, err := RootOptions(modified)
+	require.NoError(t, err)
+
+	impact := Compare(baseline, modified, options)
+	group, _ := impact.Change(primaryGroup)
+	assert.Equal(t, Change{Address: primaryGroup, Type: "aws_eks_node_group", Region: "us-east-1", Action: Update,
+		Attributes: []string{"version"}, Rollout: true, planned: true}, group)
+	AssertAction(t, impact, primaryTemplate, NoOp)
+	AssertAction(t, impact, primaryDB, Replace)
+	db, _ := impact.Change(primaryDB)
+	assert.Empty(t, db.Attributes)
+
+	// The secondary region has no resource changes, so its classification falls back to ReplacedBy
+	AssertAction(t, impact, secondaryGroup, Update)
+	AssertAction(t, impact, secondaryDB, NoOp)
+	assert.Equal(t, RiskHigh, impact.Risk())
+}
+
+// schemaOf returns a provider schema in which every path of the tables is an optional argument.
+func schemaOf(tables ...map[string][]string) *tfjson.ProviderSchema {
+	schema := &tfjson.ProviderSchema{ResourceSchemas: map[string]*tfjson.Schema{}}
+	for _, table := range tables {
+		for resourceType, paths := range table {
+			resource, ok := schema.ResourceSchemas[resourceType]
+			if !ok {
+				resource = &tfjson.Schema{Block: &tfjson.SchemaBlock{}}
+				schema.ResourceSchemas[resourceType] = resource
+			}
+			for _, path := range paths {
+				block := resource.Block
+				segments := strings.Split(regexp.MustCompile(`\.\d+`).ReplaceAllString(path, ""), ".")
+				for _, segment := range segments[:len(segments)-1] {
+					if block.NestedBlocks == nil {
+						block.NestedBlocks = map[string]*tfjson.SchemaBlockType{}
+					}
+					if _, ok := block.NestedBlocks[segment]; !ok {
+						block.NestedBlocks[segment] = &tfjson.SchemaBlockType{NestingMode: tfjson.SchemaNestingModeList, Block: &tfjson.SchemaBlock{}}
+					}
+					block = block.NestedBlocks[segment].Block
+				}
+				if block.Attributes == nil {
+					block.Attributes = map[string]*tfjson.SchemaAttribute{}
+				}
+				block.Attributes[segments[len(segments)-1]] = &tfjson.SchemaAttribute{Optional: true}
+			}
+		}
+	}
+	return schema
+}
+
+func TestCheckSchema(t *testing.T) {
+	t.Parallel()
+
+	schema := schemaOf(ReplacedBy, map[string][]string{"aws_eks_node_group": RolledOutBy}, IgnoredChanges)
+	require.NoError(t, CheckSchema(schema))
+
+	nodeGroup := schema.ResourceSchemas["aws_eks_node_group"].Block
+	delete(nodeGroup.Attributes, "capacity_type")
+	nodeGroup.Attributes["release_version"] = &tfjson.SchemaAttribute{Computed: true}
+	delete(nodeGroup.NestedBlocks["launch_template"].Block.Attributes, "version")
+	delete(schema.ResourceSchemas, "aws_launch_template")
+	assert.EqualError(t, CheckSchema(schema), "ReplacedBy: aws_eks_node_group.capacity_type: the schema has no argument or block capacity_type\n"+
+		"ReplacedBy: the provider has no resource aws_launch_template\n"+
+		"RolledOutBy: aws_eks_node_group.launch_template.0.version: the schema has no argument or block version\n"+
+		"RolledOutBy: aws_eks_node_group.release_version: release_version is computed by the provider, not an argument")
+}
+
+func TestIgnoredChangesMatchModule(t *testing.T) {
+	t.Parallel()
+
+	main, err := os.ReadFile("../../modules/eks-node-groups/main.tf")
+	require.NoError(t, err)
+	block := regexp.MustCompile(`(?s)resource "aws_eks_node_group" "main" \{.*?ignore_changes = \[\n(.*?)\n\s*\]`).FindSubmatch(main)
+	require.NotNil(t, block, "ignore_changes of aws_eks_node_group.main")
+
+	var ignored []string
+	for _, line := range strings.Split(string(block[1]), "\n") {
+		if line = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(line), ",")); line != "" {
+			ignored = append(ignored, regexp.MustCompile(`\[(\d+)\]`).ReplaceAllString(line, ".$1"))
+		}
+	}
+	assert.Equal(t, IgnoredChanges["aws_eks_node_group"], ignored)
+}
+
+func TestParseRisk(t *testing.T) {
+	t.Parallel()
+
+	risk, err := ParseRisk("medium")
+	require.NoError(t, err)
+	assert.Equal(t, RiskMedium, risk)
+	assert.True(t, RiskHigh.Exceeds(risk))
+	assert.False(t, RiskLow.Exceeds(risk))
+
+	_, err = ParseRisk("severe")
+	assert.EqualError(t, err, `unknown risk "severe", want one of none, low, medium, high`)
+}
+
+func TestAssertions(t *testing.T) {
+	t.Parallel()
+
+	impact := compare(t, func(in *inputs) { in.KubernetesVersion = "1.29" })
+	assert.True(t, AssertAction(t, impact, primaryGroup, Update))
+	assert.True(t, AssertRiskAtMost(t, impact, RiskMedium))
+
+	recorder := &recordingT{}
+	assert.False(t, AssertAction(recorder, impact, primaryGroup, Replace))
+	assert.True(t, recorder.failed)
+
+	recorder = &recordingT{}
+	assert.False(t, AssertAction(recorder, impact, "aws_eks_node_group.missing", NoOp))
+	assert.True(t, recorder.failed)
+
+	recorder = &recordingT{}
+	assert.False(t, AssertRiskAtMost(recorder, impact, RiskLow))
+	assert.True(t, recorder.failed)
+}
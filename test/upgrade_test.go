@@ -0,0 +1,120 @@
+package test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/logger"
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/harness"
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
+	"github.com/your-org/multi-az-eks-cluster/test/upgrade"
+)
+
+func TestUpgradeNodeGroupDiskSize(t *testing.T) {
+	t.Parallel()
+
+	fixture := harness.NewFixture("us-east-1", "vpc-primary123", "vpc-secondary456")
+
+	baseline := tfvars.BaselineRoot(fixture.VPCs[0].ID, fixture.VPCs[1].ID)
+	modified := tfvars.BaselineRoot(fixture.VPCs[0].ID, fixture.VPCs[1].ID)
+	general := modified.NodeGroups["general"]
+	general.DiskSize = 100
+	modified.NodeGroups["general"] = general
+
+	impact := planUpgrade(t, fixture, rootOptions(t, baseline), rootOptions(t, modified))
+
+	// The volume size is part of the launch template, whose new version the node groups roll out
+	for _, region := range []string{"module.primary_region", "module.secondary_region"} {
+		upgrade.AssertAction(t, impact, region+`.module.node_groups.aws_launch_template.node_group["general"]`, upgrade.Update)
+		upgrade.AssertAction(t, impact, region+`.module.node_groups.aws_eks_node_group.main["general"]`, upgrade.Update)
+	}
+	upgrade.AssertAction(t, impact, "module.primary_region.module.rds[0].aws_db_instance.main[0]", upgrade.NoOp)
+	assert.Equal(t, "changing node_groups.general.disk_size updates 2 node groups and 2 launch templates and triggers a node rollout in 2 regions",
+		impact.Summary())
+	assert.Equal(t, upgrade.RiskMedium, impact.Risk())
+}
+
+func TestUpgradeKubernetesVersion(t *testing.T) {
+	t.Parallel()
+
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
+	baseline := tfvars.BaselineRegional(fixture.Region, fixture.VPC().ID)
+	modified := tfvars.BaselineRegional(fixture.Region, fixture.VPC().ID)
+	modified.KubernetesVersion = "1.29"
+
+	impact := planUpgrade(t, fixture, regionalOptions(t, baseline), regionalOptions(t, modified))
+
+	// The node group moves to the new version in place, by replacing its nodes; the launch template is unchanged
+	upgrade.AssertAction(t, impact, `module.node_groups.aws_eks_node_group.main["general"]`, upgrade.Update)
+	upgrade.AssertAction(t, impact, `module.node_groups.aws_launch_template.node_group["general"]`, upgrade.NoOp)
+	upgrade.AssertAction(t, impact, "module.rds[0].aws_db_instance.main[0]", upgrade.NoOp)
+	assert.Equal(t, "changing kubernetes_version updates 1 node group and triggers a node rollout in 1 region", impact.Summary())
+	upgrade.AssertRiskAtMost(t, impact, upgrade.RiskMedium)
+}
+
+func TestUpgradeNodeGroupInstanceTypes(t *testing.T) {
+	t.Parallel()
+
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
+	baseline := tfvars.BaselineRegional(fixture.Region, fixture.VPC().ID)
+	modified := tfvars.BaselineRegional(fixture.Region, fixture.VPC().ID)
+	modified.NodeGroups = tfvars.NodeGroups{"general": tfvars.BaselineNodeGroup().WithInstanceTypes("m5.xlarge")}
+
+	impact := planUpgrade(t, fixture, regionalOptions(t, baseline), regionalOptions(t, modified))
+
+	// instance_types cannot be changed in place, so the node group is replaced
+	upgrade.AssertAction(t, impact, `module.node_groups.aws_eks_node_group.main["general"]`, upgrade.Replace)
+	upgrade.AssertAction(t, impact, `module.node_groups.aws_launch_template.node_group["general"]`, upgrade.NoOp)
+	assert.Equal(t, upgrade.RiskMedium, impact.Risk())
+}
+
+func TestUpgradeRDSInstanceClass(t *testing.T) {
+	t.Parallel()
+
+	fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+
+	baseline := tfvars.BaselineRegional(fixture.Region, fixture.VPC().ID)
+	modified := tfvars.BaselineRegional(fixture.Region, fixture.VPC().ID)
+	modified.RDSConfig.InstanceClass = "db.r6g.xlarge"
+
+	impact := planUpgrade(t, fixture, regionalOptions(t, baseline), regionalOptions(t, modified))
+
+	// Resizing the instance keeps its storage, while switching off encryption would replace it
+	upgrade.AssertAction(t, impact, "module.rds[0].aws_db_instance.main[0]", upgrade.Update)
+	upgrade.AssertRiskAtMost(t, impact, upgrade.RiskLow)
+
+	modified.RDSConfig.StorageEncrypted = false
+	impact = planUpgrade(t, fixture, regionalOptions(t, baseline), regionalOptions(t, modified))
+	upgrade.AssertAction(t, impact, "module.rds[0].aws_db_instance.main[0]", upgrade.Replace)
+	assert.Equal(t, upgrade.RiskHigh, impact.Risk())
+}
+
+// TestUpgradeTablesMatchProviderSchema checks that every attribute the upgrade tables name is still an argument of
+// the AWS provider the lock file selects, as `terraform providers schema -json` reports it.
+func TestUpgradeTablesMatchProviderSchema(t *testing.T) {
+	t.Parallel()
+
+	fixture := harness.NewFixture("us-east-1")
+	terraformOptions := &terraform.Options{TerraformDir: repoRoot}
+	isolate(t, terraformOptions, t.TempDir())
+	withFixture(t, fixture, terraformOptions)
+	terraform.Init(t, terraformOptions)
+
+	// The schemas run to megabytes, so they are not logged
+	schemaOptions := *terraformOptions
+	schemaOptions.Logger = logger.Discard
+	output, err := terraform.RunTerraformCommandAndGetStdoutE(t, &schemaOptions, "providers", "schema", "-json")
+	require.NoError(t, err)
+	var schemas tfjson.ProviderSchemas
+	require.NoError(t, json.Unmarshal([]byte(output), &schemas))
+	schema, ok := schemas.Schemas[upgrade.AWSProvider]
+	require.True(t, ok, "no schema for %s", upgrade.AWSProvider)
+	assert.NoError(t, upgrade.CheckSchema(schema))
+}
@@ -0,0 +1,148 @@
+package test
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gruntwork-io/terratest/modules/terraform"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/harness"
+	"github.com/your-org/multi-az-eks-cluster/test/tfdiag"
+	"github.com/your-org/multi-az-eks-cluster/test/tfvars"
+)
+
+// validationCase is a plan of a module with one variable set to a value its validation block rejects.
+type validationCase struct {
+	name     string
+	module   string
+	variable string
+
+	// vars returns otherwise valid variables for the module.
+	vars  func(fixture *harness.Fixture) map[string]interface{}
+	value interface{}
+}
+
+func vpcVars(fixture *harness.Fixture) map[string]interface{} {
+	return map[string]interface{}{
+		"region":             fixture.Region,
+		"vpc_cidr":           "10.0.0.0/16",
+		"availability_zones": fixture.VPC().AvailabilityZones,
+		"cluster_name":       "test-cluster",
+		"environment":        "test",
+	}
+}
+
+func regionalVars(fixture *harness.Fixture) map[string]interface{} {
+	return tfvars.BaselineRegional(fixture.Region, fixture.VPC().ID).Vars()
+}
+
+func nodeGroupVars(fixture *harness.Fixture) map[string]interface{} {
+	return map[string]interface{}{
+		"cluster_name":                      "test-cluster",
+		"cluster_version":                   "1.28",
+		"vpc_id":                            fixture.VPC().ID,
+		"subnet_ids":                        fixture.VPC().PrivateSubnetIDs,
+		"cluster_security_group_id":         "sg-cluster",
+		"cluster_primary_security_group_id": "sg-primary",
+		"node_groups":                       tfvars.NodeGroups{"general": tfvars.BaselineNodeGroup()}.Vars(),
+	}
+}
+
+func rdsVars(fixture *harness.Fixture) map[string]interface{} {
+	return map[string]interface{}{
+		"identifier":         "test-rds",
+		"vpc_id":             fixture.VPC().ID,
+		"subnet_ids":         fixture.VPC().DatabaseSubnetIDs,
+		"availability_zones": fixture.VPC().AvailabilityZones,
+		"engine_version":     "15.4",
+		"instance_class":     "db.t3.medium",
+		"allocated_storage":  100,
+		"database_name":      "testdb",
+		"master_username":    "dbadmin",
+	}
+}
+
+func iamRolesVars(fixture *harness.Fixture) map[string]interface{} {
+	return map[string]interface{}{
+		"cluster_name":         "test-cluster",
+		"oidc_provider_arn":    fixture.OIDCProviderARN(),
+		"oidc_provider_url":    fixture.OIDCIssuer,
+		"organizational_units": tfvars.OUs{tfvars.BaselineOU()}.Vars(),
+	}
+}
+
+// validationCases covers every validation block in the modules; TestValidationCatalogue fails when one is missing.
+var validationCases = []validationCase{
+	{name: "VPCCIDRFormat", module: "vpc", variable: "vpc_cidr", vars: vpcVars, value: "10.0.0.0"},
+	{name: "VPCZoneCount", module: "vpc", variable: "availability_zones", vars: vpcVars,
+		value: []string{"us-east-1a", "us-east-1b"}},
+	{name: "RegionalRegionFormat", module: "regional-eks", variable: "region", vars: regionalVars, value: "us_east_1"},
+	{name: "RegionalZoneCount", module: "regional-eks", variable: "availability_zones", vars: regionalVars,
+		value: []string{"us-east-1a", "us-east-1b", "us-east-1c", "us-east-1d"}},
+	{name: "NodeGroupsSubnetMinimum", module: "eks-node-groups", variable: "subnet_ids", vars: nodeGroupVars,
+		value: []string{"subnet-private-a"}},
+	{name: "NodeGroupsSizeOrder", module: "eks-node-groups", variable: "node_groups", vars: nodeGroupVars,
+		value: tfvars.NodeGroups{"general": tfvars.BaselineNodeGroup().WithSizes(3, 16, 15)}.Vars()},
+	{name: "RDSSubnetMinimum", module: "rds", variable: "subnet_ids", vars: rdsVars, value: []string{"subnet-database-a"}},
+	{name: "RDSEngineSupported", module: "rds", variable: "engine", vars: rdsVars, value: "mariadb"},
+	{name: "IAMClusterNameEmpty", module: "iam-roles", variable: "cluster_name", vars: iamRolesVars, value: ""},
+	{name: "IAMClusterNameTooLong", module: "iam-roles", variable: "cluster_name", vars: iamRolesVars,
+		value: strings.Repeat("c", 101)},
+}
+
+// TestValidationCatalogue checks offline that validationCases covers exactly the validation blocks the modules
+// declare, so a new block cannot go untested.
+func TestValidationCatalogue(t *testing.T) {
+	t.Parallel()
+
+	dirs, err := filepath.Glob("../modules/*")
+	require.NoError(t, err)
+	dirs = append(dirs, "..")
+
+	var declared []string
+	for _, dir := range dirs {
+		validations, err := tfdiag.ParseValidations(dir)
+		require.NoError(t, err)
+		for _, validation := range validations {
+			declared = append(declared, filepath.Base(filepath.Clean(validation.Module))+"/"+validation.Variable)
+		}
+	}
+
+	covered := map[string]bool{}
+	for _, tc := range validationCases {
+		covered[tc.module+"/"+tc.variable] = true
+	}
+	var tested []string
+	for key := range covered {
+		tested = append(tested, key)
+	}
+	sort.Strings(declared)
+	sort.Strings(tested)
+	assert.Equal(t, declared, tested, "validation blocks declared by the modules and covered by validationCases")
+}
+
+// TestValidationErrors plans every module with each invalid value and checks that the plan fails on the variable's
+// validation block with its exact error_message, rather than on a provider or credentials error.
+func TestValidationErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range validationCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fixture := harness.NewFixture("us-east-1", "vpc-12345678")
+			vars := tc.vars(fixture)
+			vars[tc.variable] = tc.value
+
+			assertValidationError(t, fixture, &terraform.Options{
+				TerraformDir: "../modules/" + tc.module,
+				Vars:         vars,
+			}, tc.variable)
+		})
+	}
+}
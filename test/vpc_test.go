@@ -1,70 +1,91 @@
 package test
 
 import (
+	"net/netip"
 	"testing"
 
 	"github.com/gruntwork-io/terratest/modules/terraform"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/your-org/multi-az-eks-cluster/test/harness"
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+	"github.com/your-org/multi-az-eks-cluster/test/vpclayout"
 )
 
 func TestVPCModule(t *testing.T) {
 	t.Parallel()
 
-	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
-		TerraformDir: "../modules/vpc",
-		Vars: map[string]interface{}{
-			"region":             "us-east-1",
-			"vpc_cidr":           "10.0.0.0/16",
-			"availability_zones": []string{"us-east-1a", "us-east-1b", "us-east-1c"},
-			"cluster_name":       "test-cluster",
-			"environment":        "test",
-			"tags": map[string]string{
-				"Environment": "test",
-				"ManagedBy":   "terratest",
+	fixture := harness.NewFixture("us-east-1")
+
+	runStages(t, fixture, func() *terraform.Options {
+		return terraform.WithDefaultRetryableErrors(t, &terraform.Options{
+			TerraformDir: "../modules/vpc",
+			Vars: map[string]interface{}{
+				"region":             fixture.Region,
+				"vpc_cidr":           "10.0.0.0/16",
+				"availability_zones": []string{"us-east-1a", "us-east-1b", "us-east-1c"},
+				"cluster_name":       "test-cluster",
+				"environment":        "test",
+				"tags": map[string]string{
+					"Environment": "test",
+					"ManagedBy":   "terratest",
+				},
 			},
-		},
-		// Don't actually deploy - just validate
-		PlanOnly: true,
+		})
+	}, func(plan *planassert.Plan) {
+		// Expected resources:
+		// - 1 VPC
+		// - 1 Internet Gateway
+		// - 3 EIPs for NAT
+		// - 3 Public Subnets
+		// - 3 NAT Gateways
+		// - 3 Private Subnets
+		// - 3 Database Subnets
+		// - 1 Public Route Table + 3 associations
+		// - 3 Private Route Tables + 3 associations
+		// - 1 Database Route Table + 3 associations
+		// - 1 DB Subnet Group
+		// - 1 VPC Flow Log
+		// - 1 CloudWatch Log Group
+		// - 1 IAM Role for Flow Logs
+		// - 1 IAM Role Policy
+		planassert.AssertCounts(t, plan, 36, 0, 0)
+
+		planassert.AssertResourceCount(t, plan, "aws_vpc.main", 1)
+		planassert.AssertResourceCount(t, plan, "aws_internet_gateway.main", 1)
+		planassert.AssertResourceCount(t, plan, "aws_eip.nat[*]", 3)
+		planassert.AssertResourceCount(t, plan, "aws_nat_gateway.main[*]", 3)
+		planassert.AssertResourceCount(t, plan, "aws_subnet.public[*]", 3)
+		planassert.AssertResourceCount(t, plan, "aws_subnet.private[*]", 3)
+		planassert.AssertResourceCount(t, plan, "aws_subnet.database[*]", 3)
+		planassert.AssertResourceCount(t, plan, "aws_route_table.public", 1)
+		planassert.AssertResourceCount(t, plan, "aws_route_table.private[*]", 3)
+		planassert.AssertResourceCount(t, plan, "aws_route_table.database", 1)
+		planassert.AssertTypeCount(t, plan, "aws_route_table_association", 9)
+		planassert.AssertResourceCount(t, plan, "aws_db_subnet_group.main", 1)
+		planassert.AssertResourceCount(t, plan, "aws_flow_log.main", 1)
+
+		planassert.AssertAttributeEquals(t, plan, "aws_vpc.main", "cidr_block", "10.0.0.0/16")
+		planassert.AssertAttributeEquals(t, plan, "aws_vpc.main", "enable_dns_hostnames", true)
+		planassert.AssertAttributeEquals(t, plan, "aws_flow_log.main", "traffic_type", "ALL")
+		planassert.AssertAttributeEquals(t, plan, "aws_cloudwatch_log_group.flow_logs", "retention_in_days", 7)
+
+		assertPolicy(t, plan)
+		assertGolden(t, plan)
 	})
-
-	defer terraform.Destroy(t, terraformOptions)
-
-	// Run terraform init and plan
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-
-	// Verify resource counts
-	resourceCounts := terraform.GetResourceCount(t, planStruct)
-
-	// Expected resources:
-	// - 1 VPC
-	// - 1 Internet Gateway
-	// - 3 EIPs for NAT
-	// - 3 Public Subnets
-	// - 3 NAT Gateways
-	// - 3 Private Subnets
-	// - 3 Database Subnets
-	// - 1 Public Route Table + 3 associations
-	// - 3 Private Route Tables + 3 associations
-	// - 1 Database Route Table + 3 associations
-	// - 1 DB Subnet Group
-	// - 1 VPC Flow Log
-	// - 1 CloudWatch Log Group
-	// - 1 IAM Role for Flow Logs
-	// - 1 IAM Role Policy
-
-	assert.Greater(t, resourceCounts.Add, 25, "Should create more than 25 resources")
-	assert.Equal(t, 0, resourceCounts.Change, "Should not change any resources")
-	assert.Equal(t, 0, resourceCounts.Destroy, "Should not destroy any resources")
 }
 
 func TestVPCModuleValidation(t *testing.T) {
 	t.Parallel()
 
 	// Test that exactly 3 AZs are required
+	fixture := harness.NewFixture("us-east-1")
+
 	terraformOptions := &terraform.Options{
 		TerraformDir: "../modules/vpc",
 		Vars: map[string]interface{}{
-			"region":             "us-east-1",
+			"region":             fixture.Region,
 			"vpc_cidr":           "10.0.0.0/16",
 			"availability_zones": []string{"us-east-1a", "us-east-1b"}, // Only 2 AZs
 			"cluster_name":       "test-cluster",
@@ -73,50 +94,88 @@ func TestVPCModuleValidation(t *testing.T) {
 	}
 
 	// This should fail validation
-	_, err := terraform.InitAndPlanE(t, terraformOptions)
-	assert.Error(t, err, "Should fail validation with only 2 AZs")
+	assertValidationError(t, fixture, terraformOptions, "availability_zones")
 }
 
 func TestVPCOutputs(t *testing.T) {
 	t.Parallel()
 
+	fixture := harness.NewFixture("us-east-1")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/vpc",
 		Vars: map[string]interface{}{
-			"region":             "us-east-1",
+			"region":             fixture.Region,
 			"vpc_cidr":           "10.0.0.0/16",
 			"availability_zones": []string{"us-east-1a", "us-east-1b", "us-east-1c"},
 			"cluster_name":       "test-cluster",
 			"environment":        "test",
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-
-	// Verify that key outputs are planned
-	// Note: With PlanOnly, we can't get actual output values, but we can validate the plan
-	assert.NotNil(t, planStruct, "Plan should not be nil")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	// Output values that depend on resource IDs are unknown until apply, but every output must be planned
+	planassert.AssertOutputsPlanned(t, plan,
+		"vpc_id",
+		"vpc_cidr",
+		"private_subnet_ids",
+		"public_subnet_ids",
+		"database_subnet_ids",
+		"database_subnet_group_name",
+		"nat_gateway_ids",
+		"internet_gateway_id",
+	)
+	planassert.AssertOutputEquals(t, plan, "vpc_cidr", "10.0.0.0/16")
+	planassert.AssertOutputEquals(t, plan, "database_subnet_group_name", "test-cluster-db-subnet-group")
 }
 
 func TestVPCSubnetCIDRCalculation(t *testing.T) {
 	t.Parallel()
 
 	// Test that subnet CIDR calculations don't overlap
+	fixture := harness.NewFixture("us-west-2")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/vpc",
 		Vars: map[string]interface{}{
-			"region":             "us-west-2",
+			"region":             fixture.Region,
 			"vpc_cidr":           "10.1.0.0/16",
 			"availability_zones": []string{"us-west-2a", "us-west-2b", "us-west-2c"},
 			"cluster_name":       "test-cluster-2",
 			"environment":        "test",
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should succeed with different VPC CIDR")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	expected := map[string]string{
+		"aws_subnet.private[0]":  "10.1.0.0/19",
+		"aws_subnet.private[1]":  "10.1.32.0/19",
+		"aws_subnet.private[2]":  "10.1.64.0/19",
+		"aws_subnet.public[0]":   "10.1.96.0/20",
+		"aws_subnet.public[1]":   "10.1.112.0/20",
+		"aws_subnet.public[2]":   "10.1.128.0/20",
+		"aws_subnet.database[0]": "10.1.144.0/21",
+		"aws_subnet.database[1]": "10.1.152.0/21",
+		"aws_subnet.database[2]": "10.1.160.0/21",
+	}
+	for address, cidr := range expected {
+		planassert.AssertAttributeEquals(t, plan, address, "cidr_block", cidr)
+	}
+	planassert.AssertAttributeEquals(t, plan, "aws_subnet.private[1]", "availability_zone", "us-west-2b")
+
+	// The planned subnets must be disjoint, inside the VPC and sized as the cidrsubnet locals intend
+	allocations, err := vpclayout.ParseAllocations("../modules/vpc/main.tf")
+	require.NoError(t, err)
+	subnets, err := vpclayout.FromPlan(plan, "")
+	require.NoError(t, err)
+	require.Len(t, subnets, 9)
+
+	vpc := netip.MustParsePrefix("10.1.0.0/16")
+	report := vpclayout.Verify(vpc, subnets, vpclayout.ExpectedBits(vpc.Bits(), allocations))
+	assert.NoError(t, report.Err())
+	assert.Equal(t, uint64(22528), report.UnallocatedAddresses())
 }
 
 func TestVPCTags(t *testing.T) {
@@ -128,19 +187,29 @@ func TestVPCTags(t *testing.T) {
 		"CostCenter":  "engineering",
 	}
 
+	fixture := harness.NewFixture("us-east-1")
+
 	terraformOptions := terraform.WithDefaultRetryableErrors(t, &terraform.Options{
 		TerraformDir: "../modules/vpc",
 		Vars: map[string]interface{}{
-			"region":             "us-east-1",
+			"region":             fixture.Region,
 			"vpc_cidr":           "10.2.0.0/16",
 			"availability_zones": []string{"us-east-1a", "us-east-1b", "us-east-1c"},
 			"cluster_name":       "test-cluster-tags",
 			"environment":        "test",
 			"tags":               customTags,
 		},
-		PlanOnly: true,
 	})
 
-	planStruct := terraform.InitAndPlan(t, terraformOptions)
-	assert.NotNil(t, planStruct, "Plan should succeed with custom tags")
+	plan := initAndPlan(t, fixture, terraformOptions)
+
+	planassert.AssertAttributeEquals(t, plan, "aws_vpc.main", "tags", map[string]string{
+		"Environment": "production",
+		"Team":        "platform",
+		"CostCenter":  "engineering",
+		"Name":        "test-cluster-tags-vpc",
+		"kubernetes.io/cluster/test-cluster-tags": "shared",
+	})
+	planassert.AssertAllAttributesEqual(t, plan, "aws_cloudwatch_log_group.flow_logs", "tags", customTags)
+	planassert.AssertAllAttributesEqual(t, plan, "aws_subnet.database[*]", "tags.Team", "platform")
 }
@@ -0,0 +1,339 @@
+// Package vpclayout verifies the subnet layout of the vpc module: that the public, private and database subnets
+// carved out of var.vpc_cidr are disjoint, contained in the VPC, sized as the cidrsubnet locals intend, and how much
+// of the VPC they leave unallocated.
+package vpclayout
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// Tiers are the subnet tiers of the vpc module, in the order their locals are declared.
+var Tiers = []string{"private", "public", "database"}
+
+// MinSubnetPrefix and MaxSubnetPrefix bound the size of a subnet AWS will create.
+const (
+	MinSubnetPrefix = 16
+	MaxSubnetPrefix = 28
+)
+
+// reservedPerSubnet is the number of addresses AWS reserves in every subnet.
+const reservedPerSubnet = 5
+
+// Allocation is one cidrsubnet(var.vpc_cidr, NewBits, NetNum) call from the subnet CIDR locals.
+type Allocation struct {
+	Tier    string
+	Index   int
+	NewBits int
+	NetNum  int
+}
+
+// Subnet is a subnet CIDR of a tier, either computed from an Allocation or read from a plan.
+type Subnet struct {
+	Tier   string
+	Index  int
+	Prefix netip.Prefix
+}
+
+// String renders the subnet as e.g. private[0]=10.0.0.0/19.
+func (s Subnet) String() string {
+	return fmt.Sprintf("%s[%d]=%s", s.Tier, s.Index, s.Prefix)
+}
+
+// ParseAllocations reads the <tier>_subnet_cidrs locals of the vpc module's main.tf, so the verifier always checks the
+// layout the module actually declares.
+func ParseAllocations(path string) ([]Allocation, error) {
+	file, diags := hclparse.NewParser().ParseHCLFile(path)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("%s: unexpected body type %T", path, file.Body)
+	}
+
+	var allocations []Allocation
+	for _, block := range body.Blocks {
+		if block.Type != "locals" {
+			continue
+		}
+		for _, tier := range Tiers {
+			attribute, ok := block.Body.Attributes[tier+"_subnet_cidrs"]
+			if !ok {
+				continue
+			}
+			parsed, err := parseTier(tier, attribute)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			allocations = append(allocations, parsed...)
+		}
+	}
+	if len(allocations) == 0 {
+		return nil, fmt.Errorf("%s: no <tier>_subnet_cidrs locals found", path)
+	}
+	return allocations, nil
+}
+
+func parseTier(tier string, attribute *hclsyntax.Attribute) ([]Allocation, error) {
+	tuple, ok := attribute.Expr.(*hclsyntax.TupleConsExpr)
+	if !ok {
+		return nil, fmt.Errorf("local.%s is not a list literal", attribute.Name)
+	}
+	var allocations []Allocation
+	for i, expr := range tuple.Exprs {
+		call, ok := expr.(*hclsyntax.FunctionCallExpr)
+		if !ok || call.Name != "cidrsubnet" || len(call.Args) != 3 {
+			return nil, fmt.Errorf("local.%s[%d] is not a cidrsubnet(var.vpc_cidr, newbits, netnum) call", attribute.Name, i)
+		}
+		if !isVPCCIDR(call.Args[0]) {
+			return nil, fmt.Errorf("local.%s[%d] does not subdivide var.vpc_cidr", attribute.Name, i)
+		}
+		newBits, err := literalInt(call.Args[1])
+		if err != nil {
+			return nil, fmt.Errorf("local.%s[%d] newbits: %w", attribute.Name, i, err)
+		}
+		netNum, err := literalInt(call.Args[2])
+		if err != nil {
+			return nil, fmt.Errorf("local.%s[%d] netnum: %w", attribute.Name, i, err)
+		}
+		allocations = append(allocations, Allocation{Tier: tier, Index: i, NewBits: newBits, NetNum: netNum})
+	}
+	return allocations, nil
+}
+
+func isVPCCIDR(expr hclsyntax.Expression) bool {
+	traversal, ok := expr.(*hclsyntax.ScopeTraversalExpr)
+	if !ok || len(traversal.Traversal) != 2 {
+		return false
+	}
+	attr, ok := traversal.Traversal[1].(hcl.TraverseAttr)
+	return traversal.Traversal.RootName() == "var" && ok && attr.Name == "vpc_cidr"
+}
+
+func literalInt(expr hclsyntax.Expression) (int, error) {
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() {
+		return 0, fmt.Errorf("not a literal: %s", diags.Error())
+	}
+	if value.Type() != cty.Number {
+		return 0, fmt.Errorf("not a number")
+	}
+	n, accuracy := value.AsBigFloat().Int64()
+	if accuracy != big.Exact {
+		return 0, fmt.Errorf("not an integer")
+	}
+	return int(n), nil
+}
+
+// Cidrsubnet mirrors Terraform's cidrsubnet function for IPv4: it extends the prefix of base by newBits and returns the
+// netNum'th subnet. It fails, as Terraform does, when the extension does not fit in 32 bits or netNum does not fit in
+// newBits.
+func Cidrsubnet(base netip.Prefix, newBits, netNum int) (netip.Prefix, error) {
+	if !base.Addr().Is4() {
+		return netip.Prefix{}, fmt.Errorf("%s is not an IPv4 prefix", base)
+	}
+	bits := base.Bits() + newBits
+	if newBits < 0 || bits > 32 {
+		return netip.Prefix{}, fmt.Errorf("insufficient address space to extend prefix of %d by %d", base.Bits(), newBits)
+	}
+	if netNum < 0 || uint64(netNum) >= uint64(1)<<uint(newBits) {
+		return netip.Prefix{}, fmt.Errorf("prefix extension of %d does not accommodate a subnet numbered %d", newBits, netNum)
+	}
+	start := addrToUint(base.Masked().Addr()) + uint64(netNum)<<uint(32-bits)
+	return netip.PrefixFrom(uintToAddr(start), bits), nil
+}
+
+// Compute evaluates the allocations against a VPC CIDR. An allocation whose cidrsubnet call would fail is returned as
+// an error naming the offending local rather than stopping the whole computation.
+func Compute(vpc netip.Prefix, allocations []Allocation) ([]Subnet, []error) {
+	var (
+		subnets []Subnet
+		errs    []error
+	)
+	for _, allocation := range allocations {
+		prefix, err := Cidrsubnet(vpc, allocation.NewBits, allocation.NetNum)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cidrsubnet(%s, %d, %d) for %s[%d]: %w",
+				vpc, allocation.NewBits, allocation.NetNum, allocation.Tier, allocation.Index, err))
+			continue
+		}
+		subnets = append(subnets, Subnet{Tier: allocation.Tier, Index: allocation.Index, Prefix: prefix})
+	}
+	return subnets, errs
+}
+
+// ExpectedBits returns the prefix length each tier's subnets should have in a VPC with the given prefix length,
+// according to the newbits of the allocations.
+func ExpectedBits(vpcBits int, allocations []Allocation) map[string]int {
+	expected := map[string]int{}
+	for _, allocation := range allocations {
+		expected[allocation.Tier] = vpcBits + allocation.NewBits
+	}
+	return expected
+}
+
+// Report is the result of verifying a subnet layout.
+type Report struct {
+	VPC     netip.Prefix
+	Subnets []Subnet
+
+	// Problems lists every violated invariant in a human readable form; empty when the layout is valid.
+	Problems []string
+
+	// TotalAddresses is the size of the VPC, AllocatedAddresses the sum of the subnet sizes.
+	TotalAddresses     uint64
+	AllocatedAddresses uint64
+
+	// ReservedAddresses are the addresses AWS reserves in each subnet and are unusable for workloads.
+	ReservedAddresses uint64
+
+	// Unallocated are the blocks of the VPC not covered by any subnet, as minimal CIDRs in address order.
+	Unallocated []netip.Prefix
+}
+
+// UnallocatedAddresses returns the number of VPC addresses outside every subnet.
+func (r Report) UnallocatedAddresses() uint64 {
+	var n uint64
+	for _, prefix := range r.Unallocated {
+		n += size(prefix)
+	}
+	return n
+}
+
+// WastedFraction returns the fraction of the VPC that is unallocated or reserved by AWS.
+func (r Report) WastedFraction() float64 {
+	if r.TotalAddresses == 0 {
+		return 0
+	}
+	return float64(r.UnallocatedAddresses()+r.ReservedAddresses) / float64(r.TotalAddresses)
+}
+
+// Err returns the problems as a single error, or nil when there are none.
+func (r Report) Err() error {
+	if len(r.Problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("subnet layout of %s is invalid:\n  %s", r.VPC, strings.Join(r.Problems, "\n  "))
+}
+
+// Verify checks that the subnets are pairwise disjoint, contained in the VPC, within the sizes AWS allows, and have the
+// expected prefix length for their tier, and computes how much of the VPC they use. Tiers missing from expectedBits
+// are not size checked.
+func Verify(vpc netip.Prefix, subnets []Subnet, expectedBits map[string]int) Report {
+	report := Report{VPC: vpc, Subnets: subnets, TotalAddresses: size(vpc)}
+
+	if vpc.Masked() != vpc {
+		report.Problems = append(report.Problems, fmt.Sprintf("VPC CIDR %s has host bits set, the network is %s", vpc, vpc.Masked()))
+	}
+	vpc = vpc.Masked()
+
+	for i, subnet := range subnets {
+		if !contains(vpc, subnet.Prefix) {
+			report.Problems = append(report.Problems, fmt.Sprintf("%s is not contained in VPC %s", subnet, vpc))
+		}
+		if want, ok := expectedBits[subnet.Tier]; ok && subnet.Prefix.Bits() != want {
+			report.Problems = append(report.Problems, fmt.Sprintf("%s is a /%d, expected /%d", subnet, subnet.Prefix.Bits(), want))
+		}
+		if subnet.Prefix.Bits() < MinSubnetPrefix || subnet.Prefix.Bits() > MaxSubnetPrefix {
+			report.Problems = append(report.Problems, fmt.Sprintf("%s is outside the /%d to /%d range AWS allows for subnets",
+				subnet, MinSubnetPrefix, MaxSubnetPrefix))
+		}
+		for _, other := range subnets[i+1:] {
+			if subnet.Prefix.Overlaps(other.Prefix) {
+				report.Problems = append(report.Problems, fmt.Sprintf("%s overlaps %s", subnet, other))
+			}
+		}
+		report.AllocatedAddresses += size(subnet.Prefix)
+		report.ReservedAddresses += reservedPerSubnet
+	}
+
+	report.Unallocated = unallocated(vpc, subnets)
+	return report
+}
+
+// contains reports whether inner lies entirely within outer.
+func contains(outer, inner netip.Prefix) bool {
+	return inner.Bits() >= outer.Bits() && outer.Contains(inner.Addr())
+}
+
+func size(prefix netip.Prefix) uint64 {
+	return uint64(1) << uint(32-prefix.Bits())
+}
+
+// unallocated returns the parts of the VPC not covered by the subnets that lie inside it.
+func unallocated(vpc netip.Prefix, subnets []Subnet) []netip.Prefix {
+	type span struct{ start, end uint64 }
+	var used []span
+	for _, subnet := range subnets {
+		if !contains(vpc, subnet.Prefix) {
+			continue
+		}
+		start := addrToUint(subnet.Prefix.Masked().Addr())
+		used = append(used, span{start, start + size(subnet.Prefix)})
+	}
+	sort.Slice(used, func(i, j int) bool { return used[i].start < used[j].start })
+
+	var free []netip.Prefix
+	cursor := addrToUint(vpc.Addr())
+	end := cursor + size(vpc)
+	for _, s := range used {
+		if s.start > cursor {
+			free = append(free, rangeToPrefixes(cursor, s.start)...)
+		}
+		if s.end > cursor {
+			cursor = s.end
+		}
+	}
+	if cursor < end {
+		free = append(free, rangeToPrefixes(cursor, end)...)
+	}
+	return free
+}
+
+// rangeToPrefixes splits the address range [start, end) into the minimal list of aligned CIDR blocks.
+func rangeToPrefixes(start, end uint64) []netip.Prefix {
+	var out []netip.Prefix
+	for start < end {
+		bits := 32
+		for bits > 0 {
+			block := uint64(1) << uint(32-(bits-1))
+			if start%block != 0 || start+block > end {
+				break
+			}
+			bits--
+		}
+		out = append(out, netip.PrefixFrom(uintToAddr(start), bits))
+		start += uint64(1) << uint(32-bits)
+	}
+	return out
+}
+
+func addrToUint(addr netip.Addr) uint64 {
+	b := addr.As4()
+	return uint64(b[0])<<24 | uint64(b[1])<<16 | uint64(b[2])<<8 | uint64(b[3])
+}
+
+func uintToAddr(n uint64) netip.Addr {
+	return netip.AddrFrom4([4]byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)})
+}
+
+// Evaluate computes the layout the allocations produce in the VPC and verifies it. A cidrsubnet call that overflows
+// the VPC is reported as a problem, so a VPC CIDR too small for the module shows up in the report instead of as a
+// plan failure.
+func Evaluate(vpc netip.Prefix, allocations []Allocation) Report {
+	subnets, errs := Compute(vpc, allocations)
+	report := Verify(vpc, subnets, ExpectedBits(vpc.Bits(), allocations))
+	for _, err := range errs {
+		report.Problems = append(report.Problems, err.Error())
+	}
+	return report
+}
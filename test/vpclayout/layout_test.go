@@ -0,0 +1,164 @@
+package vpclayout
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const vpcModuleMain = "../../modules/vpc/main.tf"
+
+func TestParseAllocations(t *testing.T) {
+	t.Parallel()
+
+	allocations, err := ParseAllocations(vpcModuleMain)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Allocation{
+		{Tier: "private", Index: 0, NewBits: 3, NetNum: 0},
+		{Tier: "private", Index: 1, NewBits: 3, NetNum: 1},
+		{Tier: "private", Index: 2, NewBits: 3, NetNum: 2},
+		{Tier: "public", Index: 0, NewBits: 4, NetNum: 6},
+		{Tier: "public", Index: 1, NewBits: 4, NetNum: 7},
+		{Tier: "public", Index: 2, NewBits: 4, NetNum: 8},
+		{Tier: "database", Index: 0, NewBits: 5, NetNum: 18},
+		{Tier: "database", Index: 1, NewBits: 5, NetNum: 19},
+		{Tier: "database", Index: 2, NewBits: 5, NetNum: 20},
+	}, allocations)
+}
+
+func TestModuleLayoutAcrossVPCSizes(t *testing.T) {
+	t.Parallel()
+
+	allocations, err := ParseAllocations(vpcModuleMain)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		vpcCIDR string
+
+		// Expected prefix lengths of the private, public and database subnets
+		private, public, database int
+
+		// Last database subnet, produced by cidrsubnet(var.vpc_cidr, 5, 20)
+		lastDatabase string
+	}{
+		{"10.0.0.0/16", 19, 20, 21, "10.0.160.0/21"},
+		{"10.1.0.0/16", 19, 20, 21, "10.1.160.0/21"},
+		{"172.16.0.0/16", 19, 20, 21, "172.16.160.0/21"},
+		{"10.0.0.0/17", 20, 21, 22, "10.0.80.0/22"},
+		{"192.168.128.0/17", 20, 21, 22, "192.168.208.0/22"},
+		{"10.0.0.0/18", 21, 22, 23, "10.0.40.0/23"},
+		{"10.20.64.0/18", 21, 22, 23, "10.20.104.0/23"},
+		{"10.0.0.0/19", 22, 23, 24, "10.0.20.0/24"},
+		{"10.0.0.0/20", 23, 24, 25, "10.0.10.0/25"},
+		{"100.64.16.0/20", 23, 24, 25, "100.64.26.0/25"},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.vpcCIDR, func(t *testing.T) {
+			t.Parallel()
+
+			vpc := netip.MustParsePrefix(testCase.vpcCIDR)
+			report := Evaluate(vpc, allocations)
+			require.NoError(t, report.Err())
+			require.Len(t, report.Subnets, 9)
+
+			for _, subnet := range report.Subnets {
+				want := map[string]int{"private": testCase.private, "public": testCase.public, "database": testCase.database}[subnet.Tier]
+				assert.Equal(t, want, subnet.Prefix.Bits(), subnet.String())
+			}
+			assert.Equal(t, testCase.lastDatabase, report.Subnets[8].Prefix.String())
+
+			// 3/8 private + 3/16 public + 3/32 database leaves 11/32 of every VPC unallocated
+			assert.Equal(t, report.TotalAddresses*11/32, report.UnallocatedAddresses())
+			assert.Equal(t, report.TotalAddresses-report.AllocatedAddresses, report.UnallocatedAddresses())
+			assert.Equal(t, uint64(45), report.ReservedAddresses)
+		})
+	}
+}
+
+func TestModuleLayoutUnallocatedBlocks(t *testing.T) {
+	t.Parallel()
+
+	allocations, err := ParseAllocations(vpcModuleMain)
+	require.NoError(t, err)
+
+	report := Evaluate(netip.MustParsePrefix("10.0.0.0/16"), allocations)
+	require.NoError(t, report.Err())
+
+	assert.Equal(t, []netip.Prefix{
+		netip.MustParsePrefix("10.0.168.0/21"),
+		netip.MustParsePrefix("10.0.176.0/20"),
+		netip.MustParsePrefix("10.0.192.0/18"),
+	}, report.Unallocated)
+	assert.InDelta(t, (22528.0+45)/65536, report.WastedFraction(), 1e-9)
+}
+
+func TestModuleLayoutOverflow(t *testing.T) {
+	t.Parallel()
+
+	allocations, err := ParseAllocations(vpcModuleMain)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		vpcCIDR string
+		problem string
+	}{
+		// The /21 database extension of a /28 needs 33 bits, so cidrsubnet(var.vpc_cidr, 5, 20) fails
+		{"10.0.0.0/28", "cidrsubnet(10.0.0.0/28, 5, 20) for database[2]: insufficient address space to extend prefix of 28 by 5"},
+		// The math fits in 32 bits but produces /32 database subnets that AWS cannot create
+		{"10.0.0.0/27", "database[2]=10.0.0.20/32 is outside the /16 to /28 range AWS allows for subnets"},
+		{"10.0.0.0/24", "database[2]=10.0.0.160/29 is outside the /16 to /28 range AWS allows for subnets"},
+		// Terraform masks host bits, so the subnets land in 10.0.0.0/16 rather than around 10.0.1.0
+		{"10.0.1.0/16", "VPC CIDR 10.0.1.0/16 has host bits set, the network is 10.0.0.0/16"},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.vpcCIDR, func(t *testing.T) {
+			t.Parallel()
+
+			report := Evaluate(netip.MustParsePrefix(testCase.vpcCIDR), allocations)
+			assert.Contains(t, report.Problems, testCase.problem)
+		})
+	}
+}
+
+func TestCidrsubnet(t *testing.T) {
+	t.Parallel()
+
+	base := netip.MustParsePrefix("10.0.0.0/16")
+
+	prefix, err := Cidrsubnet(base, 4, 15)
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.240.0/20", prefix.String())
+
+	_, err = Cidrsubnet(base, 4, 16)
+	assert.EqualError(t, err, "prefix extension of 4 does not accommodate a subnet numbered 16")
+
+	_, err = Cidrsubnet(base, 17, 0)
+	assert.EqualError(t, err, "insufficient address space to extend prefix of 16 by 17")
+}
+
+func TestVerifyDetectsOverlapsAndEscapes(t *testing.T) {
+	t.Parallel()
+
+	vpc := netip.MustParsePrefix("10.0.0.0/16")
+	subnets := []Subnet{
+		{Tier: "private", Index: 0, Prefix: netip.MustParsePrefix("10.0.0.0/19")},
+		{Tier: "private", Index: 1, Prefix: netip.MustParsePrefix("10.0.16.0/20")},
+		{Tier: "public", Index: 0, Prefix: netip.MustParsePrefix("10.1.0.0/20")},
+	}
+
+	report := Verify(vpc, subnets, map[string]int{"private": 19, "public": 20})
+
+	assert.Equal(t, []string{
+		"private[0]=10.0.0.0/19 overlaps private[1]=10.0.16.0/20",
+		"private[1]=10.0.16.0/20 is a /20, expected /19",
+		"public[0]=10.1.0.0/20 is not contained in VPC 10.0.0.0/16",
+	}, report.Problems)
+	assert.Error(t, report.Err())
+}
@@ -0,0 +1,36 @@
+package vpclayout
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/your-org/multi-az-eks-cluster/test/planassert"
+)
+
+// FromPlan reads the planned cidr_block of every aws_subnet.<tier>[*] instance of the vpc module. modulePrefix is the
+// address of the vpc module instance, e.g. "module.vpc.", or empty when the module is planned directly.
+func FromPlan(plan *planassert.Plan, modulePrefix string) ([]Subnet, error) {
+	var subnets []Subnet
+	for _, tier := range Tiers {
+		for _, resource := range plan.Match(modulePrefix + "aws_subnet." + tier + "[*]") {
+			index, ok := resource.Index.(int)
+			if !ok {
+				return nil, fmt.Errorf("%s: expected a count index", resource.Address)
+			}
+			value, ok := resource.Attribute("cidr_block")
+			if !ok {
+				return nil, fmt.Errorf("%s: cidr_block is not known at plan time", resource.Address)
+			}
+			cidr, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: cidr_block is a %T, not a string", resource.Address, value)
+			}
+			prefix, err := netip.ParsePrefix(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", resource.Address, err)
+			}
+			subnets = append(subnets, Subnet{Tier: tier, Index: index, Prefix: prefix})
+		}
+	}
+	return subnets, nil
+}